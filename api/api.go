@@ -6,6 +6,7 @@ import (
 	"github.com/gin-gonic/gin"
 	notificationProto "github.com/iwhitebird/social-app-microservices/proto/generated/notification/proto"
 	postProto "github.com/iwhitebird/social-app-microservices/proto/generated/post/proto"
+	userProto "github.com/iwhitebird/social-app-microservices/proto/generated/user/proto"
 )
 
 type HttpApi struct {
@@ -13,18 +14,24 @@ type HttpApi struct {
 	port               string
 	notificationClient notificationProto.NotificationServiceClient
 	postClient         postProto.PostServiceClient
+	userClient         userProto.UserServiceClient
+	routes             []documentedRoute
 }
 
-func NewHttpApi(notificationClient notificationProto.NotificationServiceClient, postClient postProto.PostServiceClient, port string) *HttpApi {
+func NewHttpApi(notificationClient notificationProto.NotificationServiceClient, postClient postProto.PostServiceClient, userClient userProto.UserServiceClient, port string) *HttpApi {
 	gin.SetMode(gin.ReleaseMode)
 	server := &HttpApi{
-		engine:             gin.Default(),
+		engine:             gin.New(),
 		port:               port,
 		notificationClient: notificationClient,
 		postClient:         postClient,
+		userClient:         userClient,
 	}
-	server.engine.Use(gin.Recovery())
 	server.engine.Use(gin.Logger())
+	server.engine.Use(gin.CustomRecovery(recovered))
+	server.engine.HandleMethodNotAllowed = true
+	server.engine.NoRoute(notFound)
+	server.engine.NoMethod(methodNotAllowed)
 	server.setupRoutes()
 
 	return server
@@ -33,6 +40,16 @@ func NewHttpApi(notificationClient notificationProto.NotificationServiceClient,
 func (s *HttpApi) setupRoutes() {
 	api := s.engine.Group("/api")
 	s.RegisterMetricRoutes(api)
+	s.RegisterPostRoutes(api)
+	s.RegisterUserRoutes(api)
+	s.RegisterNotificationRoutes(api)
+	s.RegisterPreferenceRoutes(api)
+	s.RegisterNotificationJobRoutes(api)
+	s.RegisterDeadLetterRoutes(api)
+	s.RegisterWorkerPoolRoutes(api)
+
+	api.GET("/openapi.json", s.GetOpenAPI)
+	api.GET("/docs", s.GetDocs)
 }
 
 func (s *HttpApi) Start() error {
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	notificationProto "github.com/iwhitebird/social-app-microservices/proto/generated/notification/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func (s *HttpApi) RegisterDeadLetterRoutes(v1 *gin.RouterGroup) {
+	deadLetters := v1.Group("/admin/dead-letters")
+	{
+		s.handle(deadLetters, route{
+			Method:   http.MethodGet,
+			Summary:  "List the notifications the queue gave up on, oldest first",
+			Query:    pageQuery,
+			Response: DeadLetterPage{},
+		}, s.ListDeadLetters)
+		s.handle(deadLetters, route{
+			Method:   http.MethodGet,
+			Path:     "/:notification_id",
+			Summary:  "Get a dead-lettered notification with its delivery attempts",
+			Response: DeadLetter{},
+		}, s.GetDeadLetter)
+		s.handle(deadLetters, route{
+			Method:   http.MethodPost,
+			Path:     "/:notification_id/replay",
+			Summary:  "Put a dead-lettered notification back into the queue",
+			Response: ReplayResult{},
+		}, s.ReplayDeadLetter)
+		s.handle(deadLetters, route{
+			Method:   http.MethodPost,
+			Path:     "/replay",
+			Summary:  "Put every dead-lettered notification back into the queue",
+			Response: ReplayResult{},
+		}, s.ReplayDeadLetters)
+		s.handle(deadLetters, route{
+			Method:   http.MethodDelete,
+			Summary:  "Delete dead letters, optionally only those that failed up to a timestamp",
+			Query:    []queryParam{{Name: "before", Type: "integer", Description: "Only dead letters that failed at or before this Unix timestamp"}},
+			Response: PurgeResult{},
+		}, s.PurgeDeadLetters)
+	}
+}
+
+// ListDeadLetters returns a page of dead letters. It takes the page_size and
+// cursor query parameters.
+func (s *HttpApi) ListDeadLetters(c *gin.Context) {
+	pageSize, err := queryInt32(c, "page_size")
+	if err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	page, err := s.notificationClient.ListDeadLetters(c, &notificationProto.ListDeadLettersRequest{
+		PageSize: pageSize,
+		Cursor:   c.Query("cursor"),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, toDeadLetterPage(page))
+}
+
+func (s *HttpApi) GetDeadLetter(c *gin.Context) {
+	deadLetter, err := s.notificationClient.GetDeadLetter(c, &notificationProto.NotificationId{NotificationId: c.Param("notification_id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, toDeadLetter(deadLetter))
+}
+
+func (s *HttpApi) ReplayDeadLetter(c *gin.Context) {
+	response, err := s.notificationClient.ReplayDeadLetter(c, &notificationProto.NotificationId{NotificationId: c.Param("notification_id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, ReplayResult{Replayed: response.Replayed})
+}
+
+func (s *HttpApi) ReplayDeadLetters(c *gin.Context) {
+	response, err := s.notificationClient.ReplayDeadLetters(c, &emptypb.Empty{})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, ReplayResult{Replayed: response.Replayed})
+}
+
+// PurgeDeadLetters deletes the dead letters that failed up to the optional
+// "before" Unix timestamp, or all of them when it is missing
+func (s *HttpApi) PurgeDeadLetters(c *gin.Context) {
+	before, err := queryInt64(c, "before")
+	if err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	response, err := s.notificationClient.PurgeDeadLetters(c, &notificationProto.PurgeDeadLettersRequest{Before: before})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, PurgeResult{Purged: response.Purged})
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// httpStatusFromCode maps a gRPC status code to the closest HTTP status
+func httpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.Canceled:
+		return 499 // client closed request
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// errorCode turns a gRPC status code into the SCREAMING_SNAKE_CASE code
+// reported in error responses, e.g. InvalidArgument becomes INVALID_ARGUMENT
+func errorCode(code codes.Code) string {
+	var b strings.Builder
+	previous := ' '
+	for _, r := range code.String() {
+		// Only a new word starts a new part, so OK stays OK
+		if unicode.IsUpper(r) && unicode.IsLower(previous) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+		previous = r
+	}
+	return b.String()
+}
+
+// errorBody is the JSON envelope of every error response
+func errorBody(code codes.Code, message string) ErrorResponse {
+	return ErrorResponse{
+		Status:  "error",
+		Code:    errorCode(code),
+		Message: message,
+	}
+}
+
+// respondError writes a gRPC error as a JSON error response
+func respondError(c *gin.Context, err error) {
+	st := status.Convert(err)
+	c.AbortWithStatusJSON(httpStatusFromCode(st.Code()), errorBody(st.Code(), st.Message()))
+}
+
+// respondBadRequest writes a JSON error response for an invalid request body
+func respondBadRequest(c *gin.Context, err error) {
+	c.AbortWithStatusJSON(http.StatusBadRequest, errorBody(codes.InvalidArgument, err.Error()))
+}
+
+// notFound answers requests for routes that do not exist
+func notFound(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusNotFound, errorBody(codes.NotFound, "no route for "+c.Request.Method+" "+c.Request.URL.Path))
+}
+
+// methodNotAllowed answers requests whose path exists for other methods only
+func methodNotAllowed(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusMethodNotAllowed, errorBody(codes.Unimplemented, c.Request.Method+" is not allowed on "+c.Request.URL.Path))
+}
+
+// recovered turns a panic in a handler into a JSON error response
+func recovered(c *gin.Context, err any) {
+	log.Printf("Recovered from panic in %s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+	c.AbortWithStatusJSON(http.StatusInternalServerError, errorBody(codes.Internal, "internal server error"))
+}
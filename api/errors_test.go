@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	userProto "github.com/iwhitebird/social-app-microservices/proto/generated/user/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeUserClient fails every GetUser call with err, or panics without one
+type fakeUserClient struct {
+	userProto.UserServiceClient
+	err error
+}
+
+func (c *fakeUserClient) GetUser(ctx context.Context, in *userProto.UserId, opts ...grpc.CallOption) (*userProto.User, error) {
+	if c.err == nil {
+		panic("no error to return")
+	}
+	return nil, c.err
+}
+
+// serve sends a request to a server backed by client and decodes the error
+// envelope of the response
+func serve(t *testing.T, client userProto.UserServiceClient, method, target, body string) (int, ErrorResponse) {
+	t.Helper()
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	NewHttpApi(nil, nil, client, "").engine.ServeHTTP(recorder, request)
+
+	var envelope ErrorResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &envelope), recorder.Body.String())
+	return recorder.Code, envelope
+}
+
+func TestHttpStatusFromCode(t *testing.T) {
+	for code, want := range map[codes.Code]int{
+		codes.OK:                 http.StatusOK,
+		codes.InvalidArgument:    http.StatusBadRequest,
+		codes.OutOfRange:         http.StatusBadRequest,
+		codes.NotFound:           http.StatusNotFound,
+		codes.AlreadyExists:      http.StatusConflict,
+		codes.Aborted:            http.StatusConflict,
+		codes.FailedPrecondition: http.StatusPreconditionFailed,
+		codes.PermissionDenied:   http.StatusForbidden,
+		codes.Unauthenticated:    http.StatusUnauthorized,
+		codes.ResourceExhausted:  http.StatusTooManyRequests,
+		codes.Unimplemented:      http.StatusNotImplemented,
+		codes.Unavailable:        http.StatusServiceUnavailable,
+		codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+		codes.Canceled:           499,
+		codes.Unknown:            http.StatusInternalServerError,
+		codes.Internal:           http.StatusInternalServerError,
+		codes.DataLoss:           http.StatusInternalServerError,
+	} {
+		assert.Equal(t, want, httpStatusFromCode(code), code.String())
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	assert.Equal(t, "OK", errorCode(codes.OK))
+	assert.Equal(t, "NOT_FOUND", errorCode(codes.NotFound))
+	assert.Equal(t, "INVALID_ARGUMENT", errorCode(codes.InvalidArgument))
+	assert.Equal(t, "RESOURCE_EXHAUSTED", errorCode(codes.ResourceExhausted))
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	// gRPC errors keep their code and message
+	code, envelope := serve(t, &fakeUserClient{err: status.Error(codes.NotFound, "user u1 not found")}, http.MethodGet, "/api/users/u1", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, ErrorResponse{Status: "error", Code: "NOT_FOUND", Message: "user u1 not found"}, envelope)
+
+	code, envelope = serve(t, &fakeUserClient{err: status.Error(codes.Unavailable, "connection refused")}, http.MethodGet, "/api/users/u1", "")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "UNAVAILABLE", envelope.Code)
+
+	// Errors without a status are unknown
+	code, envelope = serve(t, &fakeUserClient{err: errors.New("boom")}, http.MethodGet, "/api/users/u1", "")
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.Equal(t, ErrorResponse{Status: "error", Code: "UNKNOWN", Message: "boom"}, envelope)
+
+	// Bodies that do not decode never reach the backend
+	code, envelope = serve(t, nil, http.MethodPost, "/api/users", "{")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "INVALID_ARGUMENT", envelope.Code)
+	assert.NotEmpty(t, envelope.Message)
+
+	// Neither do requests for routes that do not exist
+	code, envelope = serve(t, nil, http.MethodGet, "/api/nothing", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, ErrorResponse{Status: "error", Code: "NOT_FOUND", Message: "no route for GET /api/nothing"}, envelope)
+
+	code, envelope = serve(t, nil, http.MethodPost, "/api/users/u1", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+	assert.Equal(t, "UNIMPLEMENTED", envelope.Code)
+
+	// A handler that panics does not leak the panic
+	code, envelope = serve(t, &fakeUserClient{}, http.MethodGet, "/api/users/u1", "")
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.Equal(t, ErrorResponse{Status: "error", Code: "INTERNAL", Message: "internal server error"}, envelope)
+}
@@ -10,28 +10,36 @@ import (
 func (s *HttpApi) RegisterMetricRoutes(v1 *gin.RouterGroup) {
 	metrics := v1.Group("/metrics")
 	{
-		metrics.GET("", s.GetMetrics)
+		s.handle(metrics, route{
+			Method:   http.MethodGet,
+			Summary:  "Get notification metrics",
+			Response: Metrics{},
+		}, s.GetMetrics)
 	}
 }
 
 func (s *HttpApi) GetMetrics(c *gin.Context) {
 	notificationMetrics, err := s.notificationClient.GetNotificationMetrics(c, &emptypb.Empty{})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  "error",
-			"message": "Failed to get notification metrics",
-		})
+		respondError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"status": "success",
-		"data": gin.H{
-			"store_metrics": gin.H{
-				"total_notifications_sent": notificationMetrics.TotalNotificationsSent,
-				"failed_attempts":          notificationMetrics.FailedAttempts,
-				"average_delivery_time":    notificationMetrics.AverageDeliveryTime,
-			},
-			"system_status": "healthy",
+	lanes := make([]QueueLane, 0, len(notificationMetrics.Lanes))
+	for _, lane := range notificationMetrics.Lanes {
+		lanes = append(lanes, QueueLane{
+			Priority:     lane.Priority,
+			Depth:        lane.Depth,
+			OldestWaitMs: lane.OldestWaitMs,
+			Spilled:      lane.Spilled,
+		})
+	}
+	respond(c, http.StatusOK, Metrics{
+		StoreMetrics: StoreMetrics{
+			TotalNotificationsSent: notificationMetrics.TotalNotificationsSent,
+			FailedAttempts:         notificationMetrics.FailedAttempts,
+			AverageDeliveryTime:    notificationMetrics.AverageDeliveryTime,
 		},
+		Lanes:        lanes,
+		SystemStatus: "healthy",
 	})
 }
@@ -0,0 +1,301 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	notificationProto "github.com/iwhitebird/social-app-microservices/proto/generated/notification/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// sseHeartbeatInterval is how often an idle event stream gets a comment
+// line, so proxies do not time it out. Tests shorten it.
+var sseHeartbeatInterval = 15 * time.Second
+
+const (
+	// sseRetry tells EventSource clients how long to wait before reconnecting
+	sseRetry = 3 * time.Second
+
+	defaultNotificationsPageSize = 20
+	maxNotificationsPageSize     = 100
+)
+
+func (s *HttpApi) RegisterNotificationRoutes(v1 *gin.RouterGroup) {
+	notifications := v1.Group("/users/:id/notifications")
+	{
+		s.handle(notifications, route{
+			Method:  http.MethodGet,
+			Summary: "List the notifications of a user, newest first",
+			Query: []queryParam{
+				pageSizeQuery,
+				cursorQuery,
+				{Name: "unread_only", Type: "boolean", Description: "Skip notifications that have been read"},
+				{Name: "since", Type: "integer", Description: "Only notifications created at or after this Unix timestamp"},
+				{Name: "until", Type: "integer", Description: "Only notifications created before this Unix timestamp"},
+			},
+			Response: NotificationPage{},
+		}, s.ListNotifications)
+		s.handle(notifications, route{
+			Method:   http.MethodGet,
+			Path:     "/unread-count",
+			Summary:  "Count the unread notifications of a user",
+			Response: UnreadCount{},
+		}, s.GetUnreadCount)
+		s.handle(notifications, route{
+			Method:   http.MethodGet,
+			Path:     "/stream",
+			Summary:  "Receive new notifications as Server-Sent Events",
+			Query:    []queryParam{{Name: "last_event_id", Type: "string", Description: "Resume after this event, for clients that cannot set Last-Event-ID"}},
+			Response: Notification{},
+			Stream:   true,
+		}, s.StreamNotifications)
+		s.handle(notifications, route{
+			Method:   http.MethodPost,
+			Path:     "/read",
+			Summary:  "Mark several notifications as read",
+			Request:  MarkNotificationsReadRequest{},
+			Response: MarkReadResult{},
+		}, s.MarkNotificationsRead)
+		s.handle(notifications, route{
+			Method:          http.MethodPost,
+			Path:            "/read-all",
+			Summary:         "Mark all notifications as read, optionally only those up to a timestamp",
+			Request:         MarkAllReadRequest{},
+			OptionalRequest: true,
+			Response:        MarkReadResult{},
+		}, s.MarkAllRead)
+		s.handle(notifications, route{
+			Method:   http.MethodPost,
+			Path:     "/:notification_id/read",
+			Summary:  "Mark a notification as read",
+			Response: MarkReadResult{},
+		}, s.MarkNotificationRead)
+	}
+}
+
+// ListNotifications returns a page of notifications, newest first. It takes
+// the page_size, cursor, unread_only, since and until query parameters.
+func (s *HttpApi) ListNotifications(c *gin.Context) {
+	request, err := listNotificationsRequest(c)
+	if err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+	pageSize := request.PageSize
+	// Ask for one extra notification to find out whether there is another page
+	request.PageSize++
+
+	stream, err := s.notificationClient.GetNotifications(c, request)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	notifications := make([]Notification, 0, pageSize)
+	lastCursor, nextCursor := "", ""
+	for {
+		notification, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		if int32(len(notifications)) == pageSize {
+			nextCursor = lastCursor
+			break
+		}
+		notifications = append(notifications, toNotification(notification))
+		lastCursor = notification.Cursor
+	}
+
+	respond(c, http.StatusOK, NotificationPage{
+		Notifications: notifications,
+		NextCursor:    nextCursor,
+	})
+}
+
+func (s *HttpApi) GetUnreadCount(c *gin.Context) {
+	unread, err := s.notificationClient.GetUnreadCount(c, &notificationProto.UserId{UserId: c.Param("id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, UnreadCount{UnreadCount: unread.Count})
+}
+
+func (s *HttpApi) MarkNotificationRead(c *gin.Context) {
+	response, err := s.notificationClient.MarkNotificationRead(c, &notificationProto.MarkNotificationReadRequest{
+		UserId:         c.Param("id"),
+		NotificationId: c.Param("notification_id"),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, toMarkReadResult(response))
+}
+
+func (s *HttpApi) MarkNotificationsRead(c *gin.Context) {
+	var body MarkNotificationsReadRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	response, err := s.notificationClient.MarkNotificationsRead(c, &notificationProto.MarkNotificationsReadRequest{
+		UserId:          c.Param("id"),
+		NotificationIds: body.NotificationIDs,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, toMarkReadResult(response))
+}
+
+// MarkAllRead marks every notification up to the optional "before" Unix
+// timestamp in the body as read, or all of them when it is missing
+func (s *HttpApi) MarkAllRead(c *gin.Context) {
+	var body MarkAllReadRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			respondBadRequest(c, err)
+			return
+		}
+	}
+
+	response, err := s.notificationClient.MarkAllRead(c, &notificationProto.MarkAllReadRequest{
+		UserId: c.Param("id"),
+		Before: body.Before,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, toMarkReadResult(response))
+}
+
+// StreamNotifications pushes new notifications as Server-Sent Events. Each
+// event carries the notification cursor as its ID, so a reconnecting
+// EventSource resumes through the Last-Event-ID header. Clients that cannot
+// set headers may pass the last_event_id query parameter instead.
+func (s *HttpApi) StreamNotifications(c *gin.Context) {
+	cursor := c.GetHeader("Last-Event-ID")
+	if cursor == "" {
+		cursor = c.Query("last_event_id")
+	}
+
+	stream, err := s.notificationClient.SubscribeNotifications(c.Request.Context(), &notificationProto.SubscribeNotificationsRequest{
+		UserId: c.Param("id"),
+		Cursor: cursor,
+	})
+	if err == nil {
+		// The service sends its headers once the subscription is in place.
+		// A call that fails right away ends without headers, and its status
+		// comes from Recv.
+		var header metadata.MD
+		if header, err = stream.Header(); err == nil && header == nil {
+			_, err = stream.Recv()
+		}
+	}
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	received := make(chan *notificationProto.Notification)
+	streamErr := make(chan error, 1)
+	go func() {
+		for {
+			notification, err := stream.Recv()
+			if err != nil {
+				streamErr <- err
+				return
+			}
+			select {
+			case received <- notification:
+			case <-c.Request.Context().Done():
+				return
+			}
+		}
+	}()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	fmt.Fprintf(c.Writer, "retry: %d\n\n", sseRetry.Milliseconds())
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": heartbeat\n\n")
+		case notification := <-received:
+			c.Render(-1, sse.Event{
+				Id:    notification.Cursor,
+				Event: "notification",
+				Data:  toNotification(notification),
+			})
+		case err := <-streamErr:
+			if !errors.Is(err, io.EOF) && status.Code(err) != codes.Canceled {
+				log.Printf("Notification stream for user %s ended: %v", c.Param("id"), err)
+				st := status.Convert(err)
+				c.Render(-1, sse.Event{
+					Event: "error",
+					Data:  errorBody(st.Code(), st.Message()),
+				})
+			}
+			c.Writer.Flush()
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
+func listNotificationsRequest(c *gin.Context) (*notificationProto.GetNotificationsRequest, error) {
+	pageSize, err := queryInt32(c, "page_size")
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case pageSize < 0:
+		return nil, errors.New("page_size must not be negative")
+	case pageSize == 0:
+		pageSize = defaultNotificationsPageSize
+	case pageSize > maxNotificationsPageSize:
+		pageSize = maxNotificationsPageSize
+	}
+
+	request := &notificationProto.GetNotificationsRequest{
+		UserId:   c.Param("id"),
+		PageSize: pageSize,
+		Cursor:   c.Query("cursor"),
+	}
+	if request.UnreadOnly, err = queryBool(c, "unread_only"); err != nil {
+		return nil, err
+	}
+	if request.Since, err = queryInt64(c, "since"); err != nil {
+		return nil, err
+	}
+	if request.Until, err = queryInt64(c, "until"); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	notificationProto "github.com/iwhitebird/social-app-microservices/proto/generated/notification/proto"
+)
+
+func (s *HttpApi) RegisterNotificationJobRoutes(v1 *gin.RouterGroup) {
+	jobs := v1.Group("/admin/notification-jobs")
+	{
+		s.handle(jobs, route{
+			Method:   http.MethodGet,
+			Path:     "/:notification_id",
+			Summary:  "Get a notification that is still in the queue with its delivery attempts so far",
+			Response: NotificationJob{},
+		}, s.GetNotificationJob)
+	}
+}
+
+// GetNotificationJob responds with 404 once the queue delivered or
+// dead-lettered the notification
+func (s *HttpApi) GetNotificationJob(c *gin.Context) {
+	job, err := s.notificationClient.GetNotificationJob(c, &notificationProto.NotificationId{NotificationId: c.Param("notification_id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, toNotificationJob(job))
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	notificationProto "github.com/iwhitebird/social-app-microservices/proto/generated/notification/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeNotificationStream hands out the notifications sent to it, then err
+// once the channel is closed
+type fakeNotificationStream struct {
+	grpc.ClientStream
+	ctx           context.Context
+	header        metadata.MD
+	notifications chan *notificationProto.Notification
+	err           error
+}
+
+func (s *fakeNotificationStream) Header() (metadata.MD, error) {
+	return s.header, nil
+}
+
+func (s *fakeNotificationStream) Recv() (*notificationProto.Notification, error) {
+	select {
+	case notification, ok := <-s.notifications:
+		if !ok {
+			return nil, s.err
+		}
+		return notification, nil
+	case <-s.ctx.Done():
+		return nil, status.FromContextError(s.ctx.Err()).Err()
+	}
+}
+
+// fakeNotificationClient subscribes every caller to the same stream and
+// records the subscriptions
+type fakeNotificationClient struct {
+	notificationProto.NotificationServiceClient
+	notifications chan *notificationProto.Notification
+	// err ends the stream once notifications is closed. Without header the
+	// subscription fails with it right away.
+	err        error
+	header     metadata.MD
+	subscribed chan *notificationProto.SubscribeNotificationsRequest
+}
+
+func newFakeNotificationClient() *fakeNotificationClient {
+	return &fakeNotificationClient{
+		notifications: make(chan *notificationProto.Notification),
+		err:           io.EOF,
+		header:        metadata.MD{},
+		subscribed:    make(chan *notificationProto.SubscribeNotificationsRequest, 1),
+	}
+}
+
+func (c *fakeNotificationClient) SubscribeNotifications(ctx context.Context, in *notificationProto.SubscribeNotificationsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[notificationProto.Notification], error) {
+	c.subscribed <- in
+	return &fakeNotificationStream{ctx: ctx, header: c.header, notifications: c.notifications, err: c.err}, nil
+}
+
+// openStream starts streaming the notifications of u1 from a real server,
+// so the response is read as it is flushed
+func openStream(t *testing.T, client *fakeNotificationClient, target string, header http.Header) (*http.Response, *bufio.Reader) {
+	t.Helper()
+	server := httptest.NewServer(NewHttpApi(client, nil, nil, "").engine)
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+target, nil)
+	require.NoError(t, err)
+	for name, values := range header {
+		request.Header[name] = values
+	}
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	t.Cleanup(func() { response.Body.Close() })
+	return response, bufio.NewReader(response.Body)
+}
+
+// readEvent returns the lines of the next event, up to the blank line that
+// ends it
+func readEvent(t *testing.T, body *bufio.Reader) []string {
+	t.Helper()
+	var lines []string
+	for {
+		line, err := body.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return lines
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestStreamNotifications(t *testing.T) {
+	client := newFakeNotificationClient()
+	response, body := openStream(t, client, "/api/users/u1/notifications/stream", http.Header{"Last-Event-ID": {"c1"}})
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+	assert.Equal(t, "no-cache", response.Header.Get("Cache-Control"))
+
+	// The stream resumes after the last event the client saw
+	subscribed := <-client.subscribed
+	assert.Equal(t, "u1", subscribed.UserId)
+	assert.Equal(t, "c1", subscribed.Cursor)
+
+	// and tells EventSource how long to wait before reconnecting
+	assert.Equal(t, []string{"retry: 3000"}, readEvent(t, body))
+
+	client.notifications <- &notificationProto.Notification{Id: "n2", UserId: "u1", Content: "hello", Cursor: "c2"}
+	event := readEvent(t, body)
+	require.Len(t, event, 3)
+	assert.Equal(t, "id:c2", event[0])
+	assert.Equal(t, "event:notification", event[1])
+	var notification Notification
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(event[2], "data:")), &notification))
+	assert.Equal(t, "n2", notification.ID)
+	assert.Equal(t, "hello", notification.Content)
+	assert.Equal(t, "c2", notification.Cursor)
+}
+
+func TestStreamNotificationsResumesFromQuery(t *testing.T) {
+	client := newFakeNotificationClient()
+	response, _ := openStream(t, client, "/api/users/u1/notifications/stream?last_event_id=c7", nil)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "c7", (<-client.subscribed).Cursor)
+
+	// The header wins over the query parameter
+	client = newFakeNotificationClient()
+	response, _ = openStream(t, client, "/api/users/u1/notifications/stream?last_event_id=c7", http.Header{"Last-Event-ID": {"c8"}})
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "c8", (<-client.subscribed).Cursor)
+}
+
+func TestStreamNotificationsHeartbeat(t *testing.T) {
+	interval := sseHeartbeatInterval
+	sseHeartbeatInterval = 10 * time.Millisecond
+	t.Cleanup(func() { sseHeartbeatInterval = interval })
+
+	client := newFakeNotificationClient()
+	_, body := openStream(t, client, "/api/users/u1/notifications/stream", nil)
+	readEvent(t, body)
+
+	// An idle stream gets comment lines, which EventSource ignores
+	assert.Equal(t, []string{": heartbeat"}, readEvent(t, body))
+	assert.Equal(t, []string{": heartbeat"}, readEvent(t, body))
+}
+
+func TestStreamNotificationsEndsWithError(t *testing.T) {
+	client := newFakeNotificationClient()
+	client.err = status.Error(codes.ResourceExhausted, "client too slow")
+	_, body := openStream(t, client, "/api/users/u1/notifications/stream", nil)
+	readEvent(t, body)
+	close(client.notifications)
+
+	// An error once the stream is open is the last event, in the error
+	// envelope
+	event := readEvent(t, body)
+	require.Len(t, event, 2)
+	assert.Equal(t, "event:error", event[0])
+	var envelope ErrorResponse
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(event[1], "data:")), &envelope))
+	assert.Equal(t, ErrorResponse{Status: "error", Code: "RESOURCE_EXHAUSTED", Message: "client too slow"}, envelope)
+	_, err := body.ReadString('\n')
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestStreamNotificationsFailsBeforeStreaming(t *testing.T) {
+	// A subscription that fails right away ends without headers, and the
+	// client gets a plain error response instead of an event stream
+	client := newFakeNotificationClient()
+	client.header = nil
+	client.err = status.Error(codes.NotFound, "user u1 not found")
+	close(client.notifications)
+
+	recorder := httptest.NewRecorder()
+	NewHttpApi(client, nil, nil, "").engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/users/u1/notifications/stream", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.JSONEq(t, `{"status":"error","code":"NOT_FOUND","message":"user u1 not found"}`, recorder.Body.String())
+}
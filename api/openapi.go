@@ -0,0 +1,313 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// route documents an HttpApi endpoint. Routes registered through handle end
+// up in the OpenAPI document served at /api/openapi.json.
+type route struct {
+	Method  string
+	Path    string
+	Summary string
+	Query   []queryParam
+	// Header documents the request headers the route reads
+	Header []queryParam
+	// Request is the type of the JSON request body, nil when there is none
+	Request any
+	// OptionalRequest marks request bodies that may be left out
+	OptionalRequest bool
+	// Response is the type of the data in the SuccessResponse, nil when
+	// there is none
+	Response any
+	// Status is the HTTP status of a successful response, 200 when unset
+	Status int
+	// Stream marks routes that answer with Server-Sent Events carrying
+	// Response as event data
+	Stream bool
+}
+
+type queryParam struct {
+	Name        string
+	Type        string
+	Description string
+	Required    bool
+}
+
+var (
+	pageSizeQuery = queryParam{Name: "page_size", Type: "integer", Description: "Maximum number of items to return"}
+	cursorQuery   = queryParam{Name: "cursor", Type: "string", Description: "next_cursor of the previous page"}
+	pageQuery     = []queryParam{pageSizeQuery, cursorQuery}
+)
+
+// handle registers handler on the group and records the route for the
+// OpenAPI document
+func (s *HttpApi) handle(group *gin.RouterGroup, r route, handler gin.HandlerFunc) {
+	group.Handle(r.Method, r.Path, handler)
+
+	r.Path = strings.TrimSuffix(group.BasePath()+"/"+strings.TrimPrefix(r.Path, "/"), "/")
+	s.routes = append(s.routes, documentedRoute{route: r, operationID: operationID(handler)})
+}
+
+type documentedRoute struct {
+	route
+	operationID string
+}
+
+// operationID names an operation after its handler, e.g. GetUser becomes getUser
+func operationID(handler gin.HandlerFunc) string {
+	name := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+	name = strings.TrimSuffix(name[strings.LastIndex(name, ".")+1:], "-fm")
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// The subset of OpenAPI 3 the API needs
+type (
+	OpenAPI struct {
+		OpenAPI    string                          `json:"openapi"`
+		Info       OpenAPIInfo                     `json:"info"`
+		Paths      map[string]map[string]Operation `json:"paths"`
+		Components Components                      `json:"components"`
+	}
+
+	OpenAPIInfo struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	}
+
+	Operation struct {
+		OperationID string              `json:"operationId"`
+		Summary     string              `json:"summary,omitempty"`
+		Parameters  []Parameter         `json:"parameters,omitempty"`
+		RequestBody *RequestBody        `json:"requestBody,omitempty"`
+		Responses   map[string]Response `json:"responses"`
+	}
+
+	Parameter struct {
+		Name        string  `json:"name"`
+		In          string  `json:"in"`
+		Description string  `json:"description,omitempty"`
+		Required    bool    `json:"required,omitempty"`
+		Schema      *Schema `json:"schema"`
+	}
+
+	RequestBody struct {
+		Required bool                 `json:"required"`
+		Content  map[string]MediaType `json:"content"`
+	}
+
+	Response struct {
+		Description string               `json:"description"`
+		Content     map[string]MediaType `json:"content,omitempty"`
+	}
+
+	MediaType struct {
+		Schema *Schema `json:"schema"`
+	}
+
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	}
+
+	Schema struct {
+		Ref         string             `json:"$ref,omitempty"`
+		Type        string             `json:"type,omitempty"`
+		Format      string             `json:"format,omitempty"`
+		Description string             `json:"description,omitempty"`
+		Properties  map[string]*Schema `json:"properties,omitempty"`
+		Required    []string           `json:"required,omitempty"`
+		Items       *Schema            `json:"items,omitempty"`
+	}
+)
+
+// openAPI builds the OpenAPI document of every route registered through handle
+func (s *HttpApi) openAPI() *OpenAPI {
+	doc := &OpenAPI{
+		OpenAPI:    "3.0.3",
+		Info:       OpenAPIInfo{Title: "Social App REST API", Version: "1.0.0"},
+		Paths:      make(map[string]map[string]Operation),
+		Components: Components{Schemas: make(map[string]*Schema)},
+	}
+	errorSchema := doc.schema(reflect.TypeOf(ErrorResponse{}))
+
+	for _, r := range s.routes {
+		operation := Operation{
+			OperationID: r.operationID,
+			Summary:     r.Summary,
+			Responses: map[string]Response{
+				"default": {
+					Description: "Error",
+					Content:     map[string]MediaType{"application/json": {Schema: errorSchema}},
+				},
+			},
+		}
+
+		path := r.Path
+		for _, segment := range strings.Split(r.Path, "/") {
+			if name, ok := strings.CutPrefix(segment, ":"); ok {
+				path = strings.Replace(path, segment, "{"+name+"}", 1)
+				operation.Parameters = append(operation.Parameters, Parameter{
+					Name:     name,
+					In:       "path",
+					Required: true,
+					Schema:   &Schema{Type: "string"},
+				})
+			}
+		}
+		for _, q := range r.Query {
+			operation.Parameters = append(operation.Parameters, Parameter{
+				Name:        q.Name,
+				In:          "query",
+				Description: q.Description,
+				Required:    q.Required,
+				Schema:      &Schema{Type: q.Type},
+			})
+		}
+		for _, h := range r.Header {
+			operation.Parameters = append(operation.Parameters, Parameter{
+				Name:        h.Name,
+				In:          "header",
+				Description: h.Description,
+				Schema:      &Schema{Type: h.Type},
+			})
+		}
+
+		if r.Request != nil {
+			operation.RequestBody = &RequestBody{
+				Required: !r.OptionalRequest,
+				Content: map[string]MediaType{
+					"application/json": {Schema: doc.schema(reflect.TypeOf(r.Request))},
+				},
+			}
+		}
+
+		statusCode := r.Status
+		if statusCode == 0 {
+			statusCode = http.StatusOK
+		}
+		success := Response{Description: http.StatusText(statusCode)}
+		if r.Stream {
+			success.Content = map[string]MediaType{
+				"text/event-stream": {Schema: doc.schema(reflect.TypeOf(r.Response))},
+			}
+		} else {
+			envelope := &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"status": {Type: "string", Description: "Always \"success\""},
+				},
+				Required: []string{"status"},
+			}
+			if r.Response != nil {
+				envelope.Properties["data"] = doc.schema(reflect.TypeOf(r.Response))
+				envelope.Required = append(envelope.Required, "data")
+			}
+			success.Content = map[string]MediaType{"application/json": {Schema: envelope}}
+		}
+		operation.Responses[strconv.Itoa(statusCode)] = success
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]Operation)
+		}
+		doc.Paths[path][strings.ToLower(r.Method)] = operation
+	}
+	return doc
+}
+
+// schema describes a Go type, adding named structs to the components and
+// referring to them
+func (doc *OpenAPI) schema(t reflect.Type) *Schema {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return doc.schema(t.Elem())
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: doc.schema(t.Elem())}
+	case reflect.Struct:
+		if _, ok := doc.Components.Schemas[t.Name()]; !ok {
+			// Reserve the name first so recursive types terminate
+			doc.Components.Schemas[t.Name()] = nil
+			doc.Components.Schemas[t.Name()] = doc.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + t.Name()}
+	default:
+		// interfaces and anything else can hold any JSON value
+		return &Schema{}
+	}
+}
+
+func (doc *OpenAPI) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := doc.schema(field.Type)
+		if description := field.Tag.Get("description"); description != "" {
+			if property.Ref != "" {
+				// OpenAPI 3.0 ignores siblings of $ref
+				property = &Schema{Ref: property.Ref}
+			} else {
+				property.Description = description
+			}
+		}
+		schema.Properties[name] = property
+
+		if field.Type.Kind() != reflect.Pointer && !strings.Contains(options, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
+
+// GetOpenAPI serves the OpenAPI document of the REST API
+func (s *HttpApi) GetOpenAPI(c *gin.Context) {
+	c.JSON(http.StatusOK, s.openAPI())
+}
+
+// GetDocs serves Swagger UI for the OpenAPI document
+func (s *HttpApi) GetDocs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(docsPage))
+}
+
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Social App REST API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = () => {
+      window.ui = SwaggerUIBundle({url: "openapi.json", dom_id: "#swagger-ui"});
+    };
+  </script>
+</body>
+</html>
+`
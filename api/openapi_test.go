@@ -0,0 +1,383 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	notificationProto "github.com/iwhitebird/social-app-microservices/proto/generated/notification/proto"
+	postProto "github.com/iwhitebird/social-app-microservices/proto/generated/post/proto"
+	userProto "github.com/iwhitebird/social-app-microservices/proto/generated/user/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// fetchOpenAPI decodes the document the server actually serves
+func fetchOpenAPI(t *testing.T, server *HttpApi) *OpenAPI {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	server.engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var doc OpenAPI
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &doc))
+	return &doc
+}
+
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	server := NewHttpApi(nil, nil, nil, "")
+	doc := fetchOpenAPI(t, server)
+
+	// Every route is documented as the operation of its own handler
+	var routes []string
+	for _, info := range server.engine.Routes() {
+		if info.Path == "/api/openapi.json" || info.Path == "/api/docs" {
+			continue
+		}
+		path := regexp.MustCompile(`:(\w+)`).ReplaceAllString(info.Path, "{$1}")
+		name := strings.TrimSuffix(info.Handler[strings.LastIndex(info.Handler, ".")+1:], "-fm")
+		routes = append(routes, info.Method+" "+path+" "+strings.ToLower(name[:1])+name[1:])
+	}
+
+	// and the document has nothing else
+	var documented []string
+	for path, operations := range doc.Paths {
+		for method, operation := range operations {
+			documented = append(documented, strings.ToUpper(method)+" "+path+" "+operation.OperationID)
+
+			// Every path parameter is declared
+			var params []string
+			for _, param := range operation.Parameters {
+				if param.In == "path" {
+					params = append(params, param.Name)
+				}
+			}
+			var placeholders []string
+			for _, match := range regexp.MustCompile(`\{(\w+)\}`).FindAllStringSubmatch(path, -1) {
+				placeholders = append(placeholders, match[1])
+			}
+			assert.ElementsMatch(t, placeholders, params, "%s %s", method, path)
+			assert.NotEmpty(t, operation.Responses["default"], "%s %s has no error response", method, path)
+		}
+	}
+
+	assert.ElementsMatch(t, routes, documented)
+}
+
+// fakeBackend serves every method of the gRPC services with a response that
+// has all of its fields set, or fails every call with fail
+func fakeBackend(t *testing.T, fail error) *grpc.ClientConn {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.UnknownServiceHandler(func(_ any, stream grpc.ServerStream) error {
+		if fail != nil {
+			return fail
+		}
+		name, _ := grpc.MethodFromServerStream(stream)
+		descriptor, err := protoregistry.GlobalFiles.FindDescriptorByName(
+			protoreflect.FullName(strings.ReplaceAll(strings.TrimPrefix(name, "/"), "/", ".")))
+		if err != nil {
+			return status.Error(codes.Unimplemented, err.Error())
+		}
+		method := descriptor.(protoreflect.MethodDescriptor)
+		if err := stream.RecvMsg(dynamicpb.NewMessage(method.Input())); err != nil {
+			return err
+		}
+		response := dynamicpb.NewMessage(method.Output())
+		populate(response, 0)
+		return stream.SendMsg(response)
+	}))
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///backend",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// populate sets every field of a message, with one element in every list
+func populate(message protoreflect.Message, depth int) {
+	if depth > 5 {
+		return
+	}
+	fields := message.Descriptor().Fields()
+	for i := range fields.Len() {
+		field := fields.Get(i)
+		switch {
+		case field.IsList():
+			list := message.Mutable(field).List()
+			element := list.NewElement()
+			if field.Message() != nil {
+				populate(element.Message(), depth+1)
+			} else {
+				element = sample(field)
+			}
+			list.Append(element)
+		case field.Message() != nil:
+			populate(message.Mutable(field).Message(), depth+1)
+		default:
+			message.Set(field, sample(field))
+		}
+	}
+}
+
+// sample returns a value other than the zero value for a scalar field
+func sample(field protoreflect.FieldDescriptor) protoreflect.Value {
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(true)
+	case protoreflect.EnumKind:
+		values := field.Enum().Values()
+		return protoreflect.ValueOfEnum(values.Get(values.Len() - 1).Number())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return protoreflect.ValueOfInt32(1)
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return protoreflect.ValueOfInt64(1)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return protoreflect.ValueOfUint32(1)
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return protoreflect.ValueOfUint64(1)
+	case protoreflect.FloatKind:
+		return protoreflect.ValueOfFloat32(1.5)
+	case protoreflect.DoubleKind:
+		return protoreflect.ValueOfFloat64(1.5)
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes([]byte("x"))
+	default:
+		return protoreflect.ValueOfString("x")
+	}
+}
+
+// resolve follows a reference to the schema of a component
+func (doc *OpenAPI) resolve(schema *Schema) *Schema {
+	if name, ok := strings.CutPrefix(schema.Ref, "#/components/schemas/"); ok {
+		return doc.Components.Schemas[name]
+	}
+	return schema
+}
+
+// example builds a JSON value of a schema with every property set
+func (doc *OpenAPI) example(schema *Schema) any {
+	schema = doc.resolve(schema)
+	switch schema.Type {
+	case "object":
+		object := make(map[string]any)
+		for name, property := range schema.Properties {
+			object[name] = doc.example(property)
+		}
+		return object
+	case "array":
+		return []any{doc.example(schema.Items)}
+	case "integer", "number":
+		return 1
+	case "boolean":
+		return true
+	default:
+		return "x"
+	}
+}
+
+// conform checks that a decoded JSON value has the type, the required
+// properties and no other properties than its schema documents
+func conform(t *testing.T, doc *OpenAPI, schema *Schema, value any, where string) {
+	t.Helper()
+	schema = doc.resolve(schema)
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !assert.True(t, ok, "%s is %#v, not an object", where, value) {
+			return
+		}
+		for _, name := range schema.Required {
+			assert.Contains(t, object, name, "%s lacks %s", where, name)
+		}
+		for name, property := range object {
+			if assert.Contains(t, schema.Properties, name, "%s has undocumented %s", where, name) {
+				conform(t, doc, schema.Properties[name], property, where+"."+name)
+			}
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !assert.True(t, ok, "%s is %#v, not an array", where, value) {
+			return
+		}
+		for i, item := range items {
+			conform(t, doc, schema.Items, item, fmt.Sprintf("%s[%d]", where, i))
+		}
+	case "integer":
+		number, ok := value.(float64)
+		assert.True(t, ok && number == math.Trunc(number), "%s is %#v, not an integer", where, value)
+	case "number":
+		assert.IsType(t, float64(0), value, where)
+	case "string":
+		assert.IsType(t, "", value, where)
+	case "boolean":
+		assert.IsType(t, false, value, where)
+	}
+}
+
+// events decodes the data of the Server-Sent Events of a type
+func events(t *testing.T, body io.Reader, event string) []any {
+	t.Helper()
+	var data []any
+	var current string
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if name, ok := strings.CutPrefix(line, "event:"); ok {
+			current = name
+		}
+		if raw, ok := strings.CutPrefix(line, "data:"); ok && current == event {
+			var value any
+			require.NoError(t, json.Unmarshal([]byte(raw), &value))
+			data = append(data, value)
+		}
+	}
+	return data
+}
+
+func TestHandlersMatchOpenAPI(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		fail error
+	}{
+		{"success", nil},
+		{"error", status.Error(codes.NotFound, "not found")},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			conn := fakeBackend(t, tc.fail)
+			server := NewHttpApi(notificationProto.NewNotificationServiceClient(conn),
+				postProto.NewPostServiceClient(conn), userProto.NewUserServiceClient(conn), "")
+			doc := fetchOpenAPI(t, server)
+
+			// Every operation answers with the status and body documented
+			// for it, given a request built from its documentation
+			for path, operations := range doc.Paths {
+				for method, operation := range operations {
+					where := strings.ToUpper(method) + " " + path
+					var body io.Reader
+					if operation.RequestBody != nil {
+						encoded, err := json.Marshal(doc.example(operation.RequestBody.Content["application/json"].Schema))
+						require.NoError(t, err)
+						body = bytes.NewReader(encoded)
+					}
+					target := regexp.MustCompile(`\{\w+\}`).ReplaceAllString(path, "x")
+					query := url.Values{}
+					for _, param := range operation.Parameters {
+						if param.In == "query" && param.Required {
+							query.Set(param.Name, "x")
+						}
+					}
+					if len(query) > 0 {
+						target += "?" + query.Encode()
+					}
+					request := httptest.NewRequest(strings.ToUpper(method), target, body)
+					request.Header.Set("Content-Type", "application/json")
+					recorder := httptest.NewRecorder()
+					server.engine.ServeHTTP(recorder, request)
+
+					code := "default"
+					for documented := range operation.Responses {
+						if tc.fail == nil && documented != "default" {
+							code = documented
+						}
+					}
+					if tc.fail != nil {
+						assert.Equal(t, http.StatusNotFound, recorder.Code, where)
+					} else {
+						assert.Equal(t, code, fmt.Sprint(recorder.Code), "%s: %s", where, recorder.Body.String())
+					}
+
+					for mediaType, media := range operation.Responses[code].Content {
+						if mediaType == "text/event-stream" {
+							data := events(t, recorder.Body, "notification")
+							assert.NotEmpty(t, data, where)
+							for _, value := range data {
+								conform(t, doc, media.Schema, value, where)
+							}
+							continue
+						}
+						var value any
+						if assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &value), where) {
+							conform(t, doc, media.Schema, value, where)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestOpenAPISchemas(t *testing.T) {
+	doc := fetchOpenAPI(t, NewHttpApi(nil, nil, nil, ""))
+
+	// Every reference resolves
+	var check func(where string, schema *Schema)
+	check = func(where string, schema *Schema) {
+		if schema == nil {
+			return
+		}
+		if name, ok := strings.CutPrefix(schema.Ref, "#/components/schemas/"); ok {
+			assert.Contains(t, doc.Components.Schemas, name, "dangling reference in %s", where)
+		}
+		for name, property := range schema.Properties {
+			check(where+"."+name, property)
+		}
+		check(where+"[]", schema.Items)
+	}
+	for name, schema := range doc.Components.Schemas {
+		check(name, schema)
+	}
+	for path, operations := range doc.Paths {
+		for method, operation := range operations {
+			if operation.RequestBody != nil {
+				for _, media := range operation.RequestBody.Content {
+					check(method+" "+path+" request", media.Schema)
+				}
+			}
+			for code, response := range operation.Responses {
+				for _, media := range response.Content {
+					check(method+" "+path+" "+code, media.Schema)
+				}
+			}
+		}
+	}
+
+	// Pointer and omitempty fields are optional, the rest is required
+	assert.Empty(t, doc.Components.Schemas["UpdateUserRequest"].Required)
+	assert.Empty(t, doc.Components.Schemas["MarkAllReadRequest"].Required)
+	assert.ElementsMatch(t, []string{"users", "next_cursor", "total_count"}, doc.Components.Schemas["UserPage"].Required)
+	assert.Equal(t, "#/components/schemas/User", doc.Components.Schemas["UserPage"].Properties["users"].Items.Ref)
+}
+
+func TestDocsPage(t *testing.T) {
+	server := NewHttpApi(nil, nil, nil, "")
+	recorder := httptest.NewRecorder()
+	server.engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/docs", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, recorder.Body.String(), `url: "openapi.json"`)
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	postProto "github.com/iwhitebird/social-app-microservices/proto/generated/post/proto"
+)
+
+var idempotencyKeyHeader = queryParam{
+	Name:        "Idempotency-Key",
+	Type:        "string",
+	Description: "Retries with the same key get the original response instead of publishing the post again",
+}
+
+func (s *HttpApi) RegisterPostRoutes(v1 *gin.RouterGroup) {
+	s.handle(v1, route{
+		Method:   http.MethodPost,
+		Path:     "/posts",
+		Summary:  "Publish a post and start notifying the author's followers",
+		Header:   []queryParam{idempotencyKeyHeader},
+		Request:  PublishPostRequest{},
+		Response: PublishPostResult{},
+		Status:   http.StatusCreated,
+	}, s.PublishPost)
+	s.handle(v1, route{
+		Method:   http.MethodGet,
+		Path:     "/posts/:id",
+		Summary:  "Get a post by ID",
+		Response: Post{},
+	}, s.GetPost)
+	s.handle(v1, route{
+		Method:   http.MethodGet,
+		Path:     "/users/:id/posts",
+		Summary:  "List the posts of a user, newest first",
+		Query:    []queryParam{{Name: "limit", Type: "integer", Description: "Maximum number of posts to return"}},
+		Response: []Post{},
+	}, s.ListPostsByUser)
+	s.handle(v1, route{
+		Method:   http.MethodGet,
+		Path:     "/fanouts/:id",
+		Summary:  "Get the progress of notifying the followers of a post",
+		Response: FanoutJob{},
+	}, s.GetFanout)
+}
+
+func (s *HttpApi) PublishPost(c *gin.Context) {
+	var body PublishPostRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	response, err := s.postClient.PublishPost(c, &postProto.Post{
+		UserId:         body.UserID,
+		Content:        body.Content,
+		IdempotencyKey: c.GetHeader("Idempotency-Key"),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	respond(c, http.StatusCreated, PublishPostResult{
+		PostID:              response.PostId,
+		NotificationsQueued: response.NotificationsQueued,
+		FanoutID:            response.FanoutId,
+		Followers:           response.Followers,
+		Message:             response.Message,
+	})
+}
+
+func (s *HttpApi) GetPost(c *gin.Context) {
+	post, err := s.postClient.GetPost(c, &postProto.PostId{Id: c.Param("id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, toPost(post))
+}
+
+// ListPostsByUser returns the posts of a user, newest first, capped by the
+// optional limit query parameter
+func (s *HttpApi) ListPostsByUser(c *gin.Context) {
+	limit, err := queryInt32(c, "limit")
+	if err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	list, err := s.postClient.ListPostsByUser(c, &postProto.ListPostsRequest{
+		UserId: c.Param("id"),
+		Limit:  limit,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	posts := make([]Post, 0, len(list.Posts))
+	for _, post := range list.Posts {
+		posts = append(posts, toPost(post))
+	}
+	respond(c, http.StatusOK, posts)
+}
+
+func (s *HttpApi) GetFanout(c *gin.Context) {
+	job, err := s.postClient.GetFanout(c, &postProto.FanoutId{Id: c.Param("id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, toFanoutJob(job))
+}
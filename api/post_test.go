@@ -0,0 +1,48 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	postProto "github.com/iwhitebird/social-app-microservices/proto/generated/post/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// fakePostClient records the posts it is asked to publish
+type fakePostClient struct {
+	postProto.PostServiceClient
+	published []*postProto.Post
+}
+
+func (c *fakePostClient) PublishPost(ctx context.Context, in *postProto.Post, opts ...grpc.CallOption) (*postProto.NotificationResponse, error) {
+	c.published = append(c.published, in)
+	return &postProto.NotificationResponse{Success: true, PostId: "p1", FanoutId: "f1"}, nil
+}
+
+func TestPublishPostPassesIdempotencyKey(t *testing.T) {
+	client := &fakePostClient{}
+	server := NewHttpApi(nil, client, nil, "")
+
+	publish := func(key string) {
+		request := httptest.NewRequest(http.MethodPost, "/api/posts", strings.NewReader(`{"user_id":"u1","content":"hello"}`))
+		request.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			request.Header.Set("Idempotency-Key", key)
+		}
+		recorder := httptest.NewRecorder()
+		server.engine.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusCreated, recorder.Code, recorder.Body.String())
+	}
+	publish("k1")
+	publish("")
+
+	require.Len(t, client.published, 2)
+	assert.Equal(t, "k1", client.published[0].IdempotencyKey)
+	assert.Equal(t, "hello", client.published[0].Content)
+	assert.Empty(t, client.published[1].IdempotencyKey)
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	notificationProto "github.com/iwhitebird/social-app-microservices/proto/generated/notification/proto"
+)
+
+func (s *HttpApi) RegisterPreferenceRoutes(v1 *gin.RouterGroup) {
+	preferences := v1.Group("/users/:id/preferences")
+	{
+		s.handle(preferences, route{
+			Method:   http.MethodGet,
+			Summary:  "Get the notification preferences of a user, the defaults if they never saved any",
+			Response: NotificationPreferences{},
+		}, s.GetPreferences)
+		s.handle(preferences, route{
+			Method:   http.MethodPut,
+			Summary:  "Replace the notification preferences of a user",
+			Request:  UpdatePreferencesRequest{},
+			Response: NotificationPreferences{},
+		}, s.UpdatePreferences)
+	}
+}
+
+func (s *HttpApi) GetPreferences(c *gin.Context) {
+	preferences, err := s.notificationClient.GetPreferences(c, &notificationProto.UserId{UserId: c.Param("id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, toPreferences(preferences))
+}
+
+func (s *HttpApi) UpdatePreferences(c *gin.Context) {
+	var body UpdatePreferencesRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	request := &notificationProto.NotificationPreferences{
+		UserId:          c.Param("id"),
+		Channels:        body.Channels,
+		MutedEventTypes: body.MutedEventTypes,
+		MutedAuthors:    body.MutedAuthors,
+	}
+	if body.QuietHours != nil {
+		request.QuietHours = &notificationProto.QuietHours{
+			Start:    body.QuietHours.Start,
+			End:      body.QuietHours.End,
+			TimeZone: body.QuietHours.TimeZone,
+		}
+	}
+
+	preferences, err := s.notificationClient.UpdatePreferences(c, request)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, toPreferences(preferences))
+}
@@ -0,0 +1,413 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	notificationProto "github.com/iwhitebird/social-app-microservices/proto/generated/notification/proto"
+	postProto "github.com/iwhitebird/social-app-microservices/proto/generated/post/proto"
+	userProto "github.com/iwhitebird/social-app-microservices/proto/generated/user/proto"
+)
+
+// The request and response bodies of the REST API. The OpenAPI document is
+// generated from these types, so their JSON tags are the wire format and the
+// description tags end up in the docs. A field is documented as required
+// unless it is a pointer or tagged omitempty.
+
+// SuccessResponse wraps the data of every successful response
+type SuccessResponse struct {
+	Status string `json:"status" description:"Always \"success\""`
+	Data   any    `json:"data,omitempty"`
+}
+
+// ErrorResponse is the body of every error response
+type ErrorResponse struct {
+	Status  string `json:"status" description:"Always \"error\""`
+	Code    string `json:"code" description:"gRPC status code in SCREAMING_SNAKE_CASE, e.g. NOT_FOUND"`
+	Message string `json:"message"`
+}
+
+type CreateUserRequest struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+// UpdateUserRequest changes only the fields that are present
+type UpdateUserRequest struct {
+	Username *string `json:"username"`
+	Email    *string `json:"email"`
+}
+
+type User struct {
+	ID        string `json:"id"`
+	Username  string `json:"username"`
+	Email     string `json:"email"`
+	CreatedAt int64  `json:"created_at" description:"Unix timestamp in seconds"`
+}
+
+type UserPage struct {
+	Users      []User `json:"users"`
+	NextCursor string `json:"next_cursor" description:"Cursor of the next page, empty on the last page"`
+	TotalCount int32  `json:"total_count"`
+}
+
+type PublishPostRequest struct {
+	UserID  string `json:"user_id" binding:"required"`
+	Content string `json:"content"`
+}
+
+type PublishPostResult struct {
+	PostID              string `json:"post_id"`
+	NotificationsQueued int32  `json:"notifications_queued" description:"Deprecated, always 0: followers are notified in the background, see GET /fanouts/{id}"`
+	FanoutID            string `json:"fanout_id" description:"ID of the job notifying the followers"`
+	Followers           int32  `json:"followers" description:"Followers of the author when the post was published"`
+	Message             string `json:"message"`
+}
+
+// FanoutJob is the progress of notifying the followers of a post
+type FanoutJob struct {
+	ID             string `json:"id"`
+	PostID         string `json:"post_id"`
+	AuthorID       string `json:"author_id"`
+	Status         string `json:"status" description:"pending, running, completed or failed"`
+	TotalFollowers int32  `json:"total_followers"`
+	Processed      int32  `json:"processed" description:"Followers handled so far"`
+	Queued         int32  `json:"queued" description:"Followers a notification was queued for"`
+	Skipped        int32  `json:"skipped" description:"Followers whose preferences ruled the notification out"`
+	TurnedAway     int32  `json:"turned_away" description:"Notifications the full queue turned away, each was tried again later"`
+	Error          string `json:"error,omitempty" description:"Why the job failed"`
+	CreatedAt      int64  `json:"created_at" description:"Unix timestamp in seconds"`
+	UpdatedAt      int64  `json:"updated_at" description:"Unix timestamp in seconds"`
+	CompletedAt    int64  `json:"completed_at,omitempty" description:"Unix timestamp in seconds"`
+}
+
+type Post struct {
+	ID        string `json:"id"`
+	UserID    string `json:"user_id"`
+	Content   string `json:"content"`
+	CreatedAt int64  `json:"created_at" description:"Unix timestamp in seconds"`
+}
+
+type Notification struct {
+	ID          string `json:"id"`
+	UserID      string `json:"user_id"`
+	PostID      string `json:"post_id"`
+	Content     string `json:"content"`
+	Read        bool   `json:"read"`
+	CreatedAt   int64  `json:"created_at" description:"Unix timestamp in seconds"`
+	Cursor      string `json:"cursor" description:"Position of the notification in the feed"`
+	Status      string `json:"status" description:"pending, delivering, delivered, failed or dead_lettered; empty for notifications stored before statuses were tracked"`
+	RetryCount  int32  `json:"retry_count" description:"Number of retries the delivery took so far"`
+	LastRetry   int64  `json:"last_retry,omitempty" description:"Unix timestamp in seconds of the latest retry"`
+	DeliveredAt int64  `json:"delivered_at,omitempty" description:"Unix timestamp in seconds"`
+	// Deliveries holds the outcome of every channel tried so far
+	Deliveries []ChannelDelivery `json:"deliveries"`
+	AuthorID   string            `json:"author_id,omitempty" description:"User whose activity the notification is about"`
+	EventType  string            `json:"event_type,omitempty" description:"What happened, e.g. new_post"`
+	PostIDs    []string          `json:"post_ids,omitempty" description:"Posts an aggregate notification merges, oldest first, with post_id the latest"`
+}
+
+type ChannelDelivery struct {
+	Channel     string `json:"channel" description:"in_app, webhook, email or mock"`
+	Status      string `json:"status" description:"pending, delivered or failed"`
+	Attempts    int32  `json:"attempts"`
+	LastError   string `json:"last_error,omitempty" description:"Error of the latest failed attempt"`
+	DeliveredAt int64  `json:"delivered_at,omitempty" description:"Unix timestamp in seconds"`
+}
+
+type NotificationPage struct {
+	Notifications []Notification `json:"notifications"`
+	NextCursor    string         `json:"next_cursor" description:"Cursor of the next page, empty on the last page"`
+}
+
+type UnreadCount struct {
+	UnreadCount int32 `json:"unread_count"`
+}
+
+type MarkNotificationsReadRequest struct {
+	NotificationIDs []string `json:"notification_ids" binding:"required"`
+}
+
+type MarkAllReadRequest struct {
+	Before int64 `json:"before,omitempty" description:"Only mark notifications created up to this Unix timestamp"`
+}
+
+type MarkReadResult struct {
+	Marked      int32 `json:"marked" description:"How many notifications were unread before the call"`
+	UnreadCount int32 `json:"unread_count"`
+}
+
+type NotificationPreferences struct {
+	UserID          string      `json:"user_id"`
+	Channels        []string    `json:"channels" description:"in_app, webhook, email or mock; empty for the server defaults"`
+	MutedEventTypes []string    `json:"muted_event_types" description:"Event types the user is not notified about, e.g. new_post"`
+	MutedAuthors    []string    `json:"muted_authors" description:"IDs of users whose posts the user is not notified about"`
+	QuietHours      *QuietHours `json:"quiet_hours" description:"Null when the user has no quiet hours"`
+	UpdatedAt       int64       `json:"updated_at,omitempty" description:"Unix timestamp in seconds, left out while the user has the defaults"`
+}
+
+// QuietHours is a daily period during which notifications only go to the inbox
+type QuietHours struct {
+	Start    string `json:"start" binding:"required" description:"Time of day as HH:MM"`
+	End      string `json:"end" binding:"required" description:"Time of day as HH:MM, before start for periods that run past midnight"`
+	TimeZone string `json:"time_zone,omitempty" description:"IANA time zone name, e.g. Europe/Berlin; defaults to UTC"`
+}
+
+// UpdatePreferencesRequest replaces the preferences, fields left out go
+// back to their defaults
+type UpdatePreferencesRequest struct {
+	Channels        []string    `json:"channels,omitempty" description:"in_app, webhook, email or mock, as far as enabled on the server; empty for the server defaults"`
+	MutedEventTypes []string    `json:"muted_event_types,omitempty"`
+	MutedAuthors    []string    `json:"muted_authors,omitempty"`
+	QuietHours      *QuietHours `json:"quiet_hours"`
+}
+
+type DeliveryAttempt struct {
+	Attempt     int32  `json:"attempt"`
+	AttemptedAt int64  `json:"attempted_at" description:"Unix timestamp in seconds"`
+	Error       string `json:"error,omitempty" description:"Empty if the channel delivered the notification"`
+	Channel     string `json:"channel,omitempty"`
+}
+
+// NotificationJob is a notification waiting in the queue, or being delivered
+type NotificationJob struct {
+	Notification Notification      `json:"notification"`
+	Attempt      int32             `json:"attempt" description:"The attempt the job is due for, starting at 1"`
+	DueAt        int64             `json:"due_at,omitempty" description:"Unix timestamp in seconds a retry is due at"`
+	Attempts     []DeliveryAttempt `json:"attempts"`
+}
+
+// DeadLetter is a notification the queue gave up on after its last retry
+type DeadLetter struct {
+	Notification Notification      `json:"notification"`
+	Reason       string            `json:"reason"`
+	Attempts     []DeliveryAttempt `json:"attempts"`
+	FailedAt     int64             `json:"failed_at" description:"Unix timestamp in seconds"`
+}
+
+type DeadLetterPage struct {
+	DeadLetters []DeadLetter `json:"dead_letters"`
+	NextCursor  string       `json:"next_cursor" description:"Cursor of the next page, empty on the last page"`
+	TotalCount  int32        `json:"total_count"`
+}
+
+type ReplayResult struct {
+	Replayed int32 `json:"replayed" description:"Number of notifications put back into the queue"`
+}
+
+type PurgeResult struct {
+	Purged int32 `json:"purged"`
+}
+
+type WorkerPool struct {
+	Workers     int32 `json:"workers"`
+	Busy        int32 `json:"busy" description:"Workers delivering a notification right now"`
+	MinWorkers  int32 `json:"min_workers"`
+	MaxWorkers  int32 `json:"max_workers"`
+	Autoscaling bool  `json:"autoscaling" description:"False while the size is pinned"`
+}
+
+type SetWorkerPoolRequest struct {
+	Workers *int32 `json:"workers" binding:"required" description:"Between 1 and max_workers to pin the pool to that size, or 0 to autoscale again"`
+}
+
+type Metrics struct {
+	StoreMetrics StoreMetrics `json:"store_metrics"`
+	Lanes        []QueueLane  `json:"lanes" description:"Priority lanes of the notification queue, most urgent first"`
+	SystemStatus string       `json:"system_status"`
+}
+
+type QueueLane struct {
+	Priority     string `json:"priority"`
+	Depth        int64  `json:"depth" description:"Jobs waiting for a worker"`
+	OldestWaitMs int64  `json:"oldest_wait_ms" description:"How long the job at the head of the lane has waited"`
+	Spilled      int64  `json:"spilled" description:"Jobs waiting in the store for room in the lane"`
+}
+
+type StoreMetrics struct {
+	TotalNotificationsSent int64   `json:"total_notifications_sent"`
+	FailedAttempts         int64   `json:"failed_attempts"`
+	AverageDeliveryTime    float64 `json:"average_delivery_time" description:"Average delivery time in nanoseconds"`
+}
+
+// respond writes data wrapped in a SuccessResponse
+func respond(c *gin.Context, code int, data any) {
+	c.JSON(code, SuccessResponse{Status: "success", Data: data})
+}
+
+func toUser(user *userProto.User) User {
+	return User{
+		ID:        user.Id,
+		Username:  user.Username,
+		Email:     user.Email,
+		CreatedAt: user.CreatedAt,
+	}
+}
+
+func toUserPage(page *userProto.UserPage) UserPage {
+	users := make([]User, 0, len(page.Users))
+	for _, user := range page.Users {
+		users = append(users, toUser(user))
+	}
+	return UserPage{
+		Users:      users,
+		NextCursor: page.NextCursor,
+		TotalCount: page.TotalCount,
+	}
+}
+
+func toPost(post *postProto.Post) Post {
+	return Post{
+		ID:        post.Id,
+		UserID:    post.UserId,
+		Content:   post.Content,
+		CreatedAt: post.CreatedAt,
+	}
+}
+
+func toFanoutJob(job *postProto.FanoutJob) FanoutJob {
+	return FanoutJob{
+		ID:             job.Id,
+		PostID:         job.PostId,
+		AuthorID:       job.AuthorId,
+		Status:         strings.ToLower(strings.TrimPrefix(job.Status.String(), "FANOUT_STATUS_")),
+		TotalFollowers: job.TotalFollowers,
+		Processed:      job.Processed,
+		Queued:         job.Queued,
+		Skipped:        job.Skipped,
+		TurnedAway:     job.TurnedAway,
+		Error:          job.Error,
+		CreatedAt:      job.CreatedAt,
+		UpdatedAt:      job.UpdatedAt,
+		CompletedAt:    job.CompletedAt,
+	}
+}
+
+func toNotification(notification *notificationProto.Notification) Notification {
+	return Notification{
+		ID:          notification.Id,
+		UserID:      notification.UserId,
+		PostID:      notification.PostId,
+		Content:     notification.Content,
+		Read:        notification.Read,
+		CreatedAt:   notification.CreatedAt,
+		Cursor:      notification.Cursor,
+		Status:      toStatus(notification.Status),
+		RetryCount:  notification.RetryCount,
+		LastRetry:   notification.LastRetry,
+		DeliveredAt: notification.DeliveredAt,
+		Deliveries:  toChannelDeliveries(notification.Deliveries),
+		AuthorID:    notification.AuthorId,
+		EventType:   notification.EventType,
+		PostIDs:     notification.PostIds,
+	}
+}
+
+func toChannelDeliveries(deliveries []*notificationProto.ChannelDelivery) []ChannelDelivery {
+	result := make([]ChannelDelivery, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		result = append(result, ChannelDelivery{
+			Channel:     delivery.Channel,
+			Status:      toStatus(delivery.Status),
+			Attempts:    delivery.Attempts,
+			LastError:   delivery.LastError,
+			DeliveredAt: delivery.DeliveredAt,
+		})
+	}
+	return result
+}
+
+// toStatus spells a notification status the way the models do, e.g.
+// NOTIFICATION_STATUS_DEAD_LETTERED becomes dead_lettered
+func toStatus(status notificationProto.NotificationStatus) string {
+	if status == notificationProto.NotificationStatus_NOTIFICATION_STATUS_UNSPECIFIED {
+		return ""
+	}
+	return strings.ToLower(strings.TrimPrefix(status.String(), "NOTIFICATION_STATUS_"))
+}
+
+func toMarkReadResult(response *notificationProto.MarkReadResponse) MarkReadResult {
+	return MarkReadResult{
+		Marked:      response.Marked,
+		UnreadCount: response.UnreadCount,
+	}
+}
+
+func toPreferences(preferences *notificationProto.NotificationPreferences) NotificationPreferences {
+	p := NotificationPreferences{
+		UserID:          preferences.UserId,
+		Channels:        nonNil(preferences.Channels),
+		MutedEventTypes: nonNil(preferences.MutedEventTypes),
+		MutedAuthors:    nonNil(preferences.MutedAuthors),
+		UpdatedAt:       preferences.UpdatedAt,
+	}
+	if preferences.QuietHours != nil {
+		p.QuietHours = &QuietHours{
+			Start:    preferences.QuietHours.Start,
+			End:      preferences.QuietHours.End,
+			TimeZone: preferences.QuietHours.TimeZone,
+		}
+	}
+	return p
+}
+
+// nonNil makes empty lists encode as [] instead of null
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
+
+func toDeliveryAttempts(attempts []*notificationProto.DeliveryAttempt) []DeliveryAttempt {
+	result := make([]DeliveryAttempt, 0, len(attempts))
+	for _, attempt := range attempts {
+		result = append(result, DeliveryAttempt{
+			Attempt:     attempt.Attempt,
+			AttemptedAt: attempt.AttemptedAt,
+			Error:       attempt.Error,
+			Channel:     attempt.Channel,
+		})
+	}
+	return result
+}
+
+func toNotificationJob(job *notificationProto.NotificationJob) NotificationJob {
+	return NotificationJob{
+		Notification: toNotification(job.Notification),
+		Attempt:      job.Attempt,
+		DueAt:        job.DueAt,
+		Attempts:     toDeliveryAttempts(job.Attempts),
+	}
+}
+
+func toDeadLetter(deadLetter *notificationProto.DeadLetter) DeadLetter {
+	return DeadLetter{
+		Notification: toNotification(deadLetter.Notification),
+		Reason:       deadLetter.Reason,
+		Attempts:     toDeliveryAttempts(deadLetter.Attempts),
+		FailedAt:     deadLetter.FailedAt,
+	}
+}
+
+func toDeadLetterPage(page *notificationProto.DeadLetterPage) DeadLetterPage {
+	deadLetters := make([]DeadLetter, 0, len(page.DeadLetters))
+	for _, deadLetter := range page.DeadLetters {
+		deadLetters = append(deadLetters, toDeadLetter(deadLetter))
+	}
+	return DeadLetterPage{
+		DeadLetters: deadLetters,
+		NextCursor:  page.NextCursor,
+		TotalCount:  page.TotalCount,
+	}
+}
+
+func toWorkerPool(pool *notificationProto.WorkerPool) WorkerPool {
+	return WorkerPool{
+		Workers:     pool.Workers,
+		Busy:        pool.Busy,
+		MinWorkers:  pool.MinWorkers,
+		MaxWorkers:  pool.MaxWorkers,
+		Autoscaling: pool.Autoscaling,
+	}
+}
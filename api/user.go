@@ -0,0 +1,212 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	userProto "github.com/iwhitebird/social-app-microservices/proto/generated/user/proto"
+)
+
+func (s *HttpApi) RegisterUserRoutes(v1 *gin.RouterGroup) {
+	users := v1.Group("/users")
+	{
+		s.handle(users, route{
+			Method:   http.MethodPost,
+			Summary:  "Create a user",
+			Request:  CreateUserRequest{},
+			Response: User{},
+			Status:   http.StatusCreated,
+		}, s.CreateUser)
+		s.handle(users, route{
+			Method:   http.MethodGet,
+			Summary:  "Get a user by username",
+			Query:    []queryParam{{Name: "username", Type: "string", Description: "Username to look up", Required: true}},
+			Response: User{},
+		}, s.GetUserByUsername)
+		s.handle(users, route{
+			Method:   http.MethodGet,
+			Path:     "/:id",
+			Summary:  "Get a user by ID",
+			Response: User{},
+		}, s.GetUser)
+		s.handle(users, route{
+			Method:   http.MethodPatch,
+			Path:     "/:id",
+			Summary:  "Update the username and/or email of a user",
+			Request:  UpdateUserRequest{},
+			Response: User{},
+		}, s.UpdateUser)
+		s.handle(users, route{
+			Method:  http.MethodDelete,
+			Path:    "/:id",
+			Summary: "Delete a user",
+		}, s.DeleteUser)
+		s.handle(users, route{
+			Method:  http.MethodPut,
+			Path:    "/:id/following/:followee",
+			Summary: "Follow a user",
+		}, s.Follow)
+		s.handle(users, route{
+			Method:  http.MethodDelete,
+			Path:    "/:id/following/:followee",
+			Summary: "Unfollow a user",
+		}, s.Unfollow)
+		s.handle(users, route{
+			Method:   http.MethodGet,
+			Path:     "/:id/followers",
+			Summary:  "List the followers of a user",
+			Query:    pageQuery,
+			Response: UserPage{},
+		}, s.ListFollowers)
+		s.handle(users, route{
+			Method:   http.MethodGet,
+			Path:     "/:id/following",
+			Summary:  "List the users a user follows",
+			Query:    pageQuery,
+			Response: UserPage{},
+		}, s.ListFollowing)
+	}
+}
+
+func (s *HttpApi) CreateUser(c *gin.Context) {
+	var body CreateUserRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	user, err := s.userClient.CreateUser(c, &userProto.CreateUserRequest{
+		Username: body.Username,
+		Email:    body.Email,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	respond(c, http.StatusCreated, toUser(user))
+}
+
+// GetUserByUsername looks a user up through the username query parameter
+func (s *HttpApi) GetUserByUsername(c *gin.Context) {
+	username := c.Query("username")
+	if username == "" {
+		respondBadRequest(c, errors.New("the username query parameter is required"))
+		return
+	}
+
+	user, err := s.userClient.GetUserByUsername(c, &userProto.Username{Username: username})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, toUser(user))
+}
+
+func (s *HttpApi) GetUser(c *gin.Context) {
+	user, err := s.userClient.GetUser(c, &userProto.UserId{UserId: c.Param("id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, toUser(user))
+}
+
+// UpdateUser changes only the fields present in the request body
+func (s *HttpApi) UpdateUser(c *gin.Context) {
+	var body UpdateUserRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	user, err := s.userClient.UpdateUser(c, &userProto.UpdateUserRequest{
+		UserId:   c.Param("id"),
+		Username: body.Username,
+		Email:    body.Email,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, toUser(user))
+}
+
+func (s *HttpApi) DeleteUser(c *gin.Context) {
+	if _, err := s.userClient.DeleteUser(c, &userProto.UserId{UserId: c.Param("id")}); err != nil {
+		respondError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, nil)
+}
+
+// Follow makes the user in the path follow the followee. It is idempotent.
+func (s *HttpApi) Follow(c *gin.Context) {
+	_, err := s.userClient.Follow(c, &userProto.FollowRequest{
+		FollowerId: c.Param("id"),
+		FolloweeId: c.Param("followee"),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, nil)
+}
+
+func (s *HttpApi) Unfollow(c *gin.Context) {
+	_, err := s.userClient.Unfollow(c, &userProto.FollowRequest{
+		FollowerId: c.Param("id"),
+		FolloweeId: c.Param("followee"),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, nil)
+}
+
+// ListFollowers returns a page of followers, see listFollowsRequest for paging
+func (s *HttpApi) ListFollowers(c *gin.Context) {
+	request, err := listFollowsRequest(c)
+	if err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	page, err := s.userClient.ListFollowers(c, request)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, toUserPage(page))
+}
+
+// ListFollowing returns a page of followed users, see listFollowsRequest for paging
+func (s *HttpApi) ListFollowing(c *gin.Context) {
+	request, err := listFollowsRequest(c)
+	if err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	page, err := s.userClient.ListFollowing(c, request)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, toUserPage(page))
+}
+
+// listFollowsRequest reads the page_size and cursor query parameters
+func listFollowsRequest(c *gin.Context) (*userProto.ListFollowsRequest, error) {
+	request := &userProto.ListFollowsRequest{
+		UserId: c.Param("id"),
+		Cursor: c.Query("cursor"),
+	}
+	pageSize, err := queryInt32(c, "page_size")
+	if err != nil {
+		return nil, err
+	}
+	request.PageSize = pageSize
+	return request, nil
+}
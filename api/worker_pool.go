@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	notificationProto "github.com/iwhitebird/social-app-microservices/proto/generated/notification/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func (s *HttpApi) RegisterWorkerPoolRoutes(v1 *gin.RouterGroup) {
+	pool := v1.Group("/admin/worker-pool")
+	{
+		s.handle(pool, route{
+			Method:   http.MethodGet,
+			Summary:  "Get the size and load of the notification worker pool",
+			Response: WorkerPool{},
+		}, s.GetWorkerPool)
+		s.handle(pool, route{
+			Method:   http.MethodPut,
+			Summary:  "Pin the notification worker pool to a size, or let it autoscale again",
+			Request:  SetWorkerPoolRequest{},
+			Response: WorkerPool{},
+		}, s.SetWorkerPool)
+	}
+}
+
+func (s *HttpApi) GetWorkerPool(c *gin.Context) {
+	pool, err := s.notificationClient.GetWorkerPool(c, &emptypb.Empty{})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, toWorkerPool(pool))
+}
+
+func (s *HttpApi) SetWorkerPool(c *gin.Context) {
+	var body SetWorkerPoolRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	pool, err := s.notificationClient.SetWorkerPool(c, &notificationProto.SetWorkerPoolRequest{Workers: *body.Workers})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, toWorkerPool(pool))
+}
@@ -1,24 +1,34 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"math/rand"
 	"net"
 	"net/http"
+	"net/smtp"
+	"net/url"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/iwhitebird/social-app-microservices/api"
 	"github.com/iwhitebird/social-app-microservices/internal/config"
+	"github.com/iwhitebird/social-app-microservices/internal/delivery"
+	"github.com/iwhitebird/social-app-microservices/internal/fanout"
 	"github.com/iwhitebird/social-app-microservices/internal/models"
 	"github.com/iwhitebird/social-app-microservices/internal/queue"
 	"github.com/iwhitebird/social-app-microservices/internal/service"
+	"github.com/iwhitebird/social-app-microservices/internal/storage"
 	notificationProto "github.com/iwhitebird/social-app-microservices/proto/generated/notification/proto"
 	postProto "github.com/iwhitebird/social-app-microservices/proto/generated/post/proto"
+	userProto "github.com/iwhitebird/social-app-microservices/proto/generated/user/proto"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/reflection"
 
 	"github.com/99designs/gqlgen/graphql/handler"
@@ -26,6 +36,7 @@ import (
 	"github.com/99designs/gqlgen/graphql/handler/lru"
 	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
+	"github.com/gorilla/websocket"
 	resolver "github.com/iwhitebird/social-app-microservices/graph"
 	graph "github.com/iwhitebird/social-app-microservices/graph/generated"
 	"github.com/vektah/gqlparser/v2/ast"
@@ -33,7 +44,7 @@ import (
 )
 
 var (
-	store  *models.Store
+	store  storage.Store
 	logger *log.Logger
 )
 
@@ -50,8 +61,17 @@ func main() {
 		os.Exit(1)
 	}
 
-	store = models.NewStore()
-	store.InitSampleData()
+	store, err = openStore(cfg)
+	if err != nil {
+		logger.Println("failed to open store", "error", err)
+		os.Exit(1)
+	}
+	defer store.Close()
+
+	if err := storage.InitSampleData(context.Background(), store); err != nil {
+		logger.Println("failed to seed sample data", "error", err)
+		os.Exit(1)
+	}
 
 	logger.Println("starting servers", "config", cfg)
 
@@ -81,6 +101,15 @@ func main() {
 	logger.Println("servers stopped")
 }
 
+func openStore(cfg *config.Config) (storage.Store, error) {
+	if cfg.StorageDriver == "sqlite" {
+		logger.Println("using SQLite storage", "path", cfg.SQLitePath)
+		return storage.NewSQLiteStore(cfg.SQLitePath)
+	}
+	logger.Println("using in-memory storage")
+	return storage.NewMemoryStore(), nil
+}
+
 func RunHTTPServer(cfg *config.Config) {
 	grpcAddr := fmt.Sprintf("%s:%s", cfg.GRPCHost, cfg.GRPCPort)
 	conn, err := grpc.NewClient(grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
@@ -92,8 +121,9 @@ func RunHTTPServer(cfg *config.Config) {
 
 	notificationClient := notificationProto.NewNotificationServiceClient(conn)
 	postClient := postProto.NewPostServiceClient(conn)
+	userClient := userProto.NewUserServiceClient(conn)
 
-	server := api.NewHttpApi(notificationClient, postClient, cfg.HTTPPort)
+	server := api.NewHttpApi(notificationClient, postClient, userClient, cfg.HTTPPort)
 
 	logger.Println("starting HTTP server", "port", cfg.HTTPPort)
 
@@ -113,11 +143,21 @@ func RunGQlServer(cfg *config.Config) {
 
 	notificationClient := notificationProto.NewNotificationServiceClient(conn)
 	postClient := postProto.NewPostServiceClient(conn)
+	userClient := userProto.NewUserServiceClient(conn)
 
 	srv := handler.New(graph.NewExecutableSchema(graph.Config{
-		Resolvers: resolver.NewResolver(notificationClient, postClient),
+		Resolvers: resolver.NewResolver(notificationClient, postClient, userClient),
 	}))
 
+	srv.SetErrorPresenter(resolver.ErrorPresenter)
+	// The WebSocket transport goes first, since the GET transport would
+	// otherwise claim the upgrade request
+	srv.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		Upgrader: websocket.Upgrader{
+			CheckOrigin: checkOrigin(cfg.WSAllowedOrigins),
+		},
+	})
 	srv.AddTransport(transport.Options{})
 	srv.AddTransport(transport.GET{})
 	srv.AddTransport(transport.POST{})
@@ -128,7 +168,7 @@ func RunGQlServer(cfg *config.Config) {
 	})
 
 	http.Handle("/", playground.Handler("GraphQL playground", "/query"))
-	http.Handle("/query", srv)
+	http.Handle("/query", resolver.WithIdempotencyKey(srv))
 
 	logger.Println("starting GraphQL server", "port", cfg.GQLPort, "playground", fmt.Sprintf("http://localhost:%s/", cfg.GQLPort))
 
@@ -137,19 +177,89 @@ func RunGQlServer(cfg *config.Config) {
 	}
 }
 
+// checkOrigin allows WebSocket upgrades from the server's own origin and from
+// the configured ones
+func checkOrigin(allowed []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+			return true
+		}
+		return slices.Contains(allowed, origin)
+	}
+}
+
+// deliverers returns the webhook, email and mock deliverers that are
+// configured. The queue delivers in-app on its own.
+func deliverers(cfg *config.Config) []delivery.Deliverer {
+	var deliverers []delivery.Deliverer
+	if cfg.WebhookURL != "" {
+		deliverers = append(deliverers, delivery.NewWebhook(cfg.WebhookURL, cfg.WebhookTimeout))
+	}
+	if cfg.SMTPAddr != "" && cfg.SMTPFrom != "" {
+		var auth smtp.Auth
+		if cfg.SMTPUsername != "" {
+			host, _, _ := net.SplitHostPort(cfg.SMTPAddr)
+			auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, host)
+		}
+		deliverers = append(deliverers, delivery.NewEmail(cfg.SMTPAddr, cfg.SMTPFrom, auth, store))
+	}
+	// The mock never stands in for a real channel unless asked for
+	if slices.Contains(cfg.DeliveryChannels, models.ChannelMock) {
+		deliverers = append(deliverers, delivery.NewMock(models.ChannelMock, delivery.WithFailureRate(cfg.MockFailureRate)))
+	}
+	return deliverers
+}
+
 func RunGRPCServer(cfg *config.Config) {
-	notificationQueue := queue.NewNotificationQueue(store, 5, 3)
+	notificationQueue := queue.NewNotificationQueue(store, cfg.MinWorkers, cfg.MaxRetries,
+		queue.WithAutoscaling(cfg.MinWorkers, cfg.MaxWorkers, cfg.ScaleInterval, cfg.ScaleTargetWait),
+		queue.WithRetryBackoff(cfg.RetryBaseBackoff, cfg.RetryMaxBackoff),
+		queue.WithJobLease(cfg.JobLease),
+		queue.WithDeliverers(deliverers(cfg)...),
+		queue.WithDefaultChannels(cfg.DeliveryChannels...),
+		queue.WithCoalescing(cfg.CoalesceWindow),
+		queue.WithDeduplication(cfg.DedupWindow),
+		queue.WithLanes(cfg.LaneWeights, cfg.LaneMaxWait),
+		queue.WithEventPriorities(cfg.EventPriorities),
+		queue.WithOverflow(queue.OverflowPolicy(cfg.OverflowPolicy), cfg.LaneCapacity, cfg.EnqueueTimeout))
 	notificationQueue.Start()
 	defer notificationQueue.Stop()
 
-	notificationService := service.NewNotificationService(store, notificationQueue)
-	postService := service.NewPostService(store, notificationQueue)
+	// Stopped before the queue, which takes the notifications it queues
+	dispatcher := fanout.NewDispatcher(store, notificationQueue,
+		fanout.WithBatchSize(cfg.FanoutBatchSize),
+		fanout.WithWorkers(cfg.FanoutWorkers),
+		fanout.WithLease(cfg.JobLease))
+	dispatcher.Start()
+	defer dispatcher.Stop()
 
-	grpcServer := grpc.NewServer()
+	notificationService := service.NewNotificationService(store, notificationQueue)
+	postService := service.NewPostService(store, dispatcher,
+		service.WithMaxContentLength(cfg.MaxPostLength),
+		service.WithIdempotencyKeyTTL(cfg.IdempotencyKeyTTL))
+	userService := service.NewUserService(store)
+
+	// Keep idle notification subscriptions alive through proxies and notice
+	// clients that went away without closing their stream
+	grpcServer := grpc.NewServer(
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:    30 * time.Second,
+			Timeout: 10 * time.Second,
+		}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             10 * time.Second,
+			PermitWithoutStream: true,
+		}),
+	)
 	defer grpcServer.GracefulStop()
 
 	notificationProto.RegisterNotificationServiceServer(grpcServer, notificationService)
 	postProto.RegisterPostServiceServer(grpcServer, postService)
+	userProto.RegisterUserServiceServer(grpcServer, userService)
 	reflection.Register(grpcServer)
 
 	grpcListener, err := net.Listen("tcp", ":"+cfg.GRPCPort)
@@ -0,0 +1,257 @@
+package graph
+
+import (
+	"strings"
+
+	"github.com/iwhitebird/social-app-microservices/graph/model"
+	notificationProto "github.com/iwhitebird/social-app-microservices/proto/generated/notification/proto"
+	postProto "github.com/iwhitebird/social-app-microservices/proto/generated/post/proto"
+	userProto "github.com/iwhitebird/social-app-microservices/proto/generated/user/proto"
+)
+
+func toGraphPost(post *postProto.Post) *model.Post {
+	return &model.Post{
+		ID:        post.Id,
+		UserID:    post.UserId,
+		Content:   post.Content,
+		CreatedAt: post.CreatedAt,
+	}
+}
+
+func toGraphFanout(job *postProto.FanoutJob) *model.FanoutJob {
+	j := &model.FanoutJob{
+		ID:             job.Id,
+		PostID:         job.PostId,
+		AuthorID:       job.AuthorId,
+		Status:         model.FanoutStatus(strings.TrimPrefix(job.Status.String(), "FANOUT_STATUS_")),
+		TotalFollowers: job.TotalFollowers,
+		Processed:      job.Processed,
+		Queued:         job.Queued,
+		Skipped:        job.Skipped,
+		TurnedAway:     job.TurnedAway,
+		CreatedAt:      job.CreatedAt,
+		UpdatedAt:      job.UpdatedAt,
+	}
+	if job.Error != "" {
+		j.Error = &job.Error
+	}
+	if job.CompletedAt != 0 {
+		j.CompletedAt = &job.CompletedAt
+	}
+	return j
+}
+
+func toGraphUser(user *userProto.User) *model.User {
+	return &model.User{
+		ID:        user.Id,
+		Username:  user.Username,
+		Email:     user.Email,
+		CreatedAt: user.CreatedAt,
+	}
+}
+
+func toListFollowsRequest(userID string, first *int32, after *string) *userProto.ListFollowsRequest {
+	request := &userProto.ListFollowsRequest{UserId: userID}
+	if first != nil {
+		request.PageSize = *first
+	}
+	if after != nil {
+		request.Cursor = *after
+	}
+	return request
+}
+
+func toGraphUserConnection(page *userProto.UserPage) *model.UserConnection {
+	connection := &model.UserConnection{
+		Users:       make([]*model.User, 0, len(page.Users)),
+		TotalCount:  page.TotalCount,
+		HasNextPage: page.NextCursor != "",
+	}
+	if page.NextCursor != "" {
+		connection.NextCursor = &page.NextCursor
+	}
+	for _, user := range page.Users {
+		connection.Users = append(connection.Users, toGraphUser(user))
+	}
+	return connection
+}
+
+func toGraphMarkReadResult(response *notificationProto.MarkReadResponse) *model.MarkReadResult {
+	return &model.MarkReadResult{
+		Marked:      response.Marked,
+		UnreadCount: response.UnreadCount,
+	}
+}
+
+func toGraphNotification(notification *notificationProto.Notification) *model.Notification {
+	n := &model.Notification{
+		ID:         notification.Id,
+		UserID:     notification.UserId,
+		PostID:     notification.PostId,
+		Content:    notification.Content,
+		Read:       notification.Read,
+		CreatedAt:  notification.CreatedAt,
+		RetryCount: notification.RetryCount,
+		PostIDs:    append([]string{}, notification.PostIds...),
+	}
+	if notification.AuthorId != "" {
+		n.AuthorID = &notification.AuthorId
+	}
+	if notification.EventType != "" {
+		n.EventType = &notification.EventType
+	}
+	if status := toGraphStatus(notification.Status); status.IsValid() {
+		n.Status = &status
+	}
+	if notification.LastRetry != 0 {
+		n.LastRetry = &notification.LastRetry
+	}
+	if notification.DeliveredAt != 0 {
+		n.DeliveredAt = &notification.DeliveredAt
+	}
+
+	n.Deliveries = make([]*model.ChannelDelivery, 0, len(notification.Deliveries))
+	for _, delivery := range notification.Deliveries {
+		d := &model.ChannelDelivery{
+			Channel:  toGraphChannel(delivery.Channel),
+			Status:   toGraphStatus(delivery.Status),
+			Attempts: delivery.Attempts,
+		}
+		if delivery.LastError != "" {
+			d.LastError = &delivery.LastError
+		}
+		if delivery.DeliveredAt != 0 {
+			d.DeliveredAt = &delivery.DeliveredAt
+		}
+		n.Deliveries = append(n.Deliveries, d)
+	}
+	return n
+}
+
+// toGraphStatus maps a status to its GraphQL value, the proto name without
+// its prefix. UNSPECIFIED maps to an invalid value.
+func toGraphStatus(status notificationProto.NotificationStatus) model.NotificationStatus {
+	return model.NotificationStatus(strings.TrimPrefix(status.String(), "NOTIFICATION_STATUS_"))
+}
+
+// toGraphChannel maps a channel such as in_app to its GraphQL value IN_APP
+func toGraphChannel(channel string) model.DeliveryChannel {
+	return model.DeliveryChannel(strings.ToUpper(channel))
+}
+
+// toGraphNotificationConnection builds a page out of up to pageSize+1
+// notifications, the extra one only signalling that there is a next page
+func toGraphNotificationConnection(notifications []*notificationProto.Notification, pageSize int, hasPrevious bool) *model.NotificationConnection {
+	connection := &model.NotificationConnection{
+		Edges:    make([]*model.NotificationEdge, 0, min(len(notifications), pageSize)),
+		PageInfo: &model.PageInfo{HasPreviousPage: hasPrevious},
+	}
+	if len(notifications) > pageSize {
+		notifications = notifications[:pageSize]
+		connection.PageInfo.HasNextPage = true
+	}
+
+	for _, notification := range notifications {
+		connection.Edges = append(connection.Edges, &model.NotificationEdge{
+			Cursor: notification.Cursor,
+			Node:   toGraphNotification(notification),
+		})
+	}
+	if len(notifications) > 0 {
+		connection.PageInfo.StartCursor = &notifications[0].Cursor
+		connection.PageInfo.EndCursor = &notifications[len(notifications)-1].Cursor
+	}
+	return connection
+}
+
+func toGraphDeliveryAttempts(attempts []*notificationProto.DeliveryAttempt) []*model.DeliveryAttempt {
+	result := make([]*model.DeliveryAttempt, 0, len(attempts))
+	for _, attempt := range attempts {
+		a := &model.DeliveryAttempt{
+			Attempt:     attempt.Attempt,
+			AttemptedAt: attempt.AttemptedAt,
+			Error:       attempt.Error,
+		}
+		if attempt.Channel != "" {
+			channel := toGraphChannel(attempt.Channel)
+			a.Channel = &channel
+		}
+		result = append(result, a)
+	}
+	return result
+}
+
+func toGraphNotificationJob(job *notificationProto.NotificationJob) *model.NotificationJob {
+	j := &model.NotificationJob{
+		Notification: toGraphNotification(job.Notification),
+		Attempt:      job.Attempt,
+		Attempts:     toGraphDeliveryAttempts(job.Attempts),
+	}
+	if job.DueAt != 0 {
+		j.DueAt = &job.DueAt
+	}
+	return j
+}
+
+func toGraphDeadLetter(deadLetter *notificationProto.DeadLetter) *model.DeadLetter {
+	return &model.DeadLetter{
+		Notification: toGraphNotification(deadLetter.Notification),
+		Reason:       deadLetter.Reason,
+		Attempts:     toGraphDeliveryAttempts(deadLetter.Attempts),
+		FailedAt:     deadLetter.FailedAt,
+	}
+}
+
+func toGraphDeadLetterConnection(page *notificationProto.DeadLetterPage) *model.DeadLetterConnection {
+	connection := &model.DeadLetterConnection{
+		DeadLetters: make([]*model.DeadLetter, 0, len(page.DeadLetters)),
+		TotalCount:  page.TotalCount,
+		HasNextPage: page.NextCursor != "",
+	}
+	if page.NextCursor != "" {
+		connection.NextCursor = &page.NextCursor
+	}
+	for _, deadLetter := range page.DeadLetters {
+		connection.DeadLetters = append(connection.DeadLetters, toGraphDeadLetter(deadLetter))
+	}
+	return connection
+}
+
+func toGraphPreferences(preferences *notificationProto.NotificationPreferences) *model.NotificationPreferences {
+	p := &model.NotificationPreferences{
+		UserID:          preferences.UserId,
+		Channels:        make([]model.DeliveryChannel, 0, len(preferences.Channels)),
+		MutedEventTypes: make([]model.NotificationEventType, 0, len(preferences.MutedEventTypes)),
+		MutedAuthors:    preferences.MutedAuthors,
+	}
+	if p.MutedAuthors == nil {
+		p.MutedAuthors = []string{}
+	}
+	for _, channel := range preferences.Channels {
+		p.Channels = append(p.Channels, toGraphChannel(channel))
+	}
+	for _, eventType := range preferences.MutedEventTypes {
+		p.MutedEventTypes = append(p.MutedEventTypes, model.NotificationEventType(strings.ToUpper(eventType)))
+	}
+	if preferences.QuietHours != nil {
+		p.QuietHours = &model.QuietHours{
+			Start:    preferences.QuietHours.Start,
+			End:      preferences.QuietHours.End,
+			TimeZone: preferences.QuietHours.TimeZone,
+		}
+	}
+	if preferences.UpdatedAt != 0 {
+		p.UpdatedAt = &preferences.UpdatedAt
+	}
+	return p
+}
+
+func toGraphWorkerPool(pool *notificationProto.WorkerPool) *model.WorkerPool {
+	return &model.WorkerPool{
+		Workers:     pool.Workers,
+		Busy:        pool.Busy,
+		MinWorkers:  pool.MinWorkers,
+		MaxWorkers:  pool.MaxWorkers,
+		Autoscaling: pool.Autoscaling,
+	}
+}
@@ -0,0 +1,88 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.72
+
+import (
+	"context"
+
+	"github.com/iwhitebird/social-app-microservices/graph/model"
+	notification "github.com/iwhitebird/social-app-microservices/proto/generated/notification/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// ReplayDeadLetter is the resolver for the replayDeadLetter field.
+func (r *mutationResolver) ReplayDeadLetter(ctx context.Context, notificationID string) (int32, error) {
+	response, err := r.notificationClient.ReplayDeadLetter(ctx, &notification.NotificationId{NotificationId: notificationID})
+	if err != nil {
+		return 0, err
+	}
+	return response.Replayed, nil
+}
+
+// ReplayDeadLetters is the resolver for the replayDeadLetters field.
+func (r *mutationResolver) ReplayDeadLetters(ctx context.Context) (int32, error) {
+	response, err := r.notificationClient.ReplayDeadLetters(ctx, &emptypb.Empty{})
+	if err != nil {
+		return 0, err
+	}
+	return response.Replayed, nil
+}
+
+// PurgeDeadLetters is the resolver for the purgeDeadLetters field.
+func (r *mutationResolver) PurgeDeadLetters(ctx context.Context, before *int64) (int32, error) {
+	request := &notification.PurgeDeadLettersRequest{}
+	if before != nil {
+		request.Before = *before
+	}
+
+	response, err := r.notificationClient.PurgeDeadLetters(ctx, request)
+	if err != nil {
+		return 0, err
+	}
+	return response.Purged, nil
+}
+
+// DeadLetters is the resolver for the deadLetters field.
+func (r *queryResolver) DeadLetters(ctx context.Context, first *int32, after *string) (*model.DeadLetterConnection, error) {
+	request := &notification.ListDeadLettersRequest{}
+	if first != nil {
+		request.PageSize = *first
+	}
+	if after != nil {
+		request.Cursor = *after
+	}
+
+	page, err := r.notificationClient.ListDeadLetters(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return toGraphDeadLetterConnection(page), nil
+}
+
+// DeadLetter is the resolver for the deadLetter field.
+func (r *queryResolver) DeadLetter(ctx context.Context, notificationID string) (*model.DeadLetter, error) {
+	deadLetter, err := r.notificationClient.GetDeadLetter(ctx, &notification.NotificationId{NotificationId: notificationID})
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toGraphDeadLetter(deadLetter), nil
+}
+
+// NotificationJob is the resolver for the notificationJob field.
+func (r *queryResolver) NotificationJob(ctx context.Context, notificationID string) (*model.NotificationJob, error) {
+	job, err := r.notificationClient.GetNotificationJob(ctx, &notification.NotificationId{NotificationId: notificationID})
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toGraphNotificationJob(job), nil
+}
@@ -0,0 +1,62 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorPresenter turns gRPC errors from the backend services into GraphQL
+// errors whose extensions carry a machine readable code
+func ErrorPresenter(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+	if gqlErr.Err == nil {
+		return gqlErr
+	}
+
+	st, ok := status.FromError(gqlErr.Err)
+	if !ok {
+		return gqlErr
+	}
+
+	gqlErr.Message = st.Message()
+	if gqlErr.Extensions == nil {
+		gqlErr.Extensions = map[string]interface{}{}
+	}
+	gqlErr.Extensions["code"] = errorCode(st.Code())
+	gqlErr.Extensions["grpcCode"] = st.Code().String()
+	return gqlErr
+}
+
+// errorCode maps a gRPC status code to the error code reported to GraphQL clients
+func errorCode(code codes.Code) string {
+	switch code {
+	case codes.InvalidArgument, codes.OutOfRange:
+		return "BAD_USER_INPUT"
+	case codes.NotFound:
+		return "NOT_FOUND"
+	case codes.AlreadyExists, codes.Aborted:
+		return "CONFLICT"
+	case codes.FailedPrecondition:
+		return "FAILED_PRECONDITION"
+	case codes.PermissionDenied:
+		return "FORBIDDEN"
+	case codes.Unauthenticated:
+		return "UNAUTHENTICATED"
+	case codes.ResourceExhausted:
+		return "RESOURCE_EXHAUSTED"
+	case codes.Unimplemented:
+		return "NOT_IMPLEMENTED"
+	case codes.Unavailable:
+		return "SERVICE_UNAVAILABLE"
+	case codes.DeadlineExceeded:
+		return "TIMEOUT"
+	case codes.Canceled:
+		return "CANCELED"
+	default:
+		return "INTERNAL_SERVER_ERROR"
+	}
+}
@@ -35,195 +35,1274 @@ type Config struct {
 type ResolverRoot interface {
 	Mutation() MutationResolver
 	Query() QueryResolver
+	Subscription() SubscriptionResolver
+	User() UserResolver
 }
 
 type DirectiveRoot struct {
 }
 
 type ComplexityRoot struct {
+	ChannelDelivery struct {
+		Attempts    func(childComplexity int) int
+		Channel     func(childComplexity int) int
+		DeliveredAt func(childComplexity int) int
+		LastError   func(childComplexity int) int
+		Status      func(childComplexity int) int
+	}
+
+	DeadLetter struct {
+		Attempts     func(childComplexity int) int
+		FailedAt     func(childComplexity int) int
+		Notification func(childComplexity int) int
+		Reason       func(childComplexity int) int
+	}
+
+	DeadLetterConnection struct {
+		DeadLetters func(childComplexity int) int
+		HasNextPage func(childComplexity int) int
+		NextCursor  func(childComplexity int) int
+		TotalCount  func(childComplexity int) int
+	}
+
+	DeliveryAttempt struct {
+		Attempt     func(childComplexity int) int
+		AttemptedAt func(childComplexity int) int
+		Channel     func(childComplexity int) int
+		Error       func(childComplexity int) int
+	}
+
+	FanoutJob struct {
+		AuthorID       func(childComplexity int) int
+		CompletedAt    func(childComplexity int) int
+		CreatedAt      func(childComplexity int) int
+		Error          func(childComplexity int) int
+		ID             func(childComplexity int) int
+		PostID         func(childComplexity int) int
+		Processed      func(childComplexity int) int
+		Queued         func(childComplexity int) int
+		Skipped        func(childComplexity int) int
+		Status         func(childComplexity int) int
+		TotalFollowers func(childComplexity int) int
+		TurnedAway     func(childComplexity int) int
+		UpdatedAt      func(childComplexity int) int
+	}
+
+	MarkReadResult struct {
+		Marked      func(childComplexity int) int
+		UnreadCount func(childComplexity int) int
+	}
+
 	Mutation struct {
-		PublishPost func(childComplexity int, input model.PublishPostInput) int
+		CreateUser                    func(childComplexity int, input model.CreateUserInput) int
+		DeleteUser                    func(childComplexity int, id string) int
+		Follow                        func(childComplexity int, followerID string, followeeID string) int
+		MarkAllNotificationsRead      func(childComplexity int, userID string, before *int64) int
+		MarkNotificationRead          func(childComplexity int, userID string, notificationID string) int
+		MarkNotificationsRead         func(childComplexity int, userID string, notificationIDs []string) int
+		PublishPost                   func(childComplexity int, input model.PublishPostInput) int
+		PurgeDeadLetters              func(childComplexity int, before *int64) int
+		ReplayDeadLetter              func(childComplexity int, notificationID string) int
+		ReplayDeadLetters             func(childComplexity int) int
+		SetWorkerPool                 func(childComplexity int, workers int32) int
+		Unfollow                      func(childComplexity int, followerID string, followeeID string) int
+		UpdateNotificationPreferences func(childComplexity int, userID string, input model.NotificationPreferencesInput) int
+		UpdateUser                    func(childComplexity int, id string, input model.UpdateUserInput) int
+	}
+
+	Notification struct {
+		AuthorID    func(childComplexity int) int
+		Content     func(childComplexity int) int
+		CreatedAt   func(childComplexity int) int
+		DeliveredAt func(childComplexity int) int
+		Deliveries  func(childComplexity int) int
+		EventType   func(childComplexity int) int
+		ID          func(childComplexity int) int
+		LastRetry   func(childComplexity int) int
+		PostID      func(childComplexity int) int
+		PostIDs     func(childComplexity int) int
+		Read        func(childComplexity int) int
+		RetryCount  func(childComplexity int) int
+		Status      func(childComplexity int) int
+		UserID      func(childComplexity int) int
+	}
+
+	NotificationConnection struct {
+		Edges    func(childComplexity int) int
+		PageInfo func(childComplexity int) int
+	}
+
+	NotificationEdge struct {
+		Cursor func(childComplexity int) int
+		Node   func(childComplexity int) int
+	}
+
+	NotificationJob struct {
+		Attempt      func(childComplexity int) int
+		Attempts     func(childComplexity int) int
+		DueAt        func(childComplexity int) int
+		Notification func(childComplexity int) int
+	}
+
+	NotificationMetrics struct {
+		AverageDeliveryTime    func(childComplexity int) int
+		FailedAttempts         func(childComplexity int) int
+		Lanes                  func(childComplexity int) int
+		TotalNotificationsSent func(childComplexity int) int
+	}
+
+	NotificationPreferences struct {
+		Channels        func(childComplexity int) int
+		MutedAuthors    func(childComplexity int) int
+		MutedEventTypes func(childComplexity int) int
+		QuietHours      func(childComplexity int) int
+		UpdatedAt       func(childComplexity int) int
+		UserID          func(childComplexity int) int
+	}
+
+	PageInfo struct {
+		EndCursor       func(childComplexity int) int
+		HasNextPage     func(childComplexity int) int
+		HasPreviousPage func(childComplexity int) int
+		StartCursor     func(childComplexity int) int
+	}
+
+	Post struct {
+		Content   func(childComplexity int) int
+		CreatedAt func(childComplexity int) int
+		ID        func(childComplexity int) int
+		UserID    func(childComplexity int) int
+	}
+
+	PostResponse struct {
+		FanoutID            func(childComplexity int) int
+		Followers           func(childComplexity int) int
+		Message             func(childComplexity int) int
+		NotificationsQueued func(childComplexity int) int
+		PostID              func(childComplexity int) int
+		Success             func(childComplexity int) int
+	}
+
+	Query struct {
+		DeadLetter              func(childComplexity int, notificationID string) int
+		DeadLetters             func(childComplexity int, first *int32, after *string) int
+		Fanout                  func(childComplexity int, id string) int
+		GetNotificationMetrics  func(childComplexity int) int
+		GetNotifications        func(childComplexity int, userID string) int
+		NotificationJob         func(childComplexity int, notificationID string) int
+		NotificationPreferences func(childComplexity int, userID string) int
+		Notifications           func(childComplexity int, userID string, first *int32, after *string, unreadOnly *bool, since *int64, until *int64) int
+		Post                    func(childComplexity int, id string) int
+		PostsByUser             func(childComplexity int, userID string, limit *int32) int
+		UnreadNotificationCount func(childComplexity int, userID string) int
+		User                    func(childComplexity int, id string) int
+		UserByUsername          func(childComplexity int, username string) int
+		WorkerPool              func(childComplexity int) int
+	}
+
+	QueueLane struct {
+		Depth        func(childComplexity int) int
+		OldestWaitMs func(childComplexity int) int
+		Priority     func(childComplexity int) int
+		Spilled      func(childComplexity int) int
+	}
+
+	QuietHours struct {
+		End      func(childComplexity int) int
+		Start    func(childComplexity int) int
+		TimeZone func(childComplexity int) int
+	}
+
+	Subscription struct {
+		NotificationAdded func(childComplexity int, userID string, after *string) int
+	}
+
+	User struct {
+		CreatedAt func(childComplexity int) int
+		Email     func(childComplexity int) int
+		Followers func(childComplexity int, first *int32, after *string) int
+		Following func(childComplexity int, first *int32, after *string) int
+		ID        func(childComplexity int) int
+		Username  func(childComplexity int) int
+	}
+
+	UserConnection struct {
+		HasNextPage func(childComplexity int) int
+		NextCursor  func(childComplexity int) int
+		TotalCount  func(childComplexity int) int
+		Users       func(childComplexity int) int
+	}
+
+	WorkerPool struct {
+		Autoscaling func(childComplexity int) int
+		Busy        func(childComplexity int) int
+		MaxWorkers  func(childComplexity int) int
+		MinWorkers  func(childComplexity int) int
+		Workers     func(childComplexity int) int
+	}
+}
+
+type executableSchema struct {
+	schema     *ast.Schema
+	resolvers  ResolverRoot
+	directives DirectiveRoot
+	complexity ComplexityRoot
+}
+
+func (e *executableSchema) Schema() *ast.Schema {
+	if e.schema != nil {
+		return e.schema
 	}
+	return parsedSchema
+}
+
+func (e *executableSchema) Complexity(ctx context.Context, typeName, field string, childComplexity int, rawArgs map[string]any) (int, bool) {
+	ec := executionContext{nil, e, 0, 0, nil}
+	_ = ec
+	switch typeName + "." + field {
+
+	case "ChannelDelivery.attempts":
+		if e.complexity.ChannelDelivery.Attempts == nil {
+			break
+		}
+
+		return e.complexity.ChannelDelivery.Attempts(childComplexity), true
+
+	case "ChannelDelivery.channel":
+		if e.complexity.ChannelDelivery.Channel == nil {
+			break
+		}
+
+		return e.complexity.ChannelDelivery.Channel(childComplexity), true
+
+	case "ChannelDelivery.deliveredAt":
+		if e.complexity.ChannelDelivery.DeliveredAt == nil {
+			break
+		}
+
+		return e.complexity.ChannelDelivery.DeliveredAt(childComplexity), true
+
+	case "ChannelDelivery.lastError":
+		if e.complexity.ChannelDelivery.LastError == nil {
+			break
+		}
+
+		return e.complexity.ChannelDelivery.LastError(childComplexity), true
+
+	case "ChannelDelivery.status":
+		if e.complexity.ChannelDelivery.Status == nil {
+			break
+		}
+
+		return e.complexity.ChannelDelivery.Status(childComplexity), true
+
+	case "DeadLetter.attempts":
+		if e.complexity.DeadLetter.Attempts == nil {
+			break
+		}
+
+		return e.complexity.DeadLetter.Attempts(childComplexity), true
+
+	case "DeadLetter.failedAt":
+		if e.complexity.DeadLetter.FailedAt == nil {
+			break
+		}
+
+		return e.complexity.DeadLetter.FailedAt(childComplexity), true
+
+	case "DeadLetter.notification":
+		if e.complexity.DeadLetter.Notification == nil {
+			break
+		}
+
+		return e.complexity.DeadLetter.Notification(childComplexity), true
+
+	case "DeadLetter.reason":
+		if e.complexity.DeadLetter.Reason == nil {
+			break
+		}
+
+		return e.complexity.DeadLetter.Reason(childComplexity), true
+
+	case "DeadLetterConnection.deadLetters":
+		if e.complexity.DeadLetterConnection.DeadLetters == nil {
+			break
+		}
+
+		return e.complexity.DeadLetterConnection.DeadLetters(childComplexity), true
+
+	case "DeadLetterConnection.hasNextPage":
+		if e.complexity.DeadLetterConnection.HasNextPage == nil {
+			break
+		}
+
+		return e.complexity.DeadLetterConnection.HasNextPage(childComplexity), true
+
+	case "DeadLetterConnection.nextCursor":
+		if e.complexity.DeadLetterConnection.NextCursor == nil {
+			break
+		}
+
+		return e.complexity.DeadLetterConnection.NextCursor(childComplexity), true
+
+	case "DeadLetterConnection.totalCount":
+		if e.complexity.DeadLetterConnection.TotalCount == nil {
+			break
+		}
+
+		return e.complexity.DeadLetterConnection.TotalCount(childComplexity), true
+
+	case "DeliveryAttempt.attempt":
+		if e.complexity.DeliveryAttempt.Attempt == nil {
+			break
+		}
+
+		return e.complexity.DeliveryAttempt.Attempt(childComplexity), true
+
+	case "DeliveryAttempt.attemptedAt":
+		if e.complexity.DeliveryAttempt.AttemptedAt == nil {
+			break
+		}
+
+		return e.complexity.DeliveryAttempt.AttemptedAt(childComplexity), true
+
+	case "DeliveryAttempt.channel":
+		if e.complexity.DeliveryAttempt.Channel == nil {
+			break
+		}
+
+		return e.complexity.DeliveryAttempt.Channel(childComplexity), true
+
+	case "DeliveryAttempt.error":
+		if e.complexity.DeliveryAttempt.Error == nil {
+			break
+		}
+
+		return e.complexity.DeliveryAttempt.Error(childComplexity), true
+
+	case "FanoutJob.authorID":
+		if e.complexity.FanoutJob.AuthorID == nil {
+			break
+		}
+
+		return e.complexity.FanoutJob.AuthorID(childComplexity), true
+
+	case "FanoutJob.completedAt":
+		if e.complexity.FanoutJob.CompletedAt == nil {
+			break
+		}
+
+		return e.complexity.FanoutJob.CompletedAt(childComplexity), true
+
+	case "FanoutJob.createdAt":
+		if e.complexity.FanoutJob.CreatedAt == nil {
+			break
+		}
+
+		return e.complexity.FanoutJob.CreatedAt(childComplexity), true
+
+	case "FanoutJob.error":
+		if e.complexity.FanoutJob.Error == nil {
+			break
+		}
+
+		return e.complexity.FanoutJob.Error(childComplexity), true
+
+	case "FanoutJob.id":
+		if e.complexity.FanoutJob.ID == nil {
+			break
+		}
+
+		return e.complexity.FanoutJob.ID(childComplexity), true
+
+	case "FanoutJob.postID":
+		if e.complexity.FanoutJob.PostID == nil {
+			break
+		}
+
+		return e.complexity.FanoutJob.PostID(childComplexity), true
+
+	case "FanoutJob.processed":
+		if e.complexity.FanoutJob.Processed == nil {
+			break
+		}
+
+		return e.complexity.FanoutJob.Processed(childComplexity), true
+
+	case "FanoutJob.queued":
+		if e.complexity.FanoutJob.Queued == nil {
+			break
+		}
+
+		return e.complexity.FanoutJob.Queued(childComplexity), true
+
+	case "FanoutJob.skipped":
+		if e.complexity.FanoutJob.Skipped == nil {
+			break
+		}
+
+		return e.complexity.FanoutJob.Skipped(childComplexity), true
+
+	case "FanoutJob.status":
+		if e.complexity.FanoutJob.Status == nil {
+			break
+		}
+
+		return e.complexity.FanoutJob.Status(childComplexity), true
+
+	case "FanoutJob.totalFollowers":
+		if e.complexity.FanoutJob.TotalFollowers == nil {
+			break
+		}
+
+		return e.complexity.FanoutJob.TotalFollowers(childComplexity), true
+
+	case "FanoutJob.turnedAway":
+		if e.complexity.FanoutJob.TurnedAway == nil {
+			break
+		}
+
+		return e.complexity.FanoutJob.TurnedAway(childComplexity), true
+
+	case "FanoutJob.updatedAt":
+		if e.complexity.FanoutJob.UpdatedAt == nil {
+			break
+		}
+
+		return e.complexity.FanoutJob.UpdatedAt(childComplexity), true
+
+	case "MarkReadResult.marked":
+		if e.complexity.MarkReadResult.Marked == nil {
+			break
+		}
+
+		return e.complexity.MarkReadResult.Marked(childComplexity), true
+
+	case "MarkReadResult.unreadCount":
+		if e.complexity.MarkReadResult.UnreadCount == nil {
+			break
+		}
+
+		return e.complexity.MarkReadResult.UnreadCount(childComplexity), true
+
+	case "Mutation.createUser":
+		if e.complexity.Mutation.CreateUser == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_createUser_args(ctx, rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.CreateUser(childComplexity, args["input"].(model.CreateUserInput)), true
+
+	case "Mutation.deleteUser":
+		if e.complexity.Mutation.DeleteUser == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_deleteUser_args(ctx, rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.DeleteUser(childComplexity, args["id"].(string)), true
+
+	case "Mutation.follow":
+		if e.complexity.Mutation.Follow == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_follow_args(ctx, rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.Follow(childComplexity, args["followerID"].(string), args["followeeID"].(string)), true
+
+	case "Mutation.markAllNotificationsRead":
+		if e.complexity.Mutation.MarkAllNotificationsRead == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_markAllNotificationsRead_args(ctx, rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.MarkAllNotificationsRead(childComplexity, args["userID"].(string), args["before"].(*int64)), true
+
+	case "Mutation.markNotificationRead":
+		if e.complexity.Mutation.MarkNotificationRead == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_markNotificationRead_args(ctx, rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.MarkNotificationRead(childComplexity, args["userID"].(string), args["notificationID"].(string)), true
+
+	case "Mutation.markNotificationsRead":
+		if e.complexity.Mutation.MarkNotificationsRead == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_markNotificationsRead_args(ctx, rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.MarkNotificationsRead(childComplexity, args["userID"].(string), args["notificationIDs"].([]string)), true
+
+	case "Mutation.publishPost":
+		if e.complexity.Mutation.PublishPost == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_publishPost_args(ctx, rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.PublishPost(childComplexity, args["input"].(model.PublishPostInput)), true
+
+	case "Mutation.purgeDeadLetters":
+		if e.complexity.Mutation.PurgeDeadLetters == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_purgeDeadLetters_args(ctx, rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.PurgeDeadLetters(childComplexity, args["before"].(*int64)), true
+
+	case "Mutation.replayDeadLetter":
+		if e.complexity.Mutation.ReplayDeadLetter == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_replayDeadLetter_args(ctx, rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.ReplayDeadLetter(childComplexity, args["notificationID"].(string)), true
+
+	case "Mutation.replayDeadLetters":
+		if e.complexity.Mutation.ReplayDeadLetters == nil {
+			break
+		}
+
+		return e.complexity.Mutation.ReplayDeadLetters(childComplexity), true
+
+	case "Mutation.setWorkerPool":
+		if e.complexity.Mutation.SetWorkerPool == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_setWorkerPool_args(ctx, rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.SetWorkerPool(childComplexity, args["workers"].(int32)), true
+
+	case "Mutation.unfollow":
+		if e.complexity.Mutation.Unfollow == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_unfollow_args(ctx, rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.Unfollow(childComplexity, args["followerID"].(string), args["followeeID"].(string)), true
+
+	case "Mutation.updateNotificationPreferences":
+		if e.complexity.Mutation.UpdateNotificationPreferences == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_updateNotificationPreferences_args(ctx, rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.UpdateNotificationPreferences(childComplexity, args["userID"].(string), args["input"].(model.NotificationPreferencesInput)), true
+
+	case "Mutation.updateUser":
+		if e.complexity.Mutation.UpdateUser == nil {
+			break
+		}
+
+		args, err := ec.field_Mutation_updateUser_args(ctx, rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Mutation.UpdateUser(childComplexity, args["id"].(string), args["input"].(model.UpdateUserInput)), true
+
+	case "Notification.authorID":
+		if e.complexity.Notification.AuthorID == nil {
+			break
+		}
+
+		return e.complexity.Notification.AuthorID(childComplexity), true
+
+	case "Notification.content":
+		if e.complexity.Notification.Content == nil {
+			break
+		}
+
+		return e.complexity.Notification.Content(childComplexity), true
+
+	case "Notification.createdAt":
+		if e.complexity.Notification.CreatedAt == nil {
+			break
+		}
+
+		return e.complexity.Notification.CreatedAt(childComplexity), true
+
+	case "Notification.deliveredAt":
+		if e.complexity.Notification.DeliveredAt == nil {
+			break
+		}
+
+		return e.complexity.Notification.DeliveredAt(childComplexity), true
+
+	case "Notification.deliveries":
+		if e.complexity.Notification.Deliveries == nil {
+			break
+		}
+
+		return e.complexity.Notification.Deliveries(childComplexity), true
+
+	case "Notification.eventType":
+		if e.complexity.Notification.EventType == nil {
+			break
+		}
+
+		return e.complexity.Notification.EventType(childComplexity), true
+
+	case "Notification.id":
+		if e.complexity.Notification.ID == nil {
+			break
+		}
+
+		return e.complexity.Notification.ID(childComplexity), true
+
+	case "Notification.lastRetry":
+		if e.complexity.Notification.LastRetry == nil {
+			break
+		}
+
+		return e.complexity.Notification.LastRetry(childComplexity), true
+
+	case "Notification.postID":
+		if e.complexity.Notification.PostID == nil {
+			break
+		}
+
+		return e.complexity.Notification.PostID(childComplexity), true
+
+	case "Notification.postIDs":
+		if e.complexity.Notification.PostIDs == nil {
+			break
+		}
+
+		return e.complexity.Notification.PostIDs(childComplexity), true
+
+	case "Notification.read":
+		if e.complexity.Notification.Read == nil {
+			break
+		}
+
+		return e.complexity.Notification.Read(childComplexity), true
+
+	case "Notification.retryCount":
+		if e.complexity.Notification.RetryCount == nil {
+			break
+		}
+
+		return e.complexity.Notification.RetryCount(childComplexity), true
+
+	case "Notification.status":
+		if e.complexity.Notification.Status == nil {
+			break
+		}
+
+		return e.complexity.Notification.Status(childComplexity), true
+
+	case "Notification.userID":
+		if e.complexity.Notification.UserID == nil {
+			break
+		}
+
+		return e.complexity.Notification.UserID(childComplexity), true
+
+	case "NotificationConnection.edges":
+		if e.complexity.NotificationConnection.Edges == nil {
+			break
+		}
+
+		return e.complexity.NotificationConnection.Edges(childComplexity), true
+
+	case "NotificationConnection.pageInfo":
+		if e.complexity.NotificationConnection.PageInfo == nil {
+			break
+		}
+
+		return e.complexity.NotificationConnection.PageInfo(childComplexity), true
+
+	case "NotificationEdge.cursor":
+		if e.complexity.NotificationEdge.Cursor == nil {
+			break
+		}
+
+		return e.complexity.NotificationEdge.Cursor(childComplexity), true
+
+	case "NotificationEdge.node":
+		if e.complexity.NotificationEdge.Node == nil {
+			break
+		}
+
+		return e.complexity.NotificationEdge.Node(childComplexity), true
+
+	case "NotificationJob.attempt":
+		if e.complexity.NotificationJob.Attempt == nil {
+			break
+		}
+
+		return e.complexity.NotificationJob.Attempt(childComplexity), true
+
+	case "NotificationJob.attempts":
+		if e.complexity.NotificationJob.Attempts == nil {
+			break
+		}
+
+		return e.complexity.NotificationJob.Attempts(childComplexity), true
+
+	case "NotificationJob.dueAt":
+		if e.complexity.NotificationJob.DueAt == nil {
+			break
+		}
+
+		return e.complexity.NotificationJob.DueAt(childComplexity), true
+
+	case "NotificationJob.notification":
+		if e.complexity.NotificationJob.Notification == nil {
+			break
+		}
+
+		return e.complexity.NotificationJob.Notification(childComplexity), true
+
+	case "NotificationMetrics.averageDeliveryTime":
+		if e.complexity.NotificationMetrics.AverageDeliveryTime == nil {
+			break
+		}
+
+		return e.complexity.NotificationMetrics.AverageDeliveryTime(childComplexity), true
+
+	case "NotificationMetrics.failedAttempts":
+		if e.complexity.NotificationMetrics.FailedAttempts == nil {
+			break
+		}
+
+		return e.complexity.NotificationMetrics.FailedAttempts(childComplexity), true
+
+	case "NotificationMetrics.lanes":
+		if e.complexity.NotificationMetrics.Lanes == nil {
+			break
+		}
+
+		return e.complexity.NotificationMetrics.Lanes(childComplexity), true
+
+	case "NotificationMetrics.totalNotificationsSent":
+		if e.complexity.NotificationMetrics.TotalNotificationsSent == nil {
+			break
+		}
+
+		return e.complexity.NotificationMetrics.TotalNotificationsSent(childComplexity), true
+
+	case "NotificationPreferences.channels":
+		if e.complexity.NotificationPreferences.Channels == nil {
+			break
+		}
+
+		return e.complexity.NotificationPreferences.Channels(childComplexity), true
+
+	case "NotificationPreferences.mutedAuthors":
+		if e.complexity.NotificationPreferences.MutedAuthors == nil {
+			break
+		}
+
+		return e.complexity.NotificationPreferences.MutedAuthors(childComplexity), true
+
+	case "NotificationPreferences.mutedEventTypes":
+		if e.complexity.NotificationPreferences.MutedEventTypes == nil {
+			break
+		}
+
+		return e.complexity.NotificationPreferences.MutedEventTypes(childComplexity), true
+
+	case "NotificationPreferences.quietHours":
+		if e.complexity.NotificationPreferences.QuietHours == nil {
+			break
+		}
+
+		return e.complexity.NotificationPreferences.QuietHours(childComplexity), true
+
+	case "NotificationPreferences.updatedAt":
+		if e.complexity.NotificationPreferences.UpdatedAt == nil {
+			break
+		}
+
+		return e.complexity.NotificationPreferences.UpdatedAt(childComplexity), true
+
+	case "NotificationPreferences.userID":
+		if e.complexity.NotificationPreferences.UserID == nil {
+			break
+		}
+
+		return e.complexity.NotificationPreferences.UserID(childComplexity), true
+
+	case "PageInfo.endCursor":
+		if e.complexity.PageInfo.EndCursor == nil {
+			break
+		}
+
+		return e.complexity.PageInfo.EndCursor(childComplexity), true
+
+	case "PageInfo.hasNextPage":
+		if e.complexity.PageInfo.HasNextPage == nil {
+			break
+		}
+
+		return e.complexity.PageInfo.HasNextPage(childComplexity), true
+
+	case "PageInfo.hasPreviousPage":
+		if e.complexity.PageInfo.HasPreviousPage == nil {
+			break
+		}
+
+		return e.complexity.PageInfo.HasPreviousPage(childComplexity), true
+
+	case "PageInfo.startCursor":
+		if e.complexity.PageInfo.StartCursor == nil {
+			break
+		}
+
+		return e.complexity.PageInfo.StartCursor(childComplexity), true
+
+	case "Post.content":
+		if e.complexity.Post.Content == nil {
+			break
+		}
+
+		return e.complexity.Post.Content(childComplexity), true
+
+	case "Post.createdAt":
+		if e.complexity.Post.CreatedAt == nil {
+			break
+		}
+
+		return e.complexity.Post.CreatedAt(childComplexity), true
+
+	case "Post.id":
+		if e.complexity.Post.ID == nil {
+			break
+		}
+
+		return e.complexity.Post.ID(childComplexity), true
+
+	case "Post.userID":
+		if e.complexity.Post.UserID == nil {
+			break
+		}
+
+		return e.complexity.Post.UserID(childComplexity), true
+
+	case "PostResponse.fanoutID":
+		if e.complexity.PostResponse.FanoutID == nil {
+			break
+		}
+
+		return e.complexity.PostResponse.FanoutID(childComplexity), true
+
+	case "PostResponse.followers":
+		if e.complexity.PostResponse.Followers == nil {
+			break
+		}
+
+		return e.complexity.PostResponse.Followers(childComplexity), true
+
+	case "PostResponse.message":
+		if e.complexity.PostResponse.Message == nil {
+			break
+		}
+
+		return e.complexity.PostResponse.Message(childComplexity), true
+
+	case "PostResponse.notificationsQueued":
+		if e.complexity.PostResponse.NotificationsQueued == nil {
+			break
+		}
+
+		return e.complexity.PostResponse.NotificationsQueued(childComplexity), true
+
+	case "PostResponse.postID":
+		if e.complexity.PostResponse.PostID == nil {
+			break
+		}
+
+		return e.complexity.PostResponse.PostID(childComplexity), true
+
+	case "PostResponse.success":
+		if e.complexity.PostResponse.Success == nil {
+			break
+		}
+
+		return e.complexity.PostResponse.Success(childComplexity), true
+
+	case "Query.deadLetter":
+		if e.complexity.Query.DeadLetter == nil {
+			break
+		}
+
+		args, err := ec.field_Query_deadLetter_args(ctx, rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Query.DeadLetter(childComplexity, args["notificationID"].(string)), true
+
+	case "Query.deadLetters":
+		if e.complexity.Query.DeadLetters == nil {
+			break
+		}
+
+		args, err := ec.field_Query_deadLetters_args(ctx, rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Query.DeadLetters(childComplexity, args["first"].(*int32), args["after"].(*string)), true
+
+	case "Query.fanout":
+		if e.complexity.Query.Fanout == nil {
+			break
+		}
+
+		args, err := ec.field_Query_fanout_args(ctx, rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Query.Fanout(childComplexity, args["id"].(string)), true
+
+	case "Query.getNotificationMetrics":
+		if e.complexity.Query.GetNotificationMetrics == nil {
+			break
+		}
+
+		return e.complexity.Query.GetNotificationMetrics(childComplexity), true
+
+	case "Query.getNotifications":
+		if e.complexity.Query.GetNotifications == nil {
+			break
+		}
+
+		args, err := ec.field_Query_getNotifications_args(ctx, rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Query.GetNotifications(childComplexity, args["userID"].(string)), true
+
+	case "Query.notificationJob":
+		if e.complexity.Query.NotificationJob == nil {
+			break
+		}
 
-	Notification struct {
-		Content func(childComplexity int) int
-		ID      func(childComplexity int) int
-		PostID  func(childComplexity int) int
-		Read    func(childComplexity int) int
-		UserID  func(childComplexity int) int
-	}
+		args, err := ec.field_Query_notificationJob_args(ctx, rawArgs)
+		if err != nil {
+			return 0, false
+		}
 
-	NotificationMetrics struct {
-		AverageDeliveryTime    func(childComplexity int) int
-		FailedAttempts         func(childComplexity int) int
-		TotalNotificationsSent func(childComplexity int) int
-	}
+		return e.complexity.Query.NotificationJob(childComplexity, args["notificationID"].(string)), true
 
-	Post struct {
-		Content func(childComplexity int) int
-		ID      func(childComplexity int) int
-		UserID  func(childComplexity int) int
-	}
+	case "Query.notificationPreferences":
+		if e.complexity.Query.NotificationPreferences == nil {
+			break
+		}
 
-	PostResponse struct {
-		Message             func(childComplexity int) int
-		NotificationsQueued func(childComplexity int) int
-		Success             func(childComplexity int) int
-	}
+		args, err := ec.field_Query_notificationPreferences_args(ctx, rawArgs)
+		if err != nil {
+			return 0, false
+		}
 
-	Query struct {
-		GetNotificationMetrics func(childComplexity int) int
-		GetNotifications       func(childComplexity int, userID string) int
-	}
-}
+		return e.complexity.Query.NotificationPreferences(childComplexity, args["userID"].(string)), true
 
-type executableSchema struct {
-	schema     *ast.Schema
-	resolvers  ResolverRoot
-	directives DirectiveRoot
-	complexity ComplexityRoot
-}
+	case "Query.notifications":
+		if e.complexity.Query.Notifications == nil {
+			break
+		}
 
-func (e *executableSchema) Schema() *ast.Schema {
-	if e.schema != nil {
-		return e.schema
-	}
-	return parsedSchema
-}
+		args, err := ec.field_Query_notifications_args(ctx, rawArgs)
+		if err != nil {
+			return 0, false
+		}
 
-func (e *executableSchema) Complexity(ctx context.Context, typeName, field string, childComplexity int, rawArgs map[string]any) (int, bool) {
-	ec := executionContext{nil, e, 0, 0, nil}
-	_ = ec
-	switch typeName + "." + field {
+		return e.complexity.Query.Notifications(childComplexity, args["userID"].(string), args["first"].(*int32), args["after"].(*string), args["unreadOnly"].(*bool), args["since"].(*int64), args["until"].(*int64)), true
 
-	case "Mutation.publishPost":
-		if e.complexity.Mutation.PublishPost == nil {
+	case "Query.post":
+		if e.complexity.Query.Post == nil {
 			break
 		}
 
-		args, err := ec.field_Mutation_publishPost_args(ctx, rawArgs)
+		args, err := ec.field_Query_post_args(ctx, rawArgs)
 		if err != nil {
 			return 0, false
 		}
 
-		return e.complexity.Mutation.PublishPost(childComplexity, args["input"].(model.PublishPostInput)), true
+		return e.complexity.Query.Post(childComplexity, args["id"].(string)), true
 
-	case "Notification.content":
-		if e.complexity.Notification.Content == nil {
+	case "Query.postsByUser":
+		if e.complexity.Query.PostsByUser == nil {
 			break
 		}
 
-		return e.complexity.Notification.Content(childComplexity), true
+		args, err := ec.field_Query_postsByUser_args(ctx, rawArgs)
+		if err != nil {
+			return 0, false
+		}
 
-	case "Notification.id":
-		if e.complexity.Notification.ID == nil {
+		return e.complexity.Query.PostsByUser(childComplexity, args["userID"].(string), args["limit"].(*int32)), true
+
+	case "Query.unreadNotificationCount":
+		if e.complexity.Query.UnreadNotificationCount == nil {
 			break
 		}
 
-		return e.complexity.Notification.ID(childComplexity), true
+		args, err := ec.field_Query_unreadNotificationCount_args(ctx, rawArgs)
+		if err != nil {
+			return 0, false
+		}
 
-	case "Notification.postID":
-		if e.complexity.Notification.PostID == nil {
+		return e.complexity.Query.UnreadNotificationCount(childComplexity, args["userID"].(string)), true
+
+	case "Query.user":
+		if e.complexity.Query.User == nil {
 			break
 		}
 
-		return e.complexity.Notification.PostID(childComplexity), true
+		args, err := ec.field_Query_user_args(ctx, rawArgs)
+		if err != nil {
+			return 0, false
+		}
 
-	case "Notification.read":
-		if e.complexity.Notification.Read == nil {
+		return e.complexity.Query.User(childComplexity, args["id"].(string)), true
+
+	case "Query.userByUsername":
+		if e.complexity.Query.UserByUsername == nil {
 			break
 		}
 
-		return e.complexity.Notification.Read(childComplexity), true
+		args, err := ec.field_Query_userByUsername_args(ctx, rawArgs)
+		if err != nil {
+			return 0, false
+		}
 
-	case "Notification.userID":
-		if e.complexity.Notification.UserID == nil {
+		return e.complexity.Query.UserByUsername(childComplexity, args["username"].(string)), true
+
+	case "Query.workerPool":
+		if e.complexity.Query.WorkerPool == nil {
 			break
 		}
 
-		return e.complexity.Notification.UserID(childComplexity), true
+		return e.complexity.Query.WorkerPool(childComplexity), true
 
-	case "NotificationMetrics.averageDeliveryTime":
-		if e.complexity.NotificationMetrics.AverageDeliveryTime == nil {
+	case "QueueLane.depth":
+		if e.complexity.QueueLane.Depth == nil {
 			break
 		}
 
-		return e.complexity.NotificationMetrics.AverageDeliveryTime(childComplexity), true
+		return e.complexity.QueueLane.Depth(childComplexity), true
 
-	case "NotificationMetrics.failedAttempts":
-		if e.complexity.NotificationMetrics.FailedAttempts == nil {
+	case "QueueLane.oldestWaitMs":
+		if e.complexity.QueueLane.OldestWaitMs == nil {
 			break
 		}
 
-		return e.complexity.NotificationMetrics.FailedAttempts(childComplexity), true
+		return e.complexity.QueueLane.OldestWaitMs(childComplexity), true
 
-	case "NotificationMetrics.totalNotificationsSent":
-		if e.complexity.NotificationMetrics.TotalNotificationsSent == nil {
+	case "QueueLane.priority":
+		if e.complexity.QueueLane.Priority == nil {
 			break
 		}
 
-		return e.complexity.NotificationMetrics.TotalNotificationsSent(childComplexity), true
+		return e.complexity.QueueLane.Priority(childComplexity), true
 
-	case "Post.content":
-		if e.complexity.Post.Content == nil {
+	case "QueueLane.spilled":
+		if e.complexity.QueueLane.Spilled == nil {
 			break
 		}
 
-		return e.complexity.Post.Content(childComplexity), true
+		return e.complexity.QueueLane.Spilled(childComplexity), true
 
-	case "Post.id":
-		if e.complexity.Post.ID == nil {
+	case "QuietHours.end":
+		if e.complexity.QuietHours.End == nil {
 			break
 		}
 
-		return e.complexity.Post.ID(childComplexity), true
+		return e.complexity.QuietHours.End(childComplexity), true
 
-	case "Post.userID":
-		if e.complexity.Post.UserID == nil {
+	case "QuietHours.start":
+		if e.complexity.QuietHours.Start == nil {
 			break
 		}
 
-		return e.complexity.Post.UserID(childComplexity), true
+		return e.complexity.QuietHours.Start(childComplexity), true
 
-	case "PostResponse.message":
-		if e.complexity.PostResponse.Message == nil {
+	case "QuietHours.timeZone":
+		if e.complexity.QuietHours.TimeZone == nil {
 			break
 		}
 
-		return e.complexity.PostResponse.Message(childComplexity), true
+		return e.complexity.QuietHours.TimeZone(childComplexity), true
 
-	case "PostResponse.notificationsQueued":
-		if e.complexity.PostResponse.NotificationsQueued == nil {
+	case "Subscription.notificationAdded":
+		if e.complexity.Subscription.NotificationAdded == nil {
 			break
 		}
 
-		return e.complexity.PostResponse.NotificationsQueued(childComplexity), true
+		args, err := ec.field_Subscription_notificationAdded_args(ctx, rawArgs)
+		if err != nil {
+			return 0, false
+		}
 
-	case "PostResponse.success":
-		if e.complexity.PostResponse.Success == nil {
+		return e.complexity.Subscription.NotificationAdded(childComplexity, args["userID"].(string), args["after"].(*string)), true
+
+	case "User.createdAt":
+		if e.complexity.User.CreatedAt == nil {
 			break
 		}
 
-		return e.complexity.PostResponse.Success(childComplexity), true
+		return e.complexity.User.CreatedAt(childComplexity), true
 
-	case "Query.getNotificationMetrics":
-		if e.complexity.Query.GetNotificationMetrics == nil {
+	case "User.email":
+		if e.complexity.User.Email == nil {
 			break
 		}
 
-		return e.complexity.Query.GetNotificationMetrics(childComplexity), true
+		return e.complexity.User.Email(childComplexity), true
 
-	case "Query.getNotifications":
-		if e.complexity.Query.GetNotifications == nil {
+	case "User.followers":
+		if e.complexity.User.Followers == nil {
 			break
 		}
 
-		args, err := ec.field_Query_getNotifications_args(ctx, rawArgs)
+		args, err := ec.field_User_followers_args(ctx, rawArgs)
 		if err != nil {
 			return 0, false
 		}
 
-		return e.complexity.Query.GetNotifications(childComplexity, args["userID"].(string)), true
+		return e.complexity.User.Followers(childComplexity, args["first"].(*int32), args["after"].(*string)), true
+
+	case "User.following":
+		if e.complexity.User.Following == nil {
+			break
+		}
+
+		args, err := ec.field_User_following_args(ctx, rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.User.Following(childComplexity, args["first"].(*int32), args["after"].(*string)), true
+
+	case "User.id":
+		if e.complexity.User.ID == nil {
+			break
+		}
+
+		return e.complexity.User.ID(childComplexity), true
+
+	case "User.username":
+		if e.complexity.User.Username == nil {
+			break
+		}
+
+		return e.complexity.User.Username(childComplexity), true
+
+	case "UserConnection.hasNextPage":
+		if e.complexity.UserConnection.HasNextPage == nil {
+			break
+		}
+
+		return e.complexity.UserConnection.HasNextPage(childComplexity), true
+
+	case "UserConnection.nextCursor":
+		if e.complexity.UserConnection.NextCursor == nil {
+			break
+		}
+
+		return e.complexity.UserConnection.NextCursor(childComplexity), true
+
+	case "UserConnection.totalCount":
+		if e.complexity.UserConnection.TotalCount == nil {
+			break
+		}
+
+		return e.complexity.UserConnection.TotalCount(childComplexity), true
+
+	case "UserConnection.users":
+		if e.complexity.UserConnection.Users == nil {
+			break
+		}
+
+		return e.complexity.UserConnection.Users(childComplexity), true
+
+	case "WorkerPool.autoscaling":
+		if e.complexity.WorkerPool.Autoscaling == nil {
+			break
+		}
+
+		return e.complexity.WorkerPool.Autoscaling(childComplexity), true
+
+	case "WorkerPool.busy":
+		if e.complexity.WorkerPool.Busy == nil {
+			break
+		}
+
+		return e.complexity.WorkerPool.Busy(childComplexity), true
+
+	case "WorkerPool.maxWorkers":
+		if e.complexity.WorkerPool.MaxWorkers == nil {
+			break
+		}
+
+		return e.complexity.WorkerPool.MaxWorkers(childComplexity), true
+
+	case "WorkerPool.minWorkers":
+		if e.complexity.WorkerPool.MinWorkers == nil {
+			break
+		}
+
+		return e.complexity.WorkerPool.MinWorkers(childComplexity), true
+
+	case "WorkerPool.workers":
+		if e.complexity.WorkerPool.Workers == nil {
+			break
+		}
+
+		return e.complexity.WorkerPool.Workers(childComplexity), true
 
 	}
 	return 0, false
@@ -233,7 +1312,11 @@ func (e *executableSchema) Exec(ctx context.Context) graphql.ResponseHandler {
 	opCtx := graphql.GetOperationContext(ctx)
 	ec := executionContext{opCtx, e, 0, 0, make(chan graphql.DeferredResult)}
 	inputUnmarshalMap := graphql.BuildUnmarshalerMap(
+		ec.unmarshalInputCreateUserInput,
+		ec.unmarshalInputNotificationPreferencesInput,
 		ec.unmarshalInputPublishPostInput,
+		ec.unmarshalInputQuietHoursInput,
+		ec.unmarshalInputUpdateUserInput,
 	)
 	first := true
 
@@ -279,6 +1362,23 @@ func (e *executableSchema) Exec(ctx context.Context) graphql.ResponseHandler {
 			var buf bytes.Buffer
 			data.MarshalGQL(&buf)
 
+			return &graphql.Response{
+				Data: buf.Bytes(),
+			}
+		}
+	case ast.Subscription:
+		next := ec._Subscription(ctx, opCtx.Operation.SelectionSet)
+
+		var buf bytes.Buffer
+		return func(ctx context.Context) *graphql.Response {
+			buf.Reset()
+			data := next(ctx)
+
+			if data == nil {
+				return nil
+			}
+			data.MarshalGQL(&buf)
+
 			return &graphql.Response{
 				Data: buf.Bytes(),
 			}
@@ -335,12 +1435,51 @@ var sources = []*ast.Source{
   id: ID!
   userID: String!
   content: String!
+  createdAt: Int64!
 }
 
 type PostResponse {
   success: Boolean!
   message: String!
-  notificationsQueued: Int!
+  notificationsQueued: Int! @deprecated(reason: "Always 0, followers are notified in the background. Follow the progress with fanout.")
+  postID: String!
+  # Fan-out job notifying the followers, see fanout
+  fanoutID: ID!
+  # Followers of the author when the post was published
+  followers: Int!
+}
+
+# Background job notifying the followers of a post in batches
+type FanoutJob {
+  id: ID!
+  postID: String!
+  authorID: String!
+  status: FanoutStatus!
+  totalFollowers: Int!
+  processed: Int!
+  queued: Int!
+  # Followers whose preferences ruled the notification out
+  skipped: Int!
+  # Notifications the full queue turned away, each was tried again later
+  turnedAway: Int!
+  # Why the job failed, null unless it did
+  error: String
+  createdAt: Int64!
+  updatedAt: Int64!
+  completedAt: Int64
+}
+
+enum FanoutStatus {
+  PENDING
+  RUNNING
+  COMPLETED
+  FAILED
+}
+
+extend type Query {
+  post(id: ID!): Post
+  postsByUser(userID: String!, limit: Int): [Post!]!
+  fanout(id: ID!): FanoutJob
 }
 
 type Mutation {
@@ -354,8 +1493,29 @@ input PublishPostInput {
 	{Name: "../gql/notification.graphql", Input: `scalar Int64
 
 type Query {
-  getNotifications(userID: String!): [Notification!]!
+  getNotifications(userID: String!): [Notification!]! @deprecated(reason: "Use notifications, which can page through the whole history")
+  notifications(
+    userID: String!
+    first: Int
+    after: String
+    unreadOnly: Boolean
+    since: Int64
+    until: Int64
+  ): NotificationConnection!
   getNotificationMetrics: NotificationMetrics!
+  unreadNotificationCount(userID: String!): Int!
+}
+
+type Subscription {
+  # Pushes each notification delivered to the user. Pass the cursor of the
+  # last notification received as after to replay the ones missed meanwhile.
+  notificationAdded(userID: String!, after: String): NotificationEdge!
+}
+
+extend type Mutation {
+  markNotificationRead(userID: String!, notificationID: ID!): MarkReadResult!
+  markNotificationsRead(userID: String!, notificationIDs: [ID!]!): MarkReadResult!
+  markAllNotificationsRead(userID: String!, before: Int64): MarkReadResult!
 }
 
 type Notification {
@@ -364,12 +1524,247 @@ type Notification {
   postID: String!
   content: String!
   read: Boolean!
-} 
+  createdAt: Int64!
+  # Null for notifications stored before statuses were tracked
+  status: NotificationStatus
+  retryCount: Int!
+  # When the latest retry started, null before the first one
+  lastRetry: Int64
+  deliveredAt: Int64
+  # Outcome of every channel the notification went through so far
+  deliveries: [ChannelDelivery!]!
+  # User whose activity the notification is about
+  authorID: String
+  # What happened, e.g. new_post
+  eventType: String
+  # Posts an aggregate notification merges, oldest first, with postID the
+  # latest. Empty for a notification about a single post.
+  postIDs: [String!]!
+}
+
+enum DeliveryChannel {
+  IN_APP
+  WEBHOOK
+  EMAIL
+  MOCK
+}
+
+type ChannelDelivery {
+  channel: DeliveryChannel!
+  status: NotificationStatus!
+  attempts: Int!
+  # Error of the latest failed attempt, cleared once the channel delivered
+  lastError: String
+  deliveredAt: Int64
+}
+
+# Where a notification is in its delivery lifecycle
+enum NotificationStatus {
+  PENDING
+  DELIVERING
+  DELIVERED
+  # The latest attempt failed and a retry is scheduled
+  FAILED
+  # Gave up after the last retry, see deadLetters
+  DEAD_LETTERED
+}
+
+type NotificationConnection {
+  edges: [NotificationEdge!]!
+  pageInfo: PageInfo!
+}
+
+type NotificationEdge {
+  cursor: String!
+  node: Notification!
+}
+
+type PageInfo {
+  hasNextPage: Boolean!
+  hasPreviousPage: Boolean!
+  startCursor: String
+  endCursor: String
+}
+
+type MarkReadResult {
+  marked: Int!
+  unreadCount: Int!
+}
 
 type NotificationMetrics {
   totalNotificationsSent: Int64!
   failedAttempts: Int64!
   averageDeliveryTime: Float!
+  # The priority lanes of the queue, most urgent first
+  lanes: [QueueLane!]!
+}
+
+# The jobs waiting for a worker in one priority lane of the queue
+type QueueLane {
+  priority: String!
+  depth: Int64!
+  # How long the job at the head of the lane has waited
+  oldestWaitMs: Int64!
+  # Jobs waiting in the store for room in the lane
+  spilled: Int64!
+}`, BuiltIn: false},
+	{Name: "../gql/user.graphql", Input: `type User {
+  id: ID!
+  username: String!
+  email: String!
+  createdAt: Int64!
+  followers(first: Int, after: String): UserConnection!
+  following(first: Int, after: String): UserConnection!
+}
+
+type UserConnection {
+  users: [User!]!
+  totalCount: Int!
+  nextCursor: String
+  hasNextPage: Boolean!
+}
+
+extend type Query {
+  user(id: ID!): User
+  userByUsername(username: String!): User
+}
+
+extend type Mutation {
+  createUser(input: CreateUserInput!): User!
+  updateUser(id: ID!, input: UpdateUserInput!): User!
+  deleteUser(id: ID!): Boolean!
+  follow(followerID: ID!, followeeID: ID!): Boolean!
+  unfollow(followerID: ID!, followeeID: ID!): Boolean!
+}
+
+input CreateUserInput {
+  username: String!
+  email: String
+}
+
+input UpdateUserInput {
+  username: String
+  email: String
+}
+`, BuiltIn: false},
+	{Name: "../gql/dead_letter.graphql", Input: `type DeliveryAttempt {
+  attempt: Int!
+  attemptedAt: Int64!
+  # Empty if the channel delivered the notification
+  error: String!
+  channel: DeliveryChannel
+}
+
+# A notification waiting in the queue, or being delivered
+type NotificationJob {
+  notification: Notification!
+  # The attempt the job is due for, starting at 1
+  attempt: Int!
+  # When a retry is due, null for right away
+  dueAt: Int64
+  attempts: [DeliveryAttempt!]!
+}
+
+# A notification the queue gave up on after its last retry
+type DeadLetter {
+  notification: Notification!
+  reason: String!
+  attempts: [DeliveryAttempt!]!
+  failedAt: Int64!
+}
+
+type DeadLetterConnection {
+  deadLetters: [DeadLetter!]!
+  totalCount: Int!
+  nextCursor: String
+  hasNextPage: Boolean!
+}
+
+extend type Query {
+  deadLetters(first: Int, after: String): DeadLetterConnection!
+  deadLetter(notificationID: ID!): DeadLetter
+  # Null once the queue delivered or dead-lettered the notification
+  notificationJob(notificationID: ID!): NotificationJob
+}
+
+extend type Mutation {
+  # Each returns how many notifications went back into the queue
+  replayDeadLetter(notificationID: ID!): Int!
+  replayDeadLetters: Int!
+  # Deletes the dead letters that failed up to before, or all of them
+  purgeDeadLetters(before: Int64): Int!
+}`, BuiltIn: false},
+	{Name: "../gql/preferences.graphql", Input: `# What a user wants to be notified about and how
+type NotificationPreferences {
+  userID: String!
+  # Channels to deliver through, empty for the server defaults
+  channels: [DeliveryChannel!]!
+  mutedEventTypes: [NotificationEventType!]!
+  # IDs of users whose posts the user is not notified about
+  mutedAuthors: [String!]!
+  quietHours: QuietHours
+  # Null while the user has the defaults
+  updatedAt: Int64
+}
+
+enum NotificationEventType {
+  # A post by someone the user follows
+  NEW_POST
+}
+
+# A daily period during which notifications only go to the inbox
+type QuietHours {
+  # Times of day as HH:MM. A period that ends before it starts runs past
+  # midnight.
+  start: String!
+  end: String!
+  # IANA time zone name, e.g. Europe/Berlin
+  timeZone: String!
+}
+
+extend type Query {
+  # The defaults for users that never saved any preferences
+  notificationPreferences(userID: String!): NotificationPreferences!
+}
+
+extend type Mutation {
+  # Replaces the preferences of a user; fields left out go back to their
+  # defaults
+  updateNotificationPreferences(userID: String!, input: NotificationPreferencesInput!): NotificationPreferences!
+}
+
+input NotificationPreferencesInput {
+  channels: [DeliveryChannel!]
+  mutedEventTypes: [NotificationEventType!]
+  mutedAuthors: [String!]
+  quietHours: QuietHoursInput
+}
+
+input QuietHoursInput {
+  start: String!
+  end: String!
+  # Defaults to UTC
+  timeZone: String
+}`, BuiltIn: false},
+	{Name: "../gql/worker_pool.graphql", Input: `# The notification queue's worker pool
+type WorkerPool {
+  workers: Int!
+  # Workers delivering a notification right now
+  busy: Int!
+  minWorkers: Int!
+  maxWorkers: Int!
+  # False while the size is pinned through setWorkerPool
+  autoscaling: Boolean!
+}
+
+extend type Query {
+  workerPool: WorkerPool!
+}
+
+extend type Mutation {
+  # Pins the pool to between 1 and maxWorkers workers, or lets it autoscale
+  # again with 0
+  setWorkerPool(workers: Int!): WorkerPool!
 }`, BuiltIn: false},
 }
 var parsedSchema = gqlparser.MustLoadSchema(sources...)
@@ -0,0 +1,25 @@
+package graph
+
+import (
+	"context"
+	"net/http"
+)
+
+type idempotencyKeyContextKey struct{}
+
+// WithIdempotencyKey passes the Idempotency-Key header of a request on to
+// the resolvers, so that a retried publishPost does not publish twice
+func WithIdempotencyKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key := r.Header.Get("Idempotency-Key"); key != "" {
+			r = r.WithContext(context.WithValue(r.Context(), idempotencyKeyContextKey{}, key))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// idempotencyKey returns the Idempotency-Key header of the request, if any
+func idempotencyKey(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKeyContextKey{}).(string)
+	return key
+}
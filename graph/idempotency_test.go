@@ -0,0 +1,46 @@
+package graph
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iwhitebird/social-app-microservices/graph/model"
+	postProto "github.com/iwhitebird/social-app-microservices/proto/generated/post/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// fakePostClient records the posts it is asked to publish
+type fakePostClient struct {
+	postProto.PostServiceClient
+	published []*postProto.Post
+}
+
+func (c *fakePostClient) PublishPost(ctx context.Context, in *postProto.Post, opts ...grpc.CallOption) (*postProto.NotificationResponse, error) {
+	c.published = append(c.published, in)
+	return &postProto.NotificationResponse{Success: true, PostId: "p1"}, nil
+}
+
+func TestPublishPostPassesIdempotencyKey(t *testing.T) {
+	client := &fakePostClient{}
+	resolver := NewResolver(nil, client, nil)
+
+	handler := WithIdempotencyKey(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := resolver.Mutation().PublishPost(r.Context(), model.PublishPostInput{UserID: "u1", Content: "hello"})
+		require.NoError(t, err)
+	}))
+	for _, key := range []string{"k1", ""} {
+		request := httptest.NewRequest(http.MethodPost, "/query", nil)
+		if key != "" {
+			request.Header.Set("Idempotency-Key", key)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	require.Len(t, client.published, 2)
+	assert.Equal(t, "k1", client.published[0].IdempotencyKey)
+	assert.Empty(t, client.published[1].IdempotencyKey)
+}
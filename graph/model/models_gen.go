@@ -2,33 +2,149 @@
 
 package model
 
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+type ChannelDelivery struct {
+	Channel     DeliveryChannel    `json:"channel"`
+	Status      NotificationStatus `json:"status"`
+	Attempts    int32              `json:"attempts"`
+	LastError   *string            `json:"lastError,omitempty"`
+	DeliveredAt *int64             `json:"deliveredAt,omitempty"`
+}
+
+type CreateUserInput struct {
+	Username string  `json:"username"`
+	Email    *string `json:"email,omitempty"`
+}
+
+type DeadLetter struct {
+	Notification *Notification      `json:"notification"`
+	Reason       string             `json:"reason"`
+	Attempts     []*DeliveryAttempt `json:"attempts"`
+	FailedAt     int64              `json:"failedAt"`
+}
+
+type DeadLetterConnection struct {
+	DeadLetters []*DeadLetter `json:"deadLetters"`
+	TotalCount  int32         `json:"totalCount"`
+	NextCursor  *string       `json:"nextCursor,omitempty"`
+	HasNextPage bool          `json:"hasNextPage"`
+}
+
+type DeliveryAttempt struct {
+	Attempt     int32            `json:"attempt"`
+	AttemptedAt int64            `json:"attemptedAt"`
+	Error       string           `json:"error"`
+	Channel     *DeliveryChannel `json:"channel,omitempty"`
+}
+
+type FanoutJob struct {
+	ID             string       `json:"id"`
+	PostID         string       `json:"postID"`
+	AuthorID       string       `json:"authorID"`
+	Status         FanoutStatus `json:"status"`
+	TotalFollowers int32        `json:"totalFollowers"`
+	Processed      int32        `json:"processed"`
+	Queued         int32        `json:"queued"`
+	Skipped        int32        `json:"skipped"`
+	TurnedAway     int32        `json:"turnedAway"`
+	Error          *string      `json:"error,omitempty"`
+	CreatedAt      int64        `json:"createdAt"`
+	UpdatedAt      int64        `json:"updatedAt"`
+	CompletedAt    *int64       `json:"completedAt,omitempty"`
+}
+
+type MarkReadResult struct {
+	Marked      int32 `json:"marked"`
+	UnreadCount int32 `json:"unreadCount"`
+}
+
 type Mutation struct {
 }
 
 type Notification struct {
-	ID      string `json:"id"`
-	UserID  string `json:"userID"`
-	PostID  string `json:"postID"`
-	Content string `json:"content"`
-	Read    bool   `json:"read"`
+	ID          string              `json:"id"`
+	UserID      string              `json:"userID"`
+	PostID      string              `json:"postID"`
+	Content     string              `json:"content"`
+	Read        bool                `json:"read"`
+	CreatedAt   int64               `json:"createdAt"`
+	Status      *NotificationStatus `json:"status,omitempty"`
+	RetryCount  int32               `json:"retryCount"`
+	LastRetry   *int64              `json:"lastRetry,omitempty"`
+	DeliveredAt *int64              `json:"deliveredAt,omitempty"`
+	Deliveries  []*ChannelDelivery  `json:"deliveries"`
+	AuthorID    *string             `json:"authorID,omitempty"`
+	EventType   *string             `json:"eventType,omitempty"`
+	PostIDs     []string            `json:"postIDs"`
+}
+
+type NotificationConnection struct {
+	Edges    []*NotificationEdge `json:"edges"`
+	PageInfo *PageInfo           `json:"pageInfo"`
+}
+
+type NotificationEdge struct {
+	Cursor string        `json:"cursor"`
+	Node   *Notification `json:"node"`
+}
+
+type NotificationJob struct {
+	Notification *Notification      `json:"notification"`
+	Attempt      int32              `json:"attempt"`
+	DueAt        *int64             `json:"dueAt,omitempty"`
+	Attempts     []*DeliveryAttempt `json:"attempts"`
 }
 
 type NotificationMetrics struct {
-	TotalNotificationsSent int64   `json:"totalNotificationsSent"`
-	FailedAttempts         int64   `json:"failedAttempts"`
-	AverageDeliveryTime    float64 `json:"averageDeliveryTime"`
+	TotalNotificationsSent int64        `json:"totalNotificationsSent"`
+	FailedAttempts         int64        `json:"failedAttempts"`
+	AverageDeliveryTime    float64      `json:"averageDeliveryTime"`
+	Lanes                  []*QueueLane `json:"lanes"`
+}
+
+type NotificationPreferences struct {
+	UserID          string                  `json:"userID"`
+	Channels        []DeliveryChannel       `json:"channels"`
+	MutedEventTypes []NotificationEventType `json:"mutedEventTypes"`
+	MutedAuthors    []string                `json:"mutedAuthors"`
+	QuietHours      *QuietHours             `json:"quietHours,omitempty"`
+	UpdatedAt       *int64                  `json:"updatedAt,omitempty"`
+}
+
+type NotificationPreferencesInput struct {
+	Channels        []DeliveryChannel       `json:"channels,omitempty"`
+	MutedEventTypes []NotificationEventType `json:"mutedEventTypes,omitempty"`
+	MutedAuthors    []string                `json:"mutedAuthors,omitempty"`
+	QuietHours      *QuietHoursInput        `json:"quietHours,omitempty"`
+}
+
+type PageInfo struct {
+	HasNextPage     bool    `json:"hasNextPage"`
+	HasPreviousPage bool    `json:"hasPreviousPage"`
+	StartCursor     *string `json:"startCursor,omitempty"`
+	EndCursor       *string `json:"endCursor,omitempty"`
 }
 
 type Post struct {
-	ID      string `json:"id"`
-	UserID  string `json:"userID"`
-	Content string `json:"content"`
+	ID        string `json:"id"`
+	UserID    string `json:"userID"`
+	Content   string `json:"content"`
+	CreatedAt int64  `json:"createdAt"`
 }
 
 type PostResponse struct {
 	Success             bool   `json:"success"`
 	Message             string `json:"message"`
 	NotificationsQueued int32  `json:"notificationsQueued"`
+	PostID              string `json:"postID"`
+	FanoutID            string `json:"fanoutID"`
+	Followers           int32  `json:"followers"`
 }
 
 type PublishPostInput struct {
@@ -38,3 +154,286 @@ type PublishPostInput struct {
 
 type Query struct {
 }
+
+type QueueLane struct {
+	Priority     string `json:"priority"`
+	Depth        int64  `json:"depth"`
+	OldestWaitMs int64  `json:"oldestWaitMs"`
+	Spilled      int64  `json:"spilled"`
+}
+
+type QuietHours struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	TimeZone string `json:"timeZone"`
+}
+
+type QuietHoursInput struct {
+	Start    string  `json:"start"`
+	End      string  `json:"end"`
+	TimeZone *string `json:"timeZone,omitempty"`
+}
+
+type Subscription struct {
+}
+
+type UpdateUserInput struct {
+	Username *string `json:"username,omitempty"`
+	Email    *string `json:"email,omitempty"`
+}
+
+type User struct {
+	ID        string          `json:"id"`
+	Username  string          `json:"username"`
+	Email     string          `json:"email"`
+	CreatedAt int64           `json:"createdAt"`
+	Followers *UserConnection `json:"followers"`
+	Following *UserConnection `json:"following"`
+}
+
+type UserConnection struct {
+	Users       []*User `json:"users"`
+	TotalCount  int32   `json:"totalCount"`
+	NextCursor  *string `json:"nextCursor,omitempty"`
+	HasNextPage bool    `json:"hasNextPage"`
+}
+
+type WorkerPool struct {
+	Workers     int32 `json:"workers"`
+	Busy        int32 `json:"busy"`
+	MinWorkers  int32 `json:"minWorkers"`
+	MaxWorkers  int32 `json:"maxWorkers"`
+	Autoscaling bool  `json:"autoscaling"`
+}
+
+type DeliveryChannel string
+
+const (
+	DeliveryChannelInApp   DeliveryChannel = "IN_APP"
+	DeliveryChannelWebhook DeliveryChannel = "WEBHOOK"
+	DeliveryChannelEmail   DeliveryChannel = "EMAIL"
+	DeliveryChannelMock    DeliveryChannel = "MOCK"
+)
+
+var AllDeliveryChannel = []DeliveryChannel{
+	DeliveryChannelInApp,
+	DeliveryChannelWebhook,
+	DeliveryChannelEmail,
+	DeliveryChannelMock,
+}
+
+func (e DeliveryChannel) IsValid() bool {
+	switch e {
+	case DeliveryChannelInApp, DeliveryChannelWebhook, DeliveryChannelEmail, DeliveryChannelMock:
+		return true
+	}
+	return false
+}
+
+func (e DeliveryChannel) String() string {
+	return string(e)
+}
+
+func (e *DeliveryChannel) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = DeliveryChannel(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid DeliveryChannel", str)
+	}
+	return nil
+}
+
+func (e DeliveryChannel) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *DeliveryChannel) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e DeliveryChannel) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type FanoutStatus string
+
+const (
+	FanoutStatusPending   FanoutStatus = "PENDING"
+	FanoutStatusRunning   FanoutStatus = "RUNNING"
+	FanoutStatusCompleted FanoutStatus = "COMPLETED"
+	FanoutStatusFailed    FanoutStatus = "FAILED"
+)
+
+var AllFanoutStatus = []FanoutStatus{
+	FanoutStatusPending,
+	FanoutStatusRunning,
+	FanoutStatusCompleted,
+	FanoutStatusFailed,
+}
+
+func (e FanoutStatus) IsValid() bool {
+	switch e {
+	case FanoutStatusPending, FanoutStatusRunning, FanoutStatusCompleted, FanoutStatusFailed:
+		return true
+	}
+	return false
+}
+
+func (e FanoutStatus) String() string {
+	return string(e)
+}
+
+func (e *FanoutStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = FanoutStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid FanoutStatus", str)
+	}
+	return nil
+}
+
+func (e FanoutStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *FanoutStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e FanoutStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type NotificationEventType string
+
+const (
+	NotificationEventTypeNewPost NotificationEventType = "NEW_POST"
+)
+
+var AllNotificationEventType = []NotificationEventType{
+	NotificationEventTypeNewPost,
+}
+
+func (e NotificationEventType) IsValid() bool {
+	switch e {
+	case NotificationEventTypeNewPost:
+		return true
+	}
+	return false
+}
+
+func (e NotificationEventType) String() string {
+	return string(e)
+}
+
+func (e *NotificationEventType) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = NotificationEventType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid NotificationEventType", str)
+	}
+	return nil
+}
+
+func (e NotificationEventType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *NotificationEventType) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e NotificationEventType) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type NotificationStatus string
+
+const (
+	NotificationStatusPending      NotificationStatus = "PENDING"
+	NotificationStatusDelivering   NotificationStatus = "DELIVERING"
+	NotificationStatusDelivered    NotificationStatus = "DELIVERED"
+	NotificationStatusFailed       NotificationStatus = "FAILED"
+	NotificationStatusDeadLettered NotificationStatus = "DEAD_LETTERED"
+)
+
+var AllNotificationStatus = []NotificationStatus{
+	NotificationStatusPending,
+	NotificationStatusDelivering,
+	NotificationStatusDelivered,
+	NotificationStatusFailed,
+	NotificationStatusDeadLettered,
+}
+
+func (e NotificationStatus) IsValid() bool {
+	switch e {
+	case NotificationStatusPending, NotificationStatusDelivering, NotificationStatusDelivered, NotificationStatusFailed, NotificationStatusDeadLettered:
+		return true
+	}
+	return false
+}
+
+func (e NotificationStatus) String() string {
+	return string(e)
+}
+
+func (e *NotificationStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = NotificationStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid NotificationStatus", str)
+	}
+	return nil
+}
+
+func (e NotificationStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *NotificationStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e NotificationStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
@@ -6,41 +6,113 @@ package graph
 
 import (
 	"context"
-	"fmt"
 
 	graph "github.com/iwhitebird/social-app-microservices/graph/generated"
 	"github.com/iwhitebird/social-app-microservices/graph/model"
 	notification "github.com/iwhitebird/social-app-microservices/proto/generated/notification/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
+// MarkNotificationRead is the resolver for the markNotificationRead field.
+func (r *mutationResolver) MarkNotificationRead(ctx context.Context, userID string, notificationID string) (*model.MarkReadResult, error) {
+	response, err := r.notificationClient.MarkNotificationRead(ctx, &notification.MarkNotificationReadRequest{
+		UserId:         userID,
+		NotificationId: notificationID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toGraphMarkReadResult(response), nil
+}
+
+// MarkNotificationsRead is the resolver for the markNotificationsRead field.
+func (r *mutationResolver) MarkNotificationsRead(ctx context.Context, userID string, notificationIDs []string) (*model.MarkReadResult, error) {
+	response, err := r.notificationClient.MarkNotificationsRead(ctx, &notification.MarkNotificationsReadRequest{
+		UserId:          userID,
+		NotificationIds: notificationIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toGraphMarkReadResult(response), nil
+}
+
+// MarkAllNotificationsRead is the resolver for the markAllNotificationsRead field.
+func (r *mutationResolver) MarkAllNotificationsRead(ctx context.Context, userID string, before *int64) (*model.MarkReadResult, error) {
+	request := &notification.MarkAllReadRequest{UserId: userID}
+	if before != nil {
+		request.Before = *before
+	}
+
+	response, err := r.notificationClient.MarkAllRead(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return toGraphMarkReadResult(response), nil
+}
+
 // GetNotifications is the resolver for the getNotifications field.
 func (r *queryResolver) GetNotifications(ctx context.Context, userID string) ([]*model.Notification, error) {
-	fmt.Println("GetNotifications called")
-	stream, err := r.notificationClient.GetNotifications(ctx, &notification.UserId{
+	stream, err := r.notificationClient.GetNotifications(ctx, &notification.GetNotificationsRequest{
 		UserId: userID,
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	var notifications []*model.Notification
-	for {
-		notificationProto, err := stream.Recv()
-		if err != nil {
-			break
+	received, err := receiveNotifications(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	notifications := make([]*model.Notification, 0, len(received))
+	for _, n := range received {
+		notifications = append(notifications, toGraphNotification(n))
+	}
+	return notifications, nil
+}
+
+// Notifications is the resolver for the notifications field.
+func (r *queryResolver) Notifications(ctx context.Context, userID string, first *int32, after *string, unreadOnly *bool, since *int64, until *int64) (*model.NotificationConnection, error) {
+	pageSize := int32(defaultNotificationsFirst)
+	if first != nil {
+		if *first < 0 {
+			return nil, status.Error(codes.InvalidArgument, "first must not be negative")
 		}
+		pageSize = min(*first, maxNotificationsFirst)
+	}
 
-		notifications = append(notifications, &model.Notification{
-			ID:      notificationProto.Id,
-			UserID:  notificationProto.UserId,
-			PostID:  notificationProto.PostId,
-			Content: notificationProto.Content,
-			Read:    notificationProto.Read,
-		})
+	// Ask for one extra notification to find out whether there is another page
+	request := &notification.GetNotificationsRequest{
+		UserId:   userID,
+		PageSize: pageSize + 1,
+	}
+	if after != nil {
+		request.Cursor = *after
+	}
+	if unreadOnly != nil {
+		request.UnreadOnly = *unreadOnly
+	}
+	if since != nil {
+		request.Since = *since
+	}
+	if until != nil {
+		request.Until = *until
 	}
 
-	return notifications, nil
+	stream, err := r.notificationClient.GetNotifications(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	received, err := receiveNotifications(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	return toGraphNotificationConnection(received, int(pageSize), after != nil), nil
 }
 
 // GetNotificationMetrics is the resolver for the getNotificationMetrics field.
@@ -49,14 +121,60 @@ func (r *queryResolver) GetNotificationMetrics(ctx context.Context) (*model.Noti
 	if err != nil {
 		return nil, err
 	}
+	lanes := make([]*model.QueueLane, 0, len(notificationMetrics.Lanes))
+	for _, lane := range notificationMetrics.Lanes {
+		lanes = append(lanes, &model.QueueLane{
+			Priority:     lane.Priority,
+			Depth:        lane.Depth,
+			OldestWaitMs: lane.OldestWaitMs,
+			Spilled:      lane.Spilled,
+		})
+	}
 	return &model.NotificationMetrics{
 		TotalNotificationsSent: notificationMetrics.TotalNotificationsSent,
 		FailedAttempts:         notificationMetrics.FailedAttempts,
 		AverageDeliveryTime:    notificationMetrics.AverageDeliveryTime,
+		Lanes:                  lanes,
 	}, nil
 }
 
+// UnreadNotificationCount is the resolver for the unreadNotificationCount field.
+func (r *queryResolver) UnreadNotificationCount(ctx context.Context, userID string) (int32, error) {
+	unread, err := r.notificationClient.GetUnreadCount(ctx, &notification.UserId{UserId: userID})
+	if err != nil {
+		return 0, err
+	}
+	return unread.Count, nil
+}
+
+// NotificationAdded is the resolver for the notificationAdded field.
+func (r *subscriptionResolver) NotificationAdded(ctx context.Context, userID string, after *string) (<-chan *model.NotificationEdge, error) {
+	request := &notification.SubscribeNotificationsRequest{UserId: userID}
+	if after != nil {
+		request.Cursor = *after
+	}
+
+	stream, err := r.notificationClient.SubscribeNotifications(ctx, request)
+	if err == nil {
+		// The service sends its headers once the subscription is in place.
+		// A call that fails right away ends without headers, and its status
+		// comes from Recv.
+		var header metadata.MD
+		if header, err = stream.Header(); err == nil && header == nil {
+			_, err = stream.Recv()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return forwardNotifications(ctx, stream), nil
+}
+
 // Query returns graph.QueryResolver implementation.
 func (r *Resolver) Query() graph.QueryResolver { return &queryResolver{r} }
 
+// Subscription returns graph.SubscriptionResolver implementation.
+func (r *Resolver) Subscription() graph.SubscriptionResolver { return &subscriptionResolver{r} }
+
 type queryResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
@@ -0,0 +1,153 @@
+package graph
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	graph "github.com/iwhitebird/social-app-microservices/graph/generated"
+	notificationProto "github.com/iwhitebird/social-app-microservices/proto/generated/notification/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeNotificationStream hands out the notifications sent to it, then err
+// once the channel is closed
+type fakeNotificationStream struct {
+	grpc.ClientStream
+	ctx           context.Context
+	header        metadata.MD
+	notifications chan *notificationProto.Notification
+	err           error
+}
+
+func (s *fakeNotificationStream) Header() (metadata.MD, error) {
+	return s.header, nil
+}
+
+func (s *fakeNotificationStream) Recv() (*notificationProto.Notification, error) {
+	select {
+	case notification, ok := <-s.notifications:
+		if !ok {
+			return nil, s.err
+		}
+		return notification, nil
+	case <-s.ctx.Done():
+		return nil, status.FromContextError(s.ctx.Err()).Err()
+	}
+}
+
+// fakeNotificationClient subscribes every caller to the same stream and
+// records the subscriptions
+type fakeNotificationClient struct {
+	notificationProto.NotificationServiceClient
+	notifications chan *notificationProto.Notification
+	// err ends the stream once notifications is closed. Without header the
+	// subscription fails with it right away.
+	err        error
+	header     metadata.MD
+	subscribed chan *notificationProto.SubscribeNotificationsRequest
+}
+
+func newFakeNotificationClient() *fakeNotificationClient {
+	return &fakeNotificationClient{
+		notifications: make(chan *notificationProto.Notification),
+		err:           io.EOF,
+		header:        metadata.MD{},
+		subscribed:    make(chan *notificationProto.SubscribeNotificationsRequest, 1),
+	}
+}
+
+func (c *fakeNotificationClient) SubscribeNotifications(ctx context.Context, in *notificationProto.SubscribeNotificationsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[notificationProto.Notification], error) {
+	c.subscribed <- in
+	return &fakeNotificationStream{ctx: ctx, header: c.header, notifications: c.notifications, err: c.err}, nil
+}
+
+type notificationAdded struct {
+	NotificationAdded struct {
+		Cursor string
+		Node   struct {
+			ID      string
+			Content string
+		}
+	}
+}
+
+// subscribe opens a notificationAdded subscription over WebSocket, the way
+// the server serves it
+func subscribe(t *testing.T, notificationClient notificationProto.NotificationServiceClient, options ...client.Option) *client.Subscription {
+	t.Helper()
+	srv := handler.New(graph.NewExecutableSchema(graph.Config{
+		Resolvers: NewResolver(notificationClient, nil, nil),
+	}))
+	srv.SetErrorPresenter(ErrorPresenter)
+	srv.AddTransport(transport.Websocket{KeepAlivePingInterval: time.Second})
+
+	subscription := client.New(srv).Websocket(`subscription($after: String) {
+		notificationAdded(userID: "u1", after: $after) { cursor node { id content } }
+	}`, options...)
+	t.Cleanup(func() { subscription.Close() })
+	return subscription
+}
+
+func TestNotificationAdded(t *testing.T) {
+	notificationClient := newFakeNotificationClient()
+	subscription := subscribe(t, notificationClient, client.Var("after", "c1"))
+
+	// The subscription resumes after the cursor passed as after
+	subscribed := <-notificationClient.subscribed
+	assert.Equal(t, "u1", subscribed.UserId)
+	assert.Equal(t, "c1", subscribed.Cursor)
+
+	for _, notification := range []*notificationProto.Notification{
+		{Id: "n2", UserId: "u1", Content: "hello", Cursor: "c2"},
+		{Id: "n3", UserId: "u1", Content: "again", Cursor: "c3"},
+	} {
+		notificationClient.notifications <- notification
+
+		var response notificationAdded
+		require.NoError(t, subscription.Next(&response))
+		assert.Equal(t, notification.Cursor, response.NotificationAdded.Cursor)
+		assert.Equal(t, notification.Id, response.NotificationAdded.Node.ID)
+		assert.Equal(t, notification.Content, response.NotificationAdded.Node.Content)
+	}
+}
+
+func TestNotificationAddedEndsWithError(t *testing.T) {
+	notificationClient := newFakeNotificationClient()
+	notificationClient.err = status.Error(codes.ResourceExhausted, "subscriber fell behind")
+	subscription := subscribe(t, notificationClient)
+	<-notificationClient.subscribed
+	close(notificationClient.notifications)
+
+	// An error once the subscription is running is sent before it ends
+	var response notificationAdded
+	err := subscription.Next(&response)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"message":"subscriber fell behind"`)
+	assert.Contains(t, err.Error(), `"code":"RESOURCE_EXHAUSTED"`)
+}
+
+func TestNotificationAddedFailsBeforeStreaming(t *testing.T) {
+	// A subscription that fails right away ends without headers, and the
+	// client gets its status instead of an empty subscription
+	notificationClient := newFakeNotificationClient()
+	notificationClient.header = nil
+	notificationClient.err = status.Error(codes.NotFound, "user u1 not found")
+	close(notificationClient.notifications)
+	subscription := subscribe(t, notificationClient)
+
+	var response notificationAdded
+	err := subscription.Next(&response)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"message":"user u1 not found"`)
+	assert.Contains(t, err.Error(), `"code":"NOT_FOUND"`)
+}
@@ -10,13 +10,16 @@ import (
 	graph "github.com/iwhitebird/social-app-microservices/graph/generated"
 	"github.com/iwhitebird/social-app-microservices/graph/model"
 	"github.com/iwhitebird/social-app-microservices/proto/generated/post/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // PublishPost is the resolver for the publishPost field.
 func (r *mutationResolver) PublishPost(ctx context.Context, input model.PublishPostInput) (*model.PostResponse, error) {
 	response, err := r.postClient.PublishPost(ctx, &proto.Post{
-		UserId:  input.UserID,
-		Content: input.Content,
+		UserId:         input.UserID,
+		Content:        input.Content,
+		IdempotencyKey: idempotencyKey(ctx),
 	})
 	if err != nil {
 		return nil, err
@@ -25,9 +28,55 @@ func (r *mutationResolver) PublishPost(ctx context.Context, input model.PublishP
 		Success:             response.Success,
 		Message:             response.Message,
 		NotificationsQueued: response.NotificationsQueued,
+		PostID:              response.PostId,
+		FanoutID:            response.FanoutId,
+		Followers:           response.Followers,
 	}, nil
 }
 
+// Post is the resolver for the post field.
+func (r *queryResolver) Post(ctx context.Context, id string) (*model.Post, error) {
+	post, err := r.postClient.GetPost(ctx, &proto.PostId{Id: id})
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toGraphPost(post), nil
+}
+
+// PostsByUser is the resolver for the postsByUser field.
+func (r *queryResolver) PostsByUser(ctx context.Context, userID string, limit *int32) ([]*model.Post, error) {
+	request := &proto.ListPostsRequest{UserId: userID}
+	if limit != nil {
+		request.Limit = *limit
+	}
+
+	response, err := r.postClient.ListPostsByUser(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	posts := make([]*model.Post, 0, len(response.Posts))
+	for _, post := range response.Posts {
+		posts = append(posts, toGraphPost(post))
+	}
+	return posts, nil
+}
+
+// Fanout is the resolver for the fanout field.
+func (r *queryResolver) Fanout(ctx context.Context, id string) (*model.FanoutJob, error) {
+	job, err := r.postClient.GetFanout(ctx, &proto.FanoutId{Id: id})
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toGraphFanout(job), nil
+}
+
 // Mutation returns graph.MutationResolver implementation.
 func (r *Resolver) Mutation() graph.MutationResolver { return &mutationResolver{r} }
 
@@ -0,0 +1,51 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.72
+
+import (
+	"context"
+	"strings"
+
+	"github.com/iwhitebird/social-app-microservices/graph/model"
+	notification "github.com/iwhitebird/social-app-microservices/proto/generated/notification/proto"
+)
+
+// UpdateNotificationPreferences is the resolver for the updateNotificationPreferences field.
+func (r *mutationResolver) UpdateNotificationPreferences(ctx context.Context, userID string, input model.NotificationPreferencesInput) (*model.NotificationPreferences, error) {
+	request := &notification.NotificationPreferences{
+		UserId:       userID,
+		MutedAuthors: input.MutedAuthors,
+	}
+	for _, channel := range input.Channels {
+		request.Channels = append(request.Channels, strings.ToLower(string(channel)))
+	}
+	for _, eventType := range input.MutedEventTypes {
+		request.MutedEventTypes = append(request.MutedEventTypes, strings.ToLower(string(eventType)))
+	}
+	if input.QuietHours != nil {
+		request.QuietHours = &notification.QuietHours{
+			Start: input.QuietHours.Start,
+			End:   input.QuietHours.End,
+		}
+		if input.QuietHours.TimeZone != nil {
+			request.QuietHours.TimeZone = *input.QuietHours.TimeZone
+		}
+	}
+
+	preferences, err := r.notificationClient.UpdatePreferences(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return toGraphPreferences(preferences), nil
+}
+
+// NotificationPreferences is the resolver for the notificationPreferences field.
+func (r *queryResolver) NotificationPreferences(ctx context.Context, userID string) (*model.NotificationPreferences, error) {
+	preferences, err := r.notificationClient.GetPreferences(ctx, &notification.UserId{UserId: userID})
+	if err != nil {
+		return nil, err
+	}
+	return toGraphPreferences(preferences), nil
+}
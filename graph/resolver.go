@@ -7,13 +7,15 @@ package graph
 import (
 	notificationProto "github.com/iwhitebird/social-app-microservices/proto/generated/notification/proto"
 	postProto "github.com/iwhitebird/social-app-microservices/proto/generated/post/proto"
+	userProto "github.com/iwhitebird/social-app-microservices/proto/generated/user/proto"
 )
 
 type Resolver struct {
 	postClient         postProto.PostServiceClient
 	notificationClient notificationProto.NotificationServiceClient
+	userClient         userProto.UserServiceClient
 }
 
-func NewResolver(notificationClient notificationProto.NotificationServiceClient, postClient postProto.PostServiceClient) *Resolver {
-	return &Resolver{notificationClient: notificationClient, postClient: postClient}
+func NewResolver(notificationClient notificationProto.NotificationServiceClient, postClient postProto.PostServiceClient, userClient userProto.UserServiceClient) *Resolver {
+	return &Resolver{notificationClient: notificationClient, postClient: postClient, userClient: userClient}
 }
@@ -0,0 +1,65 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/iwhitebird/social-app-microservices/graph/model"
+	notificationProto "github.com/iwhitebird/social-app-microservices/proto/generated/notification/proto"
+	"google.golang.org/grpc"
+)
+
+const (
+	// defaultNotificationsFirst is the page size when a query leaves out first
+	defaultNotificationsFirst = 20
+	// maxNotificationsFirst caps the page size of the notifications query
+	maxNotificationsFirst = 100
+)
+
+// receiveNotifications drains a GetNotifications stream
+func receiveNotifications(stream grpc.ServerStreamingClient[notificationProto.Notification]) ([]*notificationProto.Notification, error) {
+	var notifications []*notificationProto.Notification
+	for {
+		notification, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return notifications, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+}
+
+// forwardNotifications feeds a SubscribeNotifications stream into a GraphQL
+// subscription. The channel is closed when either side goes away, which
+// completes the subscription; clients resubscribe with the last cursor. An
+// error that ends the stream is sent to the client first, e.g. when it fell
+// behind.
+func forwardNotifications(ctx context.Context, stream grpc.ServerStreamingClient[notificationProto.Notification]) <-chan *model.NotificationEdge {
+	edges := make(chan *model.NotificationEdge, 1)
+	go func() {
+		defer close(edges)
+		for {
+			notification, err := stream.Recv()
+			if err != nil {
+				if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+					transport.AddSubscriptionError(ctx, ErrorPresenter(ctx, err))
+				}
+				return
+			}
+
+			select {
+			case edges <- &model.NotificationEdge{
+				Cursor: notification.Cursor,
+				Node:   toGraphNotification(notification),
+			}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return edges
+}
@@ -0,0 +1,113 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.72
+
+import (
+	"context"
+
+	graph "github.com/iwhitebird/social-app-microservices/graph/generated"
+	"github.com/iwhitebird/social-app-microservices/graph/model"
+	userProto "github.com/iwhitebird/social-app-microservices/proto/generated/user/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CreateUser is the resolver for the createUser field.
+func (r *mutationResolver) CreateUser(ctx context.Context, input model.CreateUserInput) (*model.User, error) {
+	request := &userProto.CreateUserRequest{Username: input.Username}
+	if input.Email != nil {
+		request.Email = *input.Email
+	}
+
+	user, err := r.userClient.CreateUser(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return toGraphUser(user), nil
+}
+
+// UpdateUser is the resolver for the updateUser field.
+func (r *mutationResolver) UpdateUser(ctx context.Context, id string, input model.UpdateUserInput) (*model.User, error) {
+	user, err := r.userClient.UpdateUser(ctx, &userProto.UpdateUserRequest{
+		UserId:   id,
+		Username: input.Username,
+		Email:    input.Email,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toGraphUser(user), nil
+}
+
+// DeleteUser is the resolver for the deleteUser field.
+func (r *mutationResolver) DeleteUser(ctx context.Context, id string) (bool, error) {
+	if _, err := r.userClient.DeleteUser(ctx, &userProto.UserId{UserId: id}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Follow is the resolver for the follow field.
+func (r *mutationResolver) Follow(ctx context.Context, followerID string, followeeID string) (bool, error) {
+	if _, err := r.userClient.Follow(ctx, &userProto.FollowRequest{FollowerId: followerID, FolloweeId: followeeID}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Unfollow is the resolver for the unfollow field.
+func (r *mutationResolver) Unfollow(ctx context.Context, followerID string, followeeID string) (bool, error) {
+	if _, err := r.userClient.Unfollow(ctx, &userProto.FollowRequest{FollowerId: followerID, FolloweeId: followeeID}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// User is the resolver for the user field.
+func (r *queryResolver) User(ctx context.Context, id string) (*model.User, error) {
+	user, err := r.userClient.GetUser(ctx, &userProto.UserId{UserId: id})
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toGraphUser(user), nil
+}
+
+// UserByUsername is the resolver for the userByUsername field.
+func (r *queryResolver) UserByUsername(ctx context.Context, username string) (*model.User, error) {
+	user, err := r.userClient.GetUserByUsername(ctx, &userProto.Username{Username: username})
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toGraphUser(user), nil
+}
+
+// Followers is the resolver for the followers field.
+func (r *userResolver) Followers(ctx context.Context, obj *model.User, first *int32, after *string) (*model.UserConnection, error) {
+	page, err := r.userClient.ListFollowers(ctx, toListFollowsRequest(obj.ID, first, after))
+	if err != nil {
+		return nil, err
+	}
+	return toGraphUserConnection(page), nil
+}
+
+// Following is the resolver for the following field.
+func (r *userResolver) Following(ctx context.Context, obj *model.User, first *int32, after *string) (*model.UserConnection, error) {
+	page, err := r.userClient.ListFollowing(ctx, toListFollowsRequest(obj.ID, first, after))
+	if err != nil {
+		return nil, err
+	}
+	return toGraphUserConnection(page), nil
+}
+
+// User returns graph.UserResolver implementation.
+func (r *Resolver) User() graph.UserResolver { return &userResolver{r} }
+
+type userResolver struct{ *Resolver }
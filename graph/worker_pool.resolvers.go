@@ -0,0 +1,31 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.72
+
+import (
+	"context"
+
+	"github.com/iwhitebird/social-app-microservices/graph/model"
+	notification "github.com/iwhitebird/social-app-microservices/proto/generated/notification/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// SetWorkerPool is the resolver for the setWorkerPool field.
+func (r *mutationResolver) SetWorkerPool(ctx context.Context, workers int32) (*model.WorkerPool, error) {
+	pool, err := r.notificationClient.SetWorkerPool(ctx, &notification.SetWorkerPoolRequest{Workers: workers})
+	if err != nil {
+		return nil, err
+	}
+	return toGraphWorkerPool(pool), nil
+}
+
+// WorkerPool is the resolver for the workerPool field.
+func (r *queryResolver) WorkerPool(ctx context.Context) (*model.WorkerPool, error) {
+	pool, err := r.notificationClient.GetWorkerPool(ctx, &emptypb.Empty{})
+	if err != nil {
+		return nil, err
+	}
+	return toGraphWorkerPool(pool), nil
+}
@@ -3,17 +3,78 @@ package config
 import (
 	"fmt"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/iwhitebird/social-app-microservices/internal/models"
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	HTTPPort    string
-	GQLPort     string
-	GRPCPort    string
-	GRPCHost    string
-	EnabledSrvs map[string]bool
+	HTTPPort      string
+	GQLPort       string
+	GRPCPort      string
+	GRPCHost      string
+	StorageDriver string
+	SQLitePath    string
+	MaxPostLength int
+	// MinWorkers and MaxWorkers bound the notification worker pool, which
+	// is resized every ScaleInterval so due jobs wait at most about
+	// ScaleTargetWait. MaxRetries is how often a notification is attempted.
+	MinWorkers      int
+	MaxWorkers      int
+	ScaleInterval   time.Duration
+	ScaleTargetWait time.Duration
+	MaxRetries      int
+	// RetryBaseBackoff and RetryMaxBackoff bound the delay before a failed
+	// notification is retried
+	RetryBaseBackoff time.Duration
+	RetryMaxBackoff  time.Duration
+	// JobLease is how long a process holds the jobs it runs before another
+	// one sharing the database may take them over
+	JobLease time.Duration
+	// FanoutBatchSize is how many followers a fan-out job notifies between
+	// checkpoints, FanoutWorkers how many posts fan out at the same time
+	FanoutBatchSize int
+	FanoutWorkers   int
+	// IdempotencyKeyTTL is how long PublishPost remembers idempotency keys
+	IdempotencyKeyTTL time.Duration
+	// CoalesceWindow is how long notifications about one author are held to
+	// be merged, DedupWindow how long duplicates are suppressed. Zero
+	// disables either.
+	CoalesceWindow time.Duration
+	DedupWindow    time.Duration
+	// LaneWeights are the shares of the notification workers each priority
+	// lane gets, LaneMaxWait how long a job waits at most before it is
+	// taken whatever its lane. EventPriorities maps event types to lanes.
+	LaneWeights     map[models.Priority]int
+	LaneMaxWait     time.Duration
+	EventPriorities map[models.EventType]models.Priority
+	// LaneCapacity is how many due jobs each lane holds. OverflowPolicy
+	// (block, reject or spill) decides what happens to the jobs that do not
+	// fit, EnqueueTimeout is how long the block policy waits for room.
+	LaneCapacity   int
+	OverflowPolicy string
+	EnqueueTimeout time.Duration
+	// DeliveryChannels are the channels notifications go out through
+	DeliveryChannels []models.Channel
+	WebhookURL       string
+	WebhookTimeout   time.Duration
+	// SMTPAddr is the host:port of the mail server the email channel uses.
+	// SMTPUsername and SMTPPassword are left empty for servers without auth.
+	SMTPAddr     string
+	SMTPFrom     string
+	SMTPUsername string
+	SMTPPassword string
+	// MockFailureRate is the share of deliveries, from 0 to 1, the mock
+	// channel fails
+	MockFailureRate float64
+	// WSAllowedOrigins lists the origins allowed to open GraphQL WebSockets
+	// besides the server's own
+	WSAllowedOrigins []string
+	EnabledSrvs      map[string]bool
 }
 
 func Load() (*Config, error) {
@@ -23,11 +84,153 @@ func Load() (*Config, error) {
 	}
 
 	cfg := &Config{
-		HTTPPort:    getEnvWithDefault("HTTP_PORT", "3000"),
-		GQLPort:     getEnvWithDefault("GQL_PORT", "8080"),
-		GRPCPort:    getEnvWithDefault("GRPC_PORT", "50051"),
-		GRPCHost:    getEnvWithDefault("GRPC_HOST", "localhost"),
-		EnabledSrvs: make(map[string]bool),
+		HTTPPort:      getEnvWithDefault("HTTP_PORT", "3000"),
+		GQLPort:       getEnvWithDefault("GQL_PORT", "8080"),
+		GRPCPort:      getEnvWithDefault("GRPC_PORT", "50051"),
+		GRPCHost:      getEnvWithDefault("GRPC_HOST", "localhost"),
+		StorageDriver: getEnvWithDefault("STORAGE_DRIVER", "memory"),
+		SQLitePath:    getEnvWithDefault("SQLITE_PATH", "data/social.db"),
+		EnabledSrvs:   make(map[string]bool),
+	}
+
+	if cfg.StorageDriver != "memory" && cfg.StorageDriver != "sqlite" {
+		return nil, fmt.Errorf("unknown STORAGE_DRIVER %q, expected memory or sqlite", cfg.StorageDriver)
+	}
+
+	maxPostLength, err := strconv.Atoi(getEnvWithDefault("MAX_POST_LENGTH", "1000"))
+	if err != nil || maxPostLength <= 0 {
+		return nil, fmt.Errorf("MAX_POST_LENGTH must be a positive number")
+	}
+	cfg.MaxPostLength = maxPostLength
+
+	cfg.MinWorkers, err = strconv.Atoi(getEnvWithDefault("NOTIFICATION_WORKERS_MIN", "2"))
+	if err != nil || cfg.MinWorkers <= 0 {
+		return nil, fmt.Errorf("NOTIFICATION_WORKERS_MIN must be a positive number")
+	}
+	cfg.MaxWorkers, err = strconv.Atoi(getEnvWithDefault("NOTIFICATION_WORKERS_MAX", "20"))
+	if err != nil || cfg.MaxWorkers < cfg.MinWorkers {
+		return nil, fmt.Errorf("NOTIFICATION_WORKERS_MAX must be a number of at least NOTIFICATION_WORKERS_MIN")
+	}
+	cfg.ScaleInterval, err = time.ParseDuration(getEnvWithDefault("NOTIFICATION_SCALE_INTERVAL", "5s"))
+	if err != nil || cfg.ScaleInterval <= 0 {
+		return nil, fmt.Errorf("NOTIFICATION_SCALE_INTERVAL must be a positive duration, e.g. 5s")
+	}
+	cfg.ScaleTargetWait, err = time.ParseDuration(getEnvWithDefault("NOTIFICATION_SCALE_TARGET_WAIT", "1s"))
+	if err != nil || cfg.ScaleTargetWait <= 0 {
+		return nil, fmt.Errorf("NOTIFICATION_SCALE_TARGET_WAIT must be a positive duration, e.g. 1s")
+	}
+	cfg.MaxRetries, err = strconv.Atoi(getEnvWithDefault("NOTIFICATION_MAX_RETRIES", "3"))
+	if err != nil || cfg.MaxRetries <= 0 {
+		return nil, fmt.Errorf("NOTIFICATION_MAX_RETRIES must be a positive number")
+	}
+
+	cfg.RetryBaseBackoff, err = time.ParseDuration(getEnvWithDefault("RETRY_BASE_BACKOFF", "1s"))
+	if err != nil || cfg.RetryBaseBackoff <= 0 {
+		return nil, fmt.Errorf("RETRY_BASE_BACKOFF must be a positive duration, e.g. 1s")
+	}
+	cfg.RetryMaxBackoff, err = time.ParseDuration(getEnvWithDefault("RETRY_MAX_BACKOFF", "30s"))
+	if err != nil || cfg.RetryMaxBackoff < cfg.RetryBaseBackoff {
+		return nil, fmt.Errorf("RETRY_MAX_BACKOFF must be a duration of at least RETRY_BASE_BACKOFF")
+	}
+	cfg.JobLease, err = time.ParseDuration(getEnvWithDefault("JOB_LEASE", "1m"))
+	if err != nil || cfg.JobLease <= 0 {
+		return nil, fmt.Errorf("JOB_LEASE must be a positive duration, e.g. 1m")
+	}
+
+	cfg.FanoutBatchSize, err = strconv.Atoi(getEnvWithDefault("FANOUT_BATCH_SIZE", "500"))
+	if err != nil || cfg.FanoutBatchSize <= 0 {
+		return nil, fmt.Errorf("FANOUT_BATCH_SIZE must be a positive number")
+	}
+	cfg.FanoutWorkers, err = strconv.Atoi(getEnvWithDefault("FANOUT_WORKERS", "2"))
+	if err != nil || cfg.FanoutWorkers <= 0 {
+		return nil, fmt.Errorf("FANOUT_WORKERS must be a positive number")
+	}
+	cfg.IdempotencyKeyTTL, err = time.ParseDuration(getEnvWithDefault("IDEMPOTENCY_KEY_TTL", "24h"))
+	if err != nil || cfg.IdempotencyKeyTTL <= 0 {
+		return nil, fmt.Errorf("IDEMPOTENCY_KEY_TTL must be a positive duration, e.g. 24h")
+	}
+	cfg.CoalesceWindow, err = time.ParseDuration(getEnvWithDefault("NOTIFICATION_COALESCE_WINDOW", "0s"))
+	if err != nil || cfg.CoalesceWindow < 0 {
+		return nil, fmt.Errorf("NOTIFICATION_COALESCE_WINDOW must be a duration, e.g. 30s, or 0 to disable coalescing")
+	}
+	cfg.DedupWindow, err = time.ParseDuration(getEnvWithDefault("NOTIFICATION_DEDUP_WINDOW", "1h"))
+	if err != nil || cfg.DedupWindow < 0 {
+		return nil, fmt.Errorf("NOTIFICATION_DEDUP_WINDOW must be a duration, e.g. 1h, or 0 to disable deduplication")
+	}
+
+	cfg.LaneWeights = make(map[models.Priority]int)
+	for priority, weight := range pairs(getEnvWithDefault("NOTIFICATION_LANE_WEIGHTS", "high=6,normal=3,low=1")) {
+		if !slices.Contains(models.Priorities, models.Priority(priority)) {
+			return nil, fmt.Errorf("unknown priority %q in NOTIFICATION_LANE_WEIGHTS, expected high, normal or low", priority)
+		}
+		cfg.LaneWeights[models.Priority(priority)], err = strconv.Atoi(weight)
+		if err != nil || cfg.LaneWeights[models.Priority(priority)] <= 0 {
+			return nil, fmt.Errorf("NOTIFICATION_LANE_WEIGHTS must give each priority a positive weight, e.g. high=6,normal=3,low=1")
+		}
+	}
+	cfg.LaneMaxWait, err = time.ParseDuration(getEnvWithDefault("NOTIFICATION_LANE_MAX_WAIT", "30s"))
+	if err != nil || cfg.LaneMaxWait < 0 {
+		return nil, fmt.Errorf("NOTIFICATION_LANE_MAX_WAIT must be a duration, e.g. 30s, or 0 to disable starvation protection")
+	}
+	cfg.EventPriorities = make(map[models.EventType]models.Priority)
+	for eventType, priority := range pairs(getEnvWithDefault("NOTIFICATION_EVENT_PRIORITIES", "new_post=low")) {
+		if !slices.Contains(models.Priorities, models.Priority(priority)) {
+			return nil, fmt.Errorf("unknown priority %q for %s in NOTIFICATION_EVENT_PRIORITIES, expected high, normal or low", priority, eventType)
+		}
+		cfg.EventPriorities[models.EventType(eventType)] = models.Priority(priority)
+	}
+	cfg.LaneCapacity, err = strconv.Atoi(getEnvWithDefault("NOTIFICATION_LANE_CAPACITY", "1000"))
+	if err != nil || cfg.LaneCapacity <= 0 {
+		return nil, fmt.Errorf("NOTIFICATION_LANE_CAPACITY must be a positive number")
+	}
+	cfg.OverflowPolicy = getEnvWithDefault("NOTIFICATION_OVERFLOW_POLICY", "block")
+	if !slices.Contains([]string{"block", "reject", "spill"}, cfg.OverflowPolicy) {
+		return nil, fmt.Errorf("unknown NOTIFICATION_OVERFLOW_POLICY %q, expected block, reject or spill", cfg.OverflowPolicy)
+	}
+	cfg.EnqueueTimeout, err = time.ParseDuration(getEnvWithDefault("NOTIFICATION_ENQUEUE_TIMEOUT", "5s"))
+	if err != nil || cfg.EnqueueTimeout <= 0 {
+		return nil, fmt.Errorf("NOTIFICATION_ENQUEUE_TIMEOUT must be a positive duration, e.g. 5s")
+	}
+
+	for _, channel := range strings.Split(getEnvWithDefault("DELIVERY_CHANNELS", "in_app"), ",") {
+		switch channel := models.Channel(strings.TrimSpace(channel)); channel {
+		case models.ChannelInApp, models.ChannelWebhook, models.ChannelEmail, models.ChannelMock:
+			cfg.DeliveryChannels = append(cfg.DeliveryChannels, channel)
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown delivery channel %q in DELIVERY_CHANNELS, expected in_app, webhook, email or mock", channel)
+		}
+	}
+	if len(cfg.DeliveryChannels) == 0 {
+		return nil, fmt.Errorf("DELIVERY_CHANNELS must name at least one channel")
+	}
+
+	cfg.WebhookURL = os.Getenv("WEBHOOK_URL")
+	if cfg.WebhookURL == "" && slices.Contains(cfg.DeliveryChannels, models.ChannelWebhook) {
+		return nil, fmt.Errorf("WEBHOOK_URL is required for the webhook delivery channel")
+	}
+	cfg.WebhookTimeout, err = time.ParseDuration(getEnvWithDefault("WEBHOOK_TIMEOUT", "5s"))
+	if err != nil || cfg.WebhookTimeout <= 0 {
+		return nil, fmt.Errorf("WEBHOOK_TIMEOUT must be a positive duration, e.g. 5s")
+	}
+
+	cfg.SMTPAddr = os.Getenv("SMTP_ADDR")
+	cfg.SMTPFrom = os.Getenv("SMTP_FROM")
+	cfg.SMTPUsername = os.Getenv("SMTP_USERNAME")
+	cfg.SMTPPassword = os.Getenv("SMTP_PASSWORD")
+	if (cfg.SMTPAddr == "" || cfg.SMTPFrom == "") && slices.Contains(cfg.DeliveryChannels, models.ChannelEmail) {
+		return nil, fmt.Errorf("SMTP_ADDR and SMTP_FROM are required for the email delivery channel")
+	}
+
+	cfg.MockFailureRate, err = strconv.ParseFloat(getEnvWithDefault("MOCK_FAILURE_RATE", "0.1"), 64)
+	if err != nil || cfg.MockFailureRate < 0 || cfg.MockFailureRate > 1 {
+		return nil, fmt.Errorf("MOCK_FAILURE_RATE must be a number from 0 to 1, e.g. 0.1")
+	}
+
+	for _, origin := range strings.Split(os.Getenv("WS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			cfg.WSAllowedOrigins = append(cfg.WSAllowedOrigins, origin)
+		}
 	}
 
 	// Get servers from command line args
@@ -61,6 +264,19 @@ func getEnvWithDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
+// pairs parses a comma separated list of key=value pairs. An entry without
+// a value maps its key to the empty string.
+func pairs(value string) map[string]string {
+	parsed := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		key, value, _ := strings.Cut(pair, "=")
+		if key = strings.TrimSpace(key); key != "" {
+			parsed[key] = strings.TrimSpace(value)
+		}
+	}
+	return parsed
+}
+
 func (c *Config) IsServerEnabled(server string) bool {
 	return c.EnabledSrvs[server]
 }
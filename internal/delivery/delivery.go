@@ -0,0 +1,17 @@
+// Package delivery sends notifications to their users. Every channel a
+// notification can go through, in-app, webhook or email, is a Deliverer.
+package delivery
+
+import (
+	"context"
+
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+)
+
+// Deliverer sends notifications through one channel. Deliver may be called
+// again for a notification it already delivered, when the queue retries the
+// other channels of that notification or replays its job after a restart.
+type Deliverer interface {
+	Channel() models.Channel
+	Deliver(ctx context.Context, notification *models.Notification) error
+}
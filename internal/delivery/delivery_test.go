@@ -0,0 +1,245 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+	"github.com/iwhitebird/social-app-microservices/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testNotification() *models.Notification {
+	return &models.Notification{
+		ID:        "n1",
+		UserID:    "u1",
+		PostID:    "p1",
+		Content:   "alice published a new post",
+		CreatedAt: time.Unix(1700000000, 0),
+	}
+}
+
+type recordingPublisher struct {
+	published []*models.Notification
+}
+
+func (p *recordingPublisher) Publish(notification *models.Notification) {
+	p.published = append(p.published, notification)
+}
+
+func TestInApp(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	publisher := &recordingPublisher{}
+	inApp := NewInApp(store, publisher)
+	assert.Equal(t, models.ChannelInApp, inApp.Channel())
+
+	notification := testNotification()
+	require.NoError(t, inApp.Deliver(ctx, notification))
+	// A retry after the inbox was already filled still counts as delivered,
+	// without pushing the notification to subscribers again
+	require.NoError(t, inApp.Deliver(ctx, notification))
+
+	inbox, err := store.ListNotifications(ctx, "u1", 0)
+	require.NoError(t, err)
+	require.Len(t, inbox, 1)
+	assert.Equal(t, models.NotificationStatusDelivered, inbox[0].Status)
+	assert.NotNil(t, inbox[0].DeliveredAt)
+	assert.Len(t, publisher.published, 1)
+
+	// The notification handed in is left alone
+	assert.Empty(t, notification.Status)
+}
+
+func TestWebhook(t *testing.T) {
+	var status int
+	var received webhookPayload
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	webhook := NewWebhook(server.URL, time.Second)
+	assert.Equal(t, models.ChannelWebhook, webhook.Channel())
+
+	status = http.StatusNoContent
+	require.NoError(t, webhook.Deliver(context.Background(), testNotification()))
+	assert.Equal(t, webhookPayload{
+		ID:        "n1",
+		UserID:    "u1",
+		PostID:    "p1",
+		Content:   "alice published a new post",
+		CreatedAt: 1700000000,
+	}, received)
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+	assert.Equal(t, "n1", header.Get("X-Notification-ID"))
+
+	status = http.StatusInternalServerError
+	err := webhook.Deliver(context.Background(), testNotification())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "500")
+}
+
+func TestEmail(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	require.NoError(t, store.CreateUser(ctx, &models.User{ID: "u1", Username: "bob", Email: "bob@example.com"}))
+	require.NoError(t, store.CreateUser(ctx, &models.User{ID: "u2", Username: "carol"}))
+
+	var sentTo []string
+	var message string
+	email := NewEmail("smtp.example.com:25", "noreply@example.com", nil, store)
+	email.send = func(ctx context.Context, to []string, msg []byte) error {
+		sentTo = to
+		message = string(msg)
+		return nil
+	}
+	assert.Equal(t, models.ChannelEmail, email.Channel())
+
+	require.NoError(t, email.Deliver(ctx, testNotification()))
+	assert.Equal(t, []string{"bob@example.com"}, sentTo)
+	assert.Contains(t, message, "To: bob@example.com\r\n")
+	assert.Contains(t, message, "Subject: New notification\r\n")
+	assert.Contains(t, message, "X-Notification-ID: n1\r\n")
+	assert.True(t, strings.HasSuffix(message, "\r\n\r\nalice published a new post\r\n"))
+
+	// Users without an address or without an account cannot be mailed
+	notification := testNotification()
+	notification.UserID = "u2"
+	assert.ErrorContains(t, email.Deliver(ctx, notification), "no email address")
+	notification.UserID = "missing"
+	assert.ErrorIs(t, email.Deliver(ctx, notification), storage.ErrNotFound)
+
+	// SMTP errors are passed on
+	email.send = func(context.Context, []string, []byte) error {
+		return errors.New("connection refused")
+	}
+	assert.ErrorContains(t, email.Deliver(ctx, testNotification()), "connection refused")
+}
+
+// fakeSMTP serves one SMTP conversation per connection on a local port and
+// sends the mail it receives to the returned channel. With silent set it
+// accepts connections but never greets.
+func fakeSMTP(t *testing.T, silent bool) (string, <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	mails := make(chan string, 1)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+			if silent {
+				continue
+			}
+			go func() {
+				text := textproto.NewConn(conn)
+				text.PrintfLine("220 localhost ESMTP")
+				for {
+					line, err := text.ReadLine()
+					if err != nil {
+						return
+					}
+					switch command, _, _ := strings.Cut(line, " "); strings.ToUpper(command) {
+					case "DATA":
+						text.PrintfLine("354 go ahead")
+						data, err := text.ReadDotLines()
+						if err != nil {
+							return
+						}
+						mails <- strings.Join(data, "\n")
+						text.PrintfLine("250 queued")
+					case "QUIT":
+						text.PrintfLine("221 bye")
+						return
+					default:
+						text.PrintfLine("250 ok")
+					}
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String(), mails
+}
+
+func TestEmailOverSMTP(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	require.NoError(t, store.CreateUser(ctx, &models.User{ID: "u1", Username: "bob", Email: "bob@example.com"}))
+
+	addr, mails := fakeSMTP(t, false)
+	email := NewEmail(addr, "noreply@example.com", nil, store)
+	require.NoError(t, email.Deliver(ctx, testNotification()))
+	select {
+	case mail := <-mails:
+		assert.Contains(t, mail, "X-Notification-ID: n1")
+		assert.True(t, strings.HasSuffix(mail, "alice published a new post"))
+	case <-time.After(time.Second):
+		t.Fatal("no mail received")
+	}
+
+	// A server that stops answering does not hold up the delivery past
+	// its context
+	addr, _ = fakeSMTP(t, true)
+	email = NewEmail(addr, "noreply@example.com", nil, store)
+	timeout, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.ErrorIs(t, email.Deliver(timeout, testNotification()), context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestMock(t *testing.T) {
+	ctx := context.Background()
+
+	// Without options everything goes through
+	mock := NewMock(models.ChannelWebhook)
+	assert.Equal(t, models.ChannelWebhook, mock.Channel())
+	require.NoError(t, mock.Deliver(ctx, testNotification()))
+	assert.Equal(t, 1, mock.Attempts())
+	require.Len(t, mock.Delivered(), 1)
+
+	// A failure rate of one fails everything
+	mock = NewMock(models.ChannelWebhook, WithFailureRate(1))
+	assert.ErrorIs(t, mock.Deliver(ctx, testNotification()), ErrMockFailure)
+	assert.Equal(t, 1, mock.Attempts())
+	assert.Empty(t, mock.Delivered())
+
+	// Deliveries that pass are handed on, failed ones are not
+	next := NewMock(models.ChannelInApp)
+	mock = NewMock(models.ChannelInApp,
+		WithFailFunc(func(notification *models.Notification) bool { return notification.ID == "fail" }),
+		WithNext(next))
+	failing := testNotification()
+	failing.ID = "fail"
+	assert.ErrorIs(t, mock.Deliver(ctx, failing), ErrMockFailure)
+	require.NoError(t, mock.Deliver(ctx, testNotification()))
+	assert.Equal(t, 2, mock.Attempts())
+	assert.Equal(t, 1, next.Attempts())
+	require.Len(t, next.Delivered(), 1)
+	assert.Equal(t, "n1", next.Delivered()[0].ID)
+
+	// Latency gives up with the context
+	mock = NewMock(models.ChannelInApp, WithLatency(time.Hour))
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, mock.Deliver(timeout, testNotification()), context.DeadlineExceeded)
+}
@@ -0,0 +1,140 @@
+package delivery
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+	"github.com/iwhitebird/social-app-microservices/internal/storage"
+)
+
+const (
+	// emailSubject is the subject of every notification email
+	emailSubject = "New notification"
+	// emailTimeout bounds a conversation with the SMTP server, however long
+	// the context allows
+	emailTimeout = 30 * time.Second
+)
+
+// Email delivers notifications to the email address of their user over SMTP
+type Email struct {
+	addr  string
+	from  string
+	auth  smtp.Auth
+	users storage.UserRepository
+	// send is sendMail, swapped out in tests
+	send func(ctx context.Context, to []string, msg []byte) error
+}
+
+// NewEmail returns a Deliverer that sends mail from the given address
+// through the SMTP server at addr (host:port). auth may be nil for servers
+// that do not require authentication.
+func NewEmail(addr, from string, auth smtp.Auth, users storage.UserRepository) *Email {
+	d := &Email{addr: addr, from: from, auth: auth, users: users}
+	d.send = d.sendMail
+	return d
+}
+
+func (d *Email) Channel() models.Channel {
+	return models.ChannelEmail
+}
+
+func (d *Email) Deliver(ctx context.Context, notification *models.Notification) error {
+	user, err := d.users.GetUser(ctx, notification.UserID)
+	if err != nil {
+		return fmt.Errorf("look up user %s: %w", notification.UserID, err)
+	}
+	if user.Email == "" {
+		return fmt.Errorf("user %s has no email address", notification.UserID)
+	}
+	err = d.send(ctx, []string{user.Email}, d.message(user.Email, notification))
+	if err != nil && ctx.Err() != nil {
+		// Report why the connection was cut rather than the I/O error
+		return ctx.Err()
+	}
+	return err
+}
+
+// sendMail does what smtp.SendMail does, but gives up once ctx is done. The
+// SMTP client has no context of its own, so the connection is cut when ctx
+// is done, and after emailTimeout at the latest.
+func (d *Email) sendMail(ctx context.Context, to []string, msg []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", d.addr)
+	if err != nil {
+		return err
+	}
+	if err := conn.SetDeadline(time.Now().Add(emailTimeout)); err != nil {
+		conn.Close()
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	host, _, err := net.SplitHostPort(d.addr)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if d.auth != nil {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := client.Auth(d.auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(d.from); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := client.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// message builds a plain text email, with CRLF line endings as SMTP wants
+func (d *Email) message(to string, notification *models.Notification) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", d.from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", emailSubject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "X-Notification-ID: %s\r\n", notification.ID)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(notification.Content, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
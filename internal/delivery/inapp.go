@@ -0,0 +1,55 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+	"github.com/iwhitebird/social-app-microservices/internal/storage"
+)
+
+// Publisher pushes notifications to whoever is listening for them live
+type Publisher interface {
+	Publish(notification *models.Notification)
+}
+
+// InApp delivers notifications to the user's inbox, the feed the
+// notification APIs list, and publishes them to live subscribers
+type InApp struct {
+	notifications storage.NotificationRepository
+	publisher     Publisher
+}
+
+// NewInApp returns an in-app Deliverer. The publisher may be nil.
+func NewInApp(notifications storage.NotificationRepository, publisher Publisher) *InApp {
+	return &InApp{notifications: notifications, publisher: publisher}
+}
+
+func (d *InApp) Channel() models.Channel {
+	return models.ChannelInApp
+}
+
+func (d *InApp) Deliver(ctx context.Context, notification *models.Notification) error {
+	// As far as the inbox is concerned the notification has arrived, its
+	// other channels update the stored copy as they go
+	stored := *notification
+	stored.Deliveries = slices.Clone(notification.Deliveries)
+	deliveredAt := time.Now()
+	stored.Status = models.NotificationStatusDelivered
+	stored.DeliveredAt = &deliveredAt
+
+	err := d.notifications.AddNotification(ctx, &stored)
+	if errors.Is(err, storage.ErrAlreadyExists) {
+		// Delivered by an earlier attempt
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if d.publisher != nil {
+		d.publisher.Publish(&stored)
+	}
+	return nil
+}
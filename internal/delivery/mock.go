@@ -0,0 +1,130 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+)
+
+// ErrMockFailure is the error of a delivery the Mock decided to fail
+var ErrMockFailure = errors.New("mock delivery failure")
+
+// Mock is a Deliverer for tests and local runs. It fails deliveries at a
+// configurable rate, or whichever ones a fail function picks, and records
+// the ones it lets through.
+type Mock struct {
+	channel     models.Channel
+	failureRate float64
+	latency     time.Duration
+	fail        func(notification *models.Notification) bool
+	next        Deliverer
+
+	mu        sync.Mutex
+	attempts  int
+	delivered []*models.Notification
+}
+
+// MockOption configures a Mock
+type MockOption func(*Mock)
+
+// WithFailureRate makes the mock fail the given share of deliveries, from 0
+// to 1, at random
+func WithFailureRate(rate float64) MockOption {
+	return func(m *Mock) {
+		m.failureRate = rate
+	}
+}
+
+// WithLatency makes every delivery take a random time up to latency
+func WithLatency(latency time.Duration) MockOption {
+	return func(m *Mock) {
+		m.latency = latency
+	}
+}
+
+// WithFailFunc fails the deliveries fail returns true for, instead of
+// failing at random
+func WithFailFunc(fail func(notification *models.Notification) bool) MockOption {
+	return func(m *Mock) {
+		m.fail = fail
+	}
+}
+
+// WithNext hands the deliveries the mock lets through on to next, e.g. to
+// make the in-app channel flaky while still filling the inbox
+func WithNext(next Deliverer) MockOption {
+	return func(m *Mock) {
+		m.next = next
+	}
+}
+
+// NewMock returns a mock standing in for channel. Without options it
+// delivers everything right away.
+func NewMock(channel models.Channel, opts ...MockOption) *Mock {
+	m := &Mock{channel: channel}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+func (m *Mock) Channel() models.Channel {
+	return m.channel
+}
+
+func (m *Mock) Deliver(ctx context.Context, notification *models.Notification) error {
+	if m.latency > 0 {
+		select {
+		case <-time.After(time.Duration(rand.Int63n(int64(m.latency)))):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	m.mu.Lock()
+	m.attempts++
+	m.mu.Unlock()
+
+	var failed bool
+	if m.fail != nil {
+		failed = m.fail(notification)
+	} else {
+		failed = rand.Float64() < m.failureRate
+	}
+	if failed {
+		return ErrMockFailure
+	}
+
+	if m.next != nil {
+		if err := m.next.Deliver(ctx, notification); err != nil {
+			return err
+		}
+	}
+
+	delivered := *notification
+	delivered.Deliveries = slices.Clone(notification.Deliveries)
+	m.mu.Lock()
+	m.delivered = append(m.delivered, &delivered)
+	m.mu.Unlock()
+	return nil
+}
+
+// Attempts returns how many deliveries were attempted, failed or not
+func (m *Mock) Attempts() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.attempts
+}
+
+// Delivered returns copies of the notifications delivered so far, in the
+// order they were delivered
+func (m *Mock) Delivered() []*models.Notification {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*models.Notification(nil), m.delivered...)
+}
@@ -0,0 +1,73 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+)
+
+// Webhook delivers notifications by POSTing them as JSON to a URL. Any
+// response other than 2xx counts as a failed delivery.
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+// webhookPayload is the body of a webhook request
+type webhookPayload struct {
+	ID        string `json:"id"`
+	UserID    string `json:"user_id"`
+	PostID    string `json:"post_id"`
+	Content   string `json:"content"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// NewWebhook returns a Deliverer that calls url, giving up on a request
+// after timeout
+func NewWebhook(url string, timeout time.Duration) *Webhook {
+	return &Webhook{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (d *Webhook) Channel() models.Channel {
+	return models.ChannelWebhook
+}
+
+func (d *Webhook) Deliver(ctx context.Context, notification *models.Notification) error {
+	body, err := json.Marshal(webhookPayload{
+		ID:        notification.ID,
+		UserID:    notification.UserID,
+		PostID:    notification.PostID,
+		Content:   notification.Content,
+		CreatedAt: notification.CreatedAt.Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	// Lets the receiver drop the duplicates of a retried delivery
+	request.Header.Set("X-Notification-ID", notification.ID)
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	// Drain the body so the connection can be reused
+	io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %s", response.Status)
+	}
+	return nil
+}
@@ -0,0 +1,381 @@
+// Package fanout notifies the followers of an author about a new post in
+// the background. Every post gets a fan-out job that pages through the
+// author's followers in batches, queues a notification for each follower
+// whose preferences allow it, and saves a checkpoint after every batch, so
+// an interrupted job resumes where it stopped instead of starting over.
+package fanout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+	"github.com/iwhitebird/social-app-microservices/internal/queue"
+	"github.com/iwhitebird/social-app-microservices/internal/storage"
+)
+
+const (
+	// DefaultBatchSize is how many followers a job handles between checkpoints
+	DefaultBatchSize = 500
+	// DefaultWorkers is how many jobs run at the same time
+	DefaultWorkers = 2
+	// DefaultLease is how long a dispatcher holds the jobs it runs before
+	// another dispatcher sharing the store may resume them
+	DefaultLease = time.Minute
+)
+
+// queueFullRetryDelay is how long a job the notification queue turned away
+// waits before it tries again from its checkpoint
+const queueFullRetryDelay = time.Second
+
+// Dispatcher runs fan-out jobs on a pool of workers
+type Dispatcher struct {
+	follows     storage.FollowRepository
+	posts       storage.PostRepository
+	preferences storage.PreferenceRepository
+	fanouts     storage.FanoutRepository
+	queue       *queue.NotificationQueue
+	batchSize   int
+	workers     int
+	// owner identifies the dispatcher in the leases on its jobs
+	owner string
+	lease time.Duration
+
+	mu sync.Mutex
+	// pending holds the IDs of the jobs waiting for a worker, oldest first
+	pending []string
+	// wake tells an idle worker that a job is pending
+	wake         chan struct{}
+	shutdownChan chan struct{}
+	wg           sync.WaitGroup
+}
+
+// Option configures a Dispatcher
+type Option func(*Dispatcher)
+
+// WithBatchSize sets how many followers a job handles between checkpoints
+func WithBatchSize(size int) Option {
+	return func(d *Dispatcher) {
+		d.batchSize = size
+	}
+}
+
+// WithWorkers sets how many jobs run at the same time
+func WithWorkers(workers int) Option {
+	return func(d *Dispatcher) {
+		d.workers = workers
+	}
+}
+
+// WithLease sets how long the lease of the dispatcher on its jobs lasts. The
+// dispatcher renews it while it runs, so another dispatcher sharing the
+// store only resumes the jobs of one that stopped, or a lease after one
+// crashed.
+func WithLease(lease time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.lease = lease
+	}
+}
+
+// NewDispatcher creates a Dispatcher that queues notifications on queue
+func NewDispatcher(store storage.Store, queue *queue.NotificationQueue, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		follows:      store,
+		posts:        store,
+		preferences:  store,
+		fanouts:      store,
+		queue:        queue,
+		batchSize:    DefaultBatchSize,
+		workers:      DefaultWorkers,
+		owner:        uuid.New().String(),
+		lease:        DefaultLease,
+		wake:         make(chan struct{}, 1),
+		shutdownChan: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if d.lease <= 0 {
+		d.lease = DefaultLease
+	}
+	return d
+}
+
+// Start resumes the jobs a previous run left unfinished and starts the
+// workers. Jobs that another dispatcher sharing the store holds a lease on
+// are left to it, see WithLease.
+func (d *Dispatcher) Start() {
+	d.resume()
+
+	for i := 0; i < d.workers; i++ {
+		d.wg.Add(1)
+		go d.worker(i)
+	}
+	d.wg.Add(1)
+	go d.leases()
+}
+
+// Stop waits for the batches in progress. Jobs that are not done stay
+// unfinished in storage and are resumed by the next Start, or by another
+// dispatcher sharing the store.
+func (d *Dispatcher) Stop() {
+	close(d.shutdownChan)
+	d.wg.Wait()
+
+	// Let another dispatcher resume the jobs right away
+	if err := d.fanouts.RenewFanoutLeases(context.Background(), d.owner, time.Now()); err != nil {
+		log.Printf("Failed to release the leases on fan-out jobs: %v", err)
+	}
+}
+
+// resume leases the unfinished jobs that no running dispatcher holds and
+// hands them to the workers
+func (d *Dispatcher) resume() {
+	now := time.Now()
+	unfinished, err := d.fanouts.ClaimFanouts(context.Background(), d.owner, now, now.Add(d.lease))
+	if err != nil {
+		log.Printf("Failed to claim unfinished fan-out jobs: %v", err)
+	}
+	for _, job := range unfinished {
+		log.Printf("Resuming fan-out %s of post %s after %d of %d followers",
+			job.ID, job.PostID, job.Processed, job.TotalFollowers)
+		d.push(job.ID)
+	}
+}
+
+// leases renews the lease of the dispatcher on its jobs a few times per
+// lease and resumes the jobs of dispatchers whose lease ran out, until the
+// dispatcher stops
+func (d *Dispatcher) leases() {
+	defer d.wg.Done()
+	ticker := time.NewTicker(d.lease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-d.shutdownChan:
+			return
+		}
+		if err := d.fanouts.RenewFanoutLeases(context.Background(), d.owner, time.Now().Add(d.lease)); err != nil {
+			log.Printf("Failed to renew the leases on fan-out jobs: %v", err)
+		}
+		d.resume()
+	}
+}
+
+// Submit stores a new post together with a pending fan-out job for it and
+// leaves the job to the workers. If it fails, neither the post nor the job
+// exist. A post without followers gets a completed job right away.
+func (d *Dispatcher) Submit(ctx context.Context, post *models.Post) (*models.FanoutJob, error) {
+	followers, err := d.follows.CountFollowers(ctx, post.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	job := &models.FanoutJob{
+		ID:             uuid.New().String(),
+		PostID:         post.ID,
+		AuthorID:       post.UserID,
+		Status:         models.FanoutStatusPending,
+		TotalFollowers: followers,
+		Owner:          d.owner,
+		LeaseUntil:     now.Add(d.lease),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if followers == 0 {
+		job.Status = models.FanoutStatusCompleted
+		job.CompletedAt = &now
+	}
+	if err := d.fanouts.CreatePostWithFanout(ctx, post, job); err != nil {
+		return nil, err
+	}
+	if followers > 0 {
+		d.push(job.ID)
+	}
+	return job, nil
+}
+
+func (d *Dispatcher) push(jobID string) {
+	d.mu.Lock()
+	d.pending = append(d.pending, jobID)
+	d.mu.Unlock()
+	d.signal()
+}
+
+// next takes the oldest pending job, if there is one
+func (d *Dispatcher) next() (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.pending) == 0 {
+		return "", false
+	}
+	jobID := d.pending[0]
+	d.pending = d.pending[1:]
+	if len(d.pending) > 0 {
+		// Pass the wake-up on, so idle workers pick up the rest
+		d.signal()
+	}
+	return jobID, true
+}
+
+func (d *Dispatcher) signal() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *Dispatcher) worker(id int) {
+	defer d.wg.Done()
+	log.Printf("Fan-out worker %d started", id)
+
+	for {
+		if jobID, ok := d.next(); ok {
+			d.run(jobID)
+			continue
+		}
+		select {
+		case <-d.wake:
+		case <-d.shutdownChan:
+			log.Printf("Fan-out worker %d shutting down", id)
+			return
+		}
+	}
+}
+
+// run works through the followers of a job one batch at a time, starting
+// after its checkpoint. While the notification queue is full, it waits and
+// tries again from the checkpoint.
+func (d *Dispatcher) run(jobID string) {
+	ctx := context.Background()
+	job, err := d.fanouts.GetFanout(ctx, jobID)
+	if err != nil {
+		log.Printf("Failed to load fan-out job %s: %v", jobID, err)
+		return
+	}
+	post, err := d.posts.GetPost(ctx, job.PostID)
+	if err != nil {
+		d.fail(job, fmt.Errorf("load post %s: %w", job.PostID, err))
+		return
+	}
+
+	for {
+		select {
+		case <-d.shutdownChan:
+			// Resumed from the last checkpoint on the next start
+			return
+		default:
+		}
+
+		done, err := d.batch(ctx, job, post)
+		switch {
+		case errors.Is(err, queue.ErrQueueFull):
+			log.Printf("Fan-out %s of post %s waits for room in the notification queue after %d of %d followers",
+				job.ID, job.PostID, job.Processed, job.TotalFollowers)
+			select {
+			case <-time.After(queueFullRetryDelay):
+			case <-d.shutdownChan:
+				return
+			}
+		case err != nil:
+			d.fail(job, err)
+			return
+		case done:
+			return
+		}
+	}
+}
+
+// batch queues the notifications of the next batch of followers after the
+// job's checkpoint and saves a new checkpoint, reporting whether that was
+// the last batch. When the queue turns a notification away, the checkpoint
+// is saved right before that follower and the error returned.
+func (d *Dispatcher) batch(ctx context.Context, job *models.FanoutJob, post *models.Post) (bool, error) {
+	job.Status = models.FanoutStatusRunning
+	followers, err := d.follows.ListFollowers(ctx, job.AuthorID, job.Cursor, d.batchSize)
+	if err != nil {
+		return false, fmt.Errorf("list followers: %w", err)
+	}
+	preferences, err := d.preferences.ListPreferences(ctx, followers)
+	if err != nil {
+		return false, fmt.Errorf("load notification preferences: %w", err)
+	}
+
+	now := time.Now()
+	defaultChannels := d.queue.DefaultChannels()
+	for _, followerID := range followers {
+		channels, notify := notificationChannels(preferences[followerID], models.EventTypeNewPost, job.AuthorID, now, defaultChannels)
+		if notify {
+			err := d.queue.EnqueueNotification(ctx, &models.Notification{
+				ID:        notificationID(job.ID, followerID),
+				UserID:    followerID,
+				PostID:    post.ID,
+				AuthorID:  post.UserID,
+				EventType: models.EventTypeNewPost,
+				Content:   fmt.Sprintf("%s posted: %s", post.UserID, post.Content),
+				CreatedAt: now,
+				Channels:  channels,
+			})
+			if err != nil {
+				if errors.Is(err, queue.ErrQueueFull) {
+					job.TurnedAway++
+				}
+				job.UpdatedAt = now
+				d.save(job)
+				return false, err
+			}
+			job.Queued++
+		} else {
+			job.Skipped++
+		}
+		job.Processed++
+		job.Cursor = followerID
+	}
+
+	job.UpdatedAt = now
+	if len(followers) < d.batchSize {
+		job.Status = models.FanoutStatusCompleted
+		job.CompletedAt = &now
+		d.save(job)
+		log.Printf("Fan-out %s of post %s queued %d notifications, %d followers skipped by their preferences",
+			job.ID, job.PostID, job.Queued, job.Skipped)
+		return true, nil
+	}
+	d.save(job)
+	return false, nil
+}
+
+// notificationID derives the ID of the notification a job queues for a
+// follower. A batch that runs again after a crash overwrites the journal
+// entries and inbox rows it wrote before instead of adding duplicates.
+func notificationID(jobID, followerID string) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte("fanout:"+jobID+":"+followerID)).String()
+}
+
+// save stores a checkpoint under the lease of the dispatcher. If that
+// fails, the job carries on and a restart redoes the batches since the last
+// checkpoint that was saved.
+func (d *Dispatcher) save(job *models.FanoutJob) {
+	job.Owner = d.owner
+	job.LeaseUntil = time.Now().Add(d.lease)
+	if err := d.fanouts.SaveFanout(context.Background(), job); err != nil {
+		log.Printf("Failed to save fan-out job %s: %v", job.ID, err)
+	}
+}
+
+func (d *Dispatcher) fail(job *models.FanoutJob, err error) {
+	log.Printf("Fan-out %s of post %s failed: %v", job.ID, job.PostID, err)
+	job.Status = models.FanoutStatusFailed
+	job.Error = err.Error()
+	job.UpdatedAt = time.Now()
+	d.save(job)
+}
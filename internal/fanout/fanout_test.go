@@ -0,0 +1,274 @@
+package fanout_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/iwhitebird/social-app-microservices/internal/delivery"
+	"github.com/iwhitebird/social-app-microservices/internal/fanout"
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+	"github.com/iwhitebird/social-app-microservices/internal/queue"
+	"github.com/iwhitebird/social-app-microservices/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setup creates an author with followers f1 to f<followers> and a post by them
+func setup(t *testing.T, store storage.Store, followers int) *models.Post {
+	t.Helper()
+	ctx := context.Background()
+	require.NoError(t, store.CreateUser(ctx, &models.User{ID: "author", Username: "author"}))
+	for i := 1; i <= followers; i++ {
+		require.NoError(t, store.Follow(ctx, fmt.Sprintf("f%d", i), "author"))
+	}
+	post := &models.Post{ID: "p1", UserID: "author", Content: "hello", CreatedAt: time.Now()}
+	require.NoError(t, store.SavePost(ctx, post))
+	return post
+}
+
+func startQueue(t *testing.T, store storage.Store) *queue.NotificationQueue {
+	t.Helper()
+	notificationQueue := queue.NewNotificationQueue(store, 3, 2)
+	notificationQueue.Start()
+	t.Cleanup(notificationQueue.Stop)
+	return notificationQueue
+}
+
+// waitFor waits until a job is no longer pending or running
+func waitFor(t *testing.T, store storage.Store, jobID string) *models.FanoutJob {
+	t.Helper()
+	var job *models.FanoutJob
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = store.GetFanout(context.Background(), jobID)
+		require.NoError(t, err)
+		return job.Status == models.FanoutStatusCompleted || job.Status == models.FanoutStatusFailed
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+func inboxSize(t *testing.T, store storage.Store, userID string) int {
+	t.Helper()
+	inbox, err := store.ListNotifications(context.Background(), userID, 0)
+	require.NoError(t, err)
+	return len(inbox)
+}
+
+func TestDispatcherNotifiesFollowersInBatches(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	post := setup(t, store, 7)
+	require.NoError(t, store.SavePreferences(ctx, &models.NotificationPreferences{
+		UserID:       "f2",
+		MutedAuthors: []string{"author"},
+	}))
+
+	dispatcher := fanout.NewDispatcher(store, startQueue(t, store), fanout.WithBatchSize(3))
+	dispatcher.Start()
+	defer dispatcher.Stop()
+
+	job, err := dispatcher.Submit(ctx, post)
+	require.NoError(t, err)
+	assert.Equal(t, models.FanoutStatusPending, job.Status)
+	assert.Equal(t, 7, job.TotalFollowers)
+
+	job = waitFor(t, store, job.ID)
+	assert.Equal(t, models.FanoutStatusCompleted, job.Status)
+	assert.Equal(t, 7, job.Processed)
+	assert.Equal(t, 6, job.Queued)
+	assert.Equal(t, 1, job.Skipped)
+	assert.Equal(t, "f7", job.Cursor)
+	assert.NotNil(t, job.CompletedAt)
+	assert.Empty(t, job.Error)
+
+	// Every follower but the one that muted the author is notified once
+	require.Eventually(t, func() bool {
+		for _, id := range []string{"f1", "f3", "f4", "f5", "f6", "f7"} {
+			if inboxSize(t, store, id) != 1 {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Zero(t, inboxSize(t, store, "f2"))
+
+	unfinished, err := store.ListUnfinishedFanouts(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, unfinished)
+}
+
+func TestDispatcherResumesFromCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	post := setup(t, store, 6)
+
+	// A previous run got through the first batch before it stopped
+	now := time.Now()
+	require.NoError(t, store.SaveFanout(ctx, &models.FanoutJob{
+		ID:             "job1",
+		PostID:         post.ID,
+		AuthorID:       post.UserID,
+		Status:         models.FanoutStatusRunning,
+		Cursor:         "f3",
+		TotalFollowers: 6,
+		Processed:      3,
+		Queued:         3,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}))
+
+	dispatcher := fanout.NewDispatcher(store, startQueue(t, store), fanout.WithBatchSize(3))
+	dispatcher.Start()
+	defer dispatcher.Stop()
+
+	job := waitFor(t, store, "job1")
+	assert.Equal(t, models.FanoutStatusCompleted, job.Status)
+	assert.Equal(t, 6, job.Processed)
+	assert.Equal(t, 6, job.Queued)
+
+	// Only the followers after the checkpoint are notified
+	require.Eventually(t, func() bool {
+		return inboxSize(t, store, "f4") == 1 && inboxSize(t, store, "f5") == 1 && inboxSize(t, store, "f6") == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	for _, id := range []string{"f1", "f2", "f3"} {
+		assert.Zero(t, inboxSize(t, store, id), id)
+	}
+}
+
+func TestDispatcherFailsJobsOfMissingPosts(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	setup(t, store, 2)
+
+	now := time.Now()
+	require.NoError(t, store.SaveFanout(ctx, &models.FanoutJob{
+		ID:        "job1",
+		PostID:    "missing",
+		AuthorID:  "author",
+		Status:    models.FanoutStatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}))
+
+	dispatcher := fanout.NewDispatcher(store, startQueue(t, store))
+	dispatcher.Start()
+	defer dispatcher.Stop()
+
+	job := waitFor(t, store, "job1")
+	assert.Equal(t, models.FanoutStatusFailed, job.Status)
+	assert.Contains(t, job.Error, "load post missing")
+	assert.Zero(t, job.Processed)
+}
+
+func TestDispatcherWaitsForRoomInTheQueue(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	post := setup(t, store, 4)
+
+	// A single worker stuck on a first notification and room for one more
+	gate := make(chan struct{})
+	stuck := delivery.NewMock(models.ChannelInApp, delivery.WithFailFunc(func(*models.Notification) bool {
+		<-gate
+		return false
+	}))
+	notificationQueue := queue.NewNotificationQueue(store, 1, 1,
+		queue.WithDeliverers(stuck),
+		queue.WithOverflow(queue.OverflowReject, 1, 0))
+	notificationQueue.Start()
+	defer notificationQueue.Stop()
+	require.NoError(t, notificationQueue.EnqueueNotification(ctx, &models.Notification{ID: "stuck", UserID: "author"}))
+	require.Eventually(t, func() bool { return notificationQueue.Pool().Busy == 1 }, time.Second, time.Millisecond)
+
+	dispatcher := fanout.NewDispatcher(store, notificationQueue)
+	dispatcher.Start()
+	defer dispatcher.Stop()
+
+	job, err := dispatcher.Submit(ctx, post)
+	require.NoError(t, err)
+
+	// The checkpoint stops right before the first follower turned away
+	require.Eventually(t, func() bool {
+		stored, err := store.GetFanout(ctx, job.ID)
+		require.NoError(t, err)
+		job = stored
+		return job.TurnedAway > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, models.FanoutStatusRunning, job.Status)
+	assert.Equal(t, 1, job.Processed)
+	assert.Equal(t, 1, job.Queued)
+	assert.Equal(t, "f1", job.Cursor)
+
+	// New posts are still stored, their jobs wait for room as well
+	second, err := dispatcher.Submit(ctx, &models.Post{ID: "p2", UserID: "author", Content: "again", CreatedAt: time.Now()})
+	require.NoError(t, err)
+	_, err = store.GetPost(ctx, "p2")
+	assert.NoError(t, err)
+
+	// The jobs carry on from their checkpoints once the queue drains
+	close(gate)
+	for _, id := range []string{job.ID, second.ID} {
+		job = waitFor(t, store, id)
+		assert.Equal(t, models.FanoutStatusCompleted, job.Status)
+		assert.Equal(t, 4, job.Processed)
+		assert.Equal(t, 4, job.Queued)
+		assert.Equal(t, "f4", job.Cursor)
+	}
+	assert.NotZero(t, job.TurnedAway)
+}
+
+func TestDispatcherCompletesPostsWithoutFollowers(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	post := setup(t, store, 0)
+
+	// There is nothing to queue, so the job is done before any worker runs
+	dispatcher := fanout.NewDispatcher(store, startQueue(t, store))
+
+	job, err := dispatcher.Submit(ctx, post)
+	require.NoError(t, err)
+	assert.Equal(t, models.FanoutStatusCompleted, job.Status)
+	assert.NotNil(t, job.CompletedAt)
+	stored, err := store.GetFanout(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.FanoutStatusCompleted, stored.Status)
+}
+
+func TestDispatchersSharingAStore(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	post := setup(t, store, 4)
+	notificationQueue := startQueue(t, store)
+
+	// A job another dispatcher is running
+	now := time.Now()
+	job := &models.FanoutJob{
+		ID:             "job1",
+		PostID:         post.ID,
+		AuthorID:       post.UserID,
+		Status:         models.FanoutStatusRunning,
+		TotalFollowers: 4,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Owner:          "other",
+		LeaseUntil:     now.Add(200 * time.Millisecond),
+	}
+	require.NoError(t, store.SaveFanout(ctx, job))
+
+	dispatcher := fanout.NewDispatcher(store, notificationQueue, fanout.WithLease(60*time.Millisecond))
+	dispatcher.Start()
+	defer dispatcher.Stop()
+
+	// is left alone while the lease holds
+	time.Sleep(100 * time.Millisecond)
+	stored, err := store.GetFanout(ctx, "job1")
+	require.NoError(t, err)
+	assert.Equal(t, models.FanoutStatusRunning, stored.Status)
+	assert.Equal(t, "other", stored.Owner)
+
+	// and resumed once it ran out
+	stored = waitFor(t, store, "job1")
+	assert.Equal(t, models.FanoutStatusCompleted, stored.Status)
+	assert.Equal(t, 4, stored.Queued)
+}
@@ -0,0 +1,63 @@
+package fanout
+
+import (
+	"log"
+	"slices"
+	"time"
+	// Quiet hours are in the user's time zone, which must not depend on the
+	// zone database of the host, neither here nor where the service
+	// validates them
+	_ "time/tzdata"
+
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+)
+
+// inQuietHours tells whether t falls into the quiet hours, in their time zone
+func inQuietHours(quietHours *models.QuietHours, t time.Time) bool {
+	location, err := time.LoadLocation(quietHours.TimeZone)
+	if err != nil {
+		// Validated when saved, so only a zone removed since can get here
+		log.Printf("Ignoring quiet hours in unknown time zone %q: %v", quietHours.TimeZone, err)
+		return false
+	}
+	start, err1 := time.Parse(models.TimeOfDayLayout, quietHours.Start)
+	end, err2 := time.Parse(models.TimeOfDayLayout, quietHours.End)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+
+	local := t.In(location)
+	now := local.Hour()*60 + local.Minute()
+	from := start.Hour()*60 + start.Minute()
+	until := end.Hour()*60 + end.Minute()
+	if from < until {
+		return now >= from && now < until
+	}
+	// Runs past midnight
+	return now >= from || now < until
+}
+
+// notificationChannels decides whether a user with the given preferences
+// hears about an event by author at time t, and through which channels.
+// During quiet hours notifications only go to the inbox. Nil preferences
+// are the defaults.
+func notificationChannels(preferences *models.NotificationPreferences, eventType models.EventType, authorID string, t time.Time, defaults []models.Channel) ([]models.Channel, bool) {
+	if preferences == nil {
+		return defaults, true
+	}
+	if slices.Contains(preferences.MutedEventTypes, eventType) || slices.Contains(preferences.MutedAuthors, authorID) {
+		return nil, false
+	}
+
+	channels := preferences.Channels
+	if len(channels) == 0 {
+		channels = defaults
+	}
+	if preferences.QuietHours != nil && inQuietHours(preferences.QuietHours, t) {
+		if !slices.Contains(channels, models.ChannelInApp) {
+			return nil, false
+		}
+		channels = []models.Channel{models.ChannelInApp}
+	}
+	return channels, true
+}
@@ -1,97 +1,255 @@
 package models
 
 import (
-	"sync"
 	"time"
 )
 
 type User struct {
-	ID        string   `json:"id"`
-	Username  string   `json:"username"`
-	Followers []string `json:"followers"`
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type Post struct {
-	ID      string `json:"id"`
-	UserID  string `json:"user_id"`
-	Content string `json:"content"`
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
+// NotificationStatus is where a notification is in its delivery lifecycle:
+// pending → delivering → delivered, or failed and back to delivering for
+// every retry, until it is dead-lettered after the last one
 type NotificationStatus string
 
 const (
-	NotificationStatusPending   NotificationStatus = "pending"
-	NotificationStatusDelivered NotificationStatus = "delivered"
-	NotificationStatusFailed    NotificationStatus = "failed"
+	// NotificationStatusPending is waiting in the queue for its first attempt
+	NotificationStatusPending NotificationStatus = "pending"
+	// NotificationStatusDelivering is being delivered by a worker
+	NotificationStatusDelivering NotificationStatus = "delivering"
+	NotificationStatusDelivered  NotificationStatus = "delivered"
+	// NotificationStatusFailed failed its latest attempt and waits for a retry
+	NotificationStatusFailed NotificationStatus = "failed"
+	// NotificationStatusDeadLettered ran out of retries
+	NotificationStatusDeadLettered NotificationStatus = "dead_lettered"
 )
 
-type Notification struct {
-	ID          string             `json:"id"`
-	UserID      string             `json:"user_id"`
-	PostID      string             `json:"post_id"`
-	Content     string             `json:"content"`
-	Read        bool               `json:"read"`
-	CreatedAt   time.Time          `json:"created_at"`
-	Status      NotificationStatus `json:"status"`
-	RetryCount  int                `json:"retry_count"`
-	LastRetry   *time.Time         `json:"last_retry,omitempty"`
-	DeliveredAt *time.Time         `json:"delivered_at,omitempty"`
+// Channel names a way of delivering notifications
+type Channel string
+
+const (
+	// ChannelInApp puts notifications in the user's inbox, the feed the
+	// notification APIs list and stream
+	ChannelInApp   Channel = "in_app"
+	ChannelWebhook Channel = "webhook"
+	ChannelEmail   Channel = "email"
+	// ChannelMock delivers nowhere and fails at a configured rate, for
+	// trying out retries and dead letters locally
+	ChannelMock Channel = "mock"
+)
+
+// EventType names the kind of event a notification is about
+type EventType string
+
+const (
+	// EventTypeNewPost is a post published by someone the user follows
+	EventTypeNewPost EventType = "new_post"
+)
+
+// Priority is the class of a notification job, which decides the lane of
+// the queue it waits in
+type Priority string
+
+const (
+	// PriorityHigh is for urgent notifications, such as direct mentions or
+	// security alerts
+	PriorityHigh Priority = "high"
+	// PriorityNormal is the priority of jobs that have no other
+	PriorityNormal Priority = "normal"
+	// PriorityLow is for bulk notifications, such as the fan-out of a post
+	PriorityLow Priority = "low"
+)
+
+// Priorities lists the priority classes, most urgent first
+var Priorities = []Priority{PriorityHigh, PriorityNormal, PriorityLow}
+
+// NotificationPreferences are a user's settings for the notifications they
+// receive. The zero value receives everything through the default channels.
+type NotificationPreferences struct {
+	UserID string `json:"user_id"`
+	// Channels are the channels to deliver through. When empty, the queue
+	// uses its default channels.
+	Channels []Channel `json:"channels,omitempty"`
+	// MutedEventTypes are the event types the user does not want to hear about
+	MutedEventTypes []EventType `json:"muted_event_types,omitempty"`
+	// MutedAuthors are the IDs of users whose posts the user is not
+	// notified about, even while following them
+	MutedAuthors []string `json:"muted_authors,omitempty"`
+	// QuietHours limits delivery to the inbox for part of the day, nil
+	// when the user has none
+	QuietHours *QuietHours `json:"quiet_hours,omitempty"`
+	UpdatedAt  time.Time   `json:"updated_at"`
 }
 
-// Metrics related structs
-type NotificationMetrics struct {
-	TotalNotificationsSent int     `json:"total_notifications_sent"`
-	FailedAttempts         int     `json:"failed_attempts"`
-	AverageDeliveryTime    float64 `json:"average_delivery_time"` // in milliseconds
+// TimeOfDayLayout is the time layout of the start and end of quiet hours
+const TimeOfDayLayout = "15:04"
+
+// QuietHours is a daily period in the user's time zone. Start and End are
+// times of day in TimeOfDayLayout; a period that ends before it starts runs
+// past midnight.
+type QuietHours struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// TimeZone is an IANA time zone name such as Europe/Berlin
+	TimeZone string `json:"time_zone"`
+}
+
+// FanoutStatus is where a fan-out job is: pending → running → completed,
+// or failed
+type FanoutStatus string
+
+const (
+	FanoutStatusPending   FanoutStatus = "pending"
+	FanoutStatusRunning   FanoutStatus = "running"
+	FanoutStatusCompleted FanoutStatus = "completed"
+	FanoutStatusFailed    FanoutStatus = "failed"
+)
+
+// FanoutJob notifies the followers of an author about a new post, working
+// through the followers in batches and saving a checkpoint after each one
+type FanoutJob struct {
+	ID       string       `json:"id"`
+	PostID   string       `json:"post_id"`
+	AuthorID string       `json:"author_id"`
+	Status   FanoutStatus `json:"status"`
+	// Cursor is the ID of the last follower handled. Followers are handled
+	// in ID order, so an interrupted job resumes after it.
+	Cursor string `json:"cursor,omitempty"`
+	// TotalFollowers is how many followers the author had when the post was
+	// published
+	TotalFollowers int `json:"total_followers"`
+	// Processed counts the followers handled so far, Queued those a
+	// notification was queued for and Skipped those whose preferences ruled
+	// it out. TurnedAway counts the notifications the queue turned away
+	// while it was full, each was tried again from the checkpoint.
+	Processed  int `json:"processed"`
+	Queued     int `json:"queued"`
+	Skipped    int `json:"skipped"`
+	TurnedAway int `json:"turned_away"`
+	// Error is why a failed job gave up
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	// Owner is the dispatcher running the job. No other dispatcher resumes
+	// it before LeaseUntil.
+	Owner      string    `json:"-"`
+	LeaseUntil time.Time `json:"-"`
 }
 
-type Store struct {
-	//UUID -> User
-	Users map[string]*User
-	//UserId -> Post
-	Posts map[string]*Post
-	//UserId -> []Notification
-	Notifications map[string][]*Notification
+// IdempotencyKey remembers a request a client sent with an idempotency key,
+// so that a retry gets the original response instead of running it again
+type IdempotencyKey struct {
+	// Keys are scoped by user, so clients of different users cannot collide
+	UserID string `json:"user_id"`
+	Key    string `json:"key"`
+	// RequestHash identifies the request, a key cannot be reused for another one
+	RequestHash string `json:"request_hash"`
+	// Response is the serialized response, empty while the request is in progress
+	Response  []byte    `json:"response,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// ExpiresAt ends the short lease of a request in progress, and once the
+	// response is stored, how long it is kept
+	ExpiresAt time.Time `json:"expires_at"`
+}
 
-	//Metrics Singleton
-	Metrics *NotificationMetrics
+type Notification struct {
+	ID         string             `json:"id"`
+	UserID     string             `json:"user_id"`
+	PostID     string             `json:"post_id"`
+	Content    string             `json:"content"`
+	Read       bool               `json:"read"`
+	CreatedAt  time.Time          `json:"created_at"`
+	Status     NotificationStatus `json:"status"`
+	RetryCount int                `json:"retry_count"`
+	// AuthorID is the user whose activity the notification is about
+	AuthorID  string    `json:"author_id,omitempty"`
+	EventType EventType `json:"event_type,omitempty"`
+	// PostIDs are the posts an aggregate notification merges, oldest first,
+	// with PostID the latest. Empty for a notification about a single post.
+	PostIDs []string `json:"post_ids,omitempty"`
+	// LastRetry is when the latest retry started, nil before the first one
+	LastRetry   *time.Time `json:"last_retry,omitempty"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+	// Channels are the channels to deliver through. When empty, the queue
+	// uses its default channels.
+	Channels []Channel `json:"channels,omitempty"`
+	// Deliveries records the outcome of every channel attempted so far
+	Deliveries []ChannelDelivery `json:"deliveries,omitempty"`
+	// Seq is the position of the notification in its user's feed. It is
+	// assigned by the storage backend and grows with every notification.
+	Seq int64 `json:"-"`
+}
 
-	//Mutex for thread safety
-	Mu sync.Mutex
+// ChannelDelivery is the outcome of delivering a notification through one channel
+type ChannelDelivery struct {
+	Channel Channel `json:"channel"`
+	// Status is delivered once the channel succeeded, failed after a failed
+	// attempt and pending before the first one
+	Status   NotificationStatus `json:"status"`
+	Attempts int                `json:"attempts"`
+	// LastError is the error of the latest failed attempt
+	LastError   string     `json:"last_error,omitempty"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
 }
 
-func NewStore() *Store {
-	return &Store{
-		Users:         make(map[string]*User),
-		Posts:         make(map[string]*Post),
-		Notifications: make(map[string][]*Notification),
-		Metrics:       &NotificationMetrics{},
-		Mu:            sync.Mutex{},
-	}
+// NotificationJob is a notification waiting in the queue to be delivered
+type NotificationJob struct {
+	Notification *Notification
+	// Attempt is the delivery attempt the job is due for, starting at 1
+	Attempt int
+	// DueAt is when a retry may be attempted, zero means right away
+	DueAt time.Time
+	// Attempts records the attempt of every channel tried so far, oldest
+	// first
+	Attempts []DeliveryAttempt
+	// Priority is the lane the job waits in, normal when empty
+	Priority Priority
+	// MaxBackoff caps the wait before a retry, the maximum of the queue when
+	// zero
+	MaxBackoff time.Duration
+	// Owner is the queue that holds the job. No other queue replays it
+	// before LeaseUntil, so queues sharing a journal each run their own.
+	Owner      string
+	LeaseUntil time.Time
 }
 
-func (s *Store) InitSampleData() {
-	users := []*User{
-		{ID: "u1", Username: "alice", Followers: []string{"u2", "u3", "u4", "u5"}},
-		{ID: "u2", Username: "bob", Followers: []string{"u1", "u3", "u4", "u5"}},
-		{ID: "u3", Username: "charlie", Followers: []string{"u1", "u2", "u4", "u5"}},
-		{ID: "u4", Username: "david", Followers: []string{"u1", "u2", "u3", "u5"}},
-		{ID: "u5", Username: "eve", Followers: []string{"u1", "u2", "u3", "u4"}},
-	}
-
-	for _, u := range users {
-		s.Users[u.ID] = u
-	}
-
-	posts := []*Post{
-		{UserID: "u1", Content: "Hello from Alice!"},
-		{UserID: "u2", Content: "Bob's first post"},
-		{UserID: "u3", Content: "Charlie shares news"},
-		{UserID: "u4", Content: "David's photo post"},
-		{UserID: "u5", Content: "Eve's thoughts"},
-	}
-
-	for _, p := range posts {
-		s.Posts[p.UserID] = p
-	}
+// DeliveryAttempt records an attempt to deliver a notification through one
+// of its channels. Error is empty if the channel delivered it.
+type DeliveryAttempt struct {
+	Attempt int       `json:"attempt"`
+	Channel Channel   `json:"channel,omitempty"`
+	At      time.Time `json:"at"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// DeadLetter is a notification the queue gave up on after running out of
+// retries
+type DeadLetter struct {
+	Notification *Notification
+	// Reason explains why the notification was given up on
+	Reason   string
+	Attempts []DeliveryAttempt
+	FailedAt time.Time
+	// Seq orders dead letters by when they were added. It is assigned by the
+	// storage backend.
+	Seq int64
+}
+
+// Metrics related structs
+type NotificationMetrics struct {
+	TotalNotificationsSent int     `json:"total_notifications_sent"`
+	FailedAttempts         int     `json:"failed_attempts"`
+	AverageDeliveryTime    float64 `json:"average_delivery_time"` // in milliseconds
 }
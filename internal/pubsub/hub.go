@@ -0,0 +1,153 @@
+// Package pubsub fans delivered notifications out to the subscribers of
+// their recipient within a single process.
+package pubsub
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+)
+
+var (
+	// ErrSlowConsumer ends a subscription whose buffer filled up. The
+	// subscriber can resume from the last notification it received.
+	ErrSlowConsumer = errors.New("subscriber fell behind")
+	// ErrHubClosed ends every subscription when the hub shuts down
+	ErrHubClosed = errors.New("hub closed")
+)
+
+// DefaultBufferSize is how many notifications a subscriber may fall behind
+// before it is dropped
+const DefaultBufferSize = 64
+
+// Hub delivers published notifications to every live subscription of their
+// user. Publishing never blocks: subscribers that cannot keep up are dropped.
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[string]map[*Subscription]struct{}
+	bufferSize  int
+	closed      bool
+}
+
+// NewHub creates a hub whose subscriptions buffer up to bufferSize notifications
+func NewHub(bufferSize int) *Hub {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	return &Hub{
+		subscribers: make(map[string]map[*Subscription]struct{}),
+		bufferSize:  bufferSize,
+	}
+}
+
+// Subscribe starts receiving the notifications published for a user. The
+// subscription must be closed once it is no longer needed.
+func (h *Hub) Subscribe(userID string) *Subscription {
+	sub := &Subscription{
+		hub:    h,
+		userID: userID,
+		ch:     make(chan *models.Notification, h.bufferSize),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		sub.end(ErrHubClosed)
+		return sub
+	}
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[*Subscription]struct{})
+	}
+	h.subscribers[userID][sub] = struct{}{}
+	return sub
+}
+
+// Publish hands a notification to the subscribers of its user
+func (h *Hub) Publish(notification *models.Notification) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subscribers[notification.UserID] {
+		n := *notification
+		select {
+		case sub.ch <- &n:
+		default:
+			h.remove(sub)
+			sub.end(ErrSlowConsumer)
+		}
+	}
+}
+
+// Subscribers returns how many subscriptions a user has
+func (h *Hub) Subscribers(userID string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subscribers[userID])
+}
+
+// Close ends every subscription with ErrHubClosed. Later subscriptions end
+// immediately.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for _, subs := range h.subscribers {
+		for sub := range subs {
+			sub.end(ErrHubClosed)
+		}
+	}
+	h.subscribers = make(map[string]map[*Subscription]struct{})
+}
+
+// remove must be called with h.mu held
+func (h *Hub) remove(sub *Subscription) {
+	subs := h.subscribers[sub.userID]
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.subscribers, sub.userID)
+	}
+}
+
+// Subscription is a live feed of one user's notifications
+type Subscription struct {
+	hub    *Hub
+	userID string
+	ch     chan *models.Notification
+	err    error
+	ended  bool
+}
+
+// C returns the channel notifications arrive on. It is closed when the
+// subscription ends, after which Err tells why.
+func (s *Subscription) C() <-chan *models.Notification {
+	return s.ch
+}
+
+// Err returns why the subscription ended, or nil if it was closed by its owner
+func (s *Subscription) Err() error {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.err
+}
+
+// Close ends the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	s.hub.remove(s)
+	s.end(nil)
+}
+
+// end must be called with the hub lock held
+func (s *Subscription) end(err error) {
+	if s.ended {
+		return
+	}
+	s.ended = true
+	s.err = err
+	close(s.ch)
+}
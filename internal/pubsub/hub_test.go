@@ -0,0 +1,87 @@
+package pubsub_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+	"github.com/iwhitebird/social-app-microservices/internal/pubsub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHubDeliversToSubscribersOfUser(t *testing.T) {
+	hub := pubsub.NewHub(10)
+
+	first := hub.Subscribe("user1")
+	defer first.Close()
+	second := hub.Subscribe("user1")
+	defer second.Close()
+	other := hub.Subscribe("user2")
+	defer other.Close()
+
+	hub.Publish(&models.Notification{ID: "n1", UserID: "user1"})
+
+	for _, sub := range []*pubsub.Subscription{first, second} {
+		select {
+		case n := <-sub.C():
+			assert.Equal(t, "n1", n.ID)
+		default:
+			t.Fatal("notification was not delivered")
+		}
+	}
+	assert.Empty(t, other.C())
+}
+
+func TestHubDropsSlowConsumers(t *testing.T) {
+	hub := pubsub.NewHub(2)
+
+	slow := hub.Subscribe("user1")
+	defer slow.Close()
+
+	for i := 0; i < 3; i++ {
+		hub.Publish(&models.Notification{ID: fmt.Sprintf("n%d", i), UserID: "user1"})
+	}
+
+	// The buffered notifications can still be drained before the channel closes
+	var received []string
+	for n := range slow.C() {
+		received = append(received, n.ID)
+	}
+	assert.Equal(t, []string{"n0", "n1"}, received)
+	assert.ErrorIs(t, slow.Err(), pubsub.ErrSlowConsumer)
+	assert.Equal(t, 0, hub.Subscribers("user1"))
+}
+
+func TestSubscriptionClose(t *testing.T) {
+	hub := pubsub.NewHub(10)
+
+	sub := hub.Subscribe("user1")
+	require.Equal(t, 1, hub.Subscribers("user1"))
+
+	sub.Close()
+	sub.Close()
+	assert.Equal(t, 0, hub.Subscribers("user1"))
+	assert.NoError(t, sub.Err())
+
+	// Publishing after a subscriber left is fine
+	hub.Publish(&models.Notification{ID: "n1", UserID: "user1"})
+	_, open := <-sub.C()
+	assert.False(t, open)
+}
+
+func TestHubClose(t *testing.T) {
+	hub := pubsub.NewHub(10)
+
+	sub := hub.Subscribe("user1")
+	hub.Close()
+
+	_, open := <-sub.C()
+	assert.False(t, open)
+	assert.ErrorIs(t, sub.Err(), pubsub.ErrHubClosed)
+
+	late := hub.Subscribe("user1")
+	_, open = <-late.C()
+	assert.False(t, open)
+	assert.ErrorIs(t, late.Err(), pubsub.ErrHubClosed)
+}
@@ -0,0 +1,184 @@
+package queue
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+)
+
+// coalesceKey groups the notifications a coalescer merges: those of one
+// recipient about the same kind of activity of one author
+type coalesceKey struct {
+	userID    string
+	authorID  string
+	eventType models.EventType
+}
+
+type coalesceGroup struct {
+	jobs  []models.NotificationJob
+	timer *time.Timer
+}
+
+// coalescer holds notifications for a window that starts with the first one
+// of a group, then hands the group to flush. The window does not slide, so
+// a busy author delays a notification by at most one window.
+type coalescer struct {
+	window time.Duration
+	flush  func(jobs []models.NotificationJob)
+
+	mu     sync.Mutex
+	groups map[coalesceKey]*coalesceGroup
+}
+
+func newCoalescer(window time.Duration, flush func(jobs []models.NotificationJob)) *coalescer {
+	return &coalescer{
+		window: window,
+		flush:  flush,
+		groups: make(map[coalesceKey]*coalesceGroup),
+	}
+}
+
+// add holds a job until the window of its group is over
+func (c *coalescer) add(job models.NotificationJob) {
+	n := job.Notification
+	key := coalesceKey{userID: n.UserID, authorID: n.AuthorID, eventType: n.EventType}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	group, ok := c.groups[key]
+	if !ok {
+		group = &coalesceGroup{}
+		group.timer = time.AfterFunc(c.window, func() { c.release(key) })
+		c.groups[key] = group
+	}
+	group.jobs = append(group.jobs, job)
+}
+
+func (c *coalescer) release(key coalesceKey) {
+	c.mu.Lock()
+	group, ok := c.groups[key]
+	delete(c.groups, key)
+	c.mu.Unlock()
+
+	// A group stop dropped is left to the journal
+	if ok {
+		c.flush(group.jobs)
+	}
+}
+
+// stop drops the groups still held. Their jobs are journaled and replayed,
+// without being coalesced, by the next start.
+func (c *coalescer) stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, group := range c.groups {
+		group.timer.Stop()
+		delete(c.groups, key)
+	}
+}
+
+// aggregate merges the notifications of a group into one that lists all
+// their posts, oldest first. Its ID is derived from theirs, so merging the
+// same notifications again yields the same notification.
+func aggregate(notifications []*models.Notification) *models.Notification {
+	latest := notifications[len(notifications)-1]
+	ids := make([]string, 0, len(notifications))
+	var postIDs []string
+	for _, n := range notifications {
+		ids = append(ids, n.ID)
+		if len(n.PostIDs) > 0 {
+			postIDs = append(postIDs, n.PostIDs...)
+		} else {
+			postIDs = append(postIDs, n.PostID)
+		}
+	}
+
+	return &models.Notification{
+		ID:        uuid.NewSHA1(uuid.NameSpaceURL, []byte("coalesced:"+strings.Join(ids, ","))).String(),
+		UserID:    latest.UserID,
+		PostID:    latest.PostID,
+		AuthorID:  latest.AuthorID,
+		EventType: latest.EventType,
+		PostIDs:   postIDs,
+		Content:   aggregateContent(latest.EventType, latest.AuthorID, len(postIDs)),
+		CreatedAt: latest.CreatedAt,
+		Status:    models.NotificationStatusPending,
+		Channels:  latest.Channels,
+	}
+}
+
+// aggregateContent sums up what an aggregate notification is about, e.g.
+// "alice posted 3 times"
+func aggregateContent(eventType models.EventType, authorID string, count int) string {
+	switch eventType {
+	case models.EventTypeNewPost:
+		return fmt.Sprintf("%s posted %d times", authorID, count)
+	default:
+		return fmt.Sprintf("%d notifications from %s", count, authorID)
+	}
+}
+
+// dedupKey identifies a notification for duplicate suppression
+type dedupKey struct {
+	userID    string
+	postID    string
+	eventType models.EventType
+}
+
+// deduplicator remembers the notifications it has seen for a window and
+// reports those seen again as duplicates
+type deduplicator struct {
+	window time.Duration
+
+	mu   sync.Mutex
+	seen map[dedupKey]time.Time
+	// order holds the keys in the order they were seen, which is also the
+	// order they expire in
+	order []dedupEntry
+}
+
+// dedupEntry is a key that expires at expires, unless it was forgotten and
+// seen again since
+type dedupEntry struct {
+	key     dedupKey
+	expires time.Time
+}
+
+func newDeduplicator(window time.Duration) *deduplicator {
+	return &deduplicator{window: window, seen: make(map[dedupKey]time.Time)}
+}
+
+// duplicate reports whether the same notification was seen within the
+// window, remembering it if not
+func (d *deduplicator) duplicate(n *models.Notification, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for len(d.order) > 0 && !d.order[0].expires.After(now) {
+		if expires, ok := d.seen[d.order[0].key]; ok && expires.Equal(d.order[0].expires) {
+			delete(d.seen, d.order[0].key)
+		}
+		d.order = d.order[1:]
+	}
+
+	key := dedupKey{userID: n.UserID, postID: n.PostID, eventType: n.EventType}
+	if _, ok := d.seen[key]; ok {
+		return true
+	}
+	d.seen[key] = now.Add(d.window)
+	d.order = append(d.order, dedupEntry{key: key, expires: d.seen[key]})
+	return false
+}
+
+// forget lets a notification through again, e.g. after the queue turned it
+// away
+func (d *deduplicator) forget(n *models.Notification) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.seen, dedupKey{userID: n.UserID, postID: n.PostID, eventType: n.EventType})
+}
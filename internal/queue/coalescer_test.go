@@ -0,0 +1,61 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregate(t *testing.T) {
+	createdAt := time.Now()
+	notification := func(id, postID string) *models.Notification {
+		return &models.Notification{
+			ID:        id,
+			UserID:    "u1",
+			PostID:    postID,
+			AuthorID:  "alice",
+			EventType: models.EventTypeNewPost,
+			CreatedAt: createdAt,
+			Channels:  []models.Channel{models.ChannelInApp},
+		}
+	}
+	// An aggregate that is merged again contributes all of its posts
+	earlier := aggregate([]*models.Notification{notification("n1", "p1"), notification("n2", "p2")})
+	merged := aggregate([]*models.Notification{earlier, notification("n3", "p3")})
+
+	assert.Equal(t, "alice posted 2 times", earlier.Content)
+	assert.Equal(t, []string{"p1", "p2", "p3"}, merged.PostIDs)
+	assert.Equal(t, "p3", merged.PostID)
+	assert.Equal(t, "alice posted 3 times", merged.Content)
+	assert.Equal(t, "u1", merged.UserID)
+	assert.Equal(t, "alice", merged.AuthorID)
+	assert.Equal(t, models.EventTypeNewPost, merged.EventType)
+	assert.Equal(t, models.NotificationStatusPending, merged.Status)
+	assert.Equal(t, []models.Channel{models.ChannelInApp}, merged.Channels)
+
+	// The ID only depends on the merged notifications
+	again := aggregate([]*models.Notification{notification("n1", "p1"), notification("n2", "p2")})
+	assert.Equal(t, earlier.ID, again.ID)
+	assert.NotEqual(t, earlier.ID, merged.ID)
+}
+
+func TestDeduplicator(t *testing.T) {
+	d := newDeduplicator(time.Minute)
+	now := time.Now()
+	notification := func(userID, postID string, eventType models.EventType) *models.Notification {
+		return &models.Notification{UserID: userID, PostID: postID, EventType: eventType}
+	}
+
+	assert.False(t, d.duplicate(notification("u1", "p1", models.EventTypeNewPost), now))
+	assert.True(t, d.duplicate(notification("u1", "p1", models.EventTypeNewPost), now.Add(time.Second)))
+	// Another recipient, post or event type is not a duplicate
+	assert.False(t, d.duplicate(notification("u2", "p1", models.EventTypeNewPost), now))
+	assert.False(t, d.duplicate(notification("u1", "p2", models.EventTypeNewPost), now))
+	assert.False(t, d.duplicate(notification("u1", "p1", "mention"), now))
+
+	// Once the window is over, the notification is new again
+	assert.False(t, d.duplicate(notification("u1", "p1", models.EventTypeNewPost), now.Add(time.Minute)))
+	assert.Len(t, d.seen, 1)
+}
@@ -0,0 +1,258 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+)
+
+// LaneStats describes the jobs waiting in one lane of the queue
+type LaneStats struct {
+	Priority models.Priority
+	// Depth is how many jobs are due and wait for a worker. Retries still
+	// waiting out their backoff are not counted.
+	Depth int
+	// OldestWait is how long the job at the head of the lane has waited
+	OldestWait time.Duration
+	// Spilled is how many jobs wait in the journal for room in the lane,
+	// see OverflowSpill
+	Spilled int
+}
+
+// errLanesClosed is returned by pushContext once the lanes are closed
+var errLanesClosed = errors.New("lanes closed")
+
+// lanedJob is a job waiting in a lane since readyAt
+type lanedJob struct {
+	job     models.NotificationJob
+	readyAt time.Time
+}
+
+// lane holds the jobs of one priority class in FIFO order
+type lane struct {
+	priority models.Priority
+	weight   int
+	// credit is the lane's balance in the weighted round robin
+	credit int
+	jobs   []lanedJob
+}
+
+// lanes hands jobs to the workers from one lane per priority class. While
+// several lanes have jobs waiting, they take turns in proportion to their
+// weights (smooth weighted round robin), so a backlog of bulk jobs delays
+// an urgent one by a few jobs at most. A job that has waited for maxWait is
+// taken next whatever its lane, so busy urgent lanes cannot starve the
+// others either.
+type lanes struct {
+	mu sync.Mutex
+	// ready is signalled when a job is pushed, space when one is popped
+	ready *sync.Cond
+	space *sync.Cond
+	// spaceWatchers are signalled without blocking whenever a job is
+	// popped, for whoever waits for room outside of push, see watchSpace
+	spaceWatchers []chan struct{}
+	lanes         []*lane
+	capacity      int
+	maxWait       time.Duration
+	size          int
+	closed        bool
+}
+
+// newLanes returns a lane for every priority class holding up to capacity
+// jobs. Priorities without a weight get a weight of 1. A zero maxWait
+// turns starvation protection off.
+func newLanes(weights map[models.Priority]int, capacity int, maxWait time.Duration) *lanes {
+	l := &lanes{capacity: capacity, maxWait: maxWait}
+	l.ready = sync.NewCond(&l.mu)
+	l.space = sync.NewCond(&l.mu)
+	for _, priority := range models.Priorities {
+		l.lanes = append(l.lanes, &lane{priority: priority, weight: max(weights[priority], 1)})
+	}
+	return l
+}
+
+// lane returns the lane of a priority, the normal one for unknown priorities
+func (l *lanes) lane(priority models.Priority) *lane {
+	for _, lane := range l.lanes {
+		if lane.priority == priority {
+			return lane
+		}
+	}
+	return l.lane(models.PriorityNormal)
+}
+
+// push adds a job to the lane of its priority, waiting while that lane is
+// full. It returns false if the lanes were closed.
+func (l *lanes) push(job models.NotificationJob) bool {
+	return l.pushContext(context.Background(), job) == nil
+}
+
+// pushContext adds a job to the lane of its priority, waiting while that
+// lane is full until ctx is done. It returns ctx.Err() if the job was not
+// added in time and errLanesClosed if the lanes were closed.
+func (l *lanes) pushContext(ctx context.Context, job models.NotificationJob) error {
+	stop := context.AfterFunc(ctx, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.space.Broadcast()
+	})
+	defer stop()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lane := l.lane(job.Priority)
+	for len(lane.jobs) >= l.capacity && !l.closed && ctx.Err() == nil {
+		l.space.Wait()
+	}
+	if l.closed {
+		return errLanesClosed
+	}
+	if len(lane.jobs) >= l.capacity {
+		return ctx.Err()
+	}
+	l.add(lane, job)
+	return nil
+}
+
+// tryPush adds a job to the lane of its priority unless that lane is full
+// or the lanes are closed
+func (l *lanes) tryPush(job models.NotificationJob) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lane := l.lane(job.Priority)
+	if l.closed || len(lane.jobs) >= l.capacity {
+		return false
+	}
+	l.add(lane, job)
+	return true
+}
+
+// add appends a job to a lane. The caller holds the lock.
+func (l *lanes) add(lane *lane, job models.NotificationJob) {
+	lane.jobs = append(lane.jobs, lanedJob{job: job, readyAt: time.Now()})
+	l.size++
+	l.ready.Signal()
+}
+
+// pop takes the next job, waiting until there is one. It returns false
+// once the lanes are closed, the jobs left in them stay in the journal, or
+// when retire, which may be nil, tells the caller to stop instead.
+func (l *lanes) pop(retire func() bool) (models.NotificationJob, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for {
+		if l.closed {
+			return models.NotificationJob{}, false
+		}
+		if retire != nil && retire() {
+			// The caller may have been woken for a job, pass it on
+			if l.size > 0 {
+				l.ready.Signal()
+			}
+			return models.NotificationJob{}, false
+		}
+		if l.size > 0 {
+			break
+		}
+		l.ready.Wait()
+	}
+
+	lane := l.next(time.Now())
+	next := lane.jobs[0]
+	lane.jobs[0] = lanedJob{}
+	lane.jobs = lane.jobs[1:]
+	if len(lane.jobs) == 0 {
+		// An idle lane does not save up turns
+		lane.credit = 0
+	}
+	l.size--
+	// Pushers may wait on different lanes, so wake all of them
+	l.space.Broadcast()
+	for _, watcher := range l.spaceWatchers {
+		select {
+		case watcher <- struct{}{}:
+		default:
+		}
+	}
+	return next.job, true
+}
+
+// watchSpace returns a channel that is signalled whenever a job leaves the
+// lanes. Signals do not pile up, one may stand for several jobs.
+func (l *lanes) watchSpace() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	watcher := make(chan struct{}, 1)
+	l.spaceWatchers = append(l.spaceWatchers, watcher)
+	return watcher
+}
+
+// next picks the lane to take a job from. The caller holds the lock and
+// at least one lane has jobs.
+func (l *lanes) next(now time.Time) *lane {
+	var oldest *lane
+	for _, lane := range l.lanes {
+		if len(lane.jobs) > 0 && (oldest == nil || lane.jobs[0].readyAt.Before(oldest.jobs[0].readyAt)) {
+			oldest = lane
+		}
+	}
+	if l.maxWait > 0 && now.Sub(oldest.jobs[0].readyAt) >= l.maxWait {
+		return oldest
+	}
+
+	var best *lane
+	total := 0
+	for _, lane := range l.lanes {
+		if len(lane.jobs) == 0 {
+			continue
+		}
+		lane.credit += lane.weight
+		total += lane.weight
+		if best == nil || lane.credit > best.credit {
+			best = lane
+		}
+	}
+	best.credit -= total
+	return best
+}
+
+// stats returns the depth of every lane, most urgent first
+func (l *lanes) stats() []LaneStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	stats := make([]LaneStats, len(l.lanes))
+	for i, lane := range l.lanes {
+		stats[i] = LaneStats{Priority: lane.priority, Depth: len(lane.jobs)}
+		if len(lane.jobs) > 0 {
+			stats[i].OldestWait = now.Sub(lane.jobs[0].readyAt)
+		}
+	}
+	return stats
+}
+
+// wake makes everyone waiting in pop check whether to retire
+func (l *lanes) wake() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.ready.Broadcast()
+}
+
+// close wakes everyone waiting to push or pop and makes them give up
+func (l *lanes) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.closed = true
+	l.ready.Broadcast()
+	l.space.Broadcast()
+}
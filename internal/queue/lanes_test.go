@@ -0,0 +1,128 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func laneJob(id string, priority models.Priority) models.NotificationJob {
+	job := testJob(id)
+	job.Priority = priority
+	return job
+}
+
+// popN pops n jobs and counts them by priority
+func popN(t *testing.T, l *lanes, n int) map[models.Priority]int {
+	counts := make(map[models.Priority]int)
+	for range n {
+		job, ok := l.pop(nil)
+		require.True(t, ok)
+		counts[job.Priority]++
+	}
+	return counts
+}
+
+func TestLanesShareByWeight(t *testing.T) {
+	l := newLanes(map[models.Priority]int{models.PriorityHigh: 3, models.PriorityLow: 1}, 100, 0)
+	for range 100 {
+		require.True(t, l.push(laneJob("low", models.PriorityLow)))
+		require.True(t, l.push(laneJob("high", models.PriorityHigh)))
+	}
+
+	// While both lanes have jobs, three high jobs go for every low one
+	counts := popN(t, l, 40)
+	assert.Equal(t, 30, counts[models.PriorityHigh])
+	assert.Equal(t, 10, counts[models.PriorityLow])
+}
+
+func TestLanesUrgentJobSkipsBacklog(t *testing.T) {
+	l := newLanes(DefaultLaneWeights, 1000, 0)
+	for range 500 {
+		l.push(laneJob("bulk", models.PriorityLow))
+	}
+	l.push(laneJob("urgent", models.PriorityHigh))
+
+	job, ok := l.pop(nil)
+	require.True(t, ok)
+	assert.Equal(t, "urgent", job.Notification.ID)
+}
+
+func TestLanesPreventStarvation(t *testing.T) {
+	l := newLanes(map[models.Priority]int{models.PriorityHigh: 1000}, 1000, 50*time.Millisecond)
+	l.push(laneJob("starving", models.PriorityLow))
+	time.Sleep(60 * time.Millisecond)
+	for range 10 {
+		l.push(laneJob("urgent", models.PriorityHigh))
+	}
+
+	// The low job waited too long to lose to the high lane again
+	job, ok := l.pop(nil)
+	require.True(t, ok)
+	assert.Equal(t, "starving", job.Notification.ID)
+}
+
+func TestLanesStats(t *testing.T) {
+	l := newLanes(nil, 10, 0)
+	l.push(laneJob("n1", models.PriorityLow))
+	l.push(laneJob("n2", models.PriorityLow))
+	// Jobs without a known priority are normal
+	l.push(laneJob("n3", ""))
+	l.push(laneJob("n4", "unknown"))
+
+	stats := l.stats()
+	require.Len(t, stats, 3)
+	assert.Equal(t, models.PriorityHigh, stats[0].Priority)
+	assert.Zero(t, stats[0].Depth)
+	assert.Zero(t, stats[0].OldestWait)
+	assert.Equal(t, models.PriorityNormal, stats[1].Priority)
+	assert.Equal(t, 2, stats[1].Depth)
+	assert.Equal(t, models.PriorityLow, stats[2].Priority)
+	assert.Equal(t, 2, stats[2].Depth)
+	assert.Positive(t, stats[2].OldestWait)
+}
+
+func TestLanesBlockOnlyWhenTheirLaneIsFull(t *testing.T) {
+	l := newLanes(nil, 1, 0)
+	require.True(t, l.push(laneJob("low-1", models.PriorityLow)))
+
+	// A full low lane does not hold up other priorities
+	require.True(t, l.push(laneJob("high", models.PriorityHigh)))
+
+	pushed := make(chan bool)
+	go func() { pushed <- l.push(laneJob("low-2", models.PriorityLow)) }()
+	select {
+	case <-pushed:
+		t.Fatal("push did not wait for room in the lane")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	assert.Equal(t, map[models.Priority]int{models.PriorityHigh: 1, models.PriorityLow: 1}, popN(t, l, 2))
+	select {
+	case ok := <-pushed:
+		assert.True(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("push did not resume")
+	}
+}
+
+func TestLanesClose(t *testing.T) {
+	l := newLanes(nil, 1, 0)
+	popped := make(chan bool)
+	go func() {
+		_, ok := l.pop(nil)
+		popped <- ok
+	}()
+
+	l.close()
+	select {
+	case ok := <-popped:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("pop did not return after close")
+	}
+	assert.False(t, l.push(laneJob("late", models.PriorityNormal)))
+}
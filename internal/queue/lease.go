@@ -0,0 +1,130 @@
+package queue
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+)
+
+// DefaultJobLease is how long a queue holds the jobs it journaled before
+// another queue sharing the journal may take them over
+const DefaultJobLease = time.Minute
+
+// claimPageSize is how many unclaimed jobs the queue claims at a time. It
+// claims the next page once its lanes took the previous one, so a large
+// backlog never has to fit in memory, and the jobs it did not get to are
+// left for other queues sharing the journal.
+const claimPageSize = 500
+
+// WithJobLease sets how long the lease of the queue on its journaled jobs
+// lasts. The queue renews it while it runs, so another queue sharing the
+// journal only takes over the jobs of a queue that stopped, or a lease
+// after one crashed.
+func WithJobLease(lease time.Duration) NotificationQueueOption {
+	return func(q *NotificationQueue) {
+		q.jobLease = lease
+	}
+}
+
+// save journals a job under the lease of the queue
+func (q *NotificationQueue) save(ctx context.Context, job *models.NotificationJob) error {
+	job.Owner = q.owner
+	job.LeaseUntil = time.Now().Add(q.jobLease)
+	return q.journal.SaveJob(ctx, job)
+}
+
+// replayer claims and replays the journaled jobs that no running queue
+// holds, those left over from a previous run or a queue that went away,
+// right away and then whenever leases wakes it, until the queue stops
+func (q *NotificationQueue) replayer() {
+	defer q.wg.Done()
+	spaceFreed := q.lanes.watchSpace()
+	for {
+		if !q.claim(spaceFreed) {
+			return
+		}
+		select {
+		case <-q.replayWake:
+		case <-q.shutdownChan:
+			return
+		}
+	}
+}
+
+// claim leases unclaimed jobs a page at a time until there are none left.
+// Retries still wait out their backoff in the scheduler, due jobs go
+// through the overflow policy like new ones and wait for room while their
+// lane is full. It returns false if the queue stopped meanwhile.
+func (q *NotificationQueue) claim(spaceFreed <-chan struct{}) bool {
+	for {
+		now := time.Now()
+		jobs, err := q.journal.ClaimJobs(context.Background(), q.owner, now, now.Add(q.jobLease), claimPageSize)
+		if err != nil {
+			log.Printf("Failed to claim pending notification jobs: %v", err)
+			return true
+		}
+		if len(jobs) > 0 {
+			log.Printf("Replaying %d pending notification jobs", len(jobs))
+		}
+		for _, job := range jobs {
+			if !job.DueAt.After(now) {
+				if !q.readmit(*job, spaceFreed) {
+					return false
+				}
+				continue
+			}
+			q.scheduler.schedule(*job, job.DueAt)
+		}
+		if len(jobs) < claimPageSize {
+			return true
+		}
+	}
+}
+
+// readmit hands a replayed job to its lane, waiting for room until the
+// queue stops. It returns false if the queue stopped first.
+func (q *NotificationQueue) readmit(job models.NotificationJob, spaceFreed <-chan struct{}) bool {
+	for q.admit(context.Background(), job, true) != nil {
+		select {
+		case <-spaceFreed:
+		case <-q.shutdownChan:
+			return false
+		}
+	}
+	return true
+}
+
+// leases renews the lease of the queue on its jobs a few times per lease
+// and takes over the jobs of queues whose lease ran out, until the queue
+// stops
+func (q *NotificationQueue) leases() {
+	defer q.wg.Done()
+	ticker := time.NewTicker(q.jobLease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-q.shutdownChan:
+			return
+		}
+		if err := q.journal.RenewJobLeases(context.Background(), q.owner, time.Now().Add(q.jobLease)); err != nil {
+			log.Printf("Failed to renew the leases on notification jobs: %v", err)
+		}
+		// Take over the jobs of queues whose lease ran out
+		select {
+		case q.replayWake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// release ends the lease of the queue on the jobs it leaves in the journal,
+// so that another queue can take them over right away
+func (q *NotificationQueue) release() {
+	if err := q.journal.RenewJobLeases(context.Background(), q.owner, time.Now()); err != nil {
+		log.Printf("Failed to release the leases on notification jobs: %v", err)
+	}
+}
@@ -1,58 +1,472 @@
 package queue
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"maps"
 	"math/rand"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/iwhitebird/social-app-microservices/internal/delivery"
 	"github.com/iwhitebird/social-app-microservices/internal/models"
+	"github.com/iwhitebird/social-app-microservices/internal/pubsub"
+	"github.com/iwhitebird/social-app-microservices/internal/storage"
 )
 
-type NotificationJob struct {
-	Notification *models.Notification
-	Attempt      int
+// NotificationQueue delivers notifications in the background. Every job is
+// persisted through the store's JobRepository when it is enqueued and only
+// deleted once it has been delivered or has run out of retries, so the jobs
+// a stopped or crashed process leaves behind are replayed by Start.
+//
+// A notification goes through each of its channels, or the queue's default
+// channels when it names none. A retry only attempts the channels that have
+// not delivered it yet.
+//
+// The workers run in a pool that keeps its size unless WithAutoscaling lets
+// it follow the load; ResizePool overrides it at runtime.
+//
+// Jobs wait for a worker in one lane per priority class, see WithLanes. The
+// priority of a job follows from the event type of its notification, see
+// WithEventPriorities.
+//
+// With WithDeduplication, a notification enqueued again for the same
+// recipient, post and event type is dropped. With WithCoalescing, the
+// notifications of a recipient about one author are held for a window and
+// merged into one aggregate notification.
+type NotificationQueue struct {
+	lanes           *lanes
+	laneWeights     map[models.Priority]int
+	laneMaxWait     time.Duration
+	laneCapacity    int
+	eventPriorities map[models.EventType]models.Priority
+	overflow        OverflowPolicy
+	enqueueTimeout  time.Duration
+	// spilled holds the IDs of the jobs of each lane that only wait in the
+	// journal, oldest first, see OverflowSpill
+	spilled         map[models.Priority][]string
+	spillMu         sync.Mutex
+	notifications   storage.NotificationRepository
+	journal         storage.JobRepository
+	deadLetters     storage.DeadLetterRepository
+	metrics         storage.MetricsRepository
+	hub             *pubsub.Hub
+	scheduler       *scheduler
+	coalescer       *coalescer
+	deduplicator    *deduplicator
+	coalesceWindow  time.Duration
+	dedupWindow     time.Duration
+	deliverers      map[models.Channel]delivery.Deliverer
+	defaultChannels []models.Channel
+	maxRetries      int
+	baseBackoff     time.Duration
+	maxBackoff      time.Duration
+	shutdownChan    chan struct{}
+	wg              sync.WaitGroup
+	mu              sync.Mutex
+
+	// The worker pool, see pool.go. poolMu guards the sizes and pinned,
+	// which is the size set through ResizePool or 0 while autoscaling.
+	poolMu        sync.Mutex
+	workers       int
+	minWorkers    int
+	maxWorkers    int
+	pinned        int
+	started       bool
+	nextWorkerID  int
+	scaleInterval time.Duration
+	targetWait    time.Duration
+	// retiring counts the workers asked to stop after their current job
+	retiring atomic.Int64
+	// busy counts the workers delivering right now, busyTime and processed
+	// add up the time they took and the jobs they handled
+	busy      atomic.Int64
+	busyTime  atomic.Int64
+	processed atomic.Int64
+
+	// owner identifies the queue in the leases on its journaled jobs, see
+	// lease.go
+	owner    string
+	jobLease time.Duration
+	// replayWake tells the replayer to look for unclaimed jobs again
+	replayWake chan struct{}
 }
 
-type NotificationQueue struct {
-	jobs         chan NotificationJob
-	store        *models.Store
-	workerCount  int
-	maxRetries   int
-	shutdownChan chan struct{}
-	wg           sync.WaitGroup
-	mu           sync.Mutex
+// replayBatchSize is how many dead letters ReplayDeadLetters reads at once
+const replayBatchSize = 100
+
+// deliveryTimeout bounds a single delivery through one channel
+const deliveryTimeout = 30 * time.Second
+
+const (
+	// DefaultBaseBackoff is the default delay before the first retry
+	DefaultBaseBackoff = time.Second
+	// DefaultMaxBackoff is the default cap on the delay before a retry
+	DefaultMaxBackoff = 30 * time.Second
+	// DefaultLaneMaxWait is how long a job waits by default before it is
+	// taken whatever its lane
+	DefaultLaneMaxWait = 30 * time.Second
+)
+
+// DefaultLaneWeights are the default shares of the workers the lanes get
+// while all of them have jobs waiting
+var DefaultLaneWeights = map[models.Priority]int{
+	models.PriorityHigh:   6,
+	models.PriorityNormal: 3,
+	models.PriorityLow:    1,
 }
 
-func NewNotificationQueue(store *models.Store, workerCount, maxRetries int) *NotificationQueue {
-	return &NotificationQueue{
-		jobs:         make(chan NotificationJob, 1000), // Buffer size of 1000
-		store:        store,
-		workerCount:  workerCount,
-		maxRetries:   maxRetries,
-		shutdownChan: make(chan struct{}),
-		mu:           sync.Mutex{},
+// NotificationQueueOption configures a NotificationQueue
+type NotificationQueueOption func(*NotificationQueue)
+
+// WithRetryBackoff sets the delay before the first retry of a job and the
+// most any retry of it waits. The delay doubles with every attempt.
+func WithRetryBackoff(base, max time.Duration) NotificationQueueOption {
+	return func(q *NotificationQueue) {
+		q.baseBackoff = base
+		q.maxBackoff = max
+	}
+}
+
+// WithAutoscaling lets the worker pool grow and shrink between minWorkers
+// and maxWorkers, starting from the size the queue was created with. Every
+// interval it grows when the due jobs cannot all be
+// started within targetWait, and shrinks while nothing waits and the
+// workers are mostly idle. Without it, the pool keeps the size the queue
+// was created with.
+func WithAutoscaling(minWorkers, maxWorkers int, interval, targetWait time.Duration) NotificationQueueOption {
+	return func(q *NotificationQueue) {
+		q.minWorkers = minWorkers
+		q.maxWorkers = maxWorkers
+		q.scaleInterval = interval
+		q.targetWait = targetWait
+	}
+}
+
+// WithLanes sets the weights of the priority lanes, replacing the defaults
+// for the priorities it names, and how long a job waits at most before it
+// is taken whatever its lane. Zero turns that starvation protection off.
+func WithLanes(weights map[models.Priority]int, maxWait time.Duration) NotificationQueueOption {
+	return func(q *NotificationQueue) {
+		maps.Copy(q.laneWeights, weights)
+		q.laneMaxWait = maxWait
+	}
+}
+
+// WithEventPriorities sets the priority of the notifications about each
+// event type. Event types it does not name are normal priority.
+func WithEventPriorities(priorities map[models.EventType]models.Priority) NotificationQueueOption {
+	return func(q *NotificationQueue) {
+		maps.Copy(q.eventPriorities, priorities)
+	}
+}
+
+// WithCoalescing holds the notifications of a recipient about the same kind
+// of activity of one author for window after the first one, and delivers
+// those that arrived within it as one aggregate notification listing their
+// posts, e.g. "alice posted 3 times". Zero disables coalescing.
+func WithCoalescing(window time.Duration) NotificationQueueOption {
+	return func(q *NotificationQueue) {
+		q.coalesceWindow = window
+	}
+}
+
+// WithDeduplication drops a notification if one for the same recipient,
+// post and event type was enqueued within window. Zero disables it.
+func WithDeduplication(window time.Duration) NotificationQueueOption {
+	return func(q *NotificationQueue) {
+		q.dedupWindow = window
+	}
+}
+
+// WithDeliverers adds delivery channels to the queue. A deliverer replaces
+// the one already registered for its channel, including the built-in in-app
+// deliverer.
+func WithDeliverers(deliverers ...delivery.Deliverer) NotificationQueueOption {
+	return func(q *NotificationQueue) {
+		for _, deliverer := range deliverers {
+			q.deliverers[deliverer.Channel()] = deliverer
+		}
+	}
+}
+
+// WithDefaultChannels sets the channels of the notifications that do not
+// name their own, in-app only by default
+func WithDefaultChannels(channels ...models.Channel) NotificationQueueOption {
+	return func(q *NotificationQueue) {
+		q.defaultChannels = channels
+	}
+}
+
+// NewNotificationQueue returns a queue that delivers in-app, to the store's
+// inbox and to the subscribers of Subscribe. Other channels are added with
+// WithDeliverers.
+func NewNotificationQueue(store storage.Store, workerCount, maxRetries int, opts ...NotificationQueueOption) *NotificationQueue {
+	q := &NotificationQueue{
+		laneWeights:     maps.Clone(DefaultLaneWeights),
+		laneMaxWait:     DefaultLaneMaxWait,
+		laneCapacity:    DefaultLaneCapacity,
+		overflow:        OverflowBlock,
+		enqueueTimeout:  DefaultEnqueueTimeout,
+		spilled:         make(map[models.Priority][]string),
+		eventPriorities: make(map[models.EventType]models.Priority),
+		notifications:   store,
+		journal:         store,
+		owner:           uuid.New().String(),
+		jobLease:        DefaultJobLease,
+		deadLetters:     store,
+		metrics:         store,
+		hub:             pubsub.NewHub(pubsub.DefaultBufferSize),
+		scheduler:       newScheduler(),
+		deliverers:      make(map[models.Channel]delivery.Deliverer),
+		defaultChannels: []models.Channel{models.ChannelInApp},
+		maxRetries:      maxRetries,
+		baseBackoff:     DefaultBaseBackoff,
+		maxBackoff:      DefaultMaxBackoff,
+		shutdownChan:    make(chan struct{}),
+		replayWake:      make(chan struct{}, 1),
+		mu:              sync.Mutex{},
+		workers:         workerCount,
+		minWorkers:      workerCount,
+		maxWorkers:      workerCount,
+		scaleInterval:   DefaultScaleInterval,
+		targetWait:      DefaultTargetWait,
+	}
+	q.deliverers[models.ChannelInApp] = delivery.NewInApp(store, q.hub)
+	for _, opt := range opts {
+		opt(q)
+	}
+	q.maxBackoff = max(q.maxBackoff, q.baseBackoff)
+	q.minWorkers = max(q.minWorkers, 1)
+	q.maxWorkers = max(q.maxWorkers, q.minWorkers)
+	q.workers = min(max(q.workers, q.minWorkers), q.maxWorkers)
+	if q.scaleInterval <= 0 {
+		q.scaleInterval = DefaultScaleInterval
+	}
+	if q.targetWait <= 0 {
+		q.targetWait = DefaultTargetWait
 	}
+	if q.jobLease <= 0 {
+		q.jobLease = DefaultJobLease
+	}
+	q.lanes = newLanes(q.laneWeights, max(q.laneCapacity, 1), q.laneMaxWait)
+	if q.enqueueTimeout <= 0 {
+		q.enqueueTimeout = DefaultEnqueueTimeout
+	}
+	if q.coalesceWindow > 0 {
+		q.coalescer = newCoalescer(q.coalesceWindow, q.flushCoalesced)
+	}
+	if q.dedupWindow > 0 {
+		q.deduplicator = newDeduplicator(q.dedupWindow)
+	}
+	return q
 }
 
+// Start launches the workers and replays the jobs left over from a previous
+// run. Jobs that another queue sharing the journal holds a lease on are left
+// to it, see WithJobLease.
 func (q *NotificationQueue) Start() {
-	log.Printf("Starting %d notification workers", q.workerCount)
-	for i := range make([]struct{}, q.workerCount) {
+	q.poolMu.Lock()
+	log.Printf("Starting %d notification workers", q.workers)
+	workers := q.workers
+	q.workers = 0
+	q.resize(workers)
+	q.started = true
+	if q.minWorkers < q.maxWorkers {
+		q.wg.Add(1)
+		go q.autoscale()
+	}
+	q.poolMu.Unlock()
+	if q.overflow == OverflowSpill {
 		q.wg.Add(1)
-		go q.worker(i)
+		go q.refill()
 	}
+	q.wg.Add(1)
+	go q.leases()
+	q.wg.Add(1)
+	go q.replayer()
+	q.wg.Add(1)
+	go func() {
+		defer q.wg.Done()
+		q.scheduler.run(q.lanes, q.shutdownChan)
+	}()
 }
 
+// Stop waits for the jobs in progress. Jobs still waiting in the buffer, for
+// a retry or to be coalesced stay in the journal and are replayed by the
+// next Start, or taken over by another queue sharing the journal.
 func (q *NotificationQueue) Stop() {
+	if q.coalescer != nil {
+		q.coalescer.stop()
+	}
+	q.lanes.close()
 	close(q.shutdownChan)
 	q.wg.Wait()
+	q.release()
+	q.hub.Close()
 	log.Println("Notification queue stopped")
 }
 
-func (q *NotificationQueue) EnqueueNotification(notification *models.Notification) {
-	q.jobs <- NotificationJob{
+// Lanes returns how many jobs wait in each lane, most urgent first
+func (q *NotificationQueue) Lanes() []LaneStats {
+	stats := q.lanes.stats()
+	spilled := q.spilledCounts()
+	for i := range stats {
+		stats[i].Spilled = spilled[stats[i].Priority]
+	}
+	return stats
+}
+
+// Subscribe returns a live feed of the notifications delivered to a user
+// from now on
+func (q *NotificationQueue) Subscribe(userID string) *pubsub.Subscription {
+	return q.hub.Subscribe(userID)
+}
+
+// EnqueueNotification journals a notification and queues it for delivery.
+// When its lane is full, the overflow policy decides whether to wait for
+// room until ctx is done, fail with ErrQueueFull or spill the job to the
+// journal, see WithOverflow. A duplicate dropped by WithDeduplication counts
+// as accepted. Notifications held for coalescing join their lane once the
+// window is over and are not subject to the overflow policy.
+func (q *NotificationQueue) EnqueueNotification(ctx context.Context, notification *models.Notification, opts ...EnqueueOption) error {
+	deduplicated := q.deduplicator != nil && notification.PostID != ""
+	if deduplicated && q.deduplicator.duplicate(notification, time.Now()) {
+		log.Printf("Dropping duplicate %s notification for user %s about post %s",
+			notification.EventType, notification.UserID, notification.PostID)
+		return nil
+	}
+
+	notification.Status = models.NotificationStatusPending
+	job := models.NotificationJob{
 		Notification: notification,
 		Attempt:      1,
+		Priority:     q.priority(notification.EventType),
+	}
+	for _, opt := range opts {
+		opt(&job)
+	}
+	err := q.save(context.Background(), &job)
+	if err != nil {
+		log.Printf("Failed to persist notification job for user %s: %v", notification.UserID, err)
+	}
+	if q.coalescer != nil && notification.AuthorID != "" {
+		q.coalescer.add(job)
+		return nil
+	}
+
+	if err := q.admit(ctx, job, err == nil); err != nil {
+		log.Printf("Turning away notification %s for user %s: %v", notification.ID, notification.UserID, err)
+		q.ack(job)
+		if deduplicated {
+			q.deduplicator.forget(notification)
+		}
+		return err
+	}
+	return nil
+}
+
+// EnqueueOption configures the job of a single notification
+type EnqueueOption func(*models.NotificationJob)
+
+// WithMaxBackoff caps how long any retry of the job waits, in place of the
+// maximum of the queue
+func WithMaxBackoff(max time.Duration) EnqueueOption {
+	return func(job *models.NotificationJob) {
+		job.MaxBackoff = max
+	}
+}
+
+// priority returns the priority of the jobs of notifications about eventType
+func (q *NotificationQueue) priority(eventType models.EventType) models.Priority {
+	if priority, ok := q.eventPriorities[eventType]; ok {
+		return priority
+	}
+	return models.PriorityNormal
+}
+
+// flushCoalesced hands the jobs a coalescing window collected to the
+// workers, merged into one when there are several. The aggregate is
+// journaled before the jobs it replaces are acked, so a crash in between
+// delivers a notification twice rather than not at all.
+func (q *NotificationQueue) flushCoalesced(jobs []models.NotificationJob) {
+	if len(jobs) == 1 {
+		q.scheduler.schedule(jobs[0], time.Time{})
+		return
+	}
+
+	notifications := make([]*models.Notification, len(jobs))
+	for i, job := range jobs {
+		notifications[i] = job.Notification
+	}
+	// The merged jobs share an event type and so a priority. The aggregate
+	// waits as long between retries as the most patient of them.
+	job := models.NotificationJob{Notification: aggregate(notifications), Attempt: 1, Priority: jobs[0].Priority}
+	for _, merged := range jobs {
+		job.MaxBackoff = max(job.MaxBackoff, merged.MaxBackoff)
+	}
+	if err := q.save(context.Background(), &job); err != nil {
+		log.Printf("Failed to persist coalesced notification for user %s, delivering %d notifications one by one: %v",
+			job.Notification.UserID, len(jobs), err)
+		for _, job := range jobs {
+			q.scheduler.schedule(job, time.Time{})
+		}
+		return
+	}
+	for _, merged := range jobs {
+		q.ack(merged)
+	}
+
+	log.Printf("Coalesced %d notifications for user %s about %s", len(jobs), job.Notification.UserID, job.Notification.AuthorID)
+	q.scheduler.schedule(job, time.Time{})
+}
+
+// retry journals the next attempt of a job and leaves it to the scheduler
+// until the delay has passed
+func (q *NotificationQueue) retry(job models.NotificationJob, delay time.Duration) {
+	next := models.NotificationJob{
+		Notification: job.Notification,
+		Attempt:      job.Attempt + 1,
+		DueAt:        time.Now().Add(delay),
+		Attempts:     job.Attempts,
+		Priority:     job.Priority,
+		MaxBackoff:   job.MaxBackoff,
+	}
+	if err := q.save(context.Background(), &next); err != nil {
+		log.Printf("Failed to persist notification job for user %s: %v", next.Notification.UserID, err)
+	}
+	q.scheduler.schedule(next, next.DueAt)
+}
+
+// retryDelay returns how long to wait after a failed attempt of a job. The
+// backoff starts at baseBackoff and doubles with every attempt up to the
+// maximum of the job, or maxBackoff if it has none, and a random part of its
+// second half is taken off, so jobs that failed together do not all retry at
+// the same moment.
+func (q *NotificationQueue) retryDelay(job models.NotificationJob) time.Duration {
+	maxBackoff := q.maxBackoff
+	if job.MaxBackoff > 0 {
+		maxBackoff = job.MaxBackoff
+	}
+	backoff := q.baseBackoff
+	for i := 1; i < job.Attempt && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, maxBackoff)
+
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(backoff-half)+1))
+}
+
+// ack removes a job the queue is done with from the journal
+func (q *NotificationQueue) ack(job models.NotificationJob) {
+	if err := q.journal.DeleteJob(context.Background(), job.Notification.ID); err != nil {
+		log.Printf("Failed to acknowledge notification job %s: %v", job.Notification.ID, err)
 	}
 }
 
@@ -61,81 +475,267 @@ func (q *NotificationQueue) worker(id int) {
 	log.Printf("Worker %d started", id)
 
 	for {
-		select {
-		case job := <-q.jobs:
-
-			startTime := time.Now()
-			success := q.processNotification(job)
-
-			timeTakenToDeliver := time.Since(startTime)
-
-			q.store.Mu.Lock()
-			if success {
-				if q.store.Metrics.TotalNotificationsSent == 0 {
-					// First successful notification
-					q.store.Metrics.AverageDeliveryTime = float64(timeTakenToDeliver)
-				} else {
-					// Update running average
-					q.store.Metrics.AverageDeliveryTime =
-						(q.store.Metrics.AverageDeliveryTime*float64(q.store.Metrics.TotalNotificationsSent) +
-							float64(timeTakenToDeliver)) / float64(q.store.Metrics.TotalNotificationsSent+1)
-				}
-				q.store.Metrics.TotalNotificationsSent++
-			} else {
-				q.store.Metrics.FailedAttempts++
-			}
-			q.store.Mu.Unlock()
-
-		case <-q.shutdownChan:
+		job, ok := q.lanes.pop(q.retire)
+		if !ok {
 			log.Printf("Worker %d shutting down", id)
 			return
 		}
+
+		q.busy.Add(1)
+		startTime := time.Now()
+		success := q.processNotification(job)
+
+		timeTakenToDeliver := time.Since(startTime)
+		q.busy.Add(-1)
+		q.busyTime.Add(int64(timeTakenToDeliver))
+		q.processed.Add(1)
+
+		var err error
+		if success {
+			err = q.metrics.RecordDelivery(context.Background(), timeTakenToDeliver)
+		} else {
+			err = q.metrics.RecordFailure(context.Background())
+		}
+		if err != nil {
+			log.Printf("Failed to record notification metrics: %v", err)
+		}
 	}
 }
 
-func (q *NotificationQueue) processNotification(job NotificationJob) bool {
-	notification := job.Notification
+// processNotification makes one delivery attempt through every channel that
+// has not delivered the notification yet, and moves the notification through
+// its lifecycle: delivering while the attempt runs, then delivered once all
+// channels succeeded, failed until its retry or dead-lettered after the last
+// one
+func (q *NotificationQueue) processNotification(job models.NotificationJob) bool {
+	// The previous attempt may still be referenced by whoever handed the job
+	// over, so update a copy
+	notification := *job.Notification
+	notification.Deliveries = slices.Clone(notification.Deliveries)
+	job.Notification = &notification
 	attempt := job.Attempt
 
-	//random delay betweek 20 to 100 ms for simulating network latency
-	time.Sleep(time.Duration(rand.Intn(80)+20) * time.Millisecond)
-
-	// Simulate delivery with 10% failure rate
-	if rand.Float64() < 0.1 {
-		log.Printf("Failed to send notification to user %s for post %s (attempt %d)",
-			notification.UserID, notification.PostID, attempt)
+	startedAt := time.Now()
+	notification.Status = models.NotificationStatusDelivering
+	notification.RetryCount = attempt - 1
+	if attempt > 1 {
+		notification.LastRetry = &startedAt
+	}
+	// Journal the status so it can be looked up while the attempt runs
+	if err := q.save(context.Background(), &job); err != nil {
+		log.Printf("Failed to persist notification job for user %s: %v", notification.UserID, err)
+	}
 
-		if attempt < q.maxRetries {
-			// Exponential backoff: 1s, 2s, 4s...
-			backoff := time.Duration(1<<(attempt-1)) * time.Second
-			log.Printf("Retrying in %v...", backoff)
+	failed := false
+	job.Attempts = slices.Clip(job.Attempts)
+	for _, channel := range q.channels(&notification) {
+		result := channelDelivery(&notification, channel)
+		if result.Status == models.NotificationStatusDelivered {
+			continue
+		}
 
-			time.Sleep(backoff)
+		result.Attempts++
+		err := q.deliver(channel, &notification)
+		attemptedAt := time.Now()
+		if err != nil {
+			log.Printf("Failed to send notification to user %s for post %s via %s (attempt %d): %v",
+				notification.UserID, notification.PostID, channel, attempt, err)
+			failed = true
+			result.Status = models.NotificationStatusFailed
+			result.LastError = err.Error()
+			job.Attempts = append(job.Attempts, models.DeliveryAttempt{
+				Attempt: attempt,
+				Channel: channel,
+				At:      attemptedAt,
+				Error:   err.Error(),
+			})
+			continue
+		}
+		result.Status = models.NotificationStatusDelivered
+		result.LastError = ""
+		result.DeliveredAt = &attemptedAt
+		job.Attempts = append(job.Attempts, models.DeliveryAttempt{Attempt: attempt, Channel: channel, At: attemptedAt})
+	}
 
-			q.jobs <- NotificationJob{
-				Notification: notification,
-				Attempt:      attempt + 1,
-			}
+	if failed {
+		notification.Status = models.NotificationStatusFailed
+		if attempt < q.maxRetries {
+			delay := q.retryDelay(job)
+			log.Printf("Retrying in %v...", delay)
+			q.record(&notification)
+			q.retry(job, delay)
 		} else {
 			log.Printf("Max retries exceeded for notification to user %s for post %s",
 				notification.UserID, notification.PostID)
+			q.deadLetter(job)
 		}
 		return false
 	}
 
-	// Simulation of successful delivery
 	log.Printf("Notification sent to user %s for post %s",
 		notification.UserID, notification.PostID)
+	deliveredAt := time.Now()
+	notification.Status = models.NotificationStatusDelivered
+	notification.DeliveredAt = &deliveredAt
+	q.record(&notification)
+	q.ack(job)
+
+	return true
+}
+
+// Job returns the journaled job of a notification. It fails with
+// storage.ErrNotFound once the queue delivered or dead-lettered the
+// notification.
+func (q *NotificationQueue) Job(ctx context.Context, notificationID string) (*models.NotificationJob, error) {
+	return q.journal.GetJob(ctx, notificationID)
+}
+
+// HasChannel tells whether the queue has a deliverer for a channel
+func (q *NotificationQueue) HasChannel(channel models.Channel) bool {
+	_, ok := q.deliverers[channel]
+	return ok
+}
+
+// DefaultChannels returns the channels of notifications that do not name any
+func (q *NotificationQueue) DefaultChannels() []models.Channel {
+	return slices.Clone(q.defaultChannels)
+}
 
-	// Store notification in user's list
+// channels returns the channels a notification goes through
+func (q *NotificationQueue) channels(notification *models.Notification) []models.Channel {
+	if len(notification.Channels) > 0 {
+		return notification.Channels
+	}
+	return q.defaultChannels
+}
+
+// channelDelivery returns the outcome of a channel recorded on the
+// notification, adding a pending one the first time the channel is tried
+func channelDelivery(notification *models.Notification, channel models.Channel) *models.ChannelDelivery {
+	for i := range notification.Deliveries {
+		if notification.Deliveries[i].Channel == channel {
+			return &notification.Deliveries[i]
+		}
+	}
+	notification.Deliveries = append(notification.Deliveries, models.ChannelDelivery{
+		Channel: channel,
+		Status:  models.NotificationStatusPending,
+	})
+	return &notification.Deliveries[len(notification.Deliveries)-1]
+}
 
-	q.store.Mu.Lock()
-	userID := notification.UserID
-	if _, exists := q.store.Notifications[userID]; !exists {
-		q.store.Notifications[userID] = []*models.Notification{}
+// deliver sends a notification through one channel
+func (q *NotificationQueue) deliver(channel models.Channel, notification *models.Notification) error {
+	deliverer, ok := q.deliverers[channel]
+	if !ok {
+		return fmt.Errorf("no deliverer configured for channel %s", channel)
 	}
-	q.store.Notifications[userID] = append(q.store.Notifications[userID], notification)
-	q.store.Mu.Unlock()
 
-	return true
+	ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+	defer cancel()
+	return deliverer.Deliver(ctx, notification)
+}
+
+// record saves the delivery state of a notification on its copy in the
+// inbox. Notifications that do not go in-app have none.
+func (q *NotificationQueue) record(notification *models.Notification) {
+	err := q.notifications.UpdateNotificationDelivery(context.Background(), notification)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		log.Printf("Failed to record the delivery of notification %s: %v", notification.ID, err)
+	}
+}
+
+// deadLetter moves a job that ran out of retries to the dead-letter store.
+// If that fails, the job stays in the journal and is retried after a restart.
+func (q *NotificationQueue) deadLetter(job models.NotificationJob) {
+	notification := *job.Notification
+	notification.Status = models.NotificationStatusDeadLettered
+	q.record(&notification)
+
+	last := lastFailure(job.Attempts)
+	deadLetter := &models.DeadLetter{
+		Notification: &notification,
+		Reason:       fmt.Sprintf("gave up after %d attempts, last error: %s: %s", last.Attempt, last.Channel, last.Error),
+		Attempts:     job.Attempts,
+		FailedAt:     last.At,
+	}
+	if err := q.deadLetters.AddDeadLetter(context.Background(), deadLetter); err != nil {
+		log.Printf("Failed to dead-letter notification %s: %v", notification.ID, err)
+		return
+	}
+	q.ack(job)
+}
+
+// lastFailure returns the latest failed attempt. Channels that delivered
+// the notification during the same attempt may have been tried after it.
+func lastFailure(attempts []models.DeliveryAttempt) models.DeliveryAttempt {
+	for i := len(attempts) - 1; i > 0; i-- {
+		if attempts[i].Error != "" {
+			return attempts[i]
+		}
+	}
+	return attempts[0]
+}
+
+// ReplayDeadLetter moves a dead-lettered notification back into the queue
+// for a fresh round of attempts. It fails with storage.ErrNotFound if the
+// notification is not dead-lettered.
+func (q *NotificationQueue) ReplayDeadLetter(ctx context.Context, notificationID string) error {
+	deadLetter, err := q.deadLetters.GetDeadLetter(ctx, notificationID)
+	if err != nil {
+		return err
+	}
+	return q.replay(ctx, deadLetter)
+}
+
+// ReplayDeadLetters moves every dead-lettered notification back into the
+// queue and returns how many there were. Notifications dead-lettered while
+// it runs, including replayed ones that fail again, are left for later.
+func (q *NotificationQueue) ReplayDeadLetters(ctx context.Context) (int, error) {
+	last, err := q.deadLetters.LastDeadLetterSeq(ctx)
+	if err != nil {
+		return 0, err
+	}
+	replayed := 0
+	var afterSeq int64
+	for afterSeq < last {
+		batch, err := q.deadLetters.ListDeadLetters(ctx, afterSeq, replayBatchSize)
+		if err != nil || len(batch) == 0 {
+			return replayed, err
+		}
+		for _, deadLetter := range batch {
+			if deadLetter.Seq > last {
+				return replayed, nil
+			}
+			if err := q.replay(ctx, deadLetter); err != nil {
+				return replayed, err
+			}
+			replayed++
+			afterSeq = deadLetter.Seq
+		}
+	}
+	return replayed, nil
+}
+
+// replay journals a new job for a dead letter before removing it, so the
+// notification is never lost in between
+func (q *NotificationQueue) replay(ctx context.Context, deadLetter *models.DeadLetter) error {
+	notification := *deadLetter.Notification
+	notification.Status = models.NotificationStatusPending
+	notification.RetryCount = 0
+	notification.LastRetry = nil
+	// Channels that delivered the notification before are not tried again
+
+	job := models.NotificationJob{Notification: &notification, Attempt: 1, Priority: q.priority(notification.EventType)}
+	if err := q.save(ctx, &job); err != nil {
+		return err
+	}
+	err := q.deadLetters.DeleteDeadLetter(ctx, notification.ID)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+
+	log.Printf("Replaying dead-lettered notification %s for user %s", notification.ID, notification.UserID)
+	q.scheduler.schedule(job, time.Time{})
+	return nil
 }
@@ -1,20 +1,26 @@
 package queue_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/iwhitebird/social-app-microservices/internal/delivery"
 	"github.com/iwhitebird/social-app-microservices/internal/models"
 	"github.com/iwhitebird/social-app-microservices/internal/queue"
+	"github.com/iwhitebird/social-app-microservices/internal/storage"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNotificationQueue(t *testing.T) {
 	// Create store
-	store := models.NewStore()
+	store := storage.NewMemoryStore()
 
 	// Create notification queue with 3 workers and max 2 retries
 	notificationQueue := queue.NewNotificationQueue(store, 3, 2)
@@ -32,23 +38,22 @@ func TestNotificationQueue(t *testing.T) {
 	}
 
 	// Send notification to queue
-	notificationQueue.EnqueueNotification(notification)
+	notificationQueue.EnqueueNotification(context.Background(), notification)
 
 	// Wait for processing to complete
 	time.Sleep(1 * time.Second)
 
 	// Check if notification was saved
-	store.Mu.Lock()
-	notifications, exists := store.Notifications["u1"]
-	store.Mu.Unlock()
+	notifications, err := store.ListNotifications(context.Background(), "u1", 0)
+	assert.NoError(t, err)
 
-	assert.True(t, exists, "Expected notifications to exist for user u1")
+	assert.True(t, len(notifications) > 0, "Expected notifications to exist for user u1")
 	assert.NotEmpty(t, notifications, "Expected at least one notification for user u1")
 }
 
 func TestConcurrentProcessing(t *testing.T) {
 	// Create store
-	store := models.NewStore()
+	store := storage.NewMemoryStore()
 
 	// Create notification queue with 5 workers and max 2 retries
 	notificationQueue := queue.NewNotificationQueue(store, 5, 2)
@@ -80,7 +85,7 @@ func TestConcurrentProcessing(t *testing.T) {
 		mu.Unlock()
 
 		// Send notification to queue
-		notificationQueue.EnqueueNotification(notification)
+		notificationQueue.EnqueueNotification(context.Background(), notification)
 	}
 
 	// Wait for processing to complete (adjust time as needed)
@@ -88,12 +93,11 @@ func TestConcurrentProcessing(t *testing.T) {
 
 	// Verify notifications were saved for each user
 	for userID, expectedCount := range userNotifications {
-		store.Mu.Lock()
-		notifications, exists := store.Notifications[userID]
+		notifications, err := store.ListNotifications(context.Background(), userID, 0)
+		assert.NoError(t, err)
 		notificationCount := len(notifications)
-		store.Mu.Unlock()
 
-		assert.True(t, exists, "Expected notifications to exist for user %s", userID)
+		assert.True(t, notificationCount > 0, "Expected notifications to exist for user %s", userID)
 
 		// Due to random failures (10% failure rate in the queue), we may not have all notifications
 		// But we should have at least some for each user
@@ -105,7 +109,8 @@ func TestConcurrentProcessing(t *testing.T) {
 	}
 
 	// Check metrics
-	assert.Greater(t, store.Metrics.TotalNotificationsSent, 0,
+	metrics := getMetrics(t, store)
+	assert.Greater(t, metrics.TotalNotificationsSent, 0,
 		"Expected some successful notifications")
 }
 
@@ -115,7 +120,7 @@ func TestRetryLogic(t *testing.T) {
 	// For now, we'll do a simple test to ensure the retry mechanism exists
 
 	// Create store
-	store := models.NewStore()
+	store := storage.NewMemoryStore()
 
 	// Create notification queue with 1 worker and max 3 retries
 	// This ensures we can observe the retry behavior more easily
@@ -134,7 +139,7 @@ func TestRetryLogic(t *testing.T) {
 			CreatedAt: time.Now(),
 		}
 
-		notificationQueue.EnqueueNotification(notification)
+		notificationQueue.EnqueueNotification(context.Background(), notification)
 	}
 
 	// Wait longer to allow for retries
@@ -142,18 +147,19 @@ func TestRetryLogic(t *testing.T) {
 
 	// We can't assert exact numbers because of the random nature,
 	// but we can check that some metrics were updated
-	assert.GreaterOrEqual(t, store.Metrics.TotalNotificationsSent, 0)
+	metrics := getMetrics(t, store)
+	assert.GreaterOrEqual(t, metrics.TotalNotificationsSent, 0)
 
 	// Log metrics for information
 	t.Logf("Metrics - Sent: %d, Failed: %d, Avg Time: %f",
-		store.Metrics.TotalNotificationsSent,
-		store.Metrics.FailedAttempts,
-		store.Metrics.AverageDeliveryTime)
+		metrics.TotalNotificationsSent,
+		metrics.FailedAttempts,
+		metrics.AverageDeliveryTime)
 }
 
 func TestQueueShutdown(t *testing.T) {
 	// Create store
-	store := models.NewStore()
+	store := storage.NewMemoryStore()
 
 	// Create notification queue with 3 workers and max 2 retries
 	notificationQueue := queue.NewNotificationQueue(store, 3, 2)
@@ -170,7 +176,7 @@ func TestQueueShutdown(t *testing.T) {
 			CreatedAt: time.Now(),
 		}
 
-		notificationQueue.EnqueueNotification(notification)
+		notificationQueue.EnqueueNotification(context.Background(), notification)
 	}
 
 	// Wait briefly for processing to start
@@ -182,16 +188,601 @@ func TestQueueShutdown(t *testing.T) {
 	// The test passes if Stop() doesn't hang indefinitely
 	// We can't easily assert the completion of all pending tasks,
 	// but we can check that some notifications were processed
-	store.Mu.Lock()
-	_, exists := store.Notifications["shutdown-test-user"]
-	store.Mu.Unlock()
+	notifications, err := store.ListNotifications(context.Background(), "shutdown-test-user", 0)
+	assert.NoError(t, err)
 
-	assert.True(t, exists, "Expected some notifications to be processed before shutdown")
+	assert.True(t, len(notifications) > 0, "Expected some notifications to be processed before shutdown")
+}
+
+func TestQueueReplaysPendingJobs(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "social.db")
+
+	store, err := storage.NewSQLiteStore(path)
+	require.NoError(t, err)
+
+	// Enqueue on a queue whose workers never run, like a process that
+	// crashed before delivering anything
+	crashed := queue.NewNotificationQueue(store, 1, 5, queue.WithJobLease(100*time.Millisecond))
+	var notifications []*models.Notification
+	for i := 0; i < 5; i++ {
+		notification := &models.Notification{
+			ID:        uuid.New().String(),
+			UserID:    "replay-test-user",
+			PostID:    "p1",
+			Content:   fmt.Sprintf("Replay test notification %d", i),
+			CreatedAt: time.Now(),
+		}
+		notifications = append(notifications, notification)
+		crashed.EnqueueNotification(context.Background(), notification)
+	}
+	// The first one made it to storage, but was never acknowledged
+	require.NoError(t, store.AddNotification(ctx, notifications[0]))
+	require.NoError(t, store.Close())
+
+	store, err = storage.NewSQLiteStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+
+	jobs, err := store.ListJobs(ctx)
+	require.NoError(t, err)
+	require.Len(t, jobs, 5)
+
+	// The next process delivers every job exactly once, taking them over
+	// once the lease of the crashed one ran out
+	restarted := queue.NewNotificationQueue(store, 2, 5, queue.WithJobLease(100*time.Millisecond))
+	restarted.Start()
+	defer restarted.Stop()
+
+	assert.Eventually(t, func() bool {
+		jobs, err := store.ListJobs(ctx)
+		return err == nil && len(jobs) == 0
+	}, 10*time.Second, 20*time.Millisecond, "Expected every replayed job to be acknowledged")
+
+	stored, err := store.ListNotifications(ctx, "replay-test-user", 0)
+	require.NoError(t, err)
+	assert.Len(t, stored, len(notifications))
+}
+
+func TestQueuesSharingAJournal(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.NewSQLiteStore(filepath.Join(t.TempDir(), "social.db"))
+	require.NoError(t, err)
+	defer store.Close()
+
+	// One process has jobs waiting for its workers
+	busy := queue.NewNotificationQueue(store, 1, 5)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, busy.EnqueueNotification(ctx, &models.Notification{
+			ID:        fmt.Sprintf("n%d", i),
+			UserID:    "shared-user",
+			CreatedAt: time.Now(),
+		}))
+	}
+
+	// Another one starting on the same database leaves them alone
+	other := queue.NewNotificationQueue(store, 2, 5, queue.WithJobLease(30*time.Millisecond))
+	other.Start()
+	defer other.Stop()
+	time.Sleep(100 * time.Millisecond)
+	jobs, err := store.ListJobs(ctx)
+	require.NoError(t, err)
+	assert.Len(t, jobs, 3)
+
+	// until the first one stops and releases them
+	busy.Stop()
+	assert.Eventually(t, func() bool {
+		jobs, err := store.ListJobs(ctx)
+		return err == nil && len(jobs) == 0
+	}, 5*time.Second, 20*time.Millisecond)
+	stored, err := store.ListNotifications(ctx, "shared-user", 0)
+	require.NoError(t, err)
+	assert.Len(t, stored, 3)
+}
+
+func TestExhaustedJobsAreDeadLettered(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+
+	// Every tenth notification cannot be delivered, and without retries it
+	// ends up dead-lettered right away
+	failing := make(map[string]bool)
+	inApp := delivery.NewMock(models.ChannelInApp,
+		delivery.WithFailFunc(func(notification *models.Notification) bool { return failing[notification.ID] }),
+		delivery.WithNext(delivery.NewInApp(store, nil)))
+	notificationQueue := queue.NewNotificationQueue(store, 20, 1, queue.WithDeliverers(inApp))
+
+	count := 200
+	var notifications []*models.Notification
+	for i := 0; i < count; i++ {
+		notification := &models.Notification{
+			ID:        uuid.New().String(),
+			UserID:    "dead-letter-test-user",
+			PostID:    "p1",
+			Content:   fmt.Sprintf("Dead letter test notification %d", i),
+			CreatedAt: time.Now(),
+		}
+		failing[notification.ID] = i%10 == 0
+		notifications = append(notifications, notification)
+	}
+
+	notificationQueue.Start()
+	defer notificationQueue.Stop()
+	for _, notification := range notifications {
+		notificationQueue.EnqueueNotification(context.Background(), notification)
+	}
+
+	assert.Eventually(t, func() bool {
+		jobs, err := store.ListJobs(ctx)
+		return err == nil && len(jobs) == 0
+	}, 5*time.Second, 20*time.Millisecond, "Expected every job to be delivered or dead-lettered")
+
+	delivered, err := store.ListNotifications(ctx, "dead-letter-test-user", 0)
+	require.NoError(t, err)
+	deadLetters, err := store.ListDeadLetters(ctx, 0, 0)
+	require.NoError(t, err)
+	assert.Len(t, delivered, count-count/10)
+	assert.Len(t, deadLetters, count/10)
+
+	for _, deadLetter := range deadLetters {
+		assert.True(t, failing[deadLetter.Notification.ID])
+		assert.Equal(t, models.NotificationStatusDeadLettered, deadLetter.Notification.Status)
+		assert.Zero(t, deadLetter.Notification.RetryCount)
+		assert.Nil(t, deadLetter.Notification.DeliveredAt)
+		assert.Contains(t, deadLetter.Reason, delivery.ErrMockFailure.Error())
+		require.Len(t, deadLetter.Attempts, 1)
+		assert.Equal(t, 1, deadLetter.Attempts[0].Attempt)
+		assert.Equal(t, models.ChannelInApp, deadLetter.Attempts[0].Channel)
+		assert.Equal(t, delivery.ErrMockFailure.Error(), deadLetter.Attempts[0].Error)
+	}
+}
+
+func TestNotificationLifecycle(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+
+	// The first attempt of every third notification fails
+	inApp := delivery.NewMock(models.ChannelInApp,
+		delivery.WithFailFunc(func(notification *models.Notification) bool {
+			var i int
+			fmt.Sscanf(notification.Content, "Lifecycle test notification %d", &i)
+			return i%3 == 0 && notification.RetryCount == 0
+		}),
+		delivery.WithNext(delivery.NewInApp(store, nil)))
+	notificationQueue := queue.NewNotificationQueue(store, 10, 10,
+		queue.WithRetryBackoff(10*time.Millisecond, 50*time.Millisecond),
+		queue.WithDeliverers(inApp))
+	notificationQueue.Start()
+	defer notificationQueue.Stop()
+
+	count := 99
+	for i := 0; i < count; i++ {
+		notification := &models.Notification{
+			ID:        uuid.New().String(),
+			UserID:    "lifecycle-test-user",
+			PostID:    "p1",
+			Content:   fmt.Sprintf("Lifecycle test notification %d", i),
+			CreatedAt: time.Now(),
+		}
+		notificationQueue.EnqueueNotification(context.Background(), notification)
+		assert.Equal(t, models.NotificationStatusPending, notification.Status)
+	}
+
+	var delivered []*models.Notification
+	require.Eventually(t, func() bool {
+		var err error
+		delivered, err = store.ListNotifications(ctx, "lifecycle-test-user", 0)
+		if err != nil || len(delivered) != count {
+			return false
+		}
+		// The inbox copy is updated once the whole notification is delivered
+		for _, notification := range delivered {
+			if notification.DeliveredAt == nil || notification.Deliveries[0].Status != models.NotificationStatusDelivered {
+				return false
+			}
+		}
+		return true
+	}, 10*time.Second, 20*time.Millisecond, "Expected every notification to be delivered")
+
+	retried := 0
+	for _, notification := range delivered {
+		assert.Equal(t, models.NotificationStatusDelivered, notification.Status)
+		require.NotNil(t, notification.DeliveredAt)
+		assert.False(t, notification.DeliveredAt.Before(notification.CreatedAt))
+
+		if notification.RetryCount == 0 {
+			assert.Nil(t, notification.LastRetry)
+			continue
+		}
+		retried++
+		require.NotNil(t, notification.LastRetry)
+		assert.False(t, notification.DeliveredAt.Before(*notification.LastRetry))
+	}
+	assert.Equal(t, count/3, retried)
+	assert.Equal(t, count+count/3, inApp.Attempts())
+}
+
+func TestDeliveryChannels(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+
+	// The webhook fails its first attempt at every notification
+	webhook := delivery.NewMock(models.ChannelWebhook, delivery.WithFailFunc(func(notification *models.Notification) bool {
+		return notification.RetryCount == 0
+	}))
+	inApp := delivery.NewMock(models.ChannelInApp, delivery.WithNext(delivery.NewInApp(store, nil)))
+	notificationQueue := queue.NewNotificationQueue(store, 2, 3,
+		queue.WithRetryBackoff(10*time.Millisecond, 50*time.Millisecond),
+		queue.WithDeliverers(webhook, inApp),
+		queue.WithDefaultChannels(models.ChannelInApp, models.ChannelWebhook))
+	notificationQueue.Start()
+	defer notificationQueue.Stop()
+
+	notificationQueue.EnqueueNotification(context.Background(), &models.Notification{
+		ID:        "both-channels",
+		UserID:    "channels-test-user",
+		CreatedAt: time.Now(),
+	})
+	// Email has no deliverer, so this one can never be delivered
+	notificationQueue.EnqueueNotification(context.Background(), &models.Notification{
+		ID:        "email-only",
+		UserID:    "channels-test-user",
+		CreatedAt: time.Now(),
+		Channels:  []models.Channel{models.ChannelEmail},
+	})
+
+	require.Eventually(t, func() bool {
+		jobs, err := store.ListJobs(ctx)
+		return err == nil && len(jobs) == 0
+	}, 5*time.Second, 20*time.Millisecond, "Expected both jobs to be acknowledged")
+
+	// The retry only went through the webhook again
+	assert.Equal(t, 1, inApp.Attempts())
+	assert.Equal(t, 2, webhook.Attempts())
+
+	inbox, err := store.ListNotifications(ctx, "channels-test-user", 0)
+	require.NoError(t, err)
+	require.Len(t, inbox, 1)
+	notification := inbox[0]
+	assert.Equal(t, "both-channels", notification.ID)
+	assert.Equal(t, models.NotificationStatusDelivered, notification.Status)
+	assert.Equal(t, 1, notification.RetryCount)
+	require.Len(t, notification.Deliveries, 2)
+	for _, result := range notification.Deliveries {
+		assert.Equal(t, models.NotificationStatusDelivered, result.Status, "channel %s", result.Channel)
+		assert.NotNil(t, result.DeliveredAt, "channel %s", result.Channel)
+	}
+	assert.Equal(t, models.ChannelInApp, notification.Deliveries[0].Channel)
+	assert.Equal(t, 1, notification.Deliveries[0].Attempts)
+	assert.Equal(t, models.ChannelWebhook, notification.Deliveries[1].Channel)
+	assert.Equal(t, 2, notification.Deliveries[1].Attempts)
+
+	deadLetter, err := store.GetDeadLetter(ctx, "email-only")
+	require.NoError(t, err)
+	assert.Contains(t, deadLetter.Reason, "no deliverer configured for channel email")
+	require.Len(t, deadLetter.Notification.Deliveries, 1)
+	assert.Equal(t, models.NotificationStatusFailed, deadLetter.Notification.Deliveries[0].Status)
+	assert.Equal(t, 3, deadLetter.Notification.Deliveries[0].Attempts)
+	assert.Len(t, deadLetter.Attempts, 3)
+}
+
+func TestJobLifecycle(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+
+	// The in-app channel holds its first attempt until the gate opens, and
+	// the webhook fails it
+	gate := make(chan struct{})
+	inApp := delivery.NewMock(models.ChannelInApp, delivery.WithFailFunc(func(notification *models.Notification) bool {
+		if notification.RetryCount == 0 {
+			<-gate
+		}
+		return false
+	}))
+	webhook := delivery.NewMock(models.ChannelWebhook, delivery.WithFailFunc(func(notification *models.Notification) bool {
+		return notification.RetryCount == 0
+	}))
+	notificationQueue := queue.NewNotificationQueue(store, 1, 3,
+		queue.WithRetryBackoff(300*time.Millisecond, 300*time.Millisecond),
+		queue.WithDeliverers(inApp, webhook),
+		queue.WithDefaultChannels(models.ChannelInApp, models.ChannelWebhook))
+	notificationQueue.Start()
+	defer notificationQueue.Stop()
+
+	require.NoError(t, notificationQueue.EnqueueNotification(ctx, &models.Notification{ID: "n1", UserID: "u1", CreatedAt: time.Now()}))
+
+	// The journal shows the attempt while it runs
+	require.Eventually(t, func() bool {
+		job, err := notificationQueue.Job(ctx, "n1")
+		return err == nil && job.Notification.Status == models.NotificationStatusDelivering
+	}, time.Second, time.Millisecond)
+	close(gate)
+
+	// then its outcome on every channel until the retry
+	var job *models.NotificationJob
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = notificationQueue.Job(ctx, "n1")
+		return err == nil && job.Notification.Status == models.NotificationStatusFailed
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, 2, job.Attempt)
+	assert.False(t, job.DueAt.IsZero())
+	require.Len(t, job.Attempts, 2)
+	assert.Equal(t, models.ChannelInApp, job.Attempts[0].Channel)
+	assert.Empty(t, job.Attempts[0].Error)
+	assert.Equal(t, models.ChannelWebhook, job.Attempts[1].Channel)
+	assert.Equal(t, delivery.ErrMockFailure.Error(), job.Attempts[1].Error)
+
+	// The queue is done with the notification once it is delivered
+	require.Eventually(t, func() bool {
+		_, err := notificationQueue.Job(ctx, "n1")
+		return errors.Is(err, storage.ErrNotFound)
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, inApp.Attempts())
+	assert.Equal(t, 2, webhook.Attempts())
+}
+
+func TestReplayDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, store.AddDeadLetter(ctx, &models.DeadLetter{
+			Notification: &models.Notification{
+				ID:        fmt.Sprintf("dead-%d", i),
+				UserID:    "replay-dead-user",
+				PostID:    "p1",
+				Content:   "Dead letter",
+				CreatedAt: time.Now(),
+			},
+			Reason:   "gave up",
+			Attempts: []models.DeliveryAttempt{{Attempt: 1, At: time.Now(), Error: "timeout"}},
+			FailedAt: time.Now(),
+		}))
+	}
+
+	notificationQueue := queue.NewNotificationQueue(store, 2, 10, queue.WithRetryBackoff(10*time.Millisecond, 50*time.Millisecond))
+	notificationQueue.Start()
+	defer notificationQueue.Stop()
+
+	assert.ErrorIs(t, notificationQueue.ReplayDeadLetter(ctx, "missing"), storage.ErrNotFound)
+
+	// Replay one, then the rest
+	require.NoError(t, notificationQueue.ReplayDeadLetter(ctx, "dead-1"))
+	_, err := store.GetDeadLetter(ctx, "dead-1")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	replayed, err := notificationQueue.ReplayDeadLetters(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, replayed)
+
+	assert.Eventually(t, func() bool {
+		delivered, err := store.ListNotifications(ctx, "replay-dead-user", 0)
+		return err == nil && len(delivered) == 3
+	}, 5*time.Second, 20*time.Millisecond, "Expected the replayed notifications to be delivered")
+
+	remaining, err := store.CountDeadLetters(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, remaining)
+
+	// Replayed notifications start over
+	delivered, err := store.ListNotifications(ctx, "replay-dead-user", 0)
+	require.NoError(t, err)
+	for _, notification := range delivered {
+		assert.Equal(t, models.NotificationStatusDelivered, notification.Status)
+		assert.Equal(t, notification.RetryCount == 0, notification.LastRetry == nil)
+	}
+}
+
+// slowReplayStore holds back removing the last dead letter of a replay until
+// the first one has been dead-lettered again
+type slowReplayStore struct {
+	*storage.MemoryStore
+	first, last string
+}
+
+func (s *slowReplayStore) DeleteDeadLetter(ctx context.Context, notificationID string) error {
+	if notificationID == s.last {
+		for {
+			if _, err := s.GetDeadLetter(ctx, s.first); err == nil {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	return s.MemoryStore.DeleteDeadLetter(ctx, notificationID)
+}
+
+func TestReplayDeadLettersThatFailAgain(t *testing.T) {
+	ctx := context.Background()
+	store := &slowReplayStore{MemoryStore: storage.NewMemoryStore(), first: "dead-0", last: "dead-2"}
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, store.AddDeadLetter(ctx, &models.DeadLetter{
+			Notification: &models.Notification{ID: fmt.Sprintf("dead-%d", i), UserID: "u1", CreatedAt: time.Now()},
+			Reason:       "gave up",
+			FailedAt:     time.Now(),
+		}))
+	}
+
+	inApp := delivery.NewMock(models.ChannelInApp, delivery.WithFailFunc(func(notification *models.Notification) bool {
+		return true
+	}))
+	notificationQueue := queue.NewNotificationQueue(store, 1, 0, queue.WithDeliverers(inApp))
+	notificationQueue.Start()
+	defer notificationQueue.Stop()
+
+	// A notification that is dead-lettered again while the replay runs is
+	// not replayed a second time
+	replayed, err := notificationQueue.ReplayDeadLetters(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, replayed)
+
+	assert.Eventually(t, func() bool {
+		remaining, err := store.CountDeadLetters(ctx)
+		return err == nil && remaining == 3
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestCoalescing(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	notificationQueue := queue.NewNotificationQueue(store, 2, 2, queue.WithCoalescing(200*time.Millisecond))
+	notificationQueue.Start()
+	defer notificationQueue.Stop()
+
+	post := func(id, authorID, postID string) *models.Notification {
+		return &models.Notification{
+			ID:        id,
+			UserID:    "u1",
+			PostID:    postID,
+			AuthorID:  authorID,
+			EventType: models.EventTypeNewPost,
+			Content:   authorID + " posted",
+			CreatedAt: time.Now(),
+		}
+	}
+	for i := 1; i <= 3; i++ {
+		notificationQueue.EnqueueNotification(context.Background(), post(fmt.Sprintf("alice%d", i), "alice", fmt.Sprintf("p%d", i)))
+	}
+	notificationQueue.EnqueueNotification(context.Background(), post("bob1", "bob", "p4"))
+
+	// Nothing is delivered before the window is over
+	time.Sleep(100 * time.Millisecond)
+	inbox, err := store.ListNotifications(ctx, "u1", 0)
+	require.NoError(t, err)
+	assert.Empty(t, inbox)
+
+	// Then alice's posts arrive as one notification, bob's on its own
+	require.Eventually(t, func() bool {
+		inbox, err = store.ListNotifications(ctx, "u1", 0)
+		require.NoError(t, err)
+		return len(inbox) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+	byAuthor := map[string]*models.Notification{}
+	for _, n := range inbox {
+		byAuthor[n.AuthorID] = n
+	}
+	require.Contains(t, byAuthor, "alice")
+	assert.Equal(t, "alice posted 3 times", byAuthor["alice"].Content)
+	assert.Equal(t, []string{"p1", "p2", "p3"}, byAuthor["alice"].PostIDs)
+	assert.Equal(t, "p3", byAuthor["alice"].PostID)
+	require.Contains(t, byAuthor, "bob")
+	assert.Equal(t, "bob1", byAuthor["bob"].ID)
+	assert.Empty(t, byAuthor["bob"].PostIDs)
+
+	// The merged jobs were acked along with the aggregate
+	require.Eventually(t, func() bool {
+		jobs, err := store.ListJobs(ctx)
+		require.NoError(t, err)
+		return len(jobs) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestCoalescedJobsSurviveStop(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	notificationQueue := queue.NewNotificationQueue(store, 1, 2, queue.WithCoalescing(time.Hour))
+	notificationQueue.Start()
+	notificationQueue.EnqueueNotification(context.Background(), &models.Notification{
+		ID: "n1", UserID: "u1", PostID: "p1", AuthorID: "alice", EventType: models.EventTypeNewPost, CreatedAt: time.Now(),
+	})
+	notificationQueue.Stop()
+
+	// The job held for coalescing stays journaled for the next start
+	jobs, err := store.ListJobs(ctx)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, "n1", jobs[0].Notification.ID)
+}
+
+func TestDeduplication(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	notificationQueue := queue.NewNotificationQueue(store, 2, 2, queue.WithDeduplication(time.Hour))
+	notificationQueue.Start()
+	defer notificationQueue.Stop()
+
+	// The same post announced twice to a user, e.g. by a fan-out that ran
+	// twice, is only delivered once
+	for _, id := range []string{"n1", "n2"} {
+		notificationQueue.EnqueueNotification(context.Background(), &models.Notification{
+			ID: id, UserID: "u1", PostID: "p1", EventType: models.EventTypeNewPost, CreatedAt: time.Now(),
+		})
+	}
+	notificationQueue.EnqueueNotification(context.Background(), &models.Notification{
+		ID: "n3", UserID: "u1", PostID: "p2", EventType: models.EventTypeNewPost, CreatedAt: time.Now(),
+	})
+
+	require.Eventually(t, func() bool {
+		inbox, err := store.ListNotifications(ctx, "u1", 0)
+		require.NoError(t, err)
+		return len(inbox) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	inbox, err := store.ListNotifications(ctx, "u1", 0)
+	require.NoError(t, err)
+	var ids []string
+	for _, n := range inbox {
+		ids = append(ids, n.ID)
+	}
+	assert.ElementsMatch(t, []string{"n1", "n3"}, ids)
+}
+
+func TestPriorityLanes(t *testing.T) {
+	store := storage.NewMemoryStore()
+	const mention models.EventType = "mention"
+
+	// The only worker is held up by the first notification until the rest
+	// are queued
+	var delivered []string
+	release := make(chan struct{})
+	inApp := delivery.NewMock(models.ChannelInApp, delivery.WithFailFunc(func(notification *models.Notification) bool {
+		if notification.ID == "first" {
+			<-release
+		}
+		delivered = append(delivered, notification.ID)
+		return false
+	}))
+	notificationQueue := queue.NewNotificationQueue(store, 1, 1,
+		queue.WithDeliverers(inApp),
+		queue.WithEventPriorities(map[models.EventType]models.Priority{models.EventTypeNewPost: models.PriorityLow, mention: models.PriorityHigh}),
+		queue.WithLanes(map[models.Priority]int{models.PriorityHigh: 10}, time.Minute))
+	notificationQueue.Start()
+	defer notificationQueue.Stop()
+
+	notificationQueue.EnqueueNotification(context.Background(), &models.Notification{ID: "first", UserID: "u1", EventType: models.EventTypeNewPost})
+	require.Eventually(t, func() bool {
+		return notificationQueue.Lanes()[2].Depth == 0
+	}, time.Second, 5*time.Millisecond)
+	for i := range 20 {
+		notificationQueue.EnqueueNotification(context.Background(), &models.Notification{
+			ID: fmt.Sprintf("post%d", i), UserID: "u1", PostID: fmt.Sprintf("p%d", i), EventType: models.EventTypeNewPost,
+		})
+	}
+	notificationQueue.EnqueueNotification(context.Background(), &models.Notification{ID: "mention", UserID: "u2", EventType: mention})
+
+	lanes := notificationQueue.Lanes()
+	assert.Equal(t, models.PriorityHigh, lanes[0].Priority)
+	assert.Equal(t, 1, lanes[0].Depth)
+	assert.Equal(t, 0, lanes[1].Depth)
+	assert.Equal(t, models.PriorityLow, lanes[2].Priority)
+	assert.Equal(t, 20, lanes[2].Depth)
+
+	// The mention goes ahead of the posts queued before it
+	close(release)
+	require.Eventually(t, func() bool {
+		jobs, err := store.ListJobs(context.Background())
+		require.NoError(t, err)
+		return len(jobs) == 0
+	}, 2*time.Second, 10*time.Millisecond)
+	require.Len(t, delivered, 22)
+	assert.Equal(t, []string{"first", "mention"}, delivered[:2])
 }
 
 func TestNotificationQueuePerformance(t *testing.T) {
 	// Create store
-	store := models.NewStore()
+	store := storage.NewMemoryStore()
 
 	// Create notification queue with more workers for performance testing
 	notificationQueue := queue.NewNotificationQueue(store, 100, 1) // More workers, fewer retries
@@ -215,22 +806,30 @@ func TestNotificationQueuePerformance(t *testing.T) {
 			CreatedAt: time.Now(),
 		}
 
-		notificationQueue.EnqueueNotification(notification)
+		notificationQueue.EnqueueNotification(context.Background(), notification)
 	}
 
 	// Wait for processing to complete - this may need to be adjusted
 	time.Sleep(1 * time.Second)
 
 	duration := time.Since(start)
+	metrics := getMetrics(t, store)
 
 	// Log performance metrics
 	t.Logf("Processed approximately %d notifications in %v",
-		store.Metrics.TotalNotificationsSent, duration)
+		metrics.TotalNotificationsSent, duration)
 	t.Logf("Approximate rate: %.2f notifications/second",
-		float64(store.Metrics.TotalNotificationsSent)/duration.Seconds())
+		float64(metrics.TotalNotificationsSent)/duration.Seconds())
 
 	// Simple performance assertion - should process at a reasonable rate
 	// This is more informative than a strict assertion
-	assert.Greater(t, store.Metrics.TotalNotificationsSent, 0,
+	assert.Greater(t, metrics.TotalNotificationsSent, 0,
 		"Expected some notifications to be processed")
 }
+
+// Helper function to read the current metrics from the store
+func getMetrics(t *testing.T, store storage.Store) *models.NotificationMetrics {
+	metrics, err := store.GetMetrics(context.Background())
+	assert.NoError(t, err)
+	return metrics
+}
@@ -0,0 +1,147 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+	"github.com/iwhitebird/social-app-microservices/internal/storage"
+)
+
+// ErrQueueFull is returned by EnqueueNotification when the lane of a
+// notification has no room for it in time
+var ErrQueueFull = errors.New("notification queue is full")
+
+// OverflowPolicy decides what EnqueueNotification does when the lane of a
+// notification is full
+type OverflowPolicy string
+
+const (
+	// OverflowBlock waits for room until the context is done or the enqueue
+	// timeout has passed, then fails with ErrQueueFull
+	OverflowBlock OverflowPolicy = "block"
+	// OverflowReject fails with ErrQueueFull right away
+	OverflowReject OverflowPolicy = "reject"
+	// OverflowSpill accepts the job but keeps it only in the journal until
+	// its lane has room again. Enqueueing never fails, the backlog is bound
+	// by the store instead of memory.
+	OverflowSpill OverflowPolicy = "spill"
+)
+
+const (
+	// DefaultLaneCapacity is how many due jobs a lane holds by default
+	DefaultLaneCapacity = 1000
+	// DefaultEnqueueTimeout is how long EnqueueNotification waits for room
+	// by default with OverflowBlock
+	DefaultEnqueueTimeout = 5 * time.Second
+)
+
+// WithOverflow sets how many due jobs a lane holds, what happens to the
+// jobs that do not fit and, for OverflowBlock, how long enqueueing waits for
+// room at most when the context has no earlier deadline
+func WithOverflow(policy OverflowPolicy, capacity int, timeout time.Duration) NotificationQueueOption {
+	return func(q *NotificationQueue) {
+		q.overflow = policy
+		q.laneCapacity = capacity
+		q.enqueueTimeout = timeout
+	}
+}
+
+// admit hands a job to its lane according to the overflow policy. A job that
+// could not be journaled cannot spill and is rejected instead.
+func (q *NotificationQueue) admit(ctx context.Context, job models.NotificationJob, journaled bool) error {
+	policy := q.overflow
+	if policy == OverflowSpill && !journaled {
+		policy = OverflowReject
+	}
+	switch policy {
+	case OverflowReject:
+		if !q.lanes.tryPush(job) {
+			return ErrQueueFull
+		}
+	case OverflowSpill:
+		q.spillMu.Lock()
+		defer q.spillMu.Unlock()
+		// Jobs that spilled before go first
+		if len(q.spilled[job.Priority]) > 0 || !q.lanes.tryPush(job) {
+			q.spilled[job.Priority] = append(q.spilled[job.Priority], job.Notification.ID)
+		}
+	default:
+		ctx, cancel := context.WithTimeout(ctx, q.enqueueTimeout)
+		defer cancel()
+		err := q.lanes.pushContext(ctx, job)
+		if err != nil && !errors.Is(err, errLanesClosed) {
+			return fmt.Errorf("%w: %w", ErrQueueFull, err)
+		}
+	}
+	// A job that finds the queue stopped stays journaled for the next start
+	return nil
+}
+
+// refill moves spilled jobs back into their lanes as room frees up, until
+// the queue stops. Jobs still spilled then are replayed by the next Start
+// like any other journaled job.
+func (q *NotificationQueue) refill() {
+	defer q.wg.Done()
+	spaceFreed := q.lanes.watchSpace()
+	for {
+		// Room may have freed up before the refiller started watching
+		for _, priority := range models.Priorities {
+			q.unspill(priority)
+		}
+		select {
+		case <-spaceFreed:
+		case <-q.shutdownChan:
+			return
+		}
+	}
+}
+
+// unspill loads the spilled jobs of a lane from the journal, oldest first,
+// until the lane is full again
+func (q *NotificationQueue) unspill(priority models.Priority) {
+	for {
+		q.spillMu.Lock()
+		if len(q.spilled[priority]) == 0 {
+			q.spillMu.Unlock()
+			return
+		}
+		id := q.spilled[priority][0]
+		q.spillMu.Unlock()
+
+		job, err := q.journal.GetJob(context.Background(), id)
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			// Acked meanwhile, e.g. replaced by a coalesced notification
+		case err != nil:
+			// Tried again once the next job leaves the lanes
+			log.Printf("Failed to load spilled notification job %s: %v", id, err)
+			return
+		default:
+			job.Priority = priority
+			if !q.lanes.tryPush(*job) {
+				return
+			}
+		}
+
+		// Only the refiller removes spilled jobs, so id is still the first
+		q.spillMu.Lock()
+		q.spilled[priority] = q.spilled[priority][1:]
+		q.spillMu.Unlock()
+	}
+}
+
+// spilledCounts returns how many jobs wait in the journal for each lane
+func (q *NotificationQueue) spilledCounts() map[models.Priority]int {
+	q.spillMu.Lock()
+	defer q.spillMu.Unlock()
+
+	counts := make(map[models.Priority]int, len(q.spilled))
+	for priority, ids := range q.spilled {
+		counts[priority] = len(ids)
+	}
+	return counts
+}
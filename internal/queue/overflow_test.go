@@ -0,0 +1,199 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iwhitebird/social-app-microservices/internal/delivery"
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+	"github.com/iwhitebird/social-app-microservices/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gated is an in-app deliverer that holds every delivery until the gate is
+// closed
+func gated(gate <-chan struct{}, delivered *atomic.Int64) delivery.Deliverer {
+	return delivery.NewMock(models.ChannelInApp, delivery.WithFailFunc(func(*models.Notification) bool {
+		<-gate
+		delivered.Add(1)
+		return false
+	}))
+}
+
+// fillLanes starts a queue with a single worker that is stuck on a first
+// notification, then fills the normal lane with capacity more
+func fillLanes(t *testing.T, q *NotificationQueue, capacity int) {
+	t.Helper()
+	q.Start()
+	require.NoError(t, q.EnqueueNotification(context.Background(), &models.Notification{ID: "busy", UserID: "u1"}))
+	require.Eventually(t, func() bool { return q.Pool().Busy == 1 }, time.Second, time.Millisecond)
+	for i := range capacity {
+		require.NoError(t, q.EnqueueNotification(context.Background(), &models.Notification{ID: fmt.Sprintf("n%d", i), UserID: "u1"}))
+	}
+}
+
+func TestOverflowReject(t *testing.T) {
+	store := storage.NewMemoryStore()
+	gate := make(chan struct{})
+	var delivered atomic.Int64
+	q := NewNotificationQueue(store, 1, 1,
+		WithDeliverers(gated(gate, &delivered)),
+		WithDeduplication(time.Hour),
+		WithOverflow(OverflowReject, 2, 0))
+	defer q.Stop()
+
+	fillLanes(t, q, 2)
+
+	// Turned away without waiting and without a trace in the journal
+	late := &models.Notification{ID: "late", UserID: "u2", PostID: "p1", EventType: models.EventTypeNewPost}
+	start := time.Now()
+	err := q.EnqueueNotification(context.Background(), late)
+	assert.ErrorIs(t, err, ErrQueueFull)
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+	_, err = store.GetJob(context.Background(), "late")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	close(gate)
+	require.Eventually(t, func() bool { return delivered.Load() == 3 }, time.Second, 5*time.Millisecond)
+
+	// The deduplicator forgot the rejected notification, so it can be retried
+	require.NoError(t, q.EnqueueNotification(context.Background(), late))
+	require.Eventually(t, func() bool { return delivered.Load() == 4 }, time.Second, 5*time.Millisecond)
+}
+
+func TestOverflowBlock(t *testing.T) {
+	gate := make(chan struct{})
+	var delivered atomic.Int64
+	q := NewNotificationQueue(storage.NewMemoryStore(), 1, 1,
+		WithDeliverers(gated(gate, &delivered)),
+		WithOverflow(OverflowBlock, 1, 50*time.Millisecond))
+	defer q.Stop()
+
+	fillLanes(t, q, 1)
+
+	// Waits for room until the enqueue timeout
+	start := time.Now()
+	err := q.EnqueueNotification(context.Background(), &models.Notification{ID: "timeout", UserID: "u1"})
+	assert.ErrorIs(t, err, ErrQueueFull)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	// or until the caller gives up, whichever comes first
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = q.EnqueueNotification(ctx, &models.Notification{ID: "cancelled", UserID: "u1"})
+	assert.ErrorIs(t, err, ErrQueueFull)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// and is let in once a worker takes a job
+	result := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		result <- q.EnqueueNotification(ctx, &models.Notification{ID: "waited", UserID: "u1"})
+	}()
+	close(gate)
+	select {
+	case err := <-result:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("enqueueing did not resume when room freed up")
+	}
+	require.Eventually(t, func() bool { return delivered.Load() == 3 }, time.Second, 5*time.Millisecond)
+}
+
+func TestOverflowSpill(t *testing.T) {
+	store := storage.NewMemoryStore()
+	gate := make(chan struct{})
+	var delivered atomic.Int64
+	q := NewNotificationQueue(store, 1, 1,
+		WithDeliverers(gated(gate, &delivered)),
+		WithOverflow(OverflowSpill, 1, 0))
+	defer q.Stop()
+
+	fillLanes(t, q, 1)
+
+	// Accepted right away and kept in the journal until the lane has room
+	for i := range 3 {
+		err := q.EnqueueNotification(context.Background(), &models.Notification{ID: fmt.Sprintf("spilled%d", i), UserID: "u1"})
+		require.NoError(t, err)
+	}
+	spilled := func() int {
+		for _, lane := range q.Lanes() {
+			if lane.Priority == models.PriorityNormal {
+				return lane.Spilled
+			}
+		}
+		return -1
+	}
+	assert.Equal(t, 3, spilled())
+	jobs, err := store.ListJobs(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, jobs, 5)
+
+	close(gate)
+	require.Eventually(t, func() bool { return delivered.Load() == 5 }, time.Second, 5*time.Millisecond)
+	assert.Zero(t, spilled())
+	require.Eventually(t, func() bool {
+		jobs, err := store.ListJobs(context.Background())
+		return err == nil && len(jobs) == 0
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestOverflowSpillNeedsTheJournal(t *testing.T) {
+	q := NewNotificationQueue(storage.NewMemoryStore(), 1, 1, WithOverflow(OverflowSpill, 1, 0))
+	job := models.NotificationJob{Notification: &models.Notification{ID: "n1"}, Priority: models.PriorityNormal}
+	require.NoError(t, q.admit(context.Background(), job, false))
+
+	// A job that is not journaled cannot spill
+	job.Notification = &models.Notification{ID: "n2"}
+	err := q.admit(context.Background(), job, false)
+	assert.ErrorIs(t, err, ErrQueueFull)
+}
+
+func TestReplayWaitsForRoomAPageAtATime(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	backlog := claimPageSize + 10
+	for i := range backlog {
+		require.NoError(t, store.SaveJob(ctx, &models.NotificationJob{
+			Notification: &models.Notification{ID: fmt.Sprintf("n%d", i), UserID: "u1"},
+			Attempt:      1,
+		}))
+	}
+
+	gate := make(chan struct{})
+	var delivered atomic.Int64
+	q := NewNotificationQueue(store, 1, 1,
+		WithDeliverers(gated(gate, &delivered)),
+		WithOverflow(OverflowReject, 2, 0))
+	q.Start()
+	defer q.Stop()
+
+	// Replayed jobs wait for room rather than being turned away, and the
+	// queue claims no more than a page while they do
+	require.Eventually(t, func() bool {
+		for _, lane := range q.Lanes() {
+			if lane.Priority == models.PriorityNormal {
+				return q.Pool().Busy == 1 && lane.Depth == 2
+			}
+		}
+		return false
+	}, time.Second, time.Millisecond)
+	jobs, err := store.ListJobs(ctx)
+	require.NoError(t, err)
+	unclaimed := 0
+	for _, job := range jobs {
+		if job.Owner == "" {
+			unclaimed++
+		}
+	}
+	assert.Equal(t, backlog-claimPageSize, unclaimed)
+
+	close(gate)
+	require.Eventually(t, func() bool { return delivered.Load() == int64(backlog) }, 5*time.Second, 5*time.Millisecond)
+}
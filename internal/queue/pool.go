@@ -0,0 +1,183 @@
+package queue
+
+import (
+	"errors"
+	"log"
+	"math"
+	"time"
+)
+
+const (
+	// DefaultScaleInterval is how often the pool is resized by default
+	DefaultScaleInterval = 5 * time.Second
+	// DefaultTargetWait is how long due jobs should wait for a worker at
+	// most before the pool grows
+	DefaultTargetWait = time.Second
+)
+
+// scaleDownUtilization is the share of time the workers are busy below
+// which an idle pool shrinks
+const scaleDownUtilization = 0.5
+
+// ErrPoolSize is returned by ResizePool for a size the pool cannot take
+var ErrPoolSize = errors.New("pool size out of range")
+
+// PoolStats describes the worker pool of a queue
+type PoolStats struct {
+	// Workers is the size of the pool. Workers that were asked to stop may
+	// still be finishing their current job.
+	Workers int
+	// Busy is how many workers are delivering a notification right now
+	Busy       int
+	MinWorkers int
+	MaxWorkers int
+	// Autoscaling is false while the size is pinned through ResizePool
+	Autoscaling bool
+}
+
+// poolSample is the load of the queue over one scaling interval
+type poolSample struct {
+	// depth is how many due jobs wait for a worker, oldestWait for how long
+	// the first of them has
+	depth      int
+	oldestWait time.Duration
+	// processing is how long a job took on average
+	processing time.Duration
+	// utilization is the share of the interval the workers were busy
+	utilization float64
+}
+
+// desiredWorkers returns the pool size for the observed load. The pool
+// grows when the waiting jobs cannot all be started within targetWait, to
+// as many workers as it takes to catch up in that time, and shrinks by one
+// worker at a time while nothing waits and the workers are mostly idle.
+func desiredWorkers(current, minWorkers, maxWorkers int, sample poolSample, targetWait time.Duration) int {
+	desired := current
+	switch {
+	case sample.depth > 0 && (sample.oldestWait > targetWait ||
+		time.Duration(sample.depth)*sample.processing/time.Duration(max(current, 1)) > targetWait):
+		needed := int(math.Ceil(float64(sample.depth) * float64(sample.processing) / float64(targetWait)))
+		desired = max(current+1, needed)
+	case sample.depth == 0 && sample.utilization < scaleDownUtilization:
+		desired = current - 1
+	}
+	return min(max(desired, minWorkers), maxWorkers)
+}
+
+// Pool returns the size and load of the worker pool
+func (q *NotificationQueue) Pool() PoolStats {
+	q.poolMu.Lock()
+	defer q.poolMu.Unlock()
+
+	return PoolStats{
+		Workers:     q.workers,
+		Busy:        int(q.busy.Load()),
+		MinWorkers:  q.minWorkers,
+		MaxWorkers:  q.maxWorkers,
+		Autoscaling: q.pinned == 0,
+	}
+}
+
+// ResizePool pins the pool to size workers and stops autoscaling it, or
+// hands it back to the autoscaler when size is 0. The size may be below the
+// minimum, e.g. to ease the load on a struggling channel, but not above the
+// maximum; other sizes fail with ErrPoolSize. Workers are only stopped
+// between jobs, so shrinking never abandons a delivery.
+func (q *NotificationQueue) ResizePool(size int) (PoolStats, error) {
+	if size < 0 || size > q.maxWorkers {
+		return PoolStats{}, ErrPoolSize
+	}
+
+	q.poolMu.Lock()
+	q.pinned = size
+	if size == 0 {
+		log.Printf("Autoscaling the notification worker pool again")
+		size = min(max(q.workers, q.minWorkers), q.maxWorkers)
+	} else {
+		log.Printf("Pinning the notification worker pool to %d workers", size)
+	}
+	if q.started {
+		q.resize(size)
+	} else {
+		// Start launches them
+		q.workers = size
+	}
+	q.poolMu.Unlock()
+	return q.Pool(), nil
+}
+
+// resize starts or stops workers until there are size of them. Stopping a
+// worker only asks it to exit once it is done with its current job. The
+// caller holds poolMu.
+func (q *NotificationQueue) resize(size int) {
+	for ; q.workers < size; q.workers++ {
+		// Take back a stop request that no worker picked up yet before
+		// starting another worker
+		if retiring := q.retiring.Load(); retiring > 0 && q.retiring.CompareAndSwap(retiring, retiring-1) {
+			continue
+		}
+		q.wg.Add(1)
+		go q.worker(q.nextWorkerID)
+		q.nextWorkerID++
+	}
+	if q.workers > size {
+		q.retiring.Add(int64(q.workers - size))
+		q.workers = size
+		// Idle workers wait for a job, wake them to stop
+		q.lanes.wake()
+	}
+}
+
+// retire claims a stop request for a worker that is between jobs
+func (q *NotificationQueue) retire() bool {
+	for {
+		retiring := q.retiring.Load()
+		if retiring == 0 {
+			return false
+		}
+		if q.retiring.CompareAndSwap(retiring, retiring-1) {
+			return true
+		}
+	}
+}
+
+// autoscale resizes the pool every interval until the queue stops
+func (q *NotificationQueue) autoscale() {
+	defer q.wg.Done()
+	ticker := time.NewTicker(q.scaleInterval)
+	defer ticker.Stop()
+
+	lastBusy, lastProcessed := q.busyTime.Load(), q.processed.Load()
+	var processing time.Duration
+	for {
+		select {
+		case <-ticker.C:
+		case <-q.shutdownChan:
+			return
+		}
+
+		busy, processed := q.busyTime.Load(), q.processed.Load()
+		if processed > lastProcessed {
+			processing = time.Duration((busy - lastBusy) / (processed - lastProcessed))
+		}
+		sample := poolSample{processing: processing}
+		for _, lane := range q.lanes.stats() {
+			sample.depth += lane.Depth
+			sample.oldestWait = max(sample.oldestWait, lane.OldestWait)
+		}
+
+		q.poolMu.Lock()
+		if q.workers > 0 {
+			sample.utilization = float64(busy-lastBusy) / float64(int64(q.scaleInterval)*int64(q.workers))
+		}
+		if q.pinned == 0 {
+			if size := desiredWorkers(q.workers, q.minWorkers, q.maxWorkers, sample, q.targetWait); size != q.workers {
+				log.Printf("Scaling notification workers from %d to %d (%d waiting, oldest %v, %.0f%% busy)",
+					q.workers, size, sample.depth, sample.oldestWait, sample.utilization*100)
+				q.resize(size)
+			}
+		}
+		q.poolMu.Unlock()
+		lastBusy, lastProcessed = busy, processed
+	}
+}
@@ -0,0 +1,130 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iwhitebird/social-app-microservices/internal/delivery"
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+	"github.com/iwhitebird/social-app-microservices/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDesiredWorkers(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		current int
+		sample  poolSample
+		want    int
+	}{
+		{"steady", 4, poolSample{depth: 2, processing: 100 * time.Millisecond, utilization: 0.8}, 4},
+		{"backlog", 4, poolSample{depth: 100, processing: 100 * time.Millisecond}, 10},
+		{"stale head", 4, poolSample{depth: 1, oldestWait: 2 * time.Second, processing: time.Millisecond}, 5},
+		{"capped", 4, poolSample{depth: 10000, processing: time.Second}, 16},
+		{"idle", 4, poolSample{utilization: 0.1}, 3},
+		{"idle at minimum", 2, poolSample{utilization: 0}, 2},
+		{"busy but drained", 4, poolSample{utilization: 0.9}, 4},
+		{"below minimum", 1, poolSample{utilization: 0.9}, 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, desiredWorkers(tc.current, 2, 16, tc.sample, time.Second))
+		})
+	}
+}
+
+// concurrency is an in-app deliverer that takes delay per notification and
+// tracks how many deliveries run at once
+type concurrency struct {
+	running, peak, delivered atomic.Int64
+}
+
+func (c *concurrency) deliverer(delay time.Duration) delivery.Deliverer {
+	return delivery.NewMock(models.ChannelInApp, delivery.WithFailFunc(func(*models.Notification) bool {
+		running := c.running.Add(1)
+		for peak := c.peak.Load(); running > peak && !c.peak.CompareAndSwap(peak, running); peak = c.peak.Load() {
+		}
+		time.Sleep(delay)
+		c.running.Add(-1)
+		c.delivered.Add(1)
+		return false
+	}))
+}
+
+func enqueue(q *NotificationQueue, n int) {
+	for i := range n {
+		q.EnqueueNotification(context.Background(), &models.Notification{ID: fmt.Sprintf("n%d", i), UserID: "u1"})
+	}
+}
+
+func TestAutoscaling(t *testing.T) {
+	var c concurrency
+	q := NewNotificationQueue(storage.NewMemoryStore(), 1, 1,
+		WithDeliverers(c.deliverer(20*time.Millisecond)),
+		WithAutoscaling(1, 8, 20*time.Millisecond, 50*time.Millisecond))
+	q.Start()
+	defer q.Stop()
+
+	// A backlog grows the pool up to its maximum
+	enqueue(q, 200)
+	require.Eventually(t, func() bool { return q.Pool().Workers == 8 }, 2*time.Second, 5*time.Millisecond)
+	assert.True(t, q.Pool().Autoscaling)
+
+	// Once it is drained and the workers idle, the pool shrinks back
+	require.Eventually(t, func() bool { return c.delivered.Load() == 200 }, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool { return q.Pool().Workers == 1 }, 2*time.Second, 5*time.Millisecond)
+	assert.LessOrEqual(t, c.peak.Load(), int64(8))
+}
+
+func TestResizePool(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	var c concurrency
+	q := NewNotificationQueue(store, 4, 1,
+		WithDeliverers(c.deliverer(50*time.Millisecond)),
+		WithAutoscaling(2, 6, time.Hour, time.Second))
+
+	_, err := q.ResizePool(7)
+	assert.ErrorIs(t, err, ErrPoolSize)
+	_, err = q.ResizePool(-1)
+	assert.ErrorIs(t, err, ErrPoolSize)
+
+	q.Start()
+	defer q.Stop()
+
+	// Shrinking while every worker is busy lets them finish their jobs
+	enqueue(q, 8)
+	require.Eventually(t, func() bool { return q.Pool().Busy == 4 }, time.Second, time.Millisecond)
+	stats, err := q.ResizePool(1)
+	require.NoError(t, err)
+	assert.Equal(t, PoolStats{Workers: 1, Busy: 4, MinWorkers: 2, MaxWorkers: 6, Autoscaling: false}, stats)
+
+	require.Eventually(t, func() bool {
+		jobs, err := store.ListJobs(ctx)
+		require.NoError(t, err)
+		return len(jobs) == 0
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.EqualValues(t, 8, c.delivered.Load())
+
+	// From now on a single worker delivers
+	c.peak.Store(0)
+	enqueue(q, 4)
+	require.Eventually(t, func() bool { return c.delivered.Load() == 12 }, 2*time.Second, 10*time.Millisecond)
+	assert.EqualValues(t, 1, c.peak.Load())
+
+	// Growing again starts new workers
+	_, err = q.ResizePool(6)
+	require.NoError(t, err)
+	enqueue(q, 12)
+	require.Eventually(t, func() bool { return c.delivered.Load() == 24 }, 2*time.Second, 10*time.Millisecond)
+	assert.EqualValues(t, 6, c.peak.Load())
+
+	// Size 0 hands the pool back to the autoscaler
+	stats, err = q.ResizePool(0)
+	require.NoError(t, err)
+	assert.True(t, stats.Autoscaling)
+	assert.Equal(t, 6, stats.Workers)
+}
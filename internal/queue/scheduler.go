@@ -0,0 +1,121 @@
+package queue
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+)
+
+// delayedJob is a job waiting in the scheduler until it is due
+type delayedJob struct {
+	job models.NotificationJob
+	due time.Time
+	// seq keeps jobs that are due at the same time in the order they were scheduled
+	seq uint64
+}
+
+// jobHeap is a min-heap of delayed jobs, the one due first on top
+type jobHeap []delayedJob
+
+func (h jobHeap) Len() int { return len(h) }
+
+func (h jobHeap) Less(i, j int) bool {
+	if h[i].due.Equal(h[j].due) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].due.Before(h[j].due)
+}
+
+func (h jobHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *jobHeap) Push(x any) { *h = append(*h, x.(delayedJob)) }
+
+func (h *jobHeap) Pop() any {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+// scheduler holds jobs in a timer heap until they are due and then feeds
+// them to the workers. Scheduling never blocks, so a worker hands a retry
+// over and moves on instead of sleeping through the backoff.
+type scheduler struct {
+	mu   sync.Mutex
+	jobs jobHeap
+	seq  uint64
+	// wake interrupts run when a job may have become the next one due
+	wake chan struct{}
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{wake: make(chan struct{}, 1)}
+}
+
+// schedule holds the job until due. A zero due time means right away.
+func (s *scheduler) schedule(job models.NotificationJob, due time.Time) {
+	s.mu.Lock()
+	s.seq++
+	heap.Push(&s.jobs, delayedJob{job: job, due: due, seq: s.seq})
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run pushes every job into its lane once it is due, until shutdown. A due
+// job whose lane is full is held back, along with the jobs due after it in
+// that lane, until a worker frees up room, while the other lanes keep
+// getting theirs. Jobs still held at shutdown stay in the journal for the
+// next start.
+func (s *scheduler) run(out *lanes, shutdown <-chan struct{}) {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	spaceFreed := out.watchSpace()
+	// held keeps the due jobs of full lanes in the order they became due
+	held := make(map[*lane][]models.NotificationJob)
+
+	for {
+		for lane, jobs := range held {
+			for len(jobs) > 0 && out.tryPush(jobs[0]) {
+				jobs = jobs[1:]
+			}
+			if len(jobs) == 0 {
+				delete(held, lane)
+			} else {
+				held[lane] = jobs
+			}
+		}
+
+		s.mu.Lock()
+		if len(s.jobs) > 0 && !s.jobs[0].due.After(time.Now()) {
+			next := heap.Pop(&s.jobs).(delayedJob)
+			s.mu.Unlock()
+
+			lane := out.lane(next.job.Priority)
+			if len(held[lane]) > 0 || !out.tryPush(next.job) {
+				held[lane] = append(held[lane], next.job)
+			}
+			continue
+		}
+
+		var due <-chan time.Time
+		if len(s.jobs) > 0 {
+			timer.Reset(time.Until(s.jobs[0].due))
+			due = timer.C
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-due:
+		case <-s.wake:
+		case <-spaceFreed:
+		case <-shutdown:
+			return
+		}
+	}
+}
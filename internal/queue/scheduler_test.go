@@ -0,0 +1,170 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+	"github.com/iwhitebird/social-app-microservices/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testJob(id string) models.NotificationJob {
+	return models.NotificationJob{Notification: &models.Notification{ID: id}, Attempt: 1}
+}
+
+// receive feeds the jobs popped from lanes into a channel until they are closed
+func receive(lanes *lanes) <-chan models.NotificationJob {
+	jobs := make(chan models.NotificationJob, 100)
+	go func() {
+		defer close(jobs)
+		for {
+			job, ok := lanes.pop(nil)
+			if !ok {
+				return
+			}
+			jobs <- job
+		}
+	}()
+	return jobs
+}
+
+func TestSchedulerReleasesJobsWhenDue(t *testing.T) {
+	s := newScheduler()
+	out := newLanes(nil, 10, 0)
+	received := receive(out)
+	shutdown := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		s.run(out, shutdown)
+		close(done)
+	}()
+
+	start := time.Now()
+	s.schedule(testJob("late"), start.Add(150*time.Millisecond))
+	s.schedule(testJob("soon"), start.Add(50*time.Millisecond))
+	s.schedule(testJob("now-1"), time.Time{})
+	s.schedule(testJob("now-2"), time.Time{})
+
+	// Jobs come out in due order, ties in the order they were scheduled
+	for _, id := range []string{"now-1", "now-2", "soon", "late"} {
+		select {
+		case job := <-received:
+			assert.Equal(t, id, job.Notification.ID)
+		case <-time.After(time.Second):
+			require.Failf(t, "timed out", "waiting for job %s", id)
+		}
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+
+	out.close()
+	close(shutdown)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("scheduler did not stop")
+	}
+}
+
+func TestSchedulerDoesNotBlockWhenNobodyReceives(t *testing.T) {
+	s := newScheduler()
+	out := newLanes(nil, 1, 0)
+	shutdown := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		s.run(out, shutdown)
+		close(done)
+	}()
+
+	// Scheduling returns right away even while run waits for room in a lane
+	scheduled := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			s.schedule(testJob("job"), time.Time{})
+		}
+		close(scheduled)
+	}()
+	select {
+	case <-scheduled:
+	case <-time.After(time.Second):
+		t.Fatal("schedule blocked")
+	}
+
+	out.close()
+	close(shutdown)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("scheduler did not stop while handing over a job")
+	}
+}
+
+func TestSchedulerHoldsBackOnlyFullLanes(t *testing.T) {
+	s := newScheduler()
+	out := newLanes(nil, 1, 0)
+	shutdown := make(chan struct{})
+	defer close(shutdown)
+	go s.run(out, shutdown)
+
+	// The second bulk job finds its lane full and waits
+	s.schedule(laneJob("low-1", models.PriorityLow), time.Time{})
+	s.schedule(laneJob("low-2", models.PriorityLow), time.Time{})
+	s.schedule(laneJob("high", models.PriorityHigh), time.Time{})
+
+	// without holding up the jobs of the other lanes
+	depth := func(priority models.Priority) int {
+		for _, lane := range out.stats() {
+			if lane.Priority == priority {
+				return lane.Depth
+			}
+		}
+		return 0
+	}
+	require.Eventually(t, func() bool { return depth(models.PriorityHigh) == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, 1, depth(models.PriorityLow))
+
+	// It moves in once a worker takes a job from its lane
+	job, ok := out.pop(nil)
+	require.True(t, ok)
+	assert.Equal(t, "high", job.Notification.ID)
+	job, ok = out.pop(nil)
+	require.True(t, ok)
+	assert.Equal(t, "low-1", job.Notification.ID)
+	job, ok = out.pop(nil)
+	require.True(t, ok)
+	assert.Equal(t, "low-2", job.Notification.ID)
+}
+
+func TestRetryDelay(t *testing.T) {
+	q := NewNotificationQueue(storage.NewMemoryStore(), 1, 10, WithRetryBackoff(100*time.Millisecond, time.Second))
+
+	for _, tc := range []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{1, 50 * time.Millisecond, 100 * time.Millisecond},
+		{2, 100 * time.Millisecond, 200 * time.Millisecond},
+		{3, 200 * time.Millisecond, 400 * time.Millisecond},
+		// Capped at the maximum backoff
+		{5, 500 * time.Millisecond, time.Second},
+		{60, 500 * time.Millisecond, time.Second},
+	} {
+		for i := 0; i < 100; i++ {
+			delay := q.retryDelay(models.NotificationJob{Attempt: tc.attempt})
+			assert.GreaterOrEqual(t, delay, tc.min, "attempt %d", tc.attempt)
+			assert.LessOrEqual(t, delay, tc.max, "attempt %d", tc.attempt)
+		}
+	}
+
+	// A job with a maximum of its own is capped by that instead
+	for i := 0; i < 100; i++ {
+		delay := q.retryDelay(models.NotificationJob{Attempt: 60, MaxBackoff: 200 * time.Millisecond})
+		assert.GreaterOrEqual(t, delay, 100*time.Millisecond)
+		assert.LessOrEqual(t, delay, 200*time.Millisecond)
+
+		delay = q.retryDelay(models.NotificationJob{Attempt: 60, MaxBackoff: time.Minute})
+		assert.GreaterOrEqual(t, delay, 30*time.Second)
+		assert.LessOrEqual(t, delay, time.Minute)
+	}
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"encoding/base64"
+	"strconv"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// defaultPageSize is used when a paginated request does not set a page size
+	defaultPageSize = 20
+	// maxPageSize caps the page size of paginated requests
+	maxPageSize = 100
+)
+
+// pageSize clamps a requested page size to (0, maxPageSize]
+func pageSize(requested int32) int {
+	if requested <= 0 {
+		return defaultPageSize
+	}
+	if requested > maxPageSize {
+		return maxPageSize
+	}
+	return int(requested)
+}
+
+// encodeCursor hides the position of a page behind an opaque token
+func encodeCursor(position string) string {
+	if position == "" {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(position))
+}
+
+// decodeCursor reverses encodeCursor. An empty cursor means the first page.
+func decodeCursor(cursor string) (string, error) {
+	position, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", status.Error(codes.InvalidArgument, "invalid cursor")
+	}
+	return string(position), nil
+}
+
+// encodeSeqCursor turns a feed position into a cursor
+func encodeSeqCursor(seq int64) string {
+	return encodeCursor(strconv.FormatInt(seq, 10))
+}
+
+// decodeSeqCursor reverses encodeSeqCursor. An empty cursor decodes to 0.
+func decodeSeqCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	position, err := decodeCursor(cursor)
+	if err != nil {
+		return 0, err
+	}
+	seq, err := strconv.ParseInt(position, 10, 64)
+	if err != nil || seq <= 0 {
+		return 0, status.Error(codes.InvalidArgument, "invalid cursor")
+	}
+	return seq, nil
+}
@@ -0,0 +1,23 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/iwhitebird/social-app-microservices/internal/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// storageError converts a storage error into a gRPC status error, using
+// subject (e.g. "user u1") to describe the record that was accessed
+func storageError(err error, subject string) error {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return status.Errorf(codes.NotFound, "%s not found", subject)
+	case errors.Is(err, storage.ErrAlreadyExists):
+		return status.Errorf(codes.AlreadyExists, "%s already exists", subject)
+	default:
+		return status.Error(codes.Internal, fmt.Sprintf("storage error: %v", err))
+	}
+}
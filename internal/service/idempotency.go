@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+	"github.com/iwhitebird/social-app-microservices/internal/storage"
+	postProto "github.com/iwhitebird/social-app-microservices/proto/generated/post/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// DefaultIdempotencyKeyTTL is how long PublishPost remembers an idempotency key
+	DefaultIdempotencyKeyTTL = 24 * time.Hour
+	// idempotencyKeyLease is how long a request holds the key it claimed
+	// before it stores its response. If the process dies in between, a retry
+	// can claim the key once the lease is up.
+	idempotencyKeyLease = 2 * time.Minute
+	// maxIdempotencyKeyLength caps the length of an idempotency key in bytes
+	maxIdempotencyKeyLength = 255
+)
+
+// WithIdempotencyKeyTTL sets how long PublishPost remembers an idempotency
+// key, i.e. for how long a retry is answered with the original response
+func WithIdempotencyKeyTTL(ttl time.Duration) PostServiceOption {
+	return func(s *PostService) {
+		s.idempotencyKeyTTL = ttl
+	}
+}
+
+// claimIdempotencyKey reserves the idempotency key of a post for this
+// request. If an earlier request with the key went through, it returns that
+// request's response instead.
+func (s *PostService) claimIdempotencyKey(ctx context.Context, post *postProto.Post) (*models.IdempotencyKey, *postProto.NotificationResponse, error) {
+	if len(post.IdempotencyKey) > maxIdempotencyKeyLength {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "idempotency key is %d bytes long, the limit is %d", len(post.IdempotencyKey), maxIdempotencyKeyLength)
+	}
+
+	now := time.Now()
+	key := &models.IdempotencyKey{
+		UserID:      post.UserId,
+		Key:         post.IdempotencyKey,
+		RequestHash: requestHash(post),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(min(idempotencyKeyLease, s.idempotencyKeyTTL)),
+	}
+	stored, err := s.idempotencyKeys.ClaimIdempotencyKey(ctx, key)
+	if err == nil {
+		return key, nil, nil
+	}
+	if !errors.Is(err, storage.ErrAlreadyExists) {
+		return nil, nil, storageError(err, "idempotency key "+post.IdempotencyKey)
+	}
+
+	if stored.RequestHash != key.RequestHash {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "idempotency key %q was already used for a different post", post.IdempotencyKey)
+	}
+	if len(stored.Response) == 0 {
+		return nil, nil, status.Errorf(codes.Aborted, "a request with idempotency key %q is still in progress, retry later", post.IdempotencyKey)
+	}
+	response := &postProto.NotificationResponse{}
+	if err := proto.Unmarshal(stored.Response, response); err != nil {
+		return nil, nil, status.Errorf(codes.Internal, "decoding the response stored for idempotency key %q: %v", post.IdempotencyKey, err)
+	}
+	return nil, response, nil
+}
+
+// settleIdempotencyKey stores the response of the request that claimed a
+// key for the full TTL, or releases the key if the request failed so that
+// it can be retried
+func (s *PostService) settleIdempotencyKey(ctx context.Context, key *models.IdempotencyKey, response *postProto.NotificationResponse, err error) {
+	// The post is published even if the client gave up waiting for it
+	ctx = context.WithoutCancel(ctx)
+
+	if err != nil {
+		if err := s.idempotencyKeys.ReleaseIdempotencyKey(ctx, key.UserID, key.Key); err != nil {
+			log.Printf("Failed to release idempotency key %q of user %s: %v", key.Key, key.UserID, err)
+		}
+		return
+	}
+
+	key.ExpiresAt = time.Now().Add(s.idempotencyKeyTTL)
+	if key.Response, err = proto.Marshal(response); err == nil {
+		err = s.idempotencyKeys.SaveIdempotencyKey(ctx, key)
+	}
+	if err != nil {
+		log.Printf("Failed to store the response for idempotency key %q of user %s: %v", key.Key, key.UserID, err)
+	}
+}
+
+// requestHash identifies what a post publishes. The author needs no part in
+// it, since keys are scoped by user.
+func requestHash(post *postProto.Post) string {
+	hash := sha256.Sum256([]byte(post.Content))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,184 @@
+package service_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iwhitebird/social-app-microservices/internal/fanout"
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+	"github.com/iwhitebird/social-app-microservices/internal/queue"
+	"github.com/iwhitebird/social-app-microservices/internal/service"
+	"github.com/iwhitebird/social-app-microservices/internal/storage"
+	postProto "github.com/iwhitebird/social-app-microservices/proto/generated/post/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// failingPostStore fails to publish posts while fail is set, and to store
+// the responses of idempotent requests while failResponses is set
+type failingPostStore struct {
+	*storage.MemoryStore
+	fail          bool
+	failResponses bool
+}
+
+func (s *failingPostStore) SaveIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) error {
+	if s.failResponses {
+		return errors.New("disk full")
+	}
+	return s.MemoryStore.SaveIdempotencyKey(ctx, key)
+}
+
+func (s *failingPostStore) CreatePostWithFanout(ctx context.Context, post *models.Post, job *models.FanoutJob) error {
+	if s.fail {
+		return errors.New("disk full")
+	}
+	return s.MemoryStore.CreatePostWithFanout(ctx, post, job)
+}
+
+// newIdempotencyTestService creates a PostService whose author has two followers
+func newIdempotencyTestService(t *testing.T, store storage.Store) *service.PostService {
+	t.Helper()
+	ctx := context.Background()
+	require.NoError(t, store.CreateUser(ctx, &models.User{ID: "author", Username: "author"}))
+	for _, followerID := range []string{"follower1", "follower2"} {
+		require.NoError(t, store.Follow(ctx, followerID, "author"))
+	}
+
+	notificationQueue := queue.NewNotificationQueue(store, 3, 2)
+	notificationQueue.Start()
+	t.Cleanup(notificationQueue.Stop)
+	dispatcher := fanout.NewDispatcher(store, notificationQueue)
+	dispatcher.Start()
+	t.Cleanup(dispatcher.Stop)
+	return service.NewPostService(store, dispatcher)
+}
+
+func TestPublishPostReplaysIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	postService := newIdempotencyTestService(t, store)
+
+	post := &postProto.Post{UserId: "author", Content: "hello", IdempotencyKey: "k1"}
+	first, err := postService.PublishPost(ctx, post)
+	require.NoError(t, err)
+	waitForFanout(t, postService, first)
+
+	// The retry gets the original response and publishes nothing
+	retry, err := postService.PublishPost(ctx, post)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(first, retry), "got %v, want %v", retry, first)
+
+	posts, err := store.ListPostsByUser(ctx, "author", 0)
+	require.NoError(t, err)
+	assert.Len(t, posts, 1)
+	for _, followerID := range []string{"follower1", "follower2"} {
+		inbox, err := store.ListNotifications(ctx, followerID, 0)
+		require.NoError(t, err)
+		assert.Len(t, inbox, 1, followerID)
+	}
+
+	// Without a key, or with another one, the post is published again
+	second, err := postService.PublishPost(ctx, &postProto.Post{UserId: "author", Content: "hello"})
+	require.NoError(t, err)
+	assert.NotEqual(t, first.PostId, second.PostId)
+	third, err := postService.PublishPost(ctx, &postProto.Post{UserId: "author", Content: "hello", IdempotencyKey: "k2"})
+	require.NoError(t, err)
+	assert.NotEqual(t, first.PostId, third.PostId)
+}
+
+func TestPublishPostRejectsReusedIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	postService := newIdempotencyTestService(t, storage.NewMemoryStore())
+
+	_, err := postService.PublishPost(ctx, &postProto.Post{UserId: "author", Content: "hello", IdempotencyKey: "k1"})
+	require.NoError(t, err)
+
+	_, err = postService.PublishPost(ctx, &postProto.Post{UserId: "author", Content: "goodbye", IdempotencyKey: "k1"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestPublishPostWithIdempotencyKeyInProgress(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	postService := newIdempotencyTestService(t, store)
+
+	// Another request claimed the key and has not stored its response yet
+	hash := sha256.Sum256([]byte("hello"))
+	now := time.Now()
+	_, err := store.ClaimIdempotencyKey(ctx, &models.IdempotencyKey{
+		UserID:      "author",
+		Key:         "k1",
+		RequestHash: hex.EncodeToString(hash[:]),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(time.Minute),
+	})
+	require.NoError(t, err)
+
+	_, err = postService.PublishPost(ctx, &postProto.Post{UserId: "author", Content: "hello", IdempotencyKey: "k1"})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+	posts, err := store.ListPostsByUser(ctx, "author", 0)
+	require.NoError(t, err)
+	assert.Empty(t, posts)
+}
+
+func TestPublishPostReleasesIdempotencyKeyOnFailure(t *testing.T) {
+	ctx := context.Background()
+	store := &failingPostStore{MemoryStore: storage.NewMemoryStore(), fail: true}
+	postService := newIdempotencyTestService(t, store)
+
+	post := &postProto.Post{UserId: "author", Content: "hello", IdempotencyKey: "k1"}
+	_, err := postService.PublishPost(ctx, post)
+	assert.Equal(t, codes.Internal, status.Code(err))
+	posts, err := store.ListPostsByUser(ctx, "author", 0)
+	require.NoError(t, err)
+	assert.Empty(t, posts)
+
+	// The retry publishes the post
+	store.fail = false
+	resp, err := postService.PublishPost(ctx, post)
+	require.NoError(t, err)
+	assert.NotEmpty(t, resp.PostId)
+	posts, err = store.ListPostsByUser(ctx, "author", 0)
+	require.NoError(t, err)
+	assert.Len(t, posts, 1)
+}
+
+func TestPublishPostRejectsLongIdempotencyKey(t *testing.T) {
+	postService := newIdempotencyTestService(t, storage.NewMemoryStore())
+
+	_, err := postService.PublishPost(context.Background(), &postProto.Post{
+		UserId:         "author",
+		Content:        "hello",
+		IdempotencyKey: strings.Repeat("k", 256),
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestPublishPostLeasesIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	store := &failingPostStore{MemoryStore: storage.NewMemoryStore(), failResponses: true}
+	postService := newIdempotencyTestService(t, store)
+
+	// The response is lost, as if the process died before storing it
+	_, err := postService.PublishPost(ctx, &postProto.Post{UserId: "author", Content: "hello", IdempotencyKey: "k1"})
+	require.NoError(t, err)
+
+	// The claim only holds the key for a short lease, not the whole TTL
+	claim := func(at time.Time) error {
+		_, err := store.ClaimIdempotencyKey(ctx, &models.IdempotencyKey{
+			UserID: "author", Key: "k1", RequestHash: "other", CreatedAt: at, ExpiresAt: at.Add(time.Minute),
+		})
+		return err
+	}
+	assert.ErrorIs(t, claim(time.Now()), storage.ErrAlreadyExists)
+	assert.NoError(t, claim(time.Now().Add(5*time.Minute)))
+}
@@ -2,68 +2,68 @@ package service
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"log"
-	"sync"
+	"time"
 
 	"github.com/iwhitebird/social-app-microservices/internal/models"
+	"github.com/iwhitebird/social-app-microservices/internal/pubsub"
 	"github.com/iwhitebird/social-app-microservices/internal/queue"
+	"github.com/iwhitebird/social-app-microservices/internal/storage"
 	notificationProto "github.com/iwhitebird/social-app-microservices/proto/generated/notification/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
+const (
+	// maxNotificationPageSize caps the page size of GetNotifications. Pages
+	// are streamed, so they can be larger than other listings.
+	maxNotificationPageSize = 500
+	// maxMarkReadIDs caps how many notifications MarkNotificationsRead accepts at once
+	maxMarkReadIDs = 1000
+)
+
 // NotificationService implements the gRPC notification service
 type NotificationService struct {
 	notificationProto.UnimplementedNotificationServiceServer
-	store *models.Store
-	queue *queue.NotificationQueue
-	mu    sync.Mutex
+	users         storage.UserRepository
+	notifications storage.NotificationRepository
+	preferences   storage.PreferenceRepository
+	deadLetters   storage.DeadLetterRepository
+	metrics       storage.MetricsRepository
+	queue         *queue.NotificationQueue
 }
 
 // NewNotificationService creates a new NotificationService
-func NewNotificationService(store *models.Store, queue *queue.NotificationQueue) *NotificationService {
+func NewNotificationService(store storage.Store, queue *queue.NotificationQueue) *NotificationService {
 	return &NotificationService{
-		store: store,
-		queue: queue,
+		users:         store,
+		notifications: store,
+		preferences:   store,
+		deadLetters:   store,
+		metrics:       store,
+		queue:         queue,
 	}
 }
 
-// GetNotifications streams notifications for a user
-func (s *NotificationService) GetNotifications(userId *notificationProto.UserId, stream notificationProto.NotificationService_GetNotificationsServer) error {
-	log.Printf("Received GetNotifications request for user %s", userId.UserId)
-
-	userID := userId.UserId
-
-	// Get notifications for the user
-	s.mu.Lock()
-	userNotifications, exists := s.store.Notifications[userID]
-	s.mu.Unlock()
+// GetNotifications streams one page of a user's notifications, newest first
+func (s *NotificationService) GetNotifications(in *notificationProto.GetNotificationsRequest, stream notificationProto.NotificationService_GetNotificationsServer) error {
+	log.Printf("Received GetNotifications request for user %s", in.UserId)
 
-	if !exists {
-		return nil
+	query, err := notificationQuery(in)
+	if err != nil {
+		return err
 	}
 
-	// Get the 20 most recent notifications
-	startIdx := 0
-	if len(userNotifications) > 20 {
-		startIdx = len(userNotifications) - 20
+	notifications, err := s.notifications.QueryNotifications(stream.Context(), in.UserId, query)
+	if err != nil {
+		return storageError(err, "notifications of user "+in.UserId)
 	}
-	recentNotifications := userNotifications[startIdx:]
 
 	// Send each notification to the client
-	for _, notification := range recentNotifications {
-		// Convert internal notification to proto notification
-		protoNotification := &notificationProto.Notification{
-			Id:        notification.ID,
-			UserId:    notification.UserID,
-			PostId:    notification.PostID,
-			Content:   notification.Content,
-			Read:      notification.Read,
-			CreatedAt: notification.CreatedAt.Unix(),
-		}
-		fmt.Println(protoNotification)
-		// Send the notification
-		if err := stream.Send(protoNotification); err != nil {
+	for _, notification := range notifications {
+		if err := stream.Send(toProtoNotification(notification)); err != nil {
 			log.Printf("Error sending notification: %v", err)
 			return err
 		}
@@ -72,11 +72,418 @@ func (s *NotificationService) GetNotifications(userId *notificationProto.UserId,
 	return nil
 }
 
+// SubscribeNotifications keeps the stream open and pushes every notification
+// delivered to the user. With a cursor, the notifications delivered after it
+// are replayed first, oldest first and a page at a time until caught up.
+func (s *NotificationService) SubscribeNotifications(in *notificationProto.SubscribeNotificationsRequest, stream notificationProto.NotificationService_SubscribeNotificationsServer) error {
+	log.Printf("Received SubscribeNotifications request for user %s", in.UserId)
+
+	afterSeq, err := decodeSeqCursor(in.Cursor)
+	if err != nil {
+		return err
+	}
+
+	// Subscribe before replaying so nothing delivered in between is lost
+	sub := s.queue.Subscribe(in.UserId)
+	defer sub.Close()
+
+	// Send the headers right away so clients can tell when the subscription
+	// is in place, without waiting for the first notification
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+
+	replayedSeq := afterSeq
+	for afterSeq > 0 {
+		missed, err := s.notifications.QueryNotifications(stream.Context(), in.UserId, storage.NotificationQuery{
+			AfterSeq:    replayedSeq,
+			Limit:       maxNotificationPageSize,
+			OldestFirst: true,
+		})
+		if err != nil {
+			return storageError(err, "notifications of user "+in.UserId)
+		}
+		for _, notification := range missed {
+			if err := stream.Send(toProtoNotification(notification)); err != nil {
+				return err
+			}
+			replayedSeq = notification.Seq
+		}
+		if len(missed) < maxNotificationPageSize {
+			break
+		}
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			log.Printf("Subscriber of user %s disconnected", in.UserId)
+			return nil
+		case notification, ok := <-sub.C():
+			if !ok {
+				return subscriptionError(sub.Err())
+			}
+			// Skip what the replay already sent
+			if notification.Seq <= replayedSeq {
+				continue
+			}
+			if err := stream.Send(toProtoNotification(notification)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// subscriptionError maps the reason a subscription ended to a gRPC status
+func subscriptionError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, pubsub.ErrSlowConsumer):
+		return status.Error(codes.ResourceExhausted, "subscriber fell behind, resume from the last cursor")
+	case errors.Is(err, pubsub.ErrHubClosed):
+		return status.Error(codes.Unavailable, "notification service is shutting down")
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// notificationQuery validates a GetNotifications request and turns it into a storage query
+func notificationQuery(in *notificationProto.GetNotificationsRequest) (storage.NotificationQuery, error) {
+	beforeSeq, err := decodeSeqCursor(in.Cursor)
+	if err != nil {
+		return storage.NotificationQuery{}, err
+	}
+	if in.Since < 0 || in.Until < 0 {
+		return storage.NotificationQuery{}, status.Error(codes.InvalidArgument, "since and until must not be negative")
+	}
+	if in.Since > 0 && in.Until > 0 && in.Since >= in.Until {
+		return storage.NotificationQuery{}, status.Error(codes.InvalidArgument, "since must be before until")
+	}
+
+	query := storage.NotificationQuery{
+		BeforeSeq:  beforeSeq,
+		UnreadOnly: in.UnreadOnly,
+		Limit:      notificationPageSize(in.PageSize),
+	}
+	if in.Since > 0 {
+		query.Since = time.Unix(in.Since, 0)
+	}
+	if in.Until > 0 {
+		query.Until = time.Unix(in.Until, 0)
+	}
+	return query, nil
+}
+
+// notificationPageSize clamps a requested page size to (0, maxNotificationPageSize]
+func notificationPageSize(requested int32) int {
+	if requested <= 0 {
+		return defaultPageSize
+	}
+	if requested > maxNotificationPageSize {
+		return maxNotificationPageSize
+	}
+	return int(requested)
+}
+
+func toProtoNotification(notification *models.Notification) *notificationProto.Notification {
+	n := &notificationProto.Notification{
+		Id:         notification.ID,
+		UserId:     notification.UserID,
+		PostId:     notification.PostID,
+		Content:    notification.Content,
+		Read:       notification.Read,
+		CreatedAt:  notification.CreatedAt.Unix(),
+		Cursor:     encodeSeqCursor(notification.Seq),
+		Status:     toProtoStatus(notification.Status),
+		RetryCount: int32(notification.RetryCount),
+		AuthorId:   notification.AuthorID,
+		EventType:  string(notification.EventType),
+		PostIds:    notification.PostIDs,
+	}
+	if notification.LastRetry != nil {
+		n.LastRetry = notification.LastRetry.Unix()
+	}
+	if notification.DeliveredAt != nil {
+		n.DeliveredAt = notification.DeliveredAt.Unix()
+	}
+	for _, delivery := range notification.Deliveries {
+		d := &notificationProto.ChannelDelivery{
+			Channel:   string(delivery.Channel),
+			Status:    toProtoStatus(delivery.Status),
+			Attempts:  int32(delivery.Attempts),
+			LastError: delivery.LastError,
+		}
+		if delivery.DeliveredAt != nil {
+			d.DeliveredAt = delivery.DeliveredAt.Unix()
+		}
+		n.Deliveries = append(n.Deliveries, d)
+	}
+	return n
+}
+
+var protoStatuses = map[models.NotificationStatus]notificationProto.NotificationStatus{
+	models.NotificationStatusPending:      notificationProto.NotificationStatus_NOTIFICATION_STATUS_PENDING,
+	models.NotificationStatusDelivering:   notificationProto.NotificationStatus_NOTIFICATION_STATUS_DELIVERING,
+	models.NotificationStatusDelivered:    notificationProto.NotificationStatus_NOTIFICATION_STATUS_DELIVERED,
+	models.NotificationStatusFailed:       notificationProto.NotificationStatus_NOTIFICATION_STATUS_FAILED,
+	models.NotificationStatusDeadLettered: notificationProto.NotificationStatus_NOTIFICATION_STATUS_DEAD_LETTERED,
+}
+
+// toProtoStatus maps a status to its proto enum, UNSPECIFIED for
+// notifications stored before statuses were tracked
+func toProtoStatus(status models.NotificationStatus) notificationProto.NotificationStatus {
+	return protoStatuses[status]
+}
+
 func (s *NotificationService) GetNotificationMetrics(ctx context.Context, in *emptypb.Empty) (*notificationProto.NotificationMetrics, error) {
+	metrics, err := s.metrics.GetMetrics(ctx)
+	if err != nil {
+		return nil, err
+	}
 	notificationMetrics := &notificationProto.NotificationMetrics{
-		TotalNotificationsSent: int64(s.store.Metrics.TotalNotificationsSent),
-		FailedAttempts:         int64(s.store.Metrics.FailedAttempts),
-		AverageDeliveryTime:    float64(s.store.Metrics.AverageDeliveryTime),
+		TotalNotificationsSent: int64(metrics.TotalNotificationsSent),
+		FailedAttempts:         int64(metrics.FailedAttempts),
+		AverageDeliveryTime:    float64(metrics.AverageDeliveryTime),
+	}
+	for _, lane := range s.queue.Lanes() {
+		notificationMetrics.Lanes = append(notificationMetrics.Lanes, &notificationProto.QueueLane{
+			Priority:     string(lane.Priority),
+			Depth:        int64(lane.Depth),
+			OldestWaitMs: lane.OldestWait.Milliseconds(),
+			Spilled:      int64(lane.Spilled),
+		})
 	}
 	return notificationMetrics, nil
 }
+
+// MarkNotificationRead marks a single notification of a user as read
+func (s *NotificationService) MarkNotificationRead(ctx context.Context, in *notificationProto.MarkNotificationReadRequest) (*notificationProto.MarkReadResponse, error) {
+	log.Printf("Received MarkNotificationRead request for notification %s of user %s", in.NotificationId, in.UserId)
+
+	marked, err := s.notifications.MarkNotificationsRead(ctx, in.UserId, []string{in.NotificationId})
+	if err != nil {
+		return nil, storageError(err, "notification "+in.NotificationId)
+	}
+	if marked == 0 {
+		// Either it was read already or the user has no such notification
+		if err := s.notifications.MarkNotificationRead(ctx, in.UserId, in.NotificationId); err != nil {
+			return nil, storageError(err, "notification "+in.NotificationId)
+		}
+	}
+	return s.markReadResponse(ctx, in.UserId, marked)
+}
+
+// MarkNotificationsRead marks a list of notifications of a user as read
+func (s *NotificationService) MarkNotificationsRead(ctx context.Context, in *notificationProto.MarkNotificationsReadRequest) (*notificationProto.MarkReadResponse, error) {
+	log.Printf("Received MarkNotificationsRead request for %d notifications of user %s", len(in.NotificationIds), in.UserId)
+
+	if len(in.NotificationIds) > maxMarkReadIDs {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d notifications can be marked at once", maxMarkReadIDs)
+	}
+
+	marked, err := s.notifications.MarkNotificationsRead(ctx, in.UserId, in.NotificationIds)
+	if err != nil {
+		return nil, storageError(err, "notifications of user "+in.UserId)
+	}
+	return s.markReadResponse(ctx, in.UserId, marked)
+}
+
+// MarkAllRead marks every notification of a user up to a point in time as read
+func (s *NotificationService) MarkAllRead(ctx context.Context, in *notificationProto.MarkAllReadRequest) (*notificationProto.MarkReadResponse, error) {
+	log.Printf("Received MarkAllRead request for user %s", in.UserId)
+
+	if in.Before < 0 {
+		return nil, status.Error(codes.InvalidArgument, "before must not be negative")
+	}
+	// The timestamp has second precision, so include the whole second
+	before := time.Now()
+	if in.Before > 0 {
+		before = time.Unix(in.Before, int64(time.Second-1))
+	}
+
+	marked, err := s.notifications.MarkAllNotificationsRead(ctx, in.UserId, before)
+	if err != nil {
+		return nil, storageError(err, "notifications of user "+in.UserId)
+	}
+	return s.markReadResponse(ctx, in.UserId, marked)
+}
+
+// GetUnreadCount returns how many unread notifications a user has
+func (s *NotificationService) GetUnreadCount(ctx context.Context, in *notificationProto.UserId) (*notificationProto.UnreadCount, error) {
+	unread, err := s.notifications.CountUnreadNotifications(ctx, in.UserId)
+	if err != nil {
+		return nil, storageError(err, "notifications of user "+in.UserId)
+	}
+	return &notificationProto.UnreadCount{Count: int32(unread)}, nil
+}
+
+func (s *NotificationService) markReadResponse(ctx context.Context, userID string, marked int) (*notificationProto.MarkReadResponse, error) {
+	unread, err := s.notifications.CountUnreadNotifications(ctx, userID)
+	if err != nil {
+		return nil, storageError(err, "notifications of user "+userID)
+	}
+	return &notificationProto.MarkReadResponse{
+		Marked:      int32(marked),
+		UnreadCount: int32(unread),
+	}, nil
+}
+
+// GetNotificationJob returns a notification that is still in the queue with
+// its delivery attempts so far
+func (s *NotificationService) GetNotificationJob(ctx context.Context, in *notificationProto.NotificationId) (*notificationProto.NotificationJob, error) {
+	job, err := s.queue.Job(ctx, in.NotificationId)
+	if err != nil {
+		return nil, storageError(err, "notification job "+in.NotificationId)
+	}
+
+	var dueAt int64
+	if !job.DueAt.IsZero() {
+		dueAt = job.DueAt.Unix()
+	}
+	notification := toProtoNotification(job.Notification)
+	// Jobs are not part of any feed
+	notification.Cursor = ""
+	return &notificationProto.NotificationJob{
+		Notification: notification,
+		Attempt:      int32(job.Attempt),
+		DueAt:        dueAt,
+		Attempts:     toProtoDeliveryAttempts(job.Attempts),
+	}, nil
+}
+
+// ListDeadLetters returns a page of the notifications the queue gave up on,
+// oldest first
+func (s *NotificationService) ListDeadLetters(ctx context.Context, in *notificationProto.ListDeadLettersRequest) (*notificationProto.DeadLetterPage, error) {
+	afterSeq, err := decodeSeqCursor(in.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	limit := pageSize(in.PageSize)
+
+	// Fetch one more than asked for to find out whether there is a next page
+	deadLetters, err := s.deadLetters.ListDeadLetters(ctx, afterSeq, limit+1)
+	if err != nil {
+		return nil, storageError(err, "dead letters")
+	}
+	total, err := s.deadLetters.CountDeadLetters(ctx)
+	if err != nil {
+		return nil, storageError(err, "dead letters")
+	}
+
+	page := &notificationProto.DeadLetterPage{TotalCount: int32(total)}
+	if len(deadLetters) > limit {
+		deadLetters = deadLetters[:limit]
+		page.NextCursor = encodeSeqCursor(deadLetters[limit-1].Seq)
+	}
+	for _, deadLetter := range deadLetters {
+		page.DeadLetters = append(page.DeadLetters, toProtoDeadLetter(deadLetter))
+	}
+	return page, nil
+}
+
+// GetDeadLetter returns a dead-lettered notification with its attempt history
+func (s *NotificationService) GetDeadLetter(ctx context.Context, in *notificationProto.NotificationId) (*notificationProto.DeadLetter, error) {
+	deadLetter, err := s.deadLetters.GetDeadLetter(ctx, in.NotificationId)
+	if err != nil {
+		return nil, storageError(err, "dead letter "+in.NotificationId)
+	}
+	return toProtoDeadLetter(deadLetter), nil
+}
+
+// ReplayDeadLetter puts a dead-lettered notification back into the queue
+func (s *NotificationService) ReplayDeadLetter(ctx context.Context, in *notificationProto.NotificationId) (*notificationProto.ReplayDeadLettersResponse, error) {
+	log.Printf("Received ReplayDeadLetter request for notification %s", in.NotificationId)
+
+	if err := s.queue.ReplayDeadLetter(ctx, in.NotificationId); err != nil {
+		return nil, storageError(err, "dead letter "+in.NotificationId)
+	}
+	return &notificationProto.ReplayDeadLettersResponse{Replayed: 1}, nil
+}
+
+// ReplayDeadLetters puts every dead-lettered notification back into the queue
+func (s *NotificationService) ReplayDeadLetters(ctx context.Context, in *emptypb.Empty) (*notificationProto.ReplayDeadLettersResponse, error) {
+	log.Printf("Received ReplayDeadLetters request")
+
+	replayed, err := s.queue.ReplayDeadLetters(ctx)
+	if err != nil {
+		return nil, storageError(err, "dead letters")
+	}
+	return &notificationProto.ReplayDeadLettersResponse{Replayed: int32(replayed)}, nil
+}
+
+// PurgeDeadLetters deletes the dead letters up to a point in time, or all of them
+func (s *NotificationService) PurgeDeadLetters(ctx context.Context, in *notificationProto.PurgeDeadLettersRequest) (*notificationProto.PurgeDeadLettersResponse, error) {
+	log.Printf("Received PurgeDeadLetters request")
+
+	if in.Before < 0 {
+		return nil, status.Error(codes.InvalidArgument, "before must not be negative")
+	}
+	// The timestamp has second precision, so include the whole second
+	before := time.Now()
+	if in.Before > 0 {
+		before = time.Unix(in.Before, int64(time.Second-1))
+	}
+
+	purged, err := s.deadLetters.PurgeDeadLetters(ctx, before)
+	if err != nil {
+		return nil, storageError(err, "dead letters")
+	}
+	return &notificationProto.PurgeDeadLettersResponse{Purged: int32(purged)}, nil
+}
+
+// GetWorkerPool returns the size and load of the queue's worker pool
+func (s *NotificationService) GetWorkerPool(ctx context.Context, in *emptypb.Empty) (*notificationProto.WorkerPool, error) {
+	return toProtoWorkerPool(s.queue.Pool()), nil
+}
+
+// SetWorkerPool pins the queue's worker pool to a size, or hands it back to
+// the autoscaler for size 0
+func (s *NotificationService) SetWorkerPool(ctx context.Context, in *notificationProto.SetWorkerPoolRequest) (*notificationProto.WorkerPool, error) {
+	log.Printf("Received SetWorkerPool request for %d workers", in.Workers)
+
+	pool, err := s.queue.ResizePool(int(in.Workers))
+	if errors.Is(err, queue.ErrPoolSize) {
+		return nil, status.Errorf(codes.InvalidArgument, "workers must be between 1 and %d, or 0 to autoscale", s.queue.Pool().MaxWorkers)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to resize the worker pool: %v", err)
+	}
+	return toProtoWorkerPool(pool), nil
+}
+
+func toProtoWorkerPool(pool queue.PoolStats) *notificationProto.WorkerPool {
+	return &notificationProto.WorkerPool{
+		Workers:     int32(pool.Workers),
+		Busy:        int32(pool.Busy),
+		MinWorkers:  int32(pool.MinWorkers),
+		MaxWorkers:  int32(pool.MaxWorkers),
+		Autoscaling: pool.Autoscaling,
+	}
+}
+
+func toProtoDeadLetter(deadLetter *models.DeadLetter) *notificationProto.DeadLetter {
+	notification := toProtoNotification(deadLetter.Notification)
+	// Dead letters are not part of any feed
+	notification.Cursor = ""
+
+	return &notificationProto.DeadLetter{
+		Notification: notification,
+		Reason:       deadLetter.Reason,
+		Attempts:     toProtoDeliveryAttempts(deadLetter.Attempts),
+		FailedAt:     deadLetter.FailedAt.Unix(),
+	}
+}
+
+func toProtoDeliveryAttempts(attempts []models.DeliveryAttempt) []*notificationProto.DeliveryAttempt {
+	result := make([]*notificationProto.DeliveryAttempt, 0, len(attempts))
+	for _, attempt := range attempts {
+		result = append(result, &notificationProto.DeliveryAttempt{
+			Attempt:     int32(attempt.Attempt),
+			AttemptedAt: attempt.At.Unix(),
+			Error:       attempt.Error,
+			Channel:     string(attempt.Channel),
+		})
+	}
+	return result
+}
@@ -2,16 +2,24 @@ package service_test
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
+	"github.com/iwhitebird/social-app-microservices/internal/delivery"
+	"github.com/iwhitebird/social-app-microservices/internal/fanout"
 	"github.com/iwhitebird/social-app-microservices/internal/models"
 	"github.com/iwhitebird/social-app-microservices/internal/queue"
 	"github.com/iwhitebird/social-app-microservices/internal/service"
+	"github.com/iwhitebird/social-app-microservices/internal/storage"
 	notificationProto "github.com/iwhitebird/social-app-microservices/proto/generated/notification/proto"
 	postProto "github.com/iwhitebird/social-app-microservices/proto/generated/post/proto"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
@@ -52,9 +60,20 @@ func (s *MockNotificationStream) RecvMsg(m interface{}) error {
 	return nil
 }
 
+// SubscribeStream hands the notifications sent on a long-lived stream to a channel
+type SubscribeStream struct {
+	MockNotificationStream
+	Sent chan *notificationProto.Notification
+}
+
+func (s *SubscribeStream) Send(notification *notificationProto.Notification) error {
+	s.Sent <- notification
+	return nil
+}
+
 func TestGetNotifications(t *testing.T) {
 	// Create real store
-	store := models.NewStore()
+	store := storage.NewMemoryStore()
 
 	// Create real queue
 	notificationQueue := queue.NewNotificationQueue(store, 3, 2)
@@ -103,7 +122,7 @@ func TestGetNotifications(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Create user ID proto message
-			userID := &notificationProto.UserId{
+			userID := &notificationProto.GetNotificationsRequest{
 				UserId: tt.userID,
 			}
 
@@ -131,6 +150,7 @@ func TestGetNotifications(t *testing.T) {
 						assert.NotEmpty(t, notification.Id)
 						assert.NotEmpty(t, notification.Content)
 						assert.NotZero(t, notification.CreatedAt)
+						assert.Equal(t, notificationProto.NotificationStatus_NOTIFICATION_STATUS_DELIVERED, notification.Status)
 					}
 				}
 			}
@@ -138,9 +158,235 @@ func TestGetNotifications(t *testing.T) {
 	}
 }
 
+func TestGetNotificationsPagination(t *testing.T) {
+	// Create real store
+	store := storage.NewMemoryStore()
+
+	// Create real queue
+	notificationQueue := queue.NewNotificationQueue(store, 3, 2)
+	notificationQueue.Start()
+	defer notificationQueue.Stop()
+
+	// Create notification service
+	notificationService := service.NewNotificationService(store, notificationQueue)
+
+	ctx := context.Background()
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for i := 0; i < 25; i++ {
+		store.AddNotification(ctx, &models.Notification{
+			ID:        fmt.Sprintf("n%02d", i),
+			UserID:    "user1",
+			Content:   fmt.Sprintf("notification %d", i),
+			Read:      i%5 == 0,
+			CreatedAt: base.Add(time.Duration(i) * time.Minute),
+		})
+	}
+
+	fetch := func(request *notificationProto.GetNotificationsRequest) []*notificationProto.Notification {
+		mockStream := &MockNotificationStream{Ctx: ctx}
+		require.NoError(t, notificationService.GetNotifications(request, mockStream))
+		return mockStream.ReceivedMsgs
+	}
+
+	// The default page holds the 20 newest notifications, newest first
+	page := fetch(&notificationProto.GetNotificationsRequest{UserId: "user1"})
+	require.Len(t, page, 20)
+	assert.Equal(t, "n24", page[0].Id)
+	assert.Equal(t, "n05", page[19].Id)
+
+	// Following the cursors reaches every notification exactly once
+	var seen []string
+	cursor := ""
+	for {
+		page := fetch(&notificationProto.GetNotificationsRequest{UserId: "user1", PageSize: 7, Cursor: cursor})
+		if len(page) == 0 {
+			break
+		}
+		for _, n := range page {
+			seen = append(seen, n.Id)
+		}
+		cursor = page[len(page)-1].Cursor
+	}
+	require.Len(t, seen, 25)
+	assert.Equal(t, "n24", seen[0])
+	assert.Equal(t, "n00", seen[24])
+
+	// Filters
+	page = fetch(&notificationProto.GetNotificationsRequest{UserId: "user1", UnreadOnly: true, PageSize: 100})
+	assert.Len(t, page, 20)
+
+	page = fetch(&notificationProto.GetNotificationsRequest{
+		UserId: "user1",
+		Since:  base.Add(10 * time.Minute).Unix(),
+		Until:  base.Add(13 * time.Minute).Unix(),
+	})
+	require.Len(t, page, 3)
+	assert.Equal(t, "n12", page[0].Id)
+	assert.Equal(t, "n10", page[2].Id)
+
+	// Invalid requests are rejected
+	err := notificationService.GetNotifications(&notificationProto.GetNotificationsRequest{
+		UserId: "user1",
+		Cursor: "garbage",
+	}, &MockNotificationStream{Ctx: ctx})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	err = notificationService.GetNotifications(&notificationProto.GetNotificationsRequest{
+		UserId: "user1",
+		Since:  base.Unix(),
+		Until:  base.Unix(),
+	}, &MockNotificationStream{Ctx: ctx})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGetNotificationsRequestAcceptsUserId(t *testing.T) {
+	// Clients built before GetNotificationsRequest existed send a UserId
+	payload, err := proto.Marshal(&notificationProto.UserId{UserId: "user1"})
+	require.NoError(t, err)
+
+	var request notificationProto.GetNotificationsRequest
+	require.NoError(t, proto.Unmarshal(payload, &request))
+	assert.Equal(t, "user1", request.UserId)
+}
+
+func TestSubscribeNotifications(t *testing.T) {
+	// Create real store
+	store := storage.NewMemoryStore()
+
+	// Create real queue, with enough retries that simulated failures do not lose notifications
+	notificationQueue := queue.NewNotificationQueue(store, 3, 10)
+	notificationQueue.Start()
+	defer notificationQueue.Stop()
+
+	// Create real fan-out dispatcher
+	dispatcher := fanout.NewDispatcher(store, notificationQueue)
+	dispatcher.Start()
+	defer dispatcher.Stop()
+
+	// Create services
+	notificationService := service.NewNotificationService(store, notificationQueue)
+	postService := service.NewPostService(store, dispatcher)
+
+	ctx := context.Background()
+	store.CreateUser(ctx, &models.User{ID: "author", Username: "author"})
+	store.Follow(ctx, "reader", "author")
+
+	publish := func(content string) {
+		_, err := postService.PublishPost(ctx, &postProto.Post{UserId: "author", Content: content})
+		require.NoError(t, err)
+	}
+	receive := func(stream *SubscribeStream) *notificationProto.Notification {
+		select {
+		case notification := <-stream.Sent:
+			return notification
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for a notification")
+			return nil
+		}
+	}
+	subscribe := func(cursor string) (*SubscribeStream, context.CancelFunc, chan error) {
+		streamCtx, cancel := context.WithCancel(ctx)
+		stream := &SubscribeStream{
+			MockNotificationStream: MockNotificationStream{Ctx: streamCtx},
+			Sent:                   make(chan *notificationProto.Notification, 10),
+		}
+		done := make(chan error, 1)
+		go func() {
+			done <- notificationService.SubscribeNotifications(&notificationProto.SubscribeNotificationsRequest{
+				UserId: "reader",
+				Cursor: cursor,
+			}, stream)
+		}()
+		// Give the subscription time to register
+		time.Sleep(100 * time.Millisecond)
+		return stream, cancel, done
+	}
+
+	// Live notifications are pushed as they are delivered
+	stream, cancel, done := subscribe("")
+	publish("first")
+	first := receive(stream)
+	assert.Contains(t, first.Content, "first")
+	assert.NotEmpty(t, first.Cursor)
+
+	// Disconnecting ends the call cleanly
+	cancel()
+	require.NoError(t, <-done)
+
+	// Notifications delivered while disconnected are replayed on resume
+	publish("second")
+	publish("third")
+	require.Eventually(t, func() bool {
+		notifications, _ := store.ListNotifications(ctx, "reader", 0)
+		return len(notifications) == 3
+	}, 10*time.Second, 50*time.Millisecond)
+
+	stream, cancel, done = subscribe(first.Cursor)
+	defer cancel()
+	var replayed []string
+	for i := 0; i < 2; i++ {
+		replayed = append(replayed, receive(stream).Content)
+	}
+	assert.ElementsMatch(t, []string{"author posted: second", "author posted: third"}, replayed)
+
+	// The resumed stream keeps delivering live notifications
+	publish("fourth")
+	assert.Contains(t, receive(stream).Content, "fourth")
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestSubscribeNotificationsReplaysLongGaps(t *testing.T) {
+	store := storage.NewMemoryStore()
+	notificationQueue := queue.NewNotificationQueue(store, 1, 1)
+	notificationService := service.NewNotificationService(store, notificationQueue)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	add := func(i int) {
+		require.NoError(t, store.AddNotification(ctx, &models.Notification{ID: fmt.Sprintf("n%d", i), UserID: "reader", CreatedAt: time.Now()}))
+	}
+
+	// The client saw the first notification, then missed more than a page
+	add(0)
+	seen := &MockNotificationStream{Ctx: ctx}
+	require.NoError(t, notificationService.GetNotifications(&notificationProto.GetNotificationsRequest{UserId: "reader"}, seen))
+	require.Len(t, seen.ReceivedMsgs, 1)
+	const missed = 1201
+	for i := 1; i <= missed; i++ {
+		add(i)
+	}
+
+	stream := &SubscribeStream{
+		MockNotificationStream: MockNotificationStream{Ctx: ctx},
+		Sent:                   make(chan *notificationProto.Notification, missed),
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- notificationService.SubscribeNotifications(&notificationProto.SubscribeNotificationsRequest{
+			UserId: "reader",
+			Cursor: seen.ReceivedMsgs[0].Cursor,
+		}, stream)
+	}()
+
+	// Every missed notification is replayed once, oldest first
+	for i := 1; i <= missed; i++ {
+		select {
+		case notification := <-stream.Sent:
+			require.Equal(t, fmt.Sprintf("n%d", i), notification.Id)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for notification %d", i)
+		}
+	}
+	cancel()
+	require.NoError(t, <-done)
+	assert.Empty(t, stream.Sent)
+}
+
 func TestGetNotificationMetrics(t *testing.T) {
 	// Create real store
-	store := models.NewStore()
+	store := storage.NewMemoryStore()
 
 	// Create real queue
 	notificationQueue := queue.NewNotificationQueue(store, 3, 2)
@@ -152,9 +398,12 @@ func TestGetNotificationMetrics(t *testing.T) {
 
 	// Initialize test data with metrics
 	initTestData(store)
-	store.Metrics.TotalNotificationsSent = 10
-	store.Metrics.FailedAttempts = 2
-	store.Metrics.AverageDeliveryTime = 150.5
+	for i := 0; i < 10; i++ {
+		// Alternate between 150ns and 151ns so the average lands on 150.5
+		store.RecordDelivery(context.Background(), time.Duration(150+i%2))
+	}
+	store.RecordFailure(context.Background())
+	store.RecordFailure(context.Background())
 
 	// Call GetNotificationMetrics
 	metrics, err := notificationService.GetNotificationMetrics(context.Background(), &emptypb.Empty{})
@@ -168,9 +417,88 @@ func TestGetNotificationMetrics(t *testing.T) {
 	assert.Equal(t, 150.5, metrics.AverageDeliveryTime)
 }
 
+func TestMarkNotificationsRead(t *testing.T) {
+	// Create real store
+	store := storage.NewMemoryStore()
+
+	// Create real queue
+	notificationQueue := queue.NewNotificationQueue(store, 3, 2)
+	notificationQueue.Start()
+	defer notificationQueue.Stop()
+
+	// Create notification service
+	notificationService := service.NewNotificationService(store, notificationQueue)
+
+	ctx := context.Background()
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for i, id := range []string{"n1", "n2", "n3", "n4"} {
+		store.AddNotification(ctx, &models.Notification{
+			ID:        id,
+			UserID:    "user1",
+			CreatedAt: base.Add(time.Duration(i) * time.Minute),
+		})
+	}
+
+	unread, err := notificationService.GetUnreadCount(ctx, &notificationProto.UserId{UserId: "user1"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(4), unread.Count)
+
+	// Mark a single notification, twice
+	resp, err := notificationService.MarkNotificationRead(ctx, &notificationProto.MarkNotificationReadRequest{
+		UserId:         "user1",
+		NotificationId: "n1",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), resp.Marked)
+	assert.Equal(t, int32(3), resp.UnreadCount)
+
+	resp, err = notificationService.MarkNotificationRead(ctx, &notificationProto.MarkNotificationReadRequest{
+		UserId:         "user1",
+		NotificationId: "n1",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), resp.Marked)
+	assert.Equal(t, int32(3), resp.UnreadCount)
+
+	// Notifications of other users cannot be marked
+	_, err = notificationService.MarkNotificationRead(ctx, &notificationProto.MarkNotificationReadRequest{
+		UserId:         "user2",
+		NotificationId: "n2",
+	})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// Mark a batch
+	resp, err = notificationService.MarkNotificationsRead(ctx, &notificationProto.MarkNotificationsReadRequest{
+		UserId:          "user1",
+		NotificationIds: []string{"n1", "n2"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), resp.Marked)
+	assert.Equal(t, int32(2), resp.UnreadCount)
+
+	// Mark everything up to the creation of n3
+	resp, err = notificationService.MarkAllRead(ctx, &notificationProto.MarkAllReadRequest{
+		UserId: "user1",
+		Before: base.Add(2 * time.Minute).Unix(),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), resp.Marked)
+	assert.Equal(t, int32(1), resp.UnreadCount)
+
+	// Without a timestamp everything is marked
+	resp, err = notificationService.MarkAllRead(ctx, &notificationProto.MarkAllReadRequest{UserId: "user1"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), resp.Marked)
+	assert.Equal(t, int32(0), resp.UnreadCount)
+
+	// A timestamp before the epoch is rejected
+	_, err = notificationService.MarkAllRead(ctx, &notificationProto.MarkAllReadRequest{UserId: "user1", Before: -1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
 func TestGetNotificationsStreamError(t *testing.T) {
 	// Create real store
-	store := models.NewStore()
+	store := storage.NewMemoryStore()
 
 	// Create real queue
 	notificationQueue := queue.NewNotificationQueue(store, 3, 2)
@@ -184,7 +512,7 @@ func TestGetNotificationsStreamError(t *testing.T) {
 	initTestData(store)
 
 	// Create a user ID proto message
-	userID := &notificationProto.UserId{
+	userID := &notificationProto.GetNotificationsRequest{
 		UserId: "test-user-1",
 	}
 
@@ -203,35 +531,28 @@ func TestGetNotificationsStreamError(t *testing.T) {
 
 func TestNotificationServiceIntegration(t *testing.T) {
 	// Create real store
-	store := models.NewStore()
+	store := storage.NewMemoryStore()
 
 	// Create real queue
 	notificationQueue := queue.NewNotificationQueue(store, 3, 2)
 	notificationQueue.Start()
 	defer notificationQueue.Stop()
 
+	// Create real fan-out dispatcher
+	dispatcher := fanout.NewDispatcher(store, notificationQueue)
+	dispatcher.Start()
+	defer dispatcher.Stop()
+
 	// Create services
 	notificationService := service.NewNotificationService(store, notificationQueue)
-	postService := service.NewPostService(store, notificationQueue)
+	postService := service.NewPostService(store, dispatcher)
 
 	// Add some test users with followers
-	store.Users["user1"] = &models.User{
-		ID:        "user1",
-		Username:  "user1",
-		Followers: []string{"user2", "user3"},
-	}
-
-	store.Users["user2"] = &models.User{
-		ID:        "user2",
-		Username:  "user2",
-		Followers: []string{},
-	}
-
-	store.Users["user3"] = &models.User{
-		ID:        "user3",
-		Username:  "user3",
-		Followers: []string{},
-	}
+	store.CreateUser(context.Background(), &models.User{ID: "user1", Username: "user1"})
+	store.CreateUser(context.Background(), &models.User{ID: "user2", Username: "user2"})
+	store.CreateUser(context.Background(), &models.User{ID: "user3", Username: "user3"})
+	store.Follow(context.Background(), "user2", "user1")
+	store.Follow(context.Background(), "user3", "user1")
 
 	// Create a test post through the post service
 	ctx := context.Background()
@@ -243,13 +564,14 @@ func TestNotificationServiceIntegration(t *testing.T) {
 	// Assert post creation was successful
 	assert.NoError(t, err)
 	assert.True(t, postResp.Success)
-	assert.Equal(t, int32(2), postResp.NotificationsQueued)
+	assert.Equal(t, int32(2), postResp.Followers)
+	assert.Equal(t, int32(2), waitForFanout(t, postService, postResp).Queued)
 
 	// Allow time for notifications to be processed
 	time.Sleep(100 * time.Millisecond)
 
 	// Now get notifications for one of the followers
-	userID := &notificationProto.UserId{
+	userID := &notificationProto.GetNotificationsRequest{
 		UserId: "user2",
 	}
 
@@ -274,16 +596,27 @@ func TestNotificationServiceIntegration(t *testing.T) {
 }
 
 // Helper function to initialize test data
-func initTestData(store *models.Store) {
+func initTestData(store storage.Store) {
+	ctx := context.Background()
+
 	// Test users
 	users := []*models.User{
-		{ID: "test-user-1", Username: "testuser1", Followers: []string{"test-user-2", "test-user-3"}},
-		{ID: "test-user-2", Username: "testuser2", Followers: []string{"test-user-1", "test-user-3"}},
-		{ID: "test-user-3", Username: "testuser3", Followers: []string{"test-user-1", "test-user-2"}},
+		{ID: "test-user-1", Username: "testuser1"},
+		{ID: "test-user-2", Username: "testuser2"},
+		{ID: "test-user-3", Username: "testuser3"},
 	}
 
 	for _, u := range users {
-		store.Users[u.ID] = u
+		store.CreateUser(ctx, u)
+	}
+
+	// Everyone follows everyone else
+	for _, follower := range users {
+		for _, followee := range users {
+			if follower.ID != followee.ID {
+				store.Follow(ctx, follower.ID, followee.ID)
+			}
+		}
 	}
 
 	// Test posts
@@ -293,7 +626,7 @@ func initTestData(store *models.Store) {
 	}
 
 	for _, p := range posts {
-		store.Posts[p.UserID] = p
+		store.SavePost(ctx, p)
 	}
 
 	// Test notifications
@@ -320,10 +653,236 @@ func initTestData(store *models.Store) {
 	}
 
 	for _, n := range notifications {
-		userID := n.UserID
-		if _, exists := store.Notifications[userID]; !exists {
-			store.Notifications[userID] = []*models.Notification{}
-		}
-		store.Notifications[userID] = append(store.Notifications[userID], n)
+		store.AddNotification(ctx, n)
 	}
 }
+
+func TestDeadLetterAdmin(t *testing.T) {
+	// Create real store
+	store := storage.NewMemoryStore()
+
+	// Create real queue
+	notificationQueue := queue.NewNotificationQueue(store, 3, 5)
+	notificationQueue.Start()
+	defer notificationQueue.Stop()
+
+	// Create notification service
+	notificationService := service.NewNotificationService(store, notificationQueue)
+
+	ctx := context.Background()
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, store.AddDeadLetter(ctx, &models.DeadLetter{
+			Notification: &models.Notification{
+				ID:        fmt.Sprintf("dead-%d", i),
+				UserID:    "user1",
+				PostID:    "post1",
+				Content:   "user2 posted",
+				CreatedAt: base,
+				Status:    models.NotificationStatusDeadLettered,
+			},
+			Reason:   "gave up after 1 attempts",
+			Attempts: []models.DeliveryAttempt{{Attempt: 1, At: base, Error: "timeout"}},
+			FailedAt: base.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+
+	// Page through them, oldest first
+	page, err := notificationService.ListDeadLetters(ctx, &notificationProto.ListDeadLettersRequest{PageSize: 2})
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), page.TotalCount)
+	require.Len(t, page.DeadLetters, 2)
+	assert.Equal(t, "dead-0", page.DeadLetters[0].Notification.Id)
+	assert.NotEmpty(t, page.NextCursor)
+
+	page, err = notificationService.ListDeadLetters(ctx, &notificationProto.ListDeadLettersRequest{
+		PageSize: 2,
+		Cursor:   page.NextCursor,
+	})
+	require.NoError(t, err)
+	require.Len(t, page.DeadLetters, 1)
+	assert.Equal(t, "dead-2", page.DeadLetters[0].Notification.Id)
+	assert.Empty(t, page.NextCursor)
+
+	_, err = notificationService.ListDeadLetters(ctx, &notificationProto.ListDeadLettersRequest{Cursor: "!"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// Inspect one
+	deadLetter, err := notificationService.GetDeadLetter(ctx, &notificationProto.NotificationId{NotificationId: "dead-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "user1", deadLetter.Notification.UserId)
+	assert.Equal(t, notificationProto.NotificationStatus_NOTIFICATION_STATUS_DEAD_LETTERED, deadLetter.Notification.Status)
+	assert.Zero(t, deadLetter.Notification.DeliveredAt)
+	assert.Equal(t, "gave up after 1 attempts", deadLetter.Reason)
+	assert.Equal(t, base.Add(time.Minute).Unix(), deadLetter.FailedAt)
+	require.Len(t, deadLetter.Attempts, 1)
+	assert.Equal(t, "timeout", deadLetter.Attempts[0].Error)
+
+	_, err = notificationService.GetDeadLetter(ctx, &notificationProto.NotificationId{NotificationId: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// Purge the oldest, replay one, then the rest
+	purged, err := notificationService.PurgeDeadLetters(ctx, &notificationProto.PurgeDeadLettersRequest{Before: base.Unix()})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), purged.Purged)
+
+	replayed, err := notificationService.ReplayDeadLetter(ctx, &notificationProto.NotificationId{NotificationId: "dead-1"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), replayed.Replayed)
+
+	_, err = notificationService.ReplayDeadLetter(ctx, &notificationProto.NotificationId{NotificationId: "dead-1"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	replayed, err = notificationService.ReplayDeadLetters(ctx, &emptypb.Empty{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), replayed.Replayed)
+
+	page, err = notificationService.ListDeadLetters(ctx, &notificationProto.ListDeadLettersRequest{})
+	require.NoError(t, err)
+	assert.Zero(t, page.TotalCount)
+
+	// The replayed notifications get delivered
+	assert.Eventually(t, func() bool {
+		delivered, err := store.ListNotifications(ctx, "user1", 0)
+		return err == nil && len(delivered) == 2
+	}, 10*time.Second, 20*time.Millisecond)
+}
+
+func TestGetNotificationJob(t *testing.T) {
+	store := storage.NewMemoryStore()
+	notificationQueue := queue.NewNotificationQueue(store, 1, 3)
+	notificationService := service.NewNotificationService(store, notificationQueue)
+
+	ctx := context.Background()
+	attemptedAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	dueAt := attemptedAt.Add(time.Hour)
+	require.NoError(t, store.SaveJob(ctx, &models.NotificationJob{
+		Notification: &models.Notification{
+			ID:        "queued",
+			UserID:    "user1",
+			CreatedAt: attemptedAt,
+			Status:    models.NotificationStatusFailed,
+		},
+		Attempt: 2,
+		DueAt:   dueAt,
+		Attempts: []models.DeliveryAttempt{
+			{Attempt: 1, Channel: models.ChannelInApp, At: attemptedAt},
+			{Attempt: 1, Channel: models.ChannelWebhook, At: attemptedAt, Error: "timeout"},
+		},
+	}))
+
+	job, err := notificationService.GetNotificationJob(ctx, &notificationProto.NotificationId{NotificationId: "queued"})
+	require.NoError(t, err)
+	assert.Equal(t, "user1", job.Notification.UserId)
+	assert.Equal(t, notificationProto.NotificationStatus_NOTIFICATION_STATUS_FAILED, job.Notification.Status)
+	assert.Equal(t, int32(2), job.Attempt)
+	assert.Equal(t, dueAt.Unix(), job.DueAt)
+	require.Len(t, job.Attempts, 2)
+	assert.Empty(t, job.Attempts[0].Error)
+	assert.Equal(t, "in_app", job.Attempts[0].Channel)
+	assert.Equal(t, "timeout", job.Attempts[1].Error)
+	assert.Equal(t, attemptedAt.Unix(), job.Attempts[1].AttemptedAt)
+
+	// Delivered and dead-lettered notifications have left the queue
+	_, err = notificationService.GetNotificationJob(ctx, &notificationProto.NotificationId{NotificationId: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestNotificationPreferences(t *testing.T) {
+	store := storage.NewMemoryStore()
+	notificationQueue := queue.NewNotificationQueue(store, 1, 1, queue.WithDeliverers(delivery.NewMock(models.ChannelEmail)))
+	notificationService := service.NewNotificationService(store, notificationQueue)
+
+	ctx := context.Background()
+	require.NoError(t, store.CreateUser(ctx, &models.User{ID: "user1", Username: "user1"}))
+
+	// Users start out with the defaults
+	preferences, err := notificationService.GetPreferences(ctx, &notificationProto.UserId{UserId: "user1"})
+	require.NoError(t, err)
+	assert.Equal(t, "user1", preferences.UserId)
+	assert.Empty(t, preferences.Channels)
+	assert.Empty(t, preferences.MutedEventTypes)
+	assert.Empty(t, preferences.MutedAuthors)
+	assert.Nil(t, preferences.QuietHours)
+	assert.Zero(t, preferences.UpdatedAt)
+
+	// Duplicates are dropped and the time zone defaults to UTC
+	updated, err := notificationService.UpdatePreferences(ctx, &notificationProto.NotificationPreferences{
+		UserId:          "user1",
+		Channels:        []string{"email", "in_app", "email"},
+		MutedEventTypes: []string{"new_post"},
+		MutedAuthors:    []string{"user2", "user2"},
+		QuietHours:      &notificationProto.QuietHours{Start: "22:30", End: "07:00"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"email", "in_app"}, updated.Channels)
+	assert.Equal(t, []string{"user2"}, updated.MutedAuthors)
+	assert.Equal(t, "UTC", updated.QuietHours.TimeZone)
+	assert.NotZero(t, updated.UpdatedAt)
+
+	preferences, err = notificationService.GetPreferences(ctx, &notificationProto.UserId{UserId: "user1"})
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(updated, preferences))
+
+	// Updating replaces everything
+	updated, err = notificationService.UpdatePreferences(ctx, &notificationProto.NotificationPreferences{
+		UserId:     "user1",
+		QuietHours: &notificationProto.QuietHours{Start: "13:00", End: "14:00", TimeZone: "America/New_York"},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, updated.Channels)
+	assert.Empty(t, updated.MutedAuthors)
+	assert.Equal(t, "America/New_York", updated.QuietHours.TimeZone)
+
+	for name, invalid := range map[string]*notificationProto.NotificationPreferences{
+		"unknown channel":    {Channels: []string{"sms"}},
+		"disabled channel":   {Channels: []string{"in_app", "webhook"}},
+		"unknown event type": {MutedEventTypes: []string{"like"}},
+		"empty author":       {MutedAuthors: []string{""}},
+		"bad start":          {QuietHours: &notificationProto.QuietHours{Start: "25:00", End: "07:00"}},
+		"bad end":            {QuietHours: &notificationProto.QuietHours{Start: "22:00", End: "7am"}},
+		"empty period":       {QuietHours: &notificationProto.QuietHours{Start: "22:00", End: "22:00"}},
+		"bad time zone":      {QuietHours: &notificationProto.QuietHours{Start: "22:00", End: "07:00", TimeZone: "Mars/Olympus"}},
+	} {
+		invalid.UserId = "user1"
+		_, err := notificationService.UpdatePreferences(ctx, invalid)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), name)
+	}
+
+	_, err = notificationService.GetPreferences(ctx, &notificationProto.UserId{UserId: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = notificationService.UpdatePreferences(ctx, &notificationProto.NotificationPreferences{UserId: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestWorkerPool(t *testing.T) {
+	store := storage.NewMemoryStore()
+	notificationQueue := queue.NewNotificationQueue(store, 2, 2, queue.WithAutoscaling(2, 8, time.Hour, time.Second))
+	notificationQueue.Start()
+	defer notificationQueue.Stop()
+	notificationService := service.NewNotificationService(store, notificationQueue)
+	ctx := context.Background()
+
+	pool, err := notificationService.GetWorkerPool(ctx, &emptypb.Empty{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), pool.Workers)
+	assert.Equal(t, int32(2), pool.MinWorkers)
+	assert.Equal(t, int32(8), pool.MaxWorkers)
+	assert.True(t, pool.Autoscaling)
+
+	// Pinning the size turns autoscaling off
+	pool, err = notificationService.SetWorkerPool(ctx, &notificationProto.SetWorkerPoolRequest{Workers: 5})
+	require.NoError(t, err)
+	assert.Equal(t, int32(5), pool.Workers)
+	assert.False(t, pool.Autoscaling)
+
+	_, err = notificationService.SetWorkerPool(ctx, &notificationProto.SetWorkerPoolRequest{Workers: 9})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = notificationService.SetWorkerPool(ctx, &notificationProto.SetWorkerPoolRequest{Workers: -1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	pool, err = notificationService.SetWorkerPool(ctx, &notificationProto.SetWorkerPoolRequest{Workers: 0})
+	require.NoError(t, err)
+	assert.Equal(t, int32(5), pool.Workers)
+	assert.True(t, pool.Autoscaling)
+}
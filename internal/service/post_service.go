@@ -4,74 +4,207 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"sync"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
+	"github.com/iwhitebird/social-app-microservices/internal/fanout"
 	"github.com/iwhitebird/social-app-microservices/internal/models"
-	"github.com/iwhitebird/social-app-microservices/internal/queue"
+	"github.com/iwhitebird/social-app-microservices/internal/storage"
 	postProto "github.com/iwhitebird/social-app-microservices/proto/generated/post/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// maxPostsPageSize caps how many posts ListPostsByUser returns at once
+	maxPostsPageSize = 100
+	// DefaultMaxContentLength is the default limit on the length of a post in characters
+	DefaultMaxContentLength = 1000
 )
 
 // PostService implements the gRPC post service
 type PostService struct {
 	postProto.UnimplementedPostServiceServer
-	store *models.Store
-	queue *queue.NotificationQueue
-	mu    sync.Mutex
+	users             storage.UserRepository
+	posts             storage.PostRepository
+	fanouts           storage.FanoutRepository
+	idempotencyKeys   storage.IdempotencyRepository
+	fanout            *fanout.Dispatcher
+	maxContentLength  int
+	idempotencyKeyTTL time.Duration
+}
+
+// PostServiceOption configures a PostService
+type PostServiceOption func(*PostService)
+
+// WithMaxContentLength limits the length of a post in characters
+func WithMaxContentLength(length int) PostServiceOption {
+	return func(s *PostService) {
+		s.maxContentLength = length
+	}
 }
 
 // NewPostService creates a new PostService
-func NewPostService(store *models.Store, queue *queue.NotificationQueue) *PostService {
-	return &PostService{
-		store: store,
-		queue: queue,
+func NewPostService(store storage.Store, dispatcher *fanout.Dispatcher, opts ...PostServiceOption) *PostService {
+	s := &PostService{
+		users:             store,
+		posts:             store,
+		fanouts:           store,
+		idempotencyKeys:   store,
+		fanout:            dispatcher,
+		maxContentLength:  DefaultMaxContentLength,
+		idempotencyKeyTTL: DefaultIdempotencyKeyTTL,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-// PublishPost handles a new post and creates notifications for followers
+// PublishPost stores a new post and starts a fan-out job that notifies the
+// author's followers. It returns without waiting for the job, see GetFanout.
+// A retry sent with the idempotency key of a request that went through gets
+// the original response, without publishing the post again.
 func (s *PostService) PublishPost(ctx context.Context, post *postProto.Post) (*postProto.NotificationResponse, error) {
 	log.Printf("Received PublishPost request for user %s", post.UserId)
 
+	if err := s.validateContent(post.Content); err != nil {
+		return nil, err
+	}
+	if _, err := s.users.GetUser(ctx, post.UserId); err != nil {
+		return nil, storageError(err, "user "+post.UserId)
+	}
+
+	if post.IdempotencyKey == "" {
+		return s.publish(ctx, post)
+	}
+	key, replayed, err := s.claimIdempotencyKey(ctx, post)
+	if err != nil {
+		return nil, err
+	}
+	if replayed != nil {
+		log.Printf("Replaying the response for idempotency key %q of user %s", post.IdempotencyKey, post.UserId)
+		return replayed, nil
+	}
+	response, err := s.publish(ctx, post)
+	s.settleIdempotencyKey(ctx, key, response, err)
+	return response, err
+}
+
+// publish stores a validated post and submits its fan-out job
+func (s *PostService) publish(ctx context.Context, post *postProto.Post) (*postProto.NotificationResponse, error) {
 	// Convert proto post to internal post
 	internalPost := &models.Post{
-		ID:      uuid.New().String(),
-		UserID:  post.UserId,
-		Content: post.Content,
+		ID:        uuid.New().String(),
+		UserID:    post.UserId,
+		Content:   post.Content,
+		CreatedAt: time.Now(),
+	}
+
+	// Store the post and notify the followers in the background, large
+	// audiences take a while. The post is only stored along with its job,
+	// so a failed request can be retried without publishing it twice.
+	job, err := s.fanout.Submit(ctx, internalPost)
+	if err != nil {
+		return nil, storageError(err, "post "+internalPost.ID)
+	}
+	log.Printf("Notifying %d followers of user %s in fan-out %s", job.TotalFollowers, post.UserId, job.ID)
+
+	return &postProto.NotificationResponse{
+		Success:   true,
+		Message:   fmt.Sprintf("Post published, notifying %d followers", job.TotalFollowers),
+		PostId:    internalPost.ID,
+		FanoutId:  job.ID,
+		Followers: int32(job.TotalFollowers),
+	}, nil
+}
+
+// validateContent checks that a post is non-empty UTF-8 text within the length limit
+func (s *PostService) validateContent(content string) error {
+	if !utf8.ValidString(content) {
+		return status.Error(codes.InvalidArgument, "content must be valid UTF-8")
+	}
+	if strings.TrimSpace(content) == "" {
+		return status.Error(codes.InvalidArgument, "content must not be empty")
 	}
+	if length := utf8.RuneCountInString(content); length > s.maxContentLength {
+		return status.Errorf(codes.InvalidArgument, "content is %d characters long, the limit is %d", length, s.maxContentLength)
+	}
+	return nil
+}
 
-	// Store the post
-	s.mu.Lock()
-	s.store.Posts[internalPost.UserID] = internalPost
-	s.mu.Unlock()
+// GetPost returns a single post by its ID
+func (s *PostService) GetPost(ctx context.Context, in *postProto.PostId) (*postProto.Post, error) {
+	post, err := s.posts.GetPost(ctx, in.Id)
+	if err != nil {
+		return nil, storageError(err, "post "+in.Id)
+	}
+	return toProtoPost(post), nil
+}
 
-	// Get followers of the post author
-	var followers []string
-	for _, val := range s.store.Users {
-		if val.ID == post.UserId {
-			followers = val.Followers
-		}
+// ListPostsByUser returns the posts of an author, newest first
+func (s *PostService) ListPostsByUser(ctx context.Context, in *postProto.ListPostsRequest) (*postProto.PostList, error) {
+	limit := int(in.Limit)
+	if limit <= 0 || limit > maxPostsPageSize {
+		limit = maxPostsPageSize
 	}
 
-	log.Printf("Creating notifications for %d followers of user %s", len(followers), post.UserId)
-	// Create and queue notifications for each follower
-	for _, followerID := range followers {
-		notification := &models.Notification{
-			ID:        uuid.New().String(),
-			UserID:    followerID,
-			PostID:    internalPost.ID,
-			Content:   fmt.Sprintf("%s posted: %s", post.UserId, post.Content),
-			Read:      false,
-			CreatedAt: time.Now(),
-		}
+	posts, err := s.posts.ListPostsByUser(ctx, in.UserId, limit)
+	if err != nil {
+		return nil, storageError(err, "posts of user "+in.UserId)
+	}
 
-		// Queue the notification for delivery
-		s.queue.EnqueueNotification(notification)
+	response := &postProto.PostList{Posts: make([]*postProto.Post, 0, len(posts))}
+	for _, post := range posts {
+		response.Posts = append(response.Posts, toProtoPost(post))
 	}
+	return response, nil
+}
 
-	return &postProto.NotificationResponse{
-		Success:             true,
-		Message:             fmt.Sprintf("Post published, %d notifications queued", len(followers)),
-		NotificationsQueued: int32(len(followers)),
-	}, nil
+// GetFanout returns the progress of the fan-out job started by PublishPost
+func (s *PostService) GetFanout(ctx context.Context, in *postProto.FanoutId) (*postProto.FanoutJob, error) {
+	job, err := s.fanouts.GetFanout(ctx, in.Id)
+	if err != nil {
+		return nil, storageError(err, "fan-out "+in.Id)
+	}
+	return toProtoFanout(job), nil
+}
+
+func toProtoPost(post *models.Post) *postProto.Post {
+	return &postProto.Post{
+		Id:        post.ID,
+		UserId:    post.UserID,
+		Content:   post.Content,
+		CreatedAt: post.CreatedAt.Unix(),
+	}
+}
+
+var protoFanoutStatuses = map[models.FanoutStatus]postProto.FanoutStatus{
+	models.FanoutStatusPending:   postProto.FanoutStatus_FANOUT_STATUS_PENDING,
+	models.FanoutStatusRunning:   postProto.FanoutStatus_FANOUT_STATUS_RUNNING,
+	models.FanoutStatusCompleted: postProto.FanoutStatus_FANOUT_STATUS_COMPLETED,
+	models.FanoutStatusFailed:    postProto.FanoutStatus_FANOUT_STATUS_FAILED,
+}
+
+func toProtoFanout(job *models.FanoutJob) *postProto.FanoutJob {
+	j := &postProto.FanoutJob{
+		Id:             job.ID,
+		PostId:         job.PostID,
+		AuthorId:       job.AuthorID,
+		Status:         protoFanoutStatuses[job.Status],
+		TotalFollowers: int32(job.TotalFollowers),
+		Processed:      int32(job.Processed),
+		Queued:         int32(job.Queued),
+		Skipped:        int32(job.Skipped),
+		TurnedAway:     int32(job.TurnedAway),
+		Error:          job.Error,
+		CreatedAt:      job.CreatedAt.Unix(),
+		UpdatedAt:      job.UpdatedAt.Unix(),
+	}
+	if job.CompletedAt != nil {
+		j.CompletedAt = job.CompletedAt.Unix()
+	}
+	return j
 }
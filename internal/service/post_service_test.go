@@ -5,12 +5,20 @@ import (
 	"testing"
 	"time"
 
+	"github.com/iwhitebird/social-app-microservices/internal/delivery"
+	"github.com/iwhitebird/social-app-microservices/internal/fanout"
 	"github.com/iwhitebird/social-app-microservices/internal/models"
 	"github.com/iwhitebird/social-app-microservices/internal/queue"
 	"github.com/iwhitebird/social-app-microservices/internal/service"
+	"github.com/iwhitebird/social-app-microservices/internal/storage"
+	notificationProto "github.com/iwhitebird/social-app-microservices/proto/generated/notification/proto"
 	postProto "github.com/iwhitebird/social-app-microservices/proto/generated/post/proto"
+	userProto "github.com/iwhitebird/social-app-microservices/proto/generated/user/proto"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 // MockPostStream implements the grpc.ServerStream interface for testing
@@ -57,23 +65,42 @@ func (s *MockPostStream) GetReceivedMsgs() []*postProto.Post {
 	return s.ReceivedMsgs
 }
 
+// waitForFanout waits until the fan-out job started by a post has notified
+// all followers and returns the finished job
+func waitForFanout(t *testing.T, postService *service.PostService, resp *postProto.NotificationResponse) *postProto.FanoutJob {
+	t.Helper()
+	var job *postProto.FanoutJob
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = postService.GetFanout(context.Background(), &postProto.FanoutId{Id: resp.FanoutId})
+		require.NoError(t, err)
+		return job.Status == postProto.FanoutStatus_FANOUT_STATUS_COMPLETED
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
 func TestPublishPost(t *testing.T) {
 	// Create real store
-	store := models.NewStore()
+	store := storage.NewMemoryStore()
 
 	// Create real queue
 	notificationQueue := queue.NewNotificationQueue(store, 3, 2)
 	notificationQueue.Start()
 	defer notificationQueue.Stop()
 
+	// Create real fan-out dispatcher
+	dispatcher := fanout.NewDispatcher(store, notificationQueue)
+	dispatcher.Start()
+	defer dispatcher.Stop()
+
 	// Create post service
-	postService := service.NewPostService(store, notificationQueue)
+	postService := service.NewPostService(store, dispatcher)
 
 	// Add test users with followers
-	store.Users["user1"] = &models.User{
-		ID:        "user1",
-		Username:  "user1",
-		Followers: []string{"follower1", "follower2", "follower3"},
+	store.CreateUser(context.Background(), &models.User{ID: "user1", Username: "user1"})
+	store.CreateUser(context.Background(), &models.User{ID: "user2", Username: "user2"})
+	for _, followerID := range []string{"follower1", "follower2", "follower3"} {
+		store.Follow(context.Background(), followerID, "user1")
 	}
 
 	// Test cases
@@ -116,29 +143,40 @@ func TestPublishPost(t *testing.T) {
 
 			// Assert response fields
 			assert.Equal(t, tt.expectedSuccess, resp.Success)
-			assert.Equal(t, tt.expectedNotifications, resp.NotificationsQueued)
+			assert.Equal(t, tt.expectedNotifications, resp.Followers)
+			assert.NotEmpty(t, resp.FanoutId)
+
+			// The followers are notified in the background
+			job := waitForFanout(t, postService, resp)
+			assert.Equal(t, resp.PostId, job.PostId)
+			assert.Equal(t, tt.userID, job.AuthorId)
+			assert.Equal(t, tt.expectedNotifications, job.TotalFollowers)
+			assert.Equal(t, tt.expectedNotifications, job.Processed)
+			assert.Equal(t, tt.expectedNotifications, job.Queued)
+			assert.NotZero(t, job.CompletedAt)
 
 			// Check if post was stored
-			storedPost, exists := store.Posts[tt.userID]
-			assert.True(t, exists)
+			storedPost, err := store.GetPost(context.Background(), resp.PostId)
+			assert.NoError(t, err)
 			assert.Equal(t, tt.content, storedPost.Content)
 			assert.Equal(t, tt.userID, storedPost.UserID)
 
 			// Check if notifications were enqueued
 			if tt.expectedNotifications > 0 {
+				followers, err := store.ListFollowers(context.Background(), tt.userID, "", 0)
+				assert.NoError(t, err)
+
 				// Allow some time for notifications to be processed
 				// because we're using the real queue now
 				for attempt := 0; attempt < 5; attempt++ {
-					store.Mu.Lock()
 					allNotificationsDelivered := true
-					for _, followerID := range store.Users[tt.userID].Followers {
-						notifications, exists := store.Notifications[followerID]
-						if !exists || len(notifications) == 0 {
+					for _, followerID := range followers {
+						notifications, _ := store.ListNotifications(context.Background(), followerID, 0)
+						if len(notifications) == 0 {
 							allNotificationsDelivered = false
 							break
 						}
 					}
-					store.Mu.Unlock()
 
 					if allNotificationsDelivered {
 						break
@@ -151,13 +189,12 @@ func TestPublishPost(t *testing.T) {
 					time.Sleep(1 * time.Second)
 				}
 
-				for _, followerID := range store.Users[tt.userID].Followers {
+				for _, followerID := range followers {
 					// Check if notifications were created for followers
-					store.Mu.Lock()
-					notifications, exists := store.Notifications[followerID]
-					store.Mu.Unlock()
+					notifications, err := store.ListNotifications(context.Background(), followerID, 0)
+					assert.NoError(t, err)
 
-					assert.True(t, exists, "Notifications should exist for follower %s", followerID)
+					assert.True(t, len(notifications) > 0, "Notifications should exist for follower %s", followerID)
 					assert.NotEmpty(t, notifications, "At least one notification should be created for follower %s", followerID)
 
 					// Verify notification content for at least one notification
@@ -174,66 +211,84 @@ func TestPublishPost(t *testing.T) {
 			}
 		})
 	}
+
+	// Unknown fan-out jobs are reported as NotFound
+	_, err := postService.GetFanout(context.Background(), &postProto.FanoutId{Id: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
 }
 
-func TestPublishPostWithEmptyContent(t *testing.T) {
+func TestPublishPostWithInvalidContent(t *testing.T) {
 	// Create real store
-	store := models.NewStore()
+	store := storage.NewMemoryStore()
 
 	// Create real queue
 	notificationQueue := queue.NewNotificationQueue(store, 3, 2)
 	notificationQueue.Start()
 	defer notificationQueue.Stop()
 
-	// Create post service
-	postService := service.NewPostService(store, notificationQueue)
+	// Create real fan-out dispatcher
+	dispatcher := fanout.NewDispatcher(store, notificationQueue)
+	dispatcher.Start()
+	defer dispatcher.Stop()
+
+	// Create post service with a small length limit
+	postService := service.NewPostService(store, dispatcher, service.WithMaxContentLength(10))
 
 	// Add test users with followers
-	store.Users["user1"] = &models.User{
-		ID:        "user1",
-		Username:  "user1",
-		Followers: []string{"follower1", "follower2"},
-	}
+	store.CreateUser(context.Background(), &models.User{ID: "user1", Username: "user1"})
+	store.Follow(context.Background(), "follower1", "user1")
 
-	// Create post with empty content
-	post := &postProto.Post{
-		UserId:  "user1",
-		Content: "",
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "empty content", content: ""},
+		{name: "whitespace only", content: " \t\n"},
+		{name: "too long", content: "this post is too long"},
+		{name: "invalid UTF-8", content: "bad \xff"},
 	}
 
-	// Call PublishPost
-	resp, err := postService.PublishPost(context.Background(), post)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := postService.PublishPost(context.Background(), &postProto.Post{
+				UserId:  "user1",
+				Content: tt.content,
+			})
+			assert.Nil(t, resp)
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		})
+	}
 
-	// Assert response
-	assert.NoError(t, err)
+	// The limit counts characters, not bytes
+	resp, err := postService.PublishPost(context.Background(), &postProto.Post{
+		UserId:  "user1",
+		Content: "ünïcödé ✓",
+	})
+	require.NoError(t, err)
 	assert.True(t, resp.Success)
-	assert.Equal(t, int32(2), resp.NotificationsQueued)
 
-	// Allow some time for processing
-	time.Sleep(1 * time.Second)
-
-	// Check if notifications were created with appropriate content
-	for _, followerID := range []string{"follower1", "follower2"} {
-		store.Mu.Lock()
-		notifications, exists := store.Notifications[followerID]
-		store.Mu.Unlock()
-
-		assert.True(t, exists, "Notifications should exist for follower %s", followerID)
-		assert.NotEmpty(t, notifications, "At least one notification should be created for follower %s", followerID)
-	}
+	// Only the valid post was stored
+	posts, err := store.ListPostsByUser(context.Background(), "user1", 0)
+	require.NoError(t, err)
+	assert.Len(t, posts, 1)
 }
 
 func TestPostServiceWithNonExistentUser(t *testing.T) {
 	// Create real store
-	store := models.NewStore()
+	store := storage.NewMemoryStore()
 
 	// Create real queue
 	notificationQueue := queue.NewNotificationQueue(store, 3, 2)
 	notificationQueue.Start()
 	defer notificationQueue.Stop()
 
+	// Create real fan-out dispatcher
+	dispatcher := fanout.NewDispatcher(store, notificationQueue)
+	dispatcher.Start()
+	defer dispatcher.Stop()
+
 	// Create post service
-	postService := service.NewPostService(store, notificationQueue)
+	postService := service.NewPostService(store, dispatcher)
 
 	// Create post from non-existent user
 	post := &postProto.Post{
@@ -244,13 +299,252 @@ func TestPostServiceWithNonExistentUser(t *testing.T) {
 	// Call PublishPost
 	resp, err := postService.PublishPost(context.Background(), post)
 
-	// Assert response
+	// Assert the post is rejected
+	assert.Nil(t, resp)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// Verify nothing was stored
+	posts, err := store.ListPostsByUser(context.Background(), "nonexistent", 0)
 	assert.NoError(t, err)
-	assert.True(t, resp.Success)
-	assert.Equal(t, int32(0), resp.NotificationsQueued) // No followers = no notifications
+	assert.Empty(t, posts)
+}
+
+func TestPublishPostWhileQueueIsFull(t *testing.T) {
+	store := storage.NewMemoryStore()
+
+	// A single worker stuck on its first notification and room for one more
+	gate := make(chan struct{})
+	stuck := delivery.NewMock(models.ChannelInApp, delivery.WithFailFunc(func(*models.Notification) bool {
+		<-gate
+		return false
+	}))
+	notificationQueue := queue.NewNotificationQueue(store, 1, 1,
+		queue.WithDeliverers(stuck),
+		queue.WithOverflow(queue.OverflowReject, 1, 0))
+	notificationQueue.Start()
+	defer notificationQueue.Stop()
+
+	dispatcher := fanout.NewDispatcher(store, notificationQueue)
+	dispatcher.Start()
+	defer dispatcher.Stop()
+
+	postService := service.NewPostService(store, dispatcher)
+
+	store.CreateUser(context.Background(), &models.User{ID: "user1", Username: "user1"})
+	for _, followerID := range []string{"follower1", "follower2", "follower3"} {
+		store.Follow(context.Background(), followerID, "user1")
+	}
+
+	require.NoError(t, notificationQueue.EnqueueNotification(context.Background(), &models.Notification{ID: "stuck", UserID: "user1"}))
+	require.Eventually(t, func() bool { return notificationQueue.Pool().Busy == 1 }, time.Second, time.Millisecond)
+
+	// The fan-out reports the notifications the queue accepted and those it
+	// turned away
+	resp, err := postService.PublishPost(context.Background(), &postProto.Post{UserId: "user1", Content: "first"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), resp.Followers)
+	var job *postProto.FanoutJob
+	require.Eventually(t, func() bool {
+		job, err = postService.GetFanout(context.Background(), &postProto.FanoutId{Id: resp.FanoutId})
+		require.NoError(t, err)
+		return job.TurnedAway > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(1), job.Queued)
+
+	// Posts are still published while the queue is full
+	_, err = postService.PublishPost(context.Background(), &postProto.Post{UserId: "user1", Content: "second"})
+	require.NoError(t, err)
+	posts, err := store.ListPostsByUser(context.Background(), "user1", 0)
+	require.NoError(t, err)
+	assert.Len(t, posts, 2)
+
+	// The job catches up once the queue drains
+	close(gate)
+	job = waitForFanout(t, postService, resp)
+	assert.Equal(t, int32(3), job.Queued)
+}
+
+func TestPostHistory(t *testing.T) {
+	// Create real store
+	store := storage.NewMemoryStore()
+
+	// Create real queue
+	notificationQueue := queue.NewNotificationQueue(store, 3, 2)
+	notificationQueue.Start()
+	defer notificationQueue.Stop()
+
+	// Create real fan-out dispatcher
+	dispatcher := fanout.NewDispatcher(store, notificationQueue)
+	dispatcher.Start()
+	defer dispatcher.Stop()
+
+	// Create post service
+	postService := service.NewPostService(store, dispatcher)
+
+	store.CreateUser(context.Background(), &models.User{ID: "user1", Username: "user1"})
+
+	// Publish several posts from the same author
+	var postIDs []string
+	for _, content := range []string{"first", "second", "third"} {
+		resp, err := postService.PublishPost(context.Background(), &postProto.Post{
+			UserId:  "user1",
+			Content: content,
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, resp.PostId)
+		postIDs = append(postIDs, resp.PostId)
+	}
+
+	// Every post is kept and can be fetched by its ID
+	for i, postID := range postIDs {
+		post, err := postService.GetPost(context.Background(), &postProto.PostId{Id: postID})
+		require.NoError(t, err)
+		assert.Equal(t, postID, post.Id)
+		assert.Equal(t, "user1", post.UserId)
+		assert.Equal(t, []string{"first", "second", "third"}[i], post.Content)
+		assert.NotZero(t, post.CreatedAt)
+	}
+
+	// Posts are listed newest first
+	list, err := postService.ListPostsByUser(context.Background(), &postProto.ListPostsRequest{UserId: "user1"})
+	require.NoError(t, err)
+	require.Len(t, list.Posts, 3)
+	assert.Equal(t, postIDs[2], list.Posts[0].Id)
+	assert.Equal(t, postIDs[1], list.Posts[1].Id)
+	assert.Equal(t, postIDs[0], list.Posts[2].Id)
+
+	// A limit returns only the newest posts
+	list, err = postService.ListPostsByUser(context.Background(), &postProto.ListPostsRequest{UserId: "user1", Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, list.Posts, 1)
+	assert.Equal(t, postIDs[2], list.Posts[0].Id)
+
+	// Unknown posts are reported as NotFound
+	_, err = postService.GetPost(context.Background(), &postProto.PostId{Id: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestFollowChangesNotifiedUsers(t *testing.T) {
+	// Create real store
+	store := storage.NewMemoryStore()
+
+	// Create real queue
+	notificationQueue := queue.NewNotificationQueue(store, 3, 2)
+	notificationQueue.Start()
+	defer notificationQueue.Stop()
+
+	// Create real fan-out dispatcher
+	dispatcher := fanout.NewDispatcher(store, notificationQueue)
+	dispatcher.Start()
+	defer dispatcher.Stop()
+
+	// Create services
+	postService := service.NewPostService(store, dispatcher)
+	userService := service.NewUserService(store)
 
-	// Verify post was still stored
-	storedPost, exists := store.Posts["nonexistent"]
-	assert.True(t, exists)
-	assert.Equal(t, post.Content, storedPost.Content)
+	ctx := context.Background()
+	for _, id := range []string{"author", "reader1", "reader2"} {
+		require.NoError(t, store.CreateUser(ctx, &models.User{ID: id, Username: id}))
+	}
+
+	publish := func() int32 {
+		resp, err := postService.PublishPost(ctx, &postProto.Post{UserId: "author", Content: "hello"})
+		require.NoError(t, err)
+		return waitForFanout(t, postService, resp).Queued
+	}
+
+	assert.Equal(t, int32(0), publish())
+
+	_, err := userService.Follow(ctx, &userProto.FollowRequest{FollowerId: "reader1", FolloweeId: "author"})
+	require.NoError(t, err)
+	_, err = userService.Follow(ctx, &userProto.FollowRequest{FollowerId: "reader2", FolloweeId: "author"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), publish())
+
+	_, err = userService.Unfollow(ctx, &userProto.FollowRequest{FollowerId: "reader1", FolloweeId: "author"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), publish())
+}
+
+func TestPublishPostRespectsPreferences(t *testing.T) {
+	store := storage.NewMemoryStore()
+	webhook := delivery.NewMock(models.ChannelWebhook)
+	email := delivery.NewMock(models.ChannelEmail)
+	notificationQueue := queue.NewNotificationQueue(store, 3, 2, queue.WithDeliverers(webhook, email))
+	notificationQueue.Start()
+	defer notificationQueue.Stop()
+
+	// Create real fan-out dispatcher
+	dispatcher := fanout.NewDispatcher(store, notificationQueue)
+	dispatcher.Start()
+	defer dispatcher.Stop()
+
+	postService := service.NewPostService(store, dispatcher)
+	notificationService := service.NewNotificationService(store, notificationQueue)
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	quietNow := &notificationProto.QuietHours{
+		Start: now.Add(-time.Hour).Format("15:04"),
+		End:   now.Add(time.Hour).Format("15:04"),
+	}
+	quietLater := &notificationProto.QuietHours{
+		Start: now.Add(2 * time.Hour).Format("15:04"),
+		End:   now.Add(3 * time.Hour).Format("15:04"),
+	}
+	preferences := map[string]*notificationProto.NotificationPreferences{
+		"defaults":      nil,
+		"muted-author":  {MutedAuthors: []string{"author"}},
+		"muted-event":   {MutedEventTypes: []string{"new_post"}},
+		"email-quiet":   {Channels: []string{"email"}, QuietHours: quietNow},
+		"webhook-quiet": {Channels: []string{"in_app", "webhook"}, QuietHours: quietNow},
+		"webhook-awake": {Channels: []string{"in_app", "webhook"}, QuietHours: quietLater},
+	}
+
+	require.NoError(t, store.CreateUser(ctx, &models.User{ID: "author", Username: "author"}))
+	for id, p := range preferences {
+		require.NoError(t, store.CreateUser(ctx, &models.User{ID: id, Username: id}))
+		require.NoError(t, store.Follow(ctx, id, "author"))
+		if p != nil {
+			p.UserId = id
+			_, err := notificationService.UpdatePreferences(ctx, p)
+			require.NoError(t, err)
+		}
+	}
+
+	resp, err := postService.PublishPost(ctx, &postProto.Post{UserId: "author", Content: "hello"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(6), resp.Followers)
+	job := waitForFanout(t, postService, resp)
+	assert.Equal(t, int32(3), job.Queued)
+	assert.Equal(t, int32(3), job.Skipped)
+
+	// Only the followers that want the notification get it, through the
+	// channels they picked, and during quiet hours only in their inbox
+	deliveredChannels := func(userID string) []models.Channel {
+		inbox, err := store.ListNotifications(ctx, userID, 0)
+		require.NoError(t, err)
+		if len(inbox) == 0 {
+			return nil
+		}
+		var channels []models.Channel
+		for _, delivery := range inbox[0].Deliveries {
+			channels = append(channels, delivery.Channel)
+		}
+		return channels
+	}
+	require.Eventually(t, func() bool {
+		return len(deliveredChannels("webhook-awake")) == 2 &&
+			len(deliveredChannels("webhook-quiet")) == 1 &&
+			len(deliveredChannels("defaults")) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []models.Channel{models.ChannelInApp, models.ChannelWebhook}, deliveredChannels("webhook-awake"))
+	assert.Equal(t, []models.Channel{models.ChannelInApp}, deliveredChannels("webhook-quiet"))
+	assert.Equal(t, []models.Channel{models.ChannelInApp}, deliveredChannels("defaults"))
+	for _, id := range []string{"muted-author", "muted-event", "email-quiet"} {
+		assert.Empty(t, deliveredChannels(id), id)
+	}
+	require.Len(t, webhook.Delivered(), 1)
+	assert.Equal(t, "webhook-awake", webhook.Delivered()[0].UserID)
+	assert.Empty(t, email.Delivered())
 }
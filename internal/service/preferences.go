@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+	"github.com/iwhitebird/social-app-microservices/internal/storage"
+	notificationProto "github.com/iwhitebird/social-app-microservices/proto/generated/notification/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxMutedAuthors caps how many authors a user can mute
+const maxMutedAuthors = 1000
+
+var (
+	knownChannels   = []models.Channel{models.ChannelInApp, models.ChannelWebhook, models.ChannelEmail, models.ChannelMock}
+	knownEventTypes = []models.EventType{models.EventTypeNewPost}
+)
+
+// GetPreferences returns the notification preferences of a user, the
+// defaults if they never saved any
+func (s *NotificationService) GetPreferences(ctx context.Context, in *notificationProto.UserId) (*notificationProto.NotificationPreferences, error) {
+	if _, err := s.users.GetUser(ctx, in.UserId); err != nil {
+		return nil, storageError(err, "user "+in.UserId)
+	}
+
+	preferences, err := s.preferences.GetPreferences(ctx, in.UserId)
+	if errors.Is(err, storage.ErrNotFound) {
+		preferences = &models.NotificationPreferences{UserID: in.UserId}
+	} else if err != nil {
+		return nil, storageError(err, "preferences of user "+in.UserId)
+	}
+	return toProtoPreferences(preferences), nil
+}
+
+// UpdatePreferences replaces the notification preferences of a user
+func (s *NotificationService) UpdatePreferences(ctx context.Context, in *notificationProto.NotificationPreferences) (*notificationProto.NotificationPreferences, error) {
+	log.Printf("Received UpdatePreferences request for user %s", in.UserId)
+
+	preferences, err := preferencesFromProto(in, s.queue.HasChannel)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.users.GetUser(ctx, in.UserId); err != nil {
+		return nil, storageError(err, "user "+in.UserId)
+	}
+
+	preferences.UpdatedAt = time.Now()
+	if err := s.preferences.SavePreferences(ctx, preferences); err != nil {
+		return nil, storageError(err, "preferences of user "+in.UserId)
+	}
+	return toProtoPreferences(preferences), nil
+}
+
+// preferencesFromProto validates preferences sent by a client. Channels
+// must be enabled, otherwise every notification through them would end up
+// dead-lettered. Duplicates in the lists are dropped.
+func preferencesFromProto(in *notificationProto.NotificationPreferences, enabled func(models.Channel) bool) (*models.NotificationPreferences, error) {
+	preferences := &models.NotificationPreferences{UserID: in.UserId}
+
+	for _, channel := range in.Channels {
+		if !slices.Contains(knownChannels, models.Channel(channel)) {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown channel %q, expected in_app, webhook, email or mock", channel)
+		}
+		if !enabled(models.Channel(channel)) {
+			return nil, status.Errorf(codes.InvalidArgument, "channel %q is not enabled on this server", channel)
+		}
+		if !slices.Contains(preferences.Channels, models.Channel(channel)) {
+			preferences.Channels = append(preferences.Channels, models.Channel(channel))
+		}
+	}
+	for _, eventType := range in.MutedEventTypes {
+		if !slices.Contains(knownEventTypes, models.EventType(eventType)) {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown event type %q, expected new_post", eventType)
+		}
+		if !slices.Contains(preferences.MutedEventTypes, models.EventType(eventType)) {
+			preferences.MutedEventTypes = append(preferences.MutedEventTypes, models.EventType(eventType))
+		}
+	}
+
+	for _, authorID := range in.MutedAuthors {
+		if authorID == "" {
+			return nil, status.Error(codes.InvalidArgument, "muted authors must not be empty")
+		}
+		if !slices.Contains(preferences.MutedAuthors, authorID) {
+			preferences.MutedAuthors = append(preferences.MutedAuthors, authorID)
+		}
+	}
+	if len(preferences.MutedAuthors) > maxMutedAuthors {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d authors can be muted", maxMutedAuthors)
+	}
+
+	if in.QuietHours != nil {
+		quietHours := &models.QuietHours{
+			Start:    in.QuietHours.Start,
+			End:      in.QuietHours.End,
+			TimeZone: in.QuietHours.TimeZone,
+		}
+		if quietHours.TimeZone == "" {
+			quietHours.TimeZone = "UTC"
+		}
+		if err := validateQuietHours(quietHours); err != nil {
+			return nil, err
+		}
+		preferences.QuietHours = quietHours
+	}
+	return preferences, nil
+}
+
+func validateQuietHours(quietHours *models.QuietHours) error {
+	start, err := time.Parse(models.TimeOfDayLayout, quietHours.Start)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "quiet hours start %q is not a time of day like 22:00", quietHours.Start)
+	}
+	end, err := time.Parse(models.TimeOfDayLayout, quietHours.End)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "quiet hours end %q is not a time of day like 07:00", quietHours.End)
+	}
+	if start.Equal(end) {
+		return status.Error(codes.InvalidArgument, "quiet hours must not start and end at the same time")
+	}
+	if _, err := time.LoadLocation(quietHours.TimeZone); err != nil {
+		return status.Errorf(codes.InvalidArgument, "unknown time zone %q", quietHours.TimeZone)
+	}
+	return nil
+}
+
+func toProtoPreferences(preferences *models.NotificationPreferences) *notificationProto.NotificationPreferences {
+	p := &notificationProto.NotificationPreferences{
+		UserId:          preferences.UserID,
+		Channels:        make([]string, 0, len(preferences.Channels)),
+		MutedEventTypes: make([]string, 0, len(preferences.MutedEventTypes)),
+		MutedAuthors:    slices.Clone(preferences.MutedAuthors),
+	}
+	for _, channel := range preferences.Channels {
+		p.Channels = append(p.Channels, string(channel))
+	}
+	for _, eventType := range preferences.MutedEventTypes {
+		p.MutedEventTypes = append(p.MutedEventTypes, string(eventType))
+	}
+	if preferences.QuietHours != nil {
+		p.QuietHours = &notificationProto.QuietHours{
+			Start:    preferences.QuietHours.Start,
+			End:      preferences.QuietHours.End,
+			TimeZone: preferences.QuietHours.TimeZone,
+		}
+	}
+	if !preferences.UpdatedAt.IsZero() {
+		p.UpdatedAt = preferences.UpdatedAt.Unix()
+	}
+	return p
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+	"github.com/iwhitebird/social-app-microservices/internal/storage"
+	userProto "github.com/iwhitebird/social-app-microservices/proto/generated/user/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_]{3,32}$`)
+
+// UserService implements the gRPC user service
+type UserService struct {
+	userProto.UnimplementedUserServiceServer
+	users   storage.UserRepository
+	follows storage.FollowRepository
+}
+
+// NewUserService creates a new UserService
+func NewUserService(store storage.Store) *UserService {
+	return &UserService{
+		users:   store,
+		follows: store,
+	}
+}
+
+// CreateUser registers a new user with a unique username
+func (s *UserService) CreateUser(ctx context.Context, in *userProto.CreateUserRequest) (*userProto.User, error) {
+	log.Printf("Received CreateUser request for username %s", in.Username)
+
+	if err := validateUsername(in.Username); err != nil {
+		return nil, err
+	}
+	if err := validateEmail(in.Email); err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		ID:        uuid.New().String(),
+		Username:  in.Username,
+		Email:     in.Email,
+		CreatedAt: time.Now(),
+	}
+	if err := s.users.CreateUser(ctx, user); err != nil {
+		return nil, storageError(err, "username "+in.Username)
+	}
+	return toProtoUser(user), nil
+}
+
+// GetUser returns a user by ID
+func (s *UserService) GetUser(ctx context.Context, in *userProto.UserId) (*userProto.User, error) {
+	user, err := s.users.GetUser(ctx, in.UserId)
+	if err != nil {
+		return nil, storageError(err, "user "+in.UserId)
+	}
+	return toProtoUser(user), nil
+}
+
+// GetUserByUsername returns a user by username, ignoring case
+func (s *UserService) GetUserByUsername(ctx context.Context, in *userProto.Username) (*userProto.User, error) {
+	user, err := s.users.GetUserByUsername(ctx, in.Username)
+	if err != nil {
+		return nil, storageError(err, "username "+in.Username)
+	}
+	return toProtoUser(user), nil
+}
+
+// UpdateUser changes the fields of a user that are set in the request
+func (s *UserService) UpdateUser(ctx context.Context, in *userProto.UpdateUserRequest) (*userProto.User, error) {
+	log.Printf("Received UpdateUser request for user %s", in.UserId)
+
+	user, err := s.users.GetUser(ctx, in.UserId)
+	if err != nil {
+		return nil, storageError(err, "user "+in.UserId)
+	}
+
+	if in.Username != nil {
+		if err := validateUsername(*in.Username); err != nil {
+			return nil, err
+		}
+		user.Username = *in.Username
+	}
+	if in.Email != nil {
+		if err := validateEmail(*in.Email); err != nil {
+			return nil, err
+		}
+		user.Email = *in.Email
+	}
+
+	if err := s.users.UpdateUser(ctx, user); err != nil {
+		subject := "user " + in.UserId
+		if errors.Is(err, storage.ErrAlreadyExists) {
+			subject = "username " + user.Username
+		}
+		return nil, storageError(err, subject)
+	}
+	return toProtoUser(user), nil
+}
+
+// DeleteUser removes a user
+func (s *UserService) DeleteUser(ctx context.Context, in *userProto.UserId) (*emptypb.Empty, error) {
+	log.Printf("Received DeleteUser request for user %s", in.UserId)
+
+	if err := s.users.DeleteUser(ctx, in.UserId); err != nil {
+		return nil, storageError(err, "user "+in.UserId)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// Follow makes one user follow another. Following someone twice is a no-op.
+func (s *UserService) Follow(ctx context.Context, in *userProto.FollowRequest) (*emptypb.Empty, error) {
+	log.Printf("Received Follow request from user %s to user %s", in.FollowerId, in.FolloweeId)
+
+	if err := s.checkFollowRequest(ctx, in); err != nil {
+		return nil, err
+	}
+	if err := s.follows.Follow(ctx, in.FollowerId, in.FolloweeId); err != nil {
+		return nil, storageError(err, "follow")
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// Unfollow removes a follow edge
+func (s *UserService) Unfollow(ctx context.Context, in *userProto.FollowRequest) (*emptypb.Empty, error) {
+	log.Printf("Received Unfollow request from user %s to user %s", in.FollowerId, in.FolloweeId)
+
+	if err := s.follows.Unfollow(ctx, in.FollowerId, in.FolloweeId); err != nil {
+		return nil, storageError(err, fmt.Sprintf("follow from user %s to user %s", in.FollowerId, in.FolloweeId))
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// ListFollowers returns a page of the users following a user
+func (s *UserService) ListFollowers(ctx context.Context, in *userProto.ListFollowsRequest) (*userProto.UserPage, error) {
+	return s.listFollows(ctx, in, s.follows.ListFollowers, s.follows.CountFollowers)
+}
+
+// ListFollowing returns a page of the users a user follows
+func (s *UserService) ListFollowing(ctx context.Context, in *userProto.ListFollowsRequest) (*userProto.UserPage, error) {
+	return s.listFollows(ctx, in, s.follows.ListFollowing, s.follows.CountFollowing)
+}
+
+func (s *UserService) checkFollowRequest(ctx context.Context, in *userProto.FollowRequest) error {
+	if in.FollowerId == in.FolloweeId {
+		return status.Error(codes.InvalidArgument, "users cannot follow themselves")
+	}
+	for _, id := range []string{in.FollowerId, in.FolloweeId} {
+		if _, err := s.users.GetUser(ctx, id); err != nil {
+			return storageError(err, "user "+id)
+		}
+	}
+	return nil
+}
+
+func (s *UserService) listFollows(
+	ctx context.Context,
+	in *userProto.ListFollowsRequest,
+	list func(ctx context.Context, userID, after string, limit int) ([]string, error),
+	count func(ctx context.Context, userID string) (int, error),
+) (*userProto.UserPage, error) {
+	after, err := decodeCursor(in.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	limit := pageSize(in.PageSize)
+
+	// Ask for one extra ID to find out whether there is another page
+	ids, err := list(ctx, in.UserId, after, limit+1)
+	if err != nil {
+		return nil, storageError(err, "follows of user "+in.UserId)
+	}
+	total, err := count(ctx, in.UserId)
+	if err != nil {
+		return nil, storageError(err, "follows of user "+in.UserId)
+	}
+
+	page := &userProto.UserPage{TotalCount: int32(total)}
+	if len(ids) > limit {
+		ids = ids[:limit]
+		page.NextCursor = encodeCursor(ids[len(ids)-1])
+	}
+
+	for _, id := range ids {
+		user, err := s.users.GetUser(ctx, id)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, storageError(err, "user "+id)
+		}
+		page.Users = append(page.Users, toProtoUser(user))
+	}
+	return page, nil
+}
+
+func validateUsername(username string) error {
+	if !usernamePattern.MatchString(username) {
+		return status.Error(codes.InvalidArgument, "username must be 3 to 32 letters, digits or underscores")
+	}
+	return nil
+}
+
+func validateEmail(email string) error {
+	if email == "" {
+		return nil
+	}
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return status.Errorf(codes.InvalidArgument, "invalid email address %q", email)
+	}
+	return nil
+}
+
+func toProtoUser(user *models.User) *userProto.User {
+	return &userProto.User{
+		Id:        user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		CreatedAt: user.CreatedAt.Unix(),
+	}
+}
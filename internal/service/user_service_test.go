@@ -0,0 +1,163 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/iwhitebird/social-app-microservices/internal/service"
+	"github.com/iwhitebird/social-app-microservices/internal/storage"
+	userProto "github.com/iwhitebird/social-app-microservices/proto/generated/user/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestUserLifecycle(t *testing.T) {
+	ctx := context.Background()
+	userService := service.NewUserService(storage.NewMemoryStore())
+
+	// Create a user
+	created, err := userService.CreateUser(ctx, &userProto.CreateUserRequest{
+		Username: "alice",
+		Email:    "alice@example.com",
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, created.Id)
+	assert.Equal(t, "alice", created.Username)
+	assert.NotZero(t, created.CreatedAt)
+
+	// Fetch it by ID and by username
+	fetched, err := userService.GetUser(ctx, &userProto.UserId{UserId: created.Id})
+	require.NoError(t, err)
+	assert.Equal(t, created.Email, fetched.Email)
+
+	fetched, err = userService.GetUserByUsername(ctx, &userProto.Username{Username: "Alice"})
+	require.NoError(t, err)
+	assert.Equal(t, created.Id, fetched.Id)
+
+	// Only the fields that are set get updated
+	updated, err := userService.UpdateUser(ctx, &userProto.UpdateUserRequest{
+		UserId:   created.Id,
+		Username: proto.String("alice_w"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "alice_w", updated.Username)
+	assert.Equal(t, "alice@example.com", updated.Email)
+
+	// Delete it
+	_, err = userService.DeleteUser(ctx, &userProto.UserId{UserId: created.Id})
+	require.NoError(t, err)
+
+	_, err = userService.GetUser(ctx, &userProto.UserId{UserId: created.Id})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = userService.DeleteUser(ctx, &userProto.UserId{UserId: created.Id})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestUsernamesAreUnique(t *testing.T) {
+	ctx := context.Background()
+	userService := service.NewUserService(storage.NewMemoryStore())
+
+	alice, err := userService.CreateUser(ctx, &userProto.CreateUserRequest{Username: "alice"})
+	require.NoError(t, err)
+	_, err = userService.CreateUser(ctx, &userProto.CreateUserRequest{Username: "bob"})
+	require.NoError(t, err)
+
+	_, err = userService.CreateUser(ctx, &userProto.CreateUserRequest{Username: "ALICE"})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	_, err = userService.UpdateUser(ctx, &userProto.UpdateUserRequest{UserId: alice.Id, Username: proto.String("bob")})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+}
+
+func TestUserValidation(t *testing.T) {
+	ctx := context.Background()
+	userService := service.NewUserService(storage.NewMemoryStore())
+
+	tests := []struct {
+		name     string
+		username string
+		email    string
+	}{
+		{name: "empty username", username: ""},
+		{name: "short username", username: "al"},
+		{name: "username with spaces", username: "alice smith"},
+		{name: "invalid email", username: "alice", email: "not-an-email"},
+		{name: "email with display name", username: "alice", email: "Alice <alice@example.com>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := userService.CreateUser(ctx, &userProto.CreateUserRequest{Username: tt.username, Email: tt.email})
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		})
+	}
+
+	_, err := userService.UpdateUser(ctx, &userProto.UpdateUserRequest{UserId: "missing", Email: proto.String("a@b.c")})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestFollowGraph(t *testing.T) {
+	ctx := context.Background()
+	userService := service.NewUserService(storage.NewMemoryStore())
+
+	var ids []string
+	for _, name := range []string{"author", "reader1", "reader2", "reader3"} {
+		user, err := userService.CreateUser(ctx, &userProto.CreateUserRequest{Username: name})
+		require.NoError(t, err)
+		ids = append(ids, user.Id)
+	}
+	author, readers := ids[0], ids[1:]
+
+	for _, reader := range readers {
+		_, err := userService.Follow(ctx, &userProto.FollowRequest{FollowerId: reader, FolloweeId: author})
+		require.NoError(t, err)
+	}
+
+	// Following twice is a no-op
+	_, err := userService.Follow(ctx, &userProto.FollowRequest{FollowerId: readers[0], FolloweeId: author})
+	require.NoError(t, err)
+
+	// Followers are paged with an opaque cursor
+	var seen []string
+	cursor := ""
+	for {
+		page, err := userService.ListFollowers(ctx, &userProto.ListFollowsRequest{UserId: author, PageSize: 2, Cursor: cursor})
+		require.NoError(t, err)
+		assert.Equal(t, int32(3), page.TotalCount)
+		for _, user := range page.Users {
+			seen = append(seen, user.Id)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	assert.ElementsMatch(t, readers, seen)
+
+	following, err := userService.ListFollowing(ctx, &userProto.ListFollowsRequest{UserId: readers[0]})
+	require.NoError(t, err)
+	require.Len(t, following.Users, 1)
+	assert.Equal(t, author, following.Users[0].Id)
+
+	// Unfollowing removes the edge from both listings
+	_, err = userService.Unfollow(ctx, &userProto.FollowRequest{FollowerId: readers[0], FolloweeId: author})
+	require.NoError(t, err)
+	following, err = userService.ListFollowing(ctx, &userProto.ListFollowsRequest{UserId: readers[0]})
+	require.NoError(t, err)
+	assert.Empty(t, following.Users)
+
+	_, err = userService.Unfollow(ctx, &userProto.FollowRequest{FollowerId: readers[0], FolloweeId: author})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// Users cannot follow themselves or someone who does not exist
+	_, err = userService.Follow(ctx, &userProto.FollowRequest{FollowerId: author, FolloweeId: author})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = userService.Follow(ctx, &userProto.FollowRequest{FollowerId: author, FolloweeId: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = userService.ListFollowers(ctx, &userProto.ListFollowsRequest{UserId: author, Cursor: "not base64!"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
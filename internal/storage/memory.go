@@ -0,0 +1,940 @@
+package storage
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+)
+
+// MemoryStore is a Store backed by in-process maps. Everything is lost when
+// the process exits.
+type MemoryStore struct {
+	//UUID -> User
+	users map[string]*models.User
+	//Lowercased username -> UUID
+	usernames map[string]string
+	//UserId -> sorted []UserId of the users following them
+	followers map[string][]string
+	//UserId -> sorted []UserId of the users they follow
+	following map[string][]string
+	//PostId -> Post
+	posts map[string]*models.Post
+	//UserId -> []PostId ordered by creation time, oldest first
+	postsByUser map[string][]string
+	//UserId -> []Notification ordered by Seq
+	notifications map[string][]*models.Notification
+	//IDs of every stored notification
+	notificationIDs map[string]struct{}
+	//Last Seq handed out to a notification
+	notificationSeq int64
+	//UserId -> NotificationPreferences
+	preferences map[string]*models.NotificationPreferences
+	//FanoutJobId -> FanoutJob
+	fanouts map[string]*models.FanoutJob
+	//(UserId, Key) -> IdempotencyKey
+	idempotencyKeys map[idempotencyKeyID]*models.IdempotencyKey
+	//Claimed keys in the order they were claimed, to purge the expired ones
+	idempotencyExpiry []idempotencyKeyID
+	//NotificationId -> queued job
+	jobs map[string]*queuedJob
+	//Last seq handed out to a job, to list jobs in the order they were saved
+	jobSeq int64
+	//Dead letters ordered by Seq
+	deadLetters []*models.DeadLetter
+	//Last Seq handed out to a dead letter
+	deadLetterSeq int64
+	//Names of the seeded data sets
+	seeds map[string]bool
+
+	//Metrics Singleton
+	metrics models.NotificationMetrics
+
+	//Mutex for thread safety
+	mu sync.RWMutex
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:           make(map[string]*models.User),
+		usernames:       make(map[string]string),
+		followers:       make(map[string][]string),
+		following:       make(map[string][]string),
+		posts:           make(map[string]*models.Post),
+		postsByUser:     make(map[string][]string),
+		notifications:   make(map[string][]*models.Notification),
+		notificationIDs: make(map[string]struct{}),
+		preferences:     make(map[string]*models.NotificationPreferences),
+		fanouts:         make(map[string]*models.FanoutJob),
+		idempotencyKeys: make(map[idempotencyKeyID]*models.IdempotencyKey),
+		jobs:            make(map[string]*queuedJob),
+		seeds:           make(map[string]bool),
+	}
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+func (s *MemoryStore) CreateUser(ctx context.Context, user *models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.users[user.ID]; exists {
+		return ErrAlreadyExists
+	}
+	if _, taken := s.usernames[strings.ToLower(user.Username)]; taken {
+		return ErrAlreadyExists
+	}
+
+	s.users[user.ID] = cloneUser(user)
+	s.usernames[strings.ToLower(user.Username)] = user.ID
+	return nil
+}
+
+func (s *MemoryStore) GetUser(ctx context.Context, id string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, exists := s.users[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return cloneUser(user), nil
+}
+
+func (s *MemoryStore) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, exists := s.usernames[strings.ToLower(username)]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return cloneUser(s.users[id]), nil
+}
+
+func (s *MemoryStore) UpdateUser(ctx context.Context, user *models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, exists := s.users[user.ID]
+	if !exists {
+		return ErrNotFound
+	}
+	if ownerID, taken := s.usernames[strings.ToLower(user.Username)]; taken && ownerID != user.ID {
+		return ErrAlreadyExists
+	}
+
+	delete(s.usernames, strings.ToLower(existing.Username))
+	s.users[user.ID] = cloneUser(user)
+	s.usernames[strings.ToLower(user.Username)] = user.ID
+	return nil
+}
+
+func (s *MemoryStore) DeleteUser(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[id]
+	if !exists {
+		return ErrNotFound
+	}
+
+	delete(s.usernames, strings.ToLower(user.Username))
+	delete(s.users, id)
+
+	// Drop every edge that points at the user from the other side
+	for _, followerID := range s.followers[id] {
+		s.following[followerID], _ = removeSorted(s.following[followerID], id)
+	}
+	for _, followeeID := range s.following[id] {
+		s.followers[followeeID], _ = removeSorted(s.followers[followeeID], id)
+	}
+	delete(s.followers, id)
+	delete(s.following, id)
+	delete(s.preferences, id)
+	return nil
+}
+
+func (s *MemoryStore) Follow(ctx context.Context, followerID, followeeID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.followers[followeeID], _ = insertSorted(s.followers[followeeID], followerID)
+	s.following[followerID], _ = insertSorted(s.following[followerID], followeeID)
+	return nil
+}
+
+func (s *MemoryStore) Unfollow(ctx context.Context, followerID, followeeID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var removed bool
+	s.followers[followeeID], removed = removeSorted(s.followers[followeeID], followerID)
+	if !removed {
+		return ErrNotFound
+	}
+	s.following[followerID], _ = removeSorted(s.following[followerID], followeeID)
+	return nil
+}
+
+func (s *MemoryStore) IsFollowing(ctx context.Context, followerID, followeeID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := s.following[followerID]
+	idx := sort.SearchStrings(ids, followeeID)
+	return idx < len(ids) && ids[idx] == followeeID, nil
+}
+
+func (s *MemoryStore) ListFollowers(ctx context.Context, userID, after string, limit int) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return pageAfter(s.followers[userID], after, limit), nil
+}
+
+func (s *MemoryStore) ListFollowing(ctx context.Context, userID, after string, limit int) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return pageAfter(s.following[userID], after, limit), nil
+}
+
+func (s *MemoryStore) CountFollowers(ctx context.Context, userID string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.followers[userID]), nil
+}
+
+func (s *MemoryStore) CountFollowing(ctx context.Context, userID string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.following[userID]), nil
+}
+
+func (s *MemoryStore) SavePost(ctx context.Context, post *models.Post) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.savePost(post)
+	return nil
+}
+
+// savePost stores a post, the caller holds the write lock
+func (s *MemoryStore) savePost(post *models.Post) {
+	p := *post
+	if _, exists := s.posts[post.ID]; exists {
+		// Author and creation time never change, so the index stays valid
+		s.posts[post.ID] = &p
+		return
+	}
+	s.posts[post.ID] = &p
+
+	// Keep the author index sorted by creation time
+	ids := s.postsByUser[post.UserID]
+	idx := sort.Search(len(ids), func(i int) bool {
+		return s.posts[ids[i]].CreatedAt.After(post.CreatedAt)
+	})
+	ids = append(ids, "")
+	copy(ids[idx+1:], ids[idx:])
+	ids[idx] = post.ID
+	s.postsByUser[post.UserID] = ids
+}
+
+func (s *MemoryStore) GetPost(ctx context.Context, id string) (*models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	post, exists := s.posts[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	p := *post
+	return &p, nil
+}
+
+func (s *MemoryStore) ListPostsByUser(ctx context.Context, userID string, limit int) ([]*models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := s.postsByUser[userID]
+	if limit <= 0 || limit > len(ids) {
+		limit = len(ids)
+	}
+
+	result := make([]*models.Post, 0, limit)
+	for i := len(ids) - 1; i >= len(ids)-limit; i-- {
+		p := *s.posts[ids[i]]
+		result = append(result, &p)
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) AddNotification(ctx context.Context, notification *models.Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.notificationIDs[notification.ID]; ok {
+		return ErrAlreadyExists
+	}
+	s.notificationIDs[notification.ID] = struct{}{}
+
+	s.notificationSeq++
+	notification.Seq = s.notificationSeq
+
+	userID := notification.UserID
+	s.notifications[userID] = append(s.notifications[userID], cloneNotification(notification))
+	return nil
+}
+
+func (s *MemoryStore) UpdateNotificationDelivery(ctx context.Context, notification *models.Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.notifications[notification.UserID] {
+		if stored.ID != notification.ID {
+			continue
+		}
+		updated := cloneNotification(notification)
+		stored.Status = updated.Status
+		stored.RetryCount = updated.RetryCount
+		stored.LastRetry = updated.LastRetry
+		stored.DeliveredAt = updated.DeliveredAt
+		stored.Channels = updated.Channels
+		stored.Deliveries = updated.Deliveries
+		return nil
+	}
+	return ErrNotFound
+}
+
+func (s *MemoryStore) ListNotifications(ctx context.Context, userID string, limit int) ([]*models.Notification, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	userNotifications := s.notifications[userID]
+	startIdx := 0
+	if limit > 0 && len(userNotifications) > limit {
+		startIdx = len(userNotifications) - limit
+	}
+
+	result := make([]*models.Notification, 0, len(userNotifications)-startIdx)
+	for _, notification := range userNotifications[startIdx:] {
+		result = append(result, cloneNotification(notification))
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) QueryNotifications(ctx context.Context, userID string, query NotificationQuery) ([]*models.Notification, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := []*models.Notification{}
+	userNotifications := s.notifications[userID]
+	for i := range userNotifications {
+		if query.Limit > 0 && len(result) == query.Limit {
+			break
+		}
+
+		notification := userNotifications[len(userNotifications)-1-i]
+		if query.OldestFirst {
+			notification = userNotifications[i]
+		}
+		switch {
+		case query.BeforeSeq > 0 && notification.Seq >= query.BeforeSeq:
+		case notification.Seq <= query.AfterSeq:
+		case query.UnreadOnly && notification.Read:
+		case !query.Since.IsZero() && notification.CreatedAt.Before(query.Since):
+		case !query.Until.IsZero() && !notification.CreatedAt.Before(query.Until):
+		default:
+			result = append(result, cloneNotification(notification))
+		}
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) MarkNotificationRead(ctx context.Context, userID, notificationID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, notification := range s.notifications[userID] {
+		if notification.ID == notificationID {
+			notification.Read = true
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *MemoryStore) MarkNotificationsRead(ctx context.Context, userID string, notificationIDs []string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make(map[string]bool, len(notificationIDs))
+	for _, id := range notificationIDs {
+		ids[id] = true
+	}
+
+	marked := 0
+	for _, notification := range s.notifications[userID] {
+		if ids[notification.ID] && !notification.Read {
+			notification.Read = true
+			marked++
+		}
+	}
+	return marked, nil
+}
+
+func (s *MemoryStore) MarkAllNotificationsRead(ctx context.Context, userID string, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	marked := 0
+	for _, notification := range s.notifications[userID] {
+		if !notification.Read && !notification.CreatedAt.After(before) {
+			notification.Read = true
+			marked++
+		}
+	}
+	return marked, nil
+}
+
+func (s *MemoryStore) CountUnreadNotifications(ctx context.Context, userID string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	unread := 0
+	for _, notification := range s.notifications[userID] {
+		if !notification.Read {
+			unread++
+		}
+	}
+	return unread, nil
+}
+
+func (s *MemoryStore) GetPreferences(ctx context.Context, userID string) (*models.NotificationPreferences, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	preferences, exists := s.preferences[userID]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return clonePreferences(preferences), nil
+}
+
+func (s *MemoryStore) ListPreferences(ctx context.Context, userIDs []string) (map[string]*models.NotificationPreferences, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]*models.NotificationPreferences)
+	for _, userID := range userIDs {
+		if preferences, exists := s.preferences[userID]; exists {
+			result[userID] = clonePreferences(preferences)
+		}
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) SavePreferences(ctx context.Context, preferences *models.NotificationPreferences) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.preferences[preferences.UserID] = clonePreferences(preferences)
+	return nil
+}
+
+func (s *MemoryStore) SaveFanout(ctx context.Context, job *models.FanoutJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fanouts[job.ID] = cloneFanout(job)
+	return nil
+}
+
+func (s *MemoryStore) CreatePostWithFanout(ctx context.Context, post *models.Post, job *models.FanoutJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.savePost(post)
+	s.fanouts[job.ID] = cloneFanout(job)
+	return nil
+}
+
+func (s *MemoryStore) GetFanout(ctx context.Context, id string) (*models.FanoutJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	job, exists := s.fanouts[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return cloneFanout(job), nil
+}
+
+func (s *MemoryStore) ListUnfinishedFanouts(ctx context.Context) ([]*models.FanoutJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var jobs []*models.FanoutJob
+	for _, job := range s.unfinishedFanouts() {
+		jobs = append(jobs, cloneFanout(job))
+	}
+	return jobs, nil
+}
+
+func (s *MemoryStore) ClaimFanouts(ctx context.Context, owner string, now, until time.Time) ([]*models.FanoutJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var jobs []*models.FanoutJob
+	for _, job := range s.unfinishedFanouts() {
+		if job.LeaseUntil.After(now) {
+			continue
+		}
+		job.Owner = owner
+		job.LeaseUntil = until
+		jobs = append(jobs, cloneFanout(job))
+	}
+	return jobs, nil
+}
+
+func (s *MemoryStore) RenewFanoutLeases(ctx context.Context, owner string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, job := range s.fanouts {
+		if job.Owner == owner {
+			job.LeaseUntil = until
+		}
+	}
+	return nil
+}
+
+// unfinishedFanouts returns the pending and running jobs, oldest first. The
+// caller holds the lock.
+func (s *MemoryStore) unfinishedFanouts() []*models.FanoutJob {
+	var jobs []*models.FanoutJob
+	for _, job := range s.fanouts {
+		if job.Status == models.FanoutStatusPending || job.Status == models.FanoutStatusRunning {
+			jobs = append(jobs, job)
+		}
+	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		if jobs[i].CreatedAt.Equal(jobs[j].CreatedAt) {
+			return jobs[i].ID < jobs[j].ID
+		}
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	return jobs
+}
+
+type idempotencyKeyID struct {
+	userID string
+	key    string
+}
+
+func (s *MemoryStore) ClaimIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) (*models.IdempotencyKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := key.CreatedAt
+	// Keys are claimed with the same TTL, so the oldest ones expire first
+	for len(s.idempotencyExpiry) > 0 {
+		id := s.idempotencyExpiry[0]
+		if stored, ok := s.idempotencyKeys[id]; ok && stored.ExpiresAt.After(now) {
+			break
+		} else if ok {
+			delete(s.idempotencyKeys, id)
+		}
+		s.idempotencyExpiry = s.idempotencyExpiry[1:]
+	}
+
+	id := idempotencyKeyID{userID: key.UserID, key: key.Key}
+	if stored, ok := s.idempotencyKeys[id]; ok && stored.ExpiresAt.After(now) {
+		return cloneIdempotencyKey(stored), ErrAlreadyExists
+	}
+	s.idempotencyKeys[id] = cloneIdempotencyKey(key)
+	s.idempotencyExpiry = append(s.idempotencyExpiry, id)
+	return key, nil
+}
+
+func (s *MemoryStore) SaveIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := idempotencyKeyID{userID: key.UserID, key: key.Key}
+	if _, ok := s.idempotencyKeys[id]; !ok {
+		s.idempotencyExpiry = append(s.idempotencyExpiry, id)
+	}
+	s.idempotencyKeys[id] = cloneIdempotencyKey(key)
+	return nil
+}
+
+func (s *MemoryStore) ReleaseIdempotencyKey(ctx context.Context, userID, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.idempotencyKeys, idempotencyKeyID{userID: userID, key: key})
+	return nil
+}
+
+func (s *MemoryStore) GetMetrics(ctx context.Context) (*models.NotificationMetrics, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	metrics := s.metrics
+	return &metrics, nil
+}
+
+func (s *MemoryStore) RecordDelivery(ctx context.Context, deliveryTime time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.metrics.AverageDeliveryTime = runningAverage(s.metrics.AverageDeliveryTime, s.metrics.TotalNotificationsSent, deliveryTime)
+	s.metrics.TotalNotificationsSent++
+	return nil
+}
+
+func (s *MemoryStore) RecordFailure(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.metrics.FailedAttempts++
+	return nil
+}
+
+func cloneUser(user *models.User) *models.User {
+	u := *user
+	return &u
+}
+
+// insertSorted adds id to a sorted slice unless it is already present
+func insertSorted(ids []string, id string) ([]string, bool) {
+	idx := sort.SearchStrings(ids, id)
+	if idx < len(ids) && ids[idx] == id {
+		return ids, false
+	}
+	ids = append(ids, "")
+	copy(ids[idx+1:], ids[idx:])
+	ids[idx] = id
+	return ids, true
+}
+
+// removeSorted removes id from a sorted slice if it is present
+func removeSorted(ids []string, id string) ([]string, bool) {
+	idx := sort.SearchStrings(ids, id)
+	if idx == len(ids) || ids[idx] != id {
+		return ids, false
+	}
+	return append(ids[:idx], ids[idx+1:]...), true
+}
+
+// pageAfter copies up to limit IDs of a sorted slice that sort after the given ID
+func pageAfter(ids []string, after string, limit int) []string {
+	start := sort.Search(len(ids), func(i int) bool { return ids[i] > after })
+	end := len(ids)
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+	return append([]string{}, ids[start:end]...)
+}
+
+type queuedJob struct {
+	seq int64
+	job models.NotificationJob
+}
+
+func (s *MemoryStore) SaveJob(ctx context.Context, job *models.NotificationJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := job.Notification.ID
+	if queued, ok := s.jobs[id]; ok {
+		queued.job.Notification = cloneNotification(job.Notification)
+		queued.job.Attempt = job.Attempt
+		queued.job.DueAt = job.DueAt
+		queued.job.Attempts = cloneAttempts(job.Attempts)
+		queued.job.Priority = job.Priority
+		queued.job.MaxBackoff = job.MaxBackoff
+		queued.job.Owner = job.Owner
+		queued.job.LeaseUntil = job.LeaseUntil
+		return nil
+	}
+	s.jobSeq++
+	s.jobs[id] = &queuedJob{seq: s.jobSeq, job: *cloneJob(job)}
+	return nil
+}
+
+func (s *MemoryStore) DeleteJob(ctx context.Context, notificationID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.jobs, notificationID)
+	return nil
+}
+
+func (s *MemoryStore) ListJobs(ctx context.Context) ([]*models.NotificationJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	jobs := make([]*models.NotificationJob, 0, len(s.jobs))
+	for _, q := range s.sortedJobs() {
+		jobs = append(jobs, cloneJob(&q.job))
+	}
+	return jobs, nil
+}
+
+func (s *MemoryStore) ClaimJobs(ctx context.Context, owner string, now, until time.Time, limit int) ([]*models.NotificationJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var jobs []*models.NotificationJob
+	for _, q := range s.sortedJobs() {
+		if limit > 0 && len(jobs) == limit {
+			break
+		}
+		if q.job.LeaseUntil.After(now) {
+			continue
+		}
+		q.job.Owner = owner
+		q.job.LeaseUntil = until
+		jobs = append(jobs, cloneJob(&q.job))
+	}
+	return jobs, nil
+}
+
+func (s *MemoryStore) RenewJobLeases(ctx context.Context, owner string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, q := range s.jobs {
+		if q.job.Owner == owner {
+			q.job.LeaseUntil = until
+		}
+	}
+	return nil
+}
+
+// sortedJobs returns the jobs in the order they were first saved. The caller
+// holds the lock.
+func (s *MemoryStore) sortedJobs() []*queuedJob {
+	queued := make([]*queuedJob, 0, len(s.jobs))
+	for _, q := range s.jobs {
+		queued = append(queued, q)
+	}
+	sort.Slice(queued, func(i, j int) bool { return queued[i].seq < queued[j].seq })
+	return queued
+}
+
+func (s *MemoryStore) GetJob(ctx context.Context, notificationID string) (*models.NotificationJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	queued, ok := s.jobs[notificationID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return cloneJob(&queued.job), nil
+}
+
+func cloneJob(job *models.NotificationJob) *models.NotificationJob {
+	return &models.NotificationJob{
+		Notification: cloneNotification(job.Notification),
+		Attempt:      job.Attempt,
+		DueAt:        job.DueAt,
+		Attempts:     cloneAttempts(job.Attempts),
+		Priority:     job.Priority,
+		MaxBackoff:   job.MaxBackoff,
+		Owner:        job.Owner,
+		LeaseUntil:   job.LeaseUntil,
+	}
+}
+
+func (s *MemoryStore) AddDeadLetter(ctx context.Context, deadLetter *models.DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteDeadLetter(deadLetter.Notification.ID)
+	s.deadLetterSeq++
+	deadLetter.Seq = s.deadLetterSeq
+	s.deadLetters = append(s.deadLetters, cloneDeadLetter(deadLetter))
+	return nil
+}
+
+func (s *MemoryStore) GetDeadLetter(ctx context.Context, notificationID string) (*models.DeadLetter, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, deadLetter := range s.deadLetters {
+		if deadLetter.Notification.ID == notificationID {
+			return cloneDeadLetter(deadLetter), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MemoryStore) ListDeadLetters(ctx context.Context, afterSeq int64, limit int) ([]*models.DeadLetter, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := sort.Search(len(s.deadLetters), func(i int) bool { return s.deadLetters[i].Seq > afterSeq })
+	end := len(s.deadLetters)
+	if limit > 0 {
+		end = min(end, start+limit)
+	}
+
+	result := make([]*models.DeadLetter, 0, end-start)
+	for _, deadLetter := range s.deadLetters[start:end] {
+		result = append(result, cloneDeadLetter(deadLetter))
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) CountDeadLetters(ctx context.Context) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.deadLetters), nil
+}
+
+func (s *MemoryStore) LastDeadLetterSeq(ctx context.Context) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.deadLetters) == 0 {
+		return 0, nil
+	}
+	return s.deadLetters[len(s.deadLetters)-1].Seq, nil
+}
+
+func (s *MemoryStore) DeleteDeadLetter(ctx context.Context, notificationID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.deleteDeadLetter(notificationID) {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MemoryStore) PurgeDeadLetters(ctx context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.deadLetters[:0]
+	for _, deadLetter := range s.deadLetters {
+		if deadLetter.FailedAt.After(before) {
+			kept = append(kept, deadLetter)
+		}
+	}
+	purged := len(s.deadLetters) - len(kept)
+	clear(s.deadLetters[len(kept):])
+	s.deadLetters = kept
+	return purged, nil
+}
+
+// deleteDeadLetter removes the dead letter of a notification and reports
+// whether there was one. The caller must hold the write lock.
+func (s *MemoryStore) deleteDeadLetter(notificationID string) bool {
+	for i, deadLetter := range s.deadLetters {
+		if deadLetter.Notification.ID == notificationID {
+			s.deadLetters = append(s.deadLetters[:i], s.deadLetters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func clonePreferences(preferences *models.NotificationPreferences) *models.NotificationPreferences {
+	p := *preferences
+	p.Channels = slices.Clone(preferences.Channels)
+	p.MutedEventTypes = slices.Clone(preferences.MutedEventTypes)
+	p.MutedAuthors = slices.Clone(preferences.MutedAuthors)
+	if preferences.QuietHours != nil {
+		quietHours := *preferences.QuietHours
+		p.QuietHours = &quietHours
+	}
+	return &p
+}
+
+func cloneFanout(job *models.FanoutJob) *models.FanoutJob {
+	j := *job
+	if job.CompletedAt != nil {
+		t := *job.CompletedAt
+		j.CompletedAt = &t
+	}
+	return &j
+}
+
+func cloneIdempotencyKey(key *models.IdempotencyKey) *models.IdempotencyKey {
+	k := *key
+	k.Response = slices.Clone(key.Response)
+	return &k
+}
+
+func cloneDeadLetter(deadLetter *models.DeadLetter) *models.DeadLetter {
+	d := *deadLetter
+	d.Notification = cloneNotification(deadLetter.Notification)
+	d.Attempts = cloneAttempts(deadLetter.Attempts)
+	return &d
+}
+
+func cloneAttempts(attempts []models.DeliveryAttempt) []models.DeliveryAttempt {
+	if len(attempts) == 0 {
+		return nil
+	}
+	return append([]models.DeliveryAttempt(nil), attempts...)
+}
+
+func cloneNotification(notification *models.Notification) *models.Notification {
+	n := *notification
+	if notification.LastRetry != nil {
+		t := *notification.LastRetry
+		n.LastRetry = &t
+	}
+	if notification.DeliveredAt != nil {
+		t := *notification.DeliveredAt
+		n.DeliveredAt = &t
+	}
+	if len(notification.Channels) > 0 {
+		n.Channels = append([]models.Channel(nil), notification.Channels...)
+	}
+	if len(notification.PostIDs) > 0 {
+		n.PostIDs = append([]string(nil), notification.PostIDs...)
+	}
+	if len(notification.Deliveries) > 0 {
+		n.Deliveries = make([]models.ChannelDelivery, len(notification.Deliveries))
+		for i, delivery := range notification.Deliveries {
+			n.Deliveries[i] = delivery
+			if delivery.DeliveredAt != nil {
+				t := *delivery.DeliveredAt
+				n.Deliveries[i].DeliveredAt = &t
+			}
+		}
+	}
+	return &n
+}
+
+func (s *MemoryStore) Seeded(ctx context.Context, name string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.seeds[name], nil
+}
+
+func (s *MemoryStore) MarkSeeded(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seeds[name] = true
+	return nil
+}
@@ -0,0 +1,14 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/iwhitebird/social-app-microservices/internal/storage"
+	"github.com/iwhitebird/social-app-microservices/internal/storage/storagetest"
+)
+
+func TestMemoryStore(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Store {
+		return storage.NewMemoryStore()
+	})
+}
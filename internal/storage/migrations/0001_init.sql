@@ -0,0 +1,43 @@
+CREATE TABLE users (
+    id       TEXT PRIMARY KEY,
+    username TEXT NOT NULL
+);
+
+CREATE TABLE user_followers (
+    user_id     TEXT    NOT NULL,
+    position    INTEGER NOT NULL,
+    follower_id TEXT    NOT NULL,
+    PRIMARY KEY (user_id, position)
+);
+
+-- One post per user, matching the behaviour of the in-memory store
+CREATE TABLE posts (
+    user_id TEXT PRIMARY KEY,
+    id      TEXT NOT NULL,
+    content TEXT NOT NULL
+);
+
+CREATE TABLE notifications (
+    seq          INTEGER PRIMARY KEY AUTOINCREMENT,
+    id           TEXT    NOT NULL UNIQUE,
+    user_id      TEXT    NOT NULL,
+    post_id      TEXT    NOT NULL,
+    content      TEXT    NOT NULL,
+    read         INTEGER NOT NULL DEFAULT 0,
+    created_at   INTEGER NOT NULL,
+    status       TEXT    NOT NULL DEFAULT '',
+    retry_count  INTEGER NOT NULL DEFAULT 0,
+    last_retry   INTEGER,
+    delivered_at INTEGER
+);
+
+CREATE INDEX notifications_user_seq ON notifications (user_id, seq);
+
+CREATE TABLE metrics (
+    id                       INTEGER PRIMARY KEY CHECK (id = 1),
+    total_notifications_sent INTEGER NOT NULL DEFAULT 0,
+    failed_attempts          INTEGER NOT NULL DEFAULT 0,
+    average_delivery_time    REAL    NOT NULL DEFAULT 0
+);
+
+INSERT INTO metrics (id) VALUES (1);
@@ -0,0 +1,18 @@
+-- Keep every post instead of one post per user
+ALTER TABLE posts RENAME TO posts_by_user;
+
+CREATE TABLE posts (
+    id         TEXT    PRIMARY KEY,
+    user_id    TEXT    NOT NULL,
+    content    TEXT    NOT NULL,
+    created_at INTEGER NOT NULL
+);
+
+CREATE INDEX posts_user_created ON posts (user_id, created_at);
+
+-- Posts from the old table had no creation time and may have had no ID
+INSERT INTO posts (id, user_id, content, created_at)
+SELECT CASE WHEN id = '' THEN 'legacy-' || user_id ELSE id END, user_id, content, 0
+FROM posts_by_user;
+
+DROP TABLE posts_by_user;
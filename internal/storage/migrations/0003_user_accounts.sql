@@ -0,0 +1,4 @@
+ALTER TABLE users ADD COLUMN email TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN created_at INTEGER NOT NULL DEFAULT 0;
+
+CREATE UNIQUE INDEX users_username ON users (username COLLATE NOCASE);
@@ -0,0 +1,15 @@
+-- Replace the per-user follower lists with a follow graph that can be
+-- queried in both directions
+CREATE TABLE follows (
+    follower_id TEXT    NOT NULL,
+    followee_id TEXT    NOT NULL,
+    created_at  INTEGER NOT NULL,
+    PRIMARY KEY (follower_id, followee_id)
+) WITHOUT ROWID;
+
+CREATE INDEX follows_followee ON follows (followee_id, follower_id);
+
+INSERT OR IGNORE INTO follows (follower_id, followee_id, created_at)
+SELECT follower_id, user_id, 0 FROM user_followers;
+
+DROP TABLE user_followers;
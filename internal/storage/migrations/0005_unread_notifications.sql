@@ -0,0 +1,3 @@
+-- Unread counts are read on every badge refresh, so index only the rows
+-- that are still unread
+CREATE INDEX notifications_user_unread ON notifications (user_id, created_at) WHERE read = 0;
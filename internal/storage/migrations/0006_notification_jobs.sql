@@ -0,0 +1,12 @@
+-- Notifications waiting to be delivered. A job is written when the
+-- notification is enqueued and deleted once it has been delivered or given
+-- up on, so the jobs left here are replayed on startup.
+CREATE TABLE notification_jobs (
+    seq             INTEGER PRIMARY KEY AUTOINCREMENT,
+    notification_id TEXT    NOT NULL UNIQUE,
+    user_id         TEXT    NOT NULL,
+    post_id         TEXT    NOT NULL,
+    content         TEXT    NOT NULL,
+    created_at      INTEGER NOT NULL,
+    attempt         INTEGER NOT NULL
+);
@@ -0,0 +1,3 @@
+-- Retries wait in the queue until they are due. Keep the due time, so a
+-- replayed retry still waits out its backoff; NULL means right away.
+ALTER TABLE notification_jobs ADD COLUMN due_at INTEGER;
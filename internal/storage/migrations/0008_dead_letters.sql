@@ -0,0 +1,18 @@
+-- Failed delivery attempts of a job so far, as a JSON array
+ALTER TABLE notification_jobs ADD COLUMN failures TEXT NOT NULL DEFAULT '[]';
+
+-- Notifications the queue gave up on. attempts holds their failed delivery
+-- attempts as a JSON array.
+CREATE TABLE dead_letters (
+    seq             INTEGER PRIMARY KEY AUTOINCREMENT,
+    notification_id TEXT    NOT NULL UNIQUE,
+    user_id         TEXT    NOT NULL,
+    post_id         TEXT    NOT NULL,
+    content         TEXT    NOT NULL,
+    created_at      INTEGER NOT NULL,
+    reason          TEXT    NOT NULL,
+    attempts        TEXT    NOT NULL,
+    failed_at       INTEGER NOT NULL
+);
+
+CREATE INDEX dead_letters_failed_at ON dead_letters (failed_at);
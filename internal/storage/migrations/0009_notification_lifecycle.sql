@@ -0,0 +1,9 @@
+-- Where a queued or dead-lettered notification is in its delivery lifecycle,
+-- matching the status columns of notifications
+ALTER TABLE notification_jobs ADD COLUMN status TEXT NOT NULL DEFAULT 'pending';
+ALTER TABLE notification_jobs ADD COLUMN retry_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE notification_jobs ADD COLUMN last_retry INTEGER;
+
+ALTER TABLE dead_letters ADD COLUMN status TEXT NOT NULL DEFAULT 'dead_lettered';
+ALTER TABLE dead_letters ADD COLUMN retry_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE dead_letters ADD COLUMN last_retry INTEGER;
@@ -0,0 +1,10 @@
+-- The channels a notification is delivered through and the outcome of each,
+-- as JSON arrays
+ALTER TABLE notifications ADD COLUMN channels TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE notifications ADD COLUMN deliveries TEXT NOT NULL DEFAULT '[]';
+
+ALTER TABLE notification_jobs ADD COLUMN channels TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE notification_jobs ADD COLUMN deliveries TEXT NOT NULL DEFAULT '[]';
+
+ALTER TABLE dead_letters ADD COLUMN channels TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE dead_letters ADD COLUMN deliveries TEXT NOT NULL DEFAULT '[]';
@@ -0,0 +1,12 @@
+-- What each user wants to be notified about and how. The lists are JSON
+-- arrays; quiet hours are unset while quiet_start is empty.
+CREATE TABLE notification_preferences (
+    user_id           TEXT    PRIMARY KEY,
+    channels          TEXT    NOT NULL DEFAULT '[]',
+    muted_event_types TEXT    NOT NULL DEFAULT '[]',
+    muted_authors     TEXT    NOT NULL DEFAULT '[]',
+    quiet_start       TEXT    NOT NULL DEFAULT '',
+    quiet_end         TEXT    NOT NULL DEFAULT '',
+    time_zone         TEXT    NOT NULL DEFAULT '',
+    updated_at        INTEGER NOT NULL
+) WITHOUT ROWID;
@@ -0,0 +1,19 @@
+-- Fan-out jobs of published posts. cursor is the checkpoint: the ID of the
+-- last follower handled.
+CREATE TABLE fanout_jobs (
+    id              TEXT    PRIMARY KEY,
+    post_id         TEXT    NOT NULL,
+    author_id       TEXT    NOT NULL,
+    status          TEXT    NOT NULL,
+    cursor          TEXT    NOT NULL DEFAULT '',
+    total_followers INTEGER NOT NULL DEFAULT 0,
+    processed       INTEGER NOT NULL DEFAULT 0,
+    queued          INTEGER NOT NULL DEFAULT 0,
+    skipped         INTEGER NOT NULL DEFAULT 0,
+    error           TEXT    NOT NULL DEFAULT '',
+    created_at      INTEGER NOT NULL,
+    updated_at      INTEGER NOT NULL,
+    completed_at    INTEGER
+);
+
+CREATE INDEX fanout_jobs_status ON fanout_jobs (status, created_at);
@@ -0,0 +1,14 @@
+-- Requests sent with an idempotency key and their serialized response,
+-- which stays NULL while the request is in progress. Expired rows are
+-- deleted when the next key is claimed.
+CREATE TABLE idempotency_keys (
+    user_id      TEXT    NOT NULL,
+    key          TEXT    NOT NULL,
+    request_hash TEXT    NOT NULL,
+    response     BLOB,
+    created_at   INTEGER NOT NULL,
+    expires_at   INTEGER NOT NULL,
+    PRIMARY KEY (user_id, key)
+) WITHOUT ROWID;
+
+CREATE INDEX idempotency_keys_expires_at ON idempotency_keys (expires_at);
@@ -0,0 +1,14 @@
+-- Who a notification is about and what happened, so notifications can be
+-- coalesced and deduplicated, and the posts an aggregate notification
+-- merges as a JSON array
+ALTER TABLE notifications ADD COLUMN author_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE notifications ADD COLUMN event_type TEXT NOT NULL DEFAULT '';
+ALTER TABLE notifications ADD COLUMN post_ids TEXT NOT NULL DEFAULT '[]';
+
+ALTER TABLE notification_jobs ADD COLUMN author_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE notification_jobs ADD COLUMN event_type TEXT NOT NULL DEFAULT '';
+ALTER TABLE notification_jobs ADD COLUMN post_ids TEXT NOT NULL DEFAULT '[]';
+
+ALTER TABLE dead_letters ADD COLUMN author_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE dead_letters ADD COLUMN event_type TEXT NOT NULL DEFAULT '';
+ALTER TABLE dead_letters ADD COLUMN post_ids TEXT NOT NULL DEFAULT '[]';
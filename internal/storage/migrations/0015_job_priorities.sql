@@ -0,0 +1,2 @@
+-- The queue lane a job waits in
+ALTER TABLE notification_jobs ADD COLUMN priority TEXT NOT NULL DEFAULT 'normal';
@@ -0,0 +1,4 @@
+-- Queues sharing a database lease the jobs they run, so that only jobs
+-- whose queue stopped or crashed are replayed by another one
+ALTER TABLE notification_jobs ADD COLUMN owner TEXT NOT NULL DEFAULT '';
+ALTER TABLE notification_jobs ADD COLUMN lease_until INTEGER NOT NULL DEFAULT 0;
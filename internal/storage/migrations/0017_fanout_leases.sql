@@ -0,0 +1,5 @@
+-- Dispatchers sharing a database lease the fan-out jobs they run, so that
+-- only the jobs of a dispatcher that stopped or crashed are resumed by
+-- another one
+ALTER TABLE fanout_jobs ADD COLUMN owner TEXT NOT NULL DEFAULT '';
+ALTER TABLE fanout_jobs ADD COLUMN lease_until INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,9 @@
+-- Data sets a database was seeded with, so that seeding runs once rather
+-- than on every start, and data deleted since is not seeded again
+CREATE TABLE seeds (
+    name      TEXT    PRIMARY KEY,
+    seeded_at INTEGER NOT NULL
+);
+
+-- Databases that hold the sample users were seeded before
+INSERT INTO seeds (name, seeded_at) SELECT 'sample', created_at FROM users WHERE id = 'u1';
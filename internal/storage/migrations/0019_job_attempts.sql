@@ -0,0 +1,2 @@
+-- Jobs record every delivery attempt, not only the failed ones
+ALTER TABLE notification_jobs RENAME COLUMN failures TO attempts;
@@ -0,0 +1,3 @@
+-- Jobs can wait less or longer between retries than the queue's maximum,
+-- in nanoseconds, 0 for the queue's
+ALTER TABLE notification_jobs ADD COLUMN max_backoff INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- Notifications the queue turned away while a fan-out job ran
+ALTER TABLE fanout_jobs ADD COLUMN turned_away INTEGER NOT NULL DEFAULT 0;
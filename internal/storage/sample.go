@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+)
+
+// sampleDataSet names the sample data in the SeedRepository
+const sampleDataSet = "sample"
+
+// InitSampleData seeds a store with a few users that all follow each other.
+// A store is only seeded once, so persistent stores keep their data across
+// restarts, including the sample users and posts deleted since. Records
+// left over from an attempt that failed halfway are kept as they are.
+func InitSampleData(ctx context.Context, store Store) error {
+	seeded, err := store.Seeded(ctx, sampleDataSet)
+	if err != nil || seeded {
+		return err
+	}
+
+	now := time.Now()
+	users := []*models.User{
+		{ID: "u1", Username: "alice", CreatedAt: now},
+		{ID: "u2", Username: "bob", CreatedAt: now},
+		{ID: "u3", Username: "charlie", CreatedAt: now},
+		{ID: "u4", Username: "david", CreatedAt: now},
+		{ID: "u5", Username: "eve", CreatedAt: now},
+	}
+
+	for _, u := range users {
+		if err := store.CreateUser(ctx, u); err != nil && !errors.Is(err, ErrAlreadyExists) {
+			return err
+		}
+	}
+
+	// Everyone follows everyone else
+	for _, follower := range users {
+		for _, followee := range users {
+			if follower.ID == followee.ID {
+				continue
+			}
+			if err := store.Follow(ctx, follower.ID, followee.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	posts := []*models.Post{
+		{ID: "p1", UserID: "u1", Content: "Hello from Alice!", CreatedAt: now},
+		{ID: "p2", UserID: "u2", Content: "Bob's first post", CreatedAt: now},
+		{ID: "p3", UserID: "u3", Content: "Charlie shares news", CreatedAt: now},
+		{ID: "p4", UserID: "u4", Content: "David's photo post", CreatedAt: now},
+		{ID: "p5", UserID: "u5", Content: "Eve's thoughts", CreatedAt: now},
+	}
+
+	for _, p := range posts {
+		_, err := store.GetPost(ctx, p.ID)
+		if errors.Is(err, ErrNotFound) {
+			err = store.SavePost(ctx, p)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return store.MarkSeeded(ctx, sampleDataSet)
+}
@@ -0,0 +1,1107 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+	"github.com/mattn/go-sqlite3"
+)
+
+// SQLiteStore is a Store persisted in an embedded SQLite database. The
+// database runs in WAL mode, so several processes on the same host can share
+// one file.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens (or creates) the database at path and brings its
+// schema up to date
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("create database directory: %w", err)
+		}
+	}
+
+	// Write transactions take the lock up front and wait for other
+	// processes instead of failing with SQLITE_BUSY
+	dsn := fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate&_foreign_keys=on", path)
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteStore{db: db}, nil
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteStore) CreateUser(ctx context.Context, user *models.User) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO users (id, username, email, created_at) VALUES (?, ?, ?, ?)`,
+		user.ID, user.Username, user.Email, user.CreatedAt.UnixNano())
+	return translateError(err)
+}
+
+func (s *SQLiteStore) GetUser(ctx context.Context, id string) (*models.User, error) {
+	return s.getUser(ctx, `SELECT id, username, email, created_at FROM users WHERE id = ?`, id)
+}
+
+func (s *SQLiteStore) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	return s.getUser(ctx, `SELECT id, username, email, created_at FROM users WHERE username = ? COLLATE NOCASE`, username)
+}
+
+func (s *SQLiteStore) getUser(ctx context.Context, query string, arg string) (*models.User, error) {
+	var (
+		user      models.User
+		createdAt int64
+	)
+	err := s.db.QueryRowContext(ctx, query, arg).Scan(&user.ID, &user.Username, &user.Email, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	user.CreatedAt = time.Unix(0, createdAt)
+	return &user, nil
+}
+
+func (s *SQLiteStore) UpdateUser(ctx context.Context, user *models.User) error {
+	result, err := s.db.ExecContext(ctx, `UPDATE users SET username = ?, email = ?, created_at = ? WHERE id = ?`,
+		user.Username, user.Email, user.CreatedAt.UnixNano(), user.ID)
+	if err != nil {
+		return translateError(err)
+	}
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStore) DeleteUser(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return ErrNotFound
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM follows WHERE follower_id = ? OR followee_id = ?`, id, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM notification_preferences WHERE user_id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) Follow(ctx context.Context, followerID, followeeID string) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO follows (follower_id, followee_id, created_at) VALUES (?, ?, ?)
+		ON CONFLICT DO NOTHING`, followerID, followeeID, time.Now().UnixNano())
+	return err
+}
+
+func (s *SQLiteStore) Unfollow(ctx context.Context, followerID, followeeID string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM follows WHERE follower_id = ? AND followee_id = ?`, followerID, followeeID)
+	if err != nil {
+		return err
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStore) IsFollowing(ctx context.Context, followerID, followeeID string) (bool, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM follows WHERE follower_id = ? AND followee_id = ?`,
+		followerID, followeeID).Scan(&count)
+	return count > 0, err
+}
+
+func (s *SQLiteStore) ListFollowers(ctx context.Context, userID, after string, limit int) ([]string, error) {
+	return s.listIDs(ctx, `SELECT follower_id FROM follows WHERE followee_id = ? AND follower_id > ?
+		ORDER BY follower_id LIMIT ?`, userID, after, limit)
+}
+
+func (s *SQLiteStore) ListFollowing(ctx context.Context, userID, after string, limit int) ([]string, error) {
+	return s.listIDs(ctx, `SELECT followee_id FROM follows WHERE follower_id = ? AND followee_id > ?
+		ORDER BY followee_id LIMIT ?`, userID, after, limit)
+}
+
+func (s *SQLiteStore) listIDs(ctx context.Context, query, userID, after string, limit int) ([]string, error) {
+	if limit <= 0 {
+		limit = -1 // no limit
+	}
+	rows, err := s.db.QueryContext(ctx, query, userID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (s *SQLiteStore) CountFollowers(ctx context.Context, userID string) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM follows WHERE followee_id = ?`, userID).Scan(&count)
+	return count, err
+}
+
+func (s *SQLiteStore) CountFollowing(ctx context.Context, userID string) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM follows WHERE follower_id = ?`, userID).Scan(&count)
+	return count, err
+}
+
+func (s *SQLiteStore) SavePost(ctx context.Context, post *models.Post) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO posts (id, user_id, content, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET content = excluded.content`,
+		post.ID, post.UserID, post.Content, post.CreatedAt.UnixNano())
+	return err
+}
+
+func (s *SQLiteStore) GetPost(ctx context.Context, id string) (*models.Post, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, user_id, content, created_at FROM posts WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	posts, err := scanPosts(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(posts) == 0 {
+		return nil, ErrNotFound
+	}
+	return posts[0], nil
+}
+
+func (s *SQLiteStore) ListPostsByUser(ctx context.Context, userID string, limit int) ([]*models.Post, error) {
+	if limit <= 0 {
+		limit = -1 // no limit
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT id, user_id, content, created_at FROM posts
+		WHERE user_id = ? ORDER BY created_at DESC, rowid DESC LIMIT ?`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanPosts(rows)
+}
+
+func scanPosts(rows *sql.Rows) ([]*models.Post, error) {
+	defer rows.Close()
+
+	posts := []*models.Post{}
+	for rows.Next() {
+		var (
+			post      models.Post
+			createdAt int64
+		)
+		if err := rows.Scan(&post.ID, &post.UserID, &post.Content, &createdAt); err != nil {
+			return nil, err
+		}
+		post.CreatedAt = time.Unix(0, createdAt)
+		posts = append(posts, &post)
+	}
+	return posts, rows.Err()
+}
+
+func (s *SQLiteStore) AddNotification(ctx context.Context, notification *models.Notification) error {
+	channels, deliveries, err := encodeDelivery(notification)
+	if err != nil {
+		return err
+	}
+	postIDs, err := encodeList(notification.PostIDs)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.ExecContext(ctx, `INSERT INTO notifications
+		(id, user_id, post_id, content, read, created_at, status, retry_count, last_retry, delivered_at, channels, deliveries,
+			author_id, event_type, post_ids)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		notification.ID, notification.UserID, notification.PostID, notification.Content, notification.Read,
+		notification.CreatedAt.UnixNano(), string(notification.Status), notification.RetryCount,
+		nullableTime(notification.LastRetry), nullableTime(notification.DeliveredAt), channels, deliveries,
+		notification.AuthorID, string(notification.EventType), postIDs)
+	if err != nil {
+		return translateError(err)
+	}
+	notification.Seq, err = result.LastInsertId()
+	return err
+}
+
+func (s *SQLiteStore) UpdateNotificationDelivery(ctx context.Context, notification *models.Notification) error {
+	channels, deliveries, err := encodeDelivery(notification)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.ExecContext(ctx, `UPDATE notifications
+		SET status = ?, retry_count = ?, last_retry = ?, delivered_at = ?, channels = ?, deliveries = ?
+		WHERE id = ?`,
+		string(notification.Status), notification.RetryCount, nullableTime(notification.LastRetry),
+		nullableTime(notification.DeliveredAt), channels, deliveries, notification.ID)
+	if err != nil {
+		return err
+	}
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStore) ListNotifications(ctx context.Context, userID string, limit int) ([]*models.Notification, error) {
+	if limit <= 0 {
+		limit = -1 // no limit
+	}
+
+	// Take the newest rows first, then flip them back into insertion order
+	rows, err := s.db.QueryContext(ctx, `SELECT * FROM (
+			SELECT `+notificationColumns+`
+			FROM notifications WHERE user_id = ? ORDER BY seq DESC LIMIT ?
+		) ORDER BY seq`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanNotifications(rows)
+}
+
+func (s *SQLiteStore) QueryNotifications(ctx context.Context, userID string, query NotificationQuery) ([]*models.Notification, error) {
+	where := []string{"user_id = ?"}
+	args := []any{userID}
+	if query.BeforeSeq > 0 {
+		where = append(where, "seq < ?")
+		args = append(args, query.BeforeSeq)
+	}
+	if query.AfterSeq > 0 {
+		where = append(where, "seq > ?")
+		args = append(args, query.AfterSeq)
+	}
+	if query.UnreadOnly {
+		where = append(where, "read = 0")
+	}
+	if !query.Since.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, query.Since.UnixNano())
+	}
+	if !query.Until.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, query.Until.UnixNano())
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = -1 // no limit
+	}
+	args = append(args, limit)
+	order := "DESC"
+	if query.OldestFirst {
+		order = "ASC"
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+notificationColumns+` FROM notifications
+		WHERE `+strings.Join(where, " AND ")+` ORDER BY seq `+order+` LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanNotifications(rows)
+}
+
+const notificationColumns = `seq, id, user_id, post_id, content, read, created_at, status, retry_count, last_retry, delivered_at,
+	channels, deliveries, author_id, event_type, post_ids`
+
+func scanNotifications(rows *sql.Rows) ([]*models.Notification, error) {
+	defer rows.Close()
+
+	notifications := []*models.Notification{}
+	for rows.Next() {
+		var (
+			n                      models.Notification
+			status, eventType      string
+			createdAt              int64
+			lastRetry, deliveredAt sql.NullInt64
+			channels, deliveries   string
+			postIDs                string
+		)
+		if err := rows.Scan(&n.Seq, &n.ID, &n.UserID, &n.PostID, &n.Content, &n.Read, &createdAt,
+			&status, &n.RetryCount, &lastRetry, &deliveredAt, &channels, &deliveries,
+			&n.AuthorID, &eventType, &postIDs); err != nil {
+			return nil, err
+		}
+		n.CreatedAt = time.Unix(0, createdAt)
+		n.Status = models.NotificationStatus(status)
+		n.EventType = models.EventType(eventType)
+		n.LastRetry = timeFromNullable(lastRetry)
+		n.DeliveredAt = timeFromNullable(deliveredAt)
+		if err := decodeDelivery(&n, channels, deliveries); err != nil {
+			return nil, err
+		}
+		var err error
+		if n.PostIDs, err = decodeList[string](postIDs, "post IDs"); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, &n)
+	}
+	return notifications, rows.Err()
+}
+
+func (s *SQLiteStore) MarkNotificationRead(ctx context.Context, userID, notificationID string) error {
+	// Rows that are already read still count as matched, so only a missing
+	// notification affects zero rows
+	result, err := s.db.ExecContext(ctx, `UPDATE notifications SET read = 1 WHERE user_id = ? AND id = ?`,
+		userID, notificationID)
+	if err != nil {
+		return err
+	}
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStore) MarkNotificationsRead(ctx context.Context, userID string, notificationIDs []string) (int, error) {
+	if len(notificationIDs) == 0 {
+		return 0, nil
+	}
+
+	args := make([]any, 0, len(notificationIDs)+1)
+	args = append(args, userID)
+	for _, id := range notificationIDs {
+		args = append(args, id)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(notificationIDs)), ",")
+
+	result, err := s.db.ExecContext(ctx, `UPDATE notifications SET read = 1
+		WHERE user_id = ? AND read = 0 AND id IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return 0, err
+	}
+	marked, err := result.RowsAffected()
+	return int(marked), err
+}
+
+func (s *SQLiteStore) MarkAllNotificationsRead(ctx context.Context, userID string, before time.Time) (int, error) {
+	result, err := s.db.ExecContext(ctx, `UPDATE notifications SET read = 1
+		WHERE user_id = ? AND read = 0 AND created_at <= ?`, userID, before.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	marked, err := result.RowsAffected()
+	return int(marked), err
+}
+
+func (s *SQLiteStore) CountUnreadNotifications(ctx context.Context, userID string) (int, error) {
+	var unread int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM notifications WHERE user_id = ? AND read = 0`,
+		userID).Scan(&unread)
+	return unread, err
+}
+
+const preferenceColumns = `user_id, channels, muted_event_types, muted_authors, quiet_start, quiet_end, time_zone, updated_at`
+
+func (s *SQLiteStore) GetPreferences(ctx context.Context, userID string) (*models.NotificationPreferences, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+preferenceColumns+` FROM notification_preferences WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+	preferences, err := scanPreferences(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(preferences) == 0 {
+		return nil, ErrNotFound
+	}
+	return preferences[0], nil
+}
+
+func (s *SQLiteStore) ListPreferences(ctx context.Context, userIDs []string) (map[string]*models.NotificationPreferences, error) {
+	result := make(map[string]*models.NotificationPreferences)
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+
+	args := make([]any, 0, len(userIDs))
+	for _, id := range userIDs {
+		args = append(args, id)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(userIDs)), ",")
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+preferenceColumns+` FROM notification_preferences
+		WHERE user_id IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	preferences, err := scanPreferences(rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range preferences {
+		result[p.UserID] = p
+	}
+	return result, nil
+}
+
+func (s *SQLiteStore) SavePreferences(ctx context.Context, preferences *models.NotificationPreferences) error {
+	channels, err := encodeList(preferences.Channels)
+	if err != nil {
+		return err
+	}
+	mutedEventTypes, err := encodeList(preferences.MutedEventTypes)
+	if err != nil {
+		return err
+	}
+	mutedAuthors, err := encodeList(preferences.MutedAuthors)
+	if err != nil {
+		return err
+	}
+	var quietHours models.QuietHours
+	if preferences.QuietHours != nil {
+		quietHours = *preferences.QuietHours
+	}
+
+	_, err = s.db.ExecContext(ctx, `INSERT OR REPLACE INTO notification_preferences (`+preferenceColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		preferences.UserID, channels, mutedEventTypes, mutedAuthors,
+		quietHours.Start, quietHours.End, quietHours.TimeZone, preferences.UpdatedAt.UnixNano())
+	return err
+}
+
+func scanPreferences(rows *sql.Rows) ([]*models.NotificationPreferences, error) {
+	defer rows.Close()
+
+	var result []*models.NotificationPreferences
+	for rows.Next() {
+		var p models.NotificationPreferences
+		var channels, mutedEventTypes, mutedAuthors string
+		var quietHours models.QuietHours
+		var updatedAt int64
+		if err := rows.Scan(&p.UserID, &channels, &mutedEventTypes, &mutedAuthors,
+			&quietHours.Start, &quietHours.End, &quietHours.TimeZone, &updatedAt); err != nil {
+			return nil, err
+		}
+
+		var err error
+		if p.Channels, err = decodeList[models.Channel](channels, "channels"); err != nil {
+			return nil, err
+		}
+		if p.MutedEventTypes, err = decodeList[models.EventType](mutedEventTypes, "muted event types"); err != nil {
+			return nil, err
+		}
+		if p.MutedAuthors, err = decodeList[string](mutedAuthors, "muted authors"); err != nil {
+			return nil, err
+		}
+		if quietHours.Start != "" {
+			p.QuietHours = &quietHours
+		}
+		p.UpdatedAt = time.Unix(0, updatedAt)
+		result = append(result, &p)
+	}
+	return result, rows.Err()
+}
+
+const fanoutColumns = `id, post_id, author_id, status, cursor, total_followers, processed, queued, skipped,
+	turned_away, error, created_at, updated_at, completed_at, owner, lease_until`
+
+func (s *SQLiteStore) SaveFanout(ctx context.Context, job *models.FanoutJob) error {
+	return saveFanout(ctx, s.db, job)
+}
+
+func (s *SQLiteStore) CreatePostWithFanout(ctx context.Context, post *models.Post, job *models.FanoutJob) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO posts (id, user_id, content, created_at) VALUES (?, ?, ?, ?)`,
+		post.ID, post.UserID, post.Content, post.CreatedAt.UnixNano()); err != nil {
+		return err
+	}
+	if err := saveFanout(ctx, tx, job); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// execer is a *sql.DB or a *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// saveFanout stores a fan-out job through the database or a transaction
+func saveFanout(ctx context.Context, db execer, job *models.FanoutJob) error {
+	var leaseUntil int64
+	if !job.LeaseUntil.IsZero() {
+		leaseUntil = job.LeaseUntil.UnixNano()
+	}
+	_, err := db.ExecContext(ctx, `INSERT OR REPLACE INTO fanout_jobs (`+fanoutColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		job.ID, job.PostID, job.AuthorID, string(job.Status), job.Cursor, job.TotalFollowers,
+		job.Processed, job.Queued, job.Skipped, job.TurnedAway, job.Error, job.CreatedAt.UnixNano(), job.UpdatedAt.UnixNano(),
+		nullableTime(job.CompletedAt), job.Owner, leaseUntil)
+	return err
+}
+
+func (s *SQLiteStore) GetFanout(ctx context.Context, id string) (*models.FanoutJob, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+fanoutColumns+` FROM fanout_jobs WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := scanFanouts(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, ErrNotFound
+	}
+	return jobs[0], nil
+}
+
+func (s *SQLiteStore) ListUnfinishedFanouts(ctx context.Context) ([]*models.FanoutJob, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+fanoutColumns+` FROM fanout_jobs
+		WHERE status IN (?, ?) ORDER BY created_at, id`,
+		string(models.FanoutStatusPending), string(models.FanoutStatusRunning))
+	if err != nil {
+		return nil, err
+	}
+	return scanFanouts(rows)
+}
+
+func (s *SQLiteStore) ClaimFanouts(ctx context.Context, owner string, now, until time.Time) ([]*models.FanoutJob, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT `+fanoutColumns+` FROM fanout_jobs
+		WHERE status IN (?, ?) AND lease_until <= ? ORDER BY created_at, id`,
+		string(models.FanoutStatusPending), string(models.FanoutStatusRunning), now.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := scanFanouts(rows)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE fanout_jobs SET owner = ?, lease_until = ?
+		WHERE status IN (?, ?) AND lease_until <= ?`,
+		owner, until.UnixNano(), string(models.FanoutStatusPending), string(models.FanoutStatusRunning),
+		now.UnixNano()); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	for _, job := range jobs {
+		job.Owner = owner
+		job.LeaseUntil = until
+	}
+	return jobs, nil
+}
+
+func (s *SQLiteStore) RenewFanoutLeases(ctx context.Context, owner string, until time.Time) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE fanout_jobs SET lease_until = ? WHERE owner = ?`, until.UnixNano(), owner)
+	return err
+}
+
+func scanFanouts(rows *sql.Rows) ([]*models.FanoutJob, error) {
+	defer rows.Close()
+
+	var jobs []*models.FanoutJob
+	for rows.Next() {
+		var job models.FanoutJob
+		var status string
+		var createdAt, updatedAt int64
+		var completedAt sql.NullInt64
+		var leaseUntil int64
+		if err := rows.Scan(&job.ID, &job.PostID, &job.AuthorID, &status, &job.Cursor, &job.TotalFollowers,
+			&job.Processed, &job.Queued, &job.Skipped, &job.TurnedAway, &job.Error, &createdAt, &updatedAt, &completedAt,
+			&job.Owner, &leaseUntil); err != nil {
+			return nil, err
+		}
+		job.Status = models.FanoutStatus(status)
+		if leaseUntil > 0 {
+			job.LeaseUntil = time.Unix(0, leaseUntil)
+		}
+		job.CreatedAt = time.Unix(0, createdAt)
+		job.UpdatedAt = time.Unix(0, updatedAt)
+		job.CompletedAt = timeFromNullable(completedAt)
+		jobs = append(jobs, &job)
+	}
+	return jobs, rows.Err()
+}
+
+const idempotencyKeyColumns = `user_id, key, request_hash, response, created_at, expires_at`
+
+func (s *SQLiteStore) ClaimIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) (*models.IdempotencyKey, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= ?`, key.CreatedAt.UnixNano()); err != nil {
+		return nil, err
+	}
+	result, err := tx.ExecContext(ctx, `INSERT INTO idempotency_keys (`+idempotencyKeyColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+		key.UserID, key.Key, key.RequestHash, key.Response, key.CreatedAt.UnixNano(), key.ExpiresAt.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	if inserted, _ := result.RowsAffected(); inserted == 0 {
+		var stored models.IdempotencyKey
+		var createdAt, expiresAt int64
+		err := tx.QueryRowContext(ctx, `SELECT `+idempotencyKeyColumns+` FROM idempotency_keys
+			WHERE user_id = ? AND key = ?`, key.UserID, key.Key).
+			Scan(&stored.UserID, &stored.Key, &stored.RequestHash, &stored.Response, &createdAt, &expiresAt)
+		if err != nil {
+			return nil, err
+		}
+		stored.CreatedAt = time.Unix(0, createdAt)
+		stored.ExpiresAt = time.Unix(0, expiresAt)
+		return &stored, ErrAlreadyExists
+	}
+	return key, tx.Commit()
+}
+
+func (s *SQLiteStore) SaveIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) error {
+	_, err := s.db.ExecContext(ctx, `INSERT OR REPLACE INTO idempotency_keys (`+idempotencyKeyColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		key.UserID, key.Key, key.RequestHash, key.Response, key.CreatedAt.UnixNano(), key.ExpiresAt.UnixNano())
+	return err
+}
+
+func (s *SQLiteStore) ReleaseIdempotencyKey(ctx context.Context, userID, key string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE user_id = ? AND key = ?`, userID, key)
+	return err
+}
+
+func (s *SQLiteStore) SaveJob(ctx context.Context, job *models.NotificationJob) error {
+	var dueAt *time.Time
+	if !job.DueAt.IsZero() {
+		dueAt = &job.DueAt
+	}
+
+	attempts, err := encodeList(job.Attempts)
+	if err != nil {
+		return err
+	}
+	n := job.Notification
+	channels, deliveries, err := encodeDelivery(n)
+	if err != nil {
+		return err
+	}
+	postIDs, err := encodeList(n.PostIDs)
+	if err != nil {
+		return err
+	}
+
+	var leaseUntil int64
+	if !job.LeaseUntil.IsZero() {
+		leaseUntil = job.LeaseUntil.UnixNano()
+	}
+
+	_, err = s.db.ExecContext(ctx, `INSERT INTO notification_jobs
+		(notification_id, user_id, post_id, content, created_at, attempt, due_at, attempts, status, retry_count, last_retry,
+			channels, deliveries, author_id, event_type, post_ids, priority, max_backoff, owner, lease_until)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (notification_id) DO UPDATE
+		SET attempt = excluded.attempt, due_at = excluded.due_at, attempts = excluded.attempts,
+			status = excluded.status, retry_count = excluded.retry_count, last_retry = excluded.last_retry,
+			channels = excluded.channels, deliveries = excluded.deliveries, author_id = excluded.author_id,
+			event_type = excluded.event_type, post_ids = excluded.post_ids, priority = excluded.priority,
+			max_backoff = excluded.max_backoff, owner = excluded.owner, lease_until = excluded.lease_until`,
+		n.ID, n.UserID, n.PostID, n.Content, n.CreatedAt.UnixNano(), job.Attempt, nullableTime(dueAt), attempts,
+		string(n.Status), n.RetryCount, nullableTime(n.LastRetry), channels, deliveries,
+		n.AuthorID, string(n.EventType), postIDs, string(job.Priority), int64(job.MaxBackoff), job.Owner, leaseUntil)
+	return err
+}
+
+func (s *SQLiteStore) DeleteJob(ctx context.Context, notificationID string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM notification_jobs WHERE notification_id = ?`, notificationID)
+	return err
+}
+
+const jobColumns = `notification_id, user_id, post_id, content, created_at, attempt, due_at, attempts,
+	status, retry_count, last_retry, channels, deliveries, author_id, event_type, post_ids, priority, max_backoff, owner,
+	lease_until`
+
+func (s *SQLiteStore) GetJob(ctx context.Context, notificationID string) (*models.NotificationJob, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+jobColumns+` FROM notification_jobs WHERE notification_id = ?`, notificationID)
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := scanJobs(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, ErrNotFound
+	}
+	return jobs[0], nil
+}
+
+func (s *SQLiteStore) ListJobs(ctx context.Context) ([]*models.NotificationJob, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+jobColumns+` FROM notification_jobs ORDER BY seq`)
+	if err != nil {
+		return nil, err
+	}
+	return scanJobs(rows)
+}
+
+func (s *SQLiteStore) ClaimJobs(ctx context.Context, owner string, now, until time.Time, limit int) ([]*models.NotificationJob, error) {
+	if limit <= 0 {
+		limit = -1 // no limit
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT `+jobColumns+` FROM notification_jobs WHERE lease_until <= ? ORDER BY seq LIMIT ?`,
+		now.UnixNano(), limit)
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := scanJobs(rows)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE notification_jobs SET owner = ?, lease_until = ? WHERE notification_id IN
+		(SELECT notification_id FROM notification_jobs WHERE lease_until <= ? ORDER BY seq LIMIT ?)`,
+		owner, until.UnixNano(), now.UnixNano(), limit); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	for _, job := range jobs {
+		job.Owner = owner
+		job.LeaseUntil = until
+	}
+	return jobs, nil
+}
+
+func (s *SQLiteStore) RenewJobLeases(ctx context.Context, owner string, until time.Time) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE notification_jobs SET lease_until = ? WHERE owner = ?`, until.UnixNano(), owner)
+	return err
+}
+
+func scanJobs(rows *sql.Rows) ([]*models.NotificationJob, error) {
+	defer rows.Close()
+
+	var jobs []*models.NotificationJob
+	for rows.Next() {
+		var (
+			n                    models.Notification
+			job                  = &models.NotificationJob{Notification: &n}
+			createdAt            int64
+			dueAt, lastRetry     sql.NullInt64
+			attempts, status     string
+			channels, deliveries string
+			eventType, postIDs   string
+			priority             string
+			maxBackoff           int64
+			leaseUntil           int64
+			err                  error
+		)
+		if err := rows.Scan(&n.ID, &n.UserID, &n.PostID, &n.Content, &createdAt, &job.Attempt, &dueAt, &attempts,
+			&status, &n.RetryCount, &lastRetry, &channels, &deliveries, &n.AuthorID, &eventType, &postIDs,
+			&priority, &maxBackoff, &job.Owner, &leaseUntil); err != nil {
+			return nil, err
+		}
+		job.Priority = models.Priority(priority)
+		job.MaxBackoff = time.Duration(maxBackoff)
+		if leaseUntil > 0 {
+			job.LeaseUntil = time.Unix(0, leaseUntil)
+		}
+		n.CreatedAt = time.Unix(0, createdAt)
+		n.Status = models.NotificationStatus(status)
+		n.EventType = models.EventType(eventType)
+		if n.PostIDs, err = decodeList[string](postIDs, "post IDs"); err != nil {
+			return nil, err
+		}
+		n.LastRetry = timeFromNullable(lastRetry)
+		if dueAt.Valid {
+			job.DueAt = time.Unix(0, dueAt.Int64)
+		}
+		if job.Attempts, err = decodeList[models.DeliveryAttempt](attempts, "delivery attempts"); err != nil {
+			return nil, err
+		}
+		if err := decodeDelivery(&n, channels, deliveries); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+const deadLetterColumns = `seq, notification_id, user_id, post_id, content, created_at, status, retry_count, last_retry,
+	channels, deliveries, author_id, event_type, post_ids, reason, attempts, failed_at`
+
+func (s *SQLiteStore) AddDeadLetter(ctx context.Context, deadLetter *models.DeadLetter) error {
+	attempts, err := encodeList(deadLetter.Attempts)
+	if err != nil {
+		return err
+	}
+	n := deadLetter.Notification
+	channels, deliveries, err := encodeDelivery(n)
+	if err != nil {
+		return err
+	}
+	postIDs, err := encodeList(n.PostIDs)
+	if err != nil {
+		return err
+	}
+
+	// Replacing deletes the old row, so the dead letter moves to the end
+	result, err := s.db.ExecContext(ctx, `INSERT OR REPLACE INTO dead_letters
+		(notification_id, user_id, post_id, content, created_at, status, retry_count, last_retry, channels, deliveries,
+			author_id, event_type, post_ids, reason, attempts, failed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		n.ID, n.UserID, n.PostID, n.Content, n.CreatedAt.UnixNano(),
+		string(n.Status), n.RetryCount, nullableTime(n.LastRetry), channels, deliveries,
+		n.AuthorID, string(n.EventType), postIDs, deadLetter.Reason, attempts, deadLetter.FailedAt.UnixNano())
+	if err != nil {
+		return err
+	}
+	deadLetter.Seq, err = result.LastInsertId()
+	return err
+}
+
+func (s *SQLiteStore) GetDeadLetter(ctx context.Context, notificationID string) (*models.DeadLetter, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+deadLetterColumns+` FROM dead_letters WHERE notification_id = ?`,
+		notificationID)
+	if err != nil {
+		return nil, err
+	}
+	deadLetters, err := scanDeadLetters(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(deadLetters) == 0 {
+		return nil, ErrNotFound
+	}
+	return deadLetters[0], nil
+}
+
+func (s *SQLiteStore) ListDeadLetters(ctx context.Context, afterSeq int64, limit int) ([]*models.DeadLetter, error) {
+	if limit <= 0 {
+		limit = -1 // no limit
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT `+deadLetterColumns+` FROM dead_letters
+		WHERE seq > ? ORDER BY seq LIMIT ?`, afterSeq, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanDeadLetters(rows)
+}
+
+func (s *SQLiteStore) CountDeadLetters(ctx context.Context) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM dead_letters`).Scan(&count)
+	return count, err
+}
+
+func (s *SQLiteStore) LastDeadLetterSeq(ctx context.Context) (int64, error) {
+	var seq int64
+	err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(seq), 0) FROM dead_letters`).Scan(&seq)
+	return seq, err
+}
+
+func (s *SQLiteStore) DeleteDeadLetter(ctx context.Context, notificationID string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM dead_letters WHERE notification_id = ?`, notificationID)
+	if err != nil {
+		return err
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStore) PurgeDeadLetters(ctx context.Context, before time.Time) (int, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM dead_letters WHERE failed_at <= ?`, before.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	purged, err := result.RowsAffected()
+	return int(purged), err
+}
+
+func scanDeadLetters(rows *sql.Rows) ([]*models.DeadLetter, error) {
+	defer rows.Close()
+
+	var deadLetters []*models.DeadLetter
+	for rows.Next() {
+		var (
+			n                    models.Notification
+			deadLetter           = &models.DeadLetter{Notification: &n}
+			createdAt, failedAt  int64
+			lastRetry            sql.NullInt64
+			status, attempts     string
+			channels, deliveries string
+			eventType, postIDs   string
+		)
+		err := rows.Scan(&deadLetter.Seq, &n.ID, &n.UserID, &n.PostID, &n.Content, &createdAt,
+			&status, &n.RetryCount, &lastRetry, &channels, &deliveries, &n.AuthorID, &eventType, &postIDs,
+			&deadLetter.Reason, &attempts, &failedAt)
+		if err != nil {
+			return nil, err
+		}
+		n.CreatedAt = time.Unix(0, createdAt)
+		n.Status = models.NotificationStatus(status)
+		n.EventType = models.EventType(eventType)
+		if n.PostIDs, err = decodeList[string](postIDs, "post IDs"); err != nil {
+			return nil, err
+		}
+		n.LastRetry = timeFromNullable(lastRetry)
+		deadLetter.FailedAt = time.Unix(0, failedAt)
+		if deadLetter.Attempts, err = decodeList[models.DeliveryAttempt](attempts, "delivery attempts"); err != nil {
+			return nil, err
+		}
+		if err := decodeDelivery(&n, channels, deliveries); err != nil {
+			return nil, err
+		}
+		deadLetters = append(deadLetters, deadLetter)
+	}
+	return deadLetters, rows.Err()
+}
+
+// encodeList stores a list as a JSON array
+func encodeList[T any](list []T) (string, error) {
+	if list == nil {
+		list = []T{}
+	}
+	encoded, err := json.Marshal(list)
+	return string(encoded), err
+}
+
+// decodeList reads a JSON array written by encodeList, nil when it is empty
+func decodeList[T any](encoded, what string) ([]T, error) {
+	var list []T
+	if err := json.Unmarshal([]byte(encoded), &list); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", what, err)
+	}
+	if len(list) == 0 {
+		return nil, nil
+	}
+	return list, nil
+}
+
+// encodeDelivery encodes the channels of a notification and their outcomes
+func encodeDelivery(n *models.Notification) (channels, deliveries string, err error) {
+	if channels, err = encodeList(n.Channels); err != nil {
+		return "", "", err
+	}
+	deliveries, err = encodeList(n.Deliveries)
+	return channels, deliveries, err
+}
+
+func decodeDelivery(n *models.Notification, channels, deliveries string) error {
+	var err error
+	if n.Channels, err = decodeList[models.Channel](channels, "channels"); err != nil {
+		return err
+	}
+	n.Deliveries, err = decodeList[models.ChannelDelivery](deliveries, "channel deliveries")
+	return err
+}
+
+func (s *SQLiteStore) GetMetrics(ctx context.Context) (*models.NotificationMetrics, error) {
+	metrics := &models.NotificationMetrics{}
+	err := s.db.QueryRowContext(ctx, `SELECT total_notifications_sent, failed_attempts, average_delivery_time
+		FROM metrics WHERE id = 1`).Scan(&metrics.TotalNotificationsSent, &metrics.FailedAttempts, &metrics.AverageDeliveryTime)
+	if err != nil {
+		return nil, err
+	}
+	return metrics, nil
+}
+
+func (s *SQLiteStore) RecordDelivery(ctx context.Context, deliveryTime time.Duration) error {
+	// The running average is computed in a single statement so concurrent
+	// writers from other processes cannot interleave between read and write
+	_, err := s.db.ExecContext(ctx, `UPDATE metrics SET
+			average_delivery_time = CASE
+				WHEN total_notifications_sent = 0 THEN ?1
+				ELSE (average_delivery_time * total_notifications_sent + ?1) / (total_notifications_sent + 1)
+			END,
+			total_notifications_sent = total_notifications_sent + 1
+		WHERE id = 1`, float64(deliveryTime))
+	return err
+}
+
+func (s *SQLiteStore) RecordFailure(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE metrics SET failed_attempts = failed_attempts + 1 WHERE id = 1`)
+	return err
+}
+
+// translateError maps SQLite constraint violations to storage errors
+func translateError(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrConstraint {
+		return ErrAlreadyExists
+	}
+	return err
+}
+
+func nullableTime(t *time.Time) sql.NullInt64 {
+	if t == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: t.UnixNano(), Valid: true}
+}
+
+func timeFromNullable(v sql.NullInt64) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	t := time.Unix(0, v.Int64)
+	return &t
+}
+
+func (s *SQLiteStore) Seeded(ctx context.Context, name string) (bool, error) {
+	var seeded bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM seeds WHERE name = ?)`, name).Scan(&seeded)
+	return seeded, err
+}
+
+func (s *SQLiteStore) MarkSeeded(ctx context.Context, name string) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO seeds (name, seeded_at) VALUES (?, ?) ON CONFLICT DO NOTHING`,
+		name, time.Now().UnixNano())
+	return err
+}
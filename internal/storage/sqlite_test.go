@@ -0,0 +1,115 @@
+package storage_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+	"github.com/iwhitebird/social-app-microservices/internal/storage"
+	"github.com/iwhitebird/social-app-microservices/internal/storage/storagetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSQLiteStore(t *testing.T, path string) *storage.SQLiteStore {
+	store, err := storage.NewSQLiteStore(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestSQLiteStore(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Store {
+		return newSQLiteStore(t, filepath.Join(t.TempDir(), "test.db"))
+	})
+}
+
+func TestSQLiteStorePersistsAcrossRestarts(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "nested", "social.db")
+
+	store, err := storage.NewSQLiteStore(path)
+	require.NoError(t, err)
+
+	require.NoError(t, storage.InitSampleData(ctx, store))
+	deliveredAt := time.Now()
+	require.NoError(t, store.AddNotification(ctx, &models.Notification{
+		ID:          "n1",
+		UserID:      "u2",
+		PostID:      "p1",
+		Content:     "alice posted",
+		CreatedAt:   deliveredAt.Add(-time.Second),
+		Status:      models.NotificationStatusDelivered,
+		RetryCount:  2,
+		DeliveredAt: &deliveredAt,
+	}))
+	require.NoError(t, store.RecordDelivery(ctx, 10*time.Millisecond))
+	require.NoError(t, store.Close())
+
+	// Reopening runs the migrations again, which must be a no-op
+	reopened := newSQLiteStore(t, path)
+	require.NoError(t, storage.InitSampleData(ctx, reopened))
+
+	followers, err := reopened.ListFollowers(ctx, "u1", "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"u2", "u3", "u4", "u5"}, followers)
+
+	post, err := reopened.GetPost(ctx, "p1")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello from Alice!", post.Content)
+
+	notifications, err := reopened.ListNotifications(ctx, "u2", 0)
+	require.NoError(t, err)
+	require.Len(t, notifications, 1)
+	assert.Equal(t, models.NotificationStatusDelivered, notifications[0].Status)
+	assert.Equal(t, 2, notifications[0].RetryCount)
+	require.NotNil(t, notifications[0].DeliveredAt)
+	assert.True(t, deliveredAt.Equal(*notifications[0].DeliveredAt))
+	assert.Nil(t, notifications[0].LastRetry)
+
+	metrics, err := reopened.GetMetrics(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, metrics.TotalNotificationsSent)
+
+	// Sample users deleted since are not seeded again
+	require.NoError(t, reopened.DeleteUser(ctx, "u1"))
+	require.NoError(t, reopened.CreateUser(ctx, &models.User{ID: "u6", Username: "alice"}))
+	require.NoError(t, storage.InitSampleData(ctx, reopened))
+	_, err = reopened.GetUser(ctx, "u1")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestSQLiteStoreSharedBetweenHandles(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "shared.db")
+
+	// Two handles on one file behave like two processes on one host
+	first := newSQLiteStore(t, path)
+	second := newSQLiteStore(t, path)
+
+	require.NoError(t, first.CreateUser(ctx, &models.User{ID: "u1", Username: "alice"}))
+	user, err := second.GetUser(ctx, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.Username)
+
+	done := make(chan error, 2)
+	for _, store := range []storage.Store{first, second} {
+		go func(store storage.Store) {
+			for i := 0; i < 50; i++ {
+				if err := store.RecordFailure(ctx); err != nil {
+					done <- err
+					return
+				}
+			}
+			done <- nil
+		}(store)
+	}
+	require.NoError(t, <-done)
+	require.NoError(t, <-done)
+
+	metrics, err := first.GetMetrics(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 100, metrics.FailedAttempts)
+}
@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+)
+
+var (
+	// ErrNotFound is returned when the requested record does not exist
+	ErrNotFound = errors.New("storage: not found")
+	// ErrAlreadyExists is returned when a record would violate a uniqueness constraint
+	ErrAlreadyExists = errors.New("storage: already exists")
+)
+
+// UserRepository stores user accounts. Usernames are unique, ignoring case.
+type UserRepository interface {
+	// CreateUser fails with ErrAlreadyExists if the ID or username is taken
+	CreateUser(ctx context.Context, user *models.User) error
+	GetUser(ctx context.Context, id string) (*models.User, error)
+	GetUserByUsername(ctx context.Context, username string) (*models.User, error)
+	// UpdateUser replaces an existing user. It fails with ErrAlreadyExists if
+	// the new username belongs to another user.
+	UpdateUser(ctx context.Context, user *models.User) error
+	// DeleteUser removes the user together with every follow edge it is part
+	// of and their notification preferences
+	DeleteUser(ctx context.Context, id string) error
+}
+
+// FollowRepository stores the follow graph, indexed in both directions.
+// Listings are ordered by user ID so they can be paged with the last ID seen.
+type FollowRepository interface {
+	// Follow is idempotent, following someone twice keeps a single edge
+	Follow(ctx context.Context, followerID, followeeID string) error
+	// Unfollow fails with ErrNotFound if followerID does not follow followeeID
+	Unfollow(ctx context.Context, followerID, followeeID string) error
+	IsFollowing(ctx context.Context, followerID, followeeID string) (bool, error)
+	// ListFollowers returns up to limit IDs of users following userID that
+	// sort after the given ID. A limit <= 0 returns all of them.
+	ListFollowers(ctx context.Context, userID, after string, limit int) ([]string, error)
+	// ListFollowing returns up to limit IDs of users that userID follows
+	// that sort after the given ID. A limit <= 0 returns all of them.
+	ListFollowing(ctx context.Context, userID, after string, limit int) ([]string, error)
+	CountFollowers(ctx context.Context, userID string) (int, error)
+	CountFollowing(ctx context.Context, userID string) (int, error)
+}
+
+// PostRepository stores published posts, keyed by post ID and indexed by author
+type PostRepository interface {
+	SavePost(ctx context.Context, post *models.Post) error
+	GetPost(ctx context.Context, id string) (*models.Post, error)
+	// ListPostsByUser returns the posts of an author, newest first. A limit
+	// <= 0 returns all of them.
+	ListPostsByUser(ctx context.Context, userID string, limit int) ([]*models.Post, error)
+}
+
+// NotificationRepository stores delivered notifications per user
+type NotificationRepository interface {
+	// AddNotification stores a notification and assigns its Seq. It fails with
+	// ErrAlreadyExists if a notification with the same ID was stored before.
+	AddNotification(ctx context.Context, notification *models.Notification) error
+	// UpdateNotificationDelivery saves the delivery state of a stored
+	// notification: its status, retry count and times, and channel
+	// deliveries. It fails with ErrNotFound if the notification is not stored.
+	UpdateNotificationDelivery(ctx context.Context, notification *models.Notification) error
+	// ListNotifications returns the most recent notifications of a user in the
+	// order they were added. A limit <= 0 returns all of them.
+	ListNotifications(ctx context.Context, userID string, limit int) ([]*models.Notification, error)
+	// QueryNotifications returns the notifications of a user that match the
+	// query, newest first
+	QueryNotifications(ctx context.Context, userID string, query NotificationQuery) ([]*models.Notification, error)
+	// MarkNotificationRead marks one notification of a user as read. Marking it
+	// again is a no-op; ErrNotFound means the user has no such notification.
+	MarkNotificationRead(ctx context.Context, userID, notificationID string) error
+	// MarkNotificationsRead marks notifications of a user as read and returns
+	// how many of them were unread. IDs the user does not own are ignored.
+	MarkNotificationsRead(ctx context.Context, userID string, notificationIDs []string) (int, error)
+	// MarkAllNotificationsRead marks every notification of a user created at
+	// or before the given time as read and returns how many were unread.
+	MarkAllNotificationsRead(ctx context.Context, userID string, before time.Time) (int, error)
+	CountUnreadNotifications(ctx context.Context, userID string) (int, error)
+}
+
+// NotificationQuery selects a page of a user's notifications. Zero values
+// leave the corresponding filter out.
+type NotificationQuery struct {
+	// BeforeSeq only returns notifications older than this feed position
+	BeforeSeq int64
+	// AfterSeq only returns notifications newer than this feed position
+	AfterSeq int64
+	// UnreadOnly skips notifications that have been read
+	UnreadOnly bool
+	// Since and Until bound the creation time to [Since, Until)
+	Since time.Time
+	Until time.Time
+	// Limit caps the number of results, <= 0 returns all of them
+	Limit int
+	// OldestFirst returns the oldest matching notifications first instead
+	// of the newest, to page forward from AfterSeq
+	OldestFirst bool
+}
+
+// PreferenceRepository stores the notification preferences of users
+type PreferenceRepository interface {
+	// GetPreferences fails with ErrNotFound if the user never saved any
+	GetPreferences(ctx context.Context, userID string) (*models.NotificationPreferences, error)
+	// ListPreferences returns the saved preferences of the given users by
+	// user ID. Users that never saved any are left out.
+	ListPreferences(ctx context.Context, userIDs []string) (map[string]*models.NotificationPreferences, error)
+	// SavePreferences replaces the preferences of a user
+	SavePreferences(ctx context.Context, preferences *models.NotificationPreferences) error
+}
+
+// FanoutRepository keeps the fan-out jobs of published posts and their
+// checkpoints
+type FanoutRepository interface {
+	// SaveFanout stores a fan-out job, replacing the one with the same ID
+	SaveFanout(ctx context.Context, job *models.FanoutJob) error
+	// CreatePostWithFanout stores a new post together with its fan-out job.
+	// Either both are stored or neither is.
+	CreatePostWithFanout(ctx context.Context, post *models.Post, job *models.FanoutJob) error
+	// GetFanout fails with ErrNotFound if there is no such job
+	GetFanout(ctx context.Context, id string) (*models.FanoutJob, error)
+	// ListUnfinishedFanouts returns the pending and running jobs, oldest first
+	ListUnfinishedFanouts(ctx context.Context) ([]*models.FanoutJob, error)
+	// ClaimFanouts leases the unfinished jobs whose lease ran out by now to
+	// owner until the given time and returns them, oldest first
+	ClaimFanouts(ctx context.Context, owner string, now, until time.Time) ([]*models.FanoutJob, error)
+	// RenewFanoutLeases moves the end of the leases owner holds to until. A
+	// time in the past releases them.
+	RenewFanoutLeases(ctx context.Context, owner string, until time.Time) error
+}
+
+// IdempotencyRepository remembers the requests clients sent with an
+// idempotency key until the key expires. Expired keys count as unused.
+type IdempotencyRepository interface {
+	// ClaimIdempotencyKey stores a key that is not in use, taking the key's
+	// CreatedAt as the current time. If the key is in use, it fails with
+	// ErrAlreadyExists and returns the stored one.
+	ClaimIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) (*models.IdempotencyKey, error)
+	// SaveIdempotencyKey replaces a stored key, e.g. to add the response
+	SaveIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) error
+	// ReleaseIdempotencyKey deletes a key, so that a request which failed can
+	// be retried with it. Releasing a key that is not stored is a no-op.
+	ReleaseIdempotencyKey(ctx context.Context, userID, key string) error
+}
+
+// JobRepository persists the notification queue. A job is saved when its
+// notification is enqueued and deleted once the queue is done with it, so
+// the jobs left over from a previous run can be replayed.
+type JobRepository interface {
+	// SaveJob stores a job, replacing the one of the same notification
+	SaveJob(ctx context.Context, job *models.NotificationJob) error
+	// DeleteJob removes the job of a notification. Deleting a job that does
+	// not exist is a no-op.
+	DeleteJob(ctx context.Context, notificationID string) error
+	// GetJob returns the job of a notification, or ErrNotFound
+	GetJob(ctx context.Context, notificationID string) (*models.NotificationJob, error)
+	// ListJobs returns every stored job in the order they were first saved
+	ListJobs(ctx context.Context) ([]*models.NotificationJob, error)
+	// ClaimJobs leases up to limit of the jobs whose lease ran out by now to
+	// owner until the given time and returns them in the order they were
+	// first saved. Claiming again returns the next ones. A limit <= 0 claims
+	// all of them.
+	ClaimJobs(ctx context.Context, owner string, now, until time.Time, limit int) ([]*models.NotificationJob, error)
+	// RenewJobLeases moves the end of the leases owner holds to until. A
+	// time in the past releases them.
+	RenewJobLeases(ctx context.Context, owner string, until time.Time) error
+}
+
+// DeadLetterRepository keeps the notifications the queue gave up on, so they
+// can be inspected and replayed. Listings are ordered by Seq, i.e. by when
+// the notifications were dead-lettered.
+type DeadLetterRepository interface {
+	// AddDeadLetter stores a dead letter and assigns its Seq. It replaces an
+	// earlier dead letter of the same notification.
+	AddDeadLetter(ctx context.Context, deadLetter *models.DeadLetter) error
+	// GetDeadLetter fails with ErrNotFound if the notification is not dead-lettered
+	GetDeadLetter(ctx context.Context, notificationID string) (*models.DeadLetter, error)
+	// ListDeadLetters returns up to limit dead letters with a Seq greater than
+	// afterSeq. A limit <= 0 returns all of them.
+	ListDeadLetters(ctx context.Context, afterSeq int64, limit int) ([]*models.DeadLetter, error)
+	CountDeadLetters(ctx context.Context) (int, error)
+	// LastDeadLetterSeq returns the highest Seq of the stored dead letters,
+	// or 0 if there are none
+	LastDeadLetterSeq(ctx context.Context) (int64, error)
+	// DeleteDeadLetter fails with ErrNotFound if the notification is not dead-lettered
+	DeleteDeadLetter(ctx context.Context, notificationID string) error
+	// PurgeDeadLetters deletes the dead letters that failed at or before the
+	// given time and returns how many there were
+	PurgeDeadLetters(ctx context.Context, before time.Time) (int, error)
+}
+
+// MetricsRepository keeps the notification delivery metrics
+type MetricsRepository interface {
+	GetMetrics(ctx context.Context) (*models.NotificationMetrics, error)
+	RecordDelivery(ctx context.Context, deliveryTime time.Duration) error
+	RecordFailure(ctx context.Context) error
+}
+
+// SeedRepository remembers the data sets a store was seeded with
+type SeedRepository interface {
+	// Seeded reports whether the named data set was seeded
+	Seeded(ctx context.Context, name string) (bool, error)
+	// MarkSeeded records that the named data set was seeded. Marking it
+	// again is a no-op.
+	MarkSeeded(ctx context.Context, name string) error
+}
+
+// Store groups every repository a backend has to provide
+type Store interface {
+	io.Closer
+	UserRepository
+	FollowRepository
+	PostRepository
+	NotificationRepository
+	PreferenceRepository
+	FanoutRepository
+	IdempotencyRepository
+	JobRepository
+	DeadLetterRepository
+	MetricsRepository
+	SeedRepository
+}
+
+// runningAverage folds a new delivery time into an average taken over count samples
+func runningAverage(average float64, count int, deliveryTime time.Duration) float64 {
+	if count == 0 {
+		// First successful notification
+		return float64(deliveryTime)
+	}
+	// Update running average
+	return (average*float64(count) + float64(deliveryTime)) / float64(count+1)
+}
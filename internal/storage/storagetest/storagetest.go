@@ -0,0 +1,918 @@
+// Package storagetest holds the conformance suite every storage backend must pass.
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/iwhitebird/social-app-microservices/internal/models"
+	"github.com/iwhitebird/social-app-microservices/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory returns a new, empty store for a single test
+type Factory func(t *testing.T) storage.Store
+
+// Run executes the conformance suite against the stores produced by newStore
+func Run(t *testing.T, newStore Factory) {
+	t.Run("Users", func(t *testing.T) { testUsers(t, newStore(t)) })
+	t.Run("Follows", func(t *testing.T) { testFollows(t, newStore(t)) })
+	t.Run("Posts", func(t *testing.T) { testPosts(t, newStore(t)) })
+	t.Run("Notifications", func(t *testing.T) { testNotifications(t, newStore(t)) })
+	t.Run("NotificationQuery", func(t *testing.T) { testNotificationQuery(t, newStore(t)) })
+	t.Run("ReadState", func(t *testing.T) { testReadState(t, newStore(t)) })
+	t.Run("Preferences", func(t *testing.T) { testPreferences(t, newStore(t)) })
+	t.Run("Fanouts", func(t *testing.T) { testFanouts(t, newStore(t)) })
+	t.Run("IdempotencyKeys", func(t *testing.T) { testIdempotencyKeys(t, newStore(t)) })
+	t.Run("Jobs", func(t *testing.T) { testJobs(t, newStore(t)) })
+	t.Run("DeadLetters", func(t *testing.T) { testDeadLetters(t, newStore(t)) })
+	t.Run("Metrics", func(t *testing.T) { testMetrics(t, newStore(t)) })
+	t.Run("Seeds", func(t *testing.T) { testSeeds(t, newStore(t)) })
+}
+
+func testUsers(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+
+	_, err := store.GetUser(ctx, "missing")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = store.GetUserByUsername(ctx, "missing")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	createdAt := time.Now().Truncate(time.Second)
+	user := &models.User{ID: "u1", Username: "alice", Email: "alice@example.com", CreatedAt: createdAt}
+	require.NoError(t, store.CreateUser(ctx, user))
+
+	// Changing the saved value must not leak into the store
+	user.Username = "changed"
+
+	stored, err := store.GetUser(ctx, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", stored.Username)
+	assert.Equal(t, "alice@example.com", stored.Email)
+	assert.True(t, createdAt.Equal(stored.CreatedAt))
+
+	// Usernames are looked up and kept unique regardless of case
+	stored, err = store.GetUserByUsername(ctx, "ALICE")
+	require.NoError(t, err)
+	assert.Equal(t, "u1", stored.ID)
+	assert.ErrorIs(t, store.CreateUser(ctx, &models.User{ID: "u2", Username: "Alice"}), storage.ErrAlreadyExists)
+	assert.ErrorIs(t, store.CreateUser(ctx, &models.User{ID: "u1", Username: "other"}), storage.ErrAlreadyExists)
+
+	require.NoError(t, store.CreateUser(ctx, &models.User{ID: "u2", Username: "bob"}))
+
+	// Updating frees the old username and rejects taken ones
+	require.NoError(t, store.UpdateUser(ctx, &models.User{ID: "u1", Username: "alice2", CreatedAt: createdAt}))
+	stored, err = store.GetUser(ctx, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, "alice2", stored.Username)
+	assert.Empty(t, stored.Email)
+	_, err = store.GetUserByUsername(ctx, "alice")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	assert.ErrorIs(t, store.UpdateUser(ctx, &models.User{ID: "u1", Username: "BOB"}), storage.ErrAlreadyExists)
+	assert.ErrorIs(t, store.UpdateUser(ctx, &models.User{ID: "missing", Username: "carol"}), storage.ErrNotFound)
+
+	// Deleting frees the username
+	require.NoError(t, store.DeleteUser(ctx, "u2"))
+	_, err = store.GetUser(ctx, "u2")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	assert.ErrorIs(t, store.DeleteUser(ctx, "u2"), storage.ErrNotFound)
+	require.NoError(t, store.CreateUser(ctx, &models.User{ID: "u3", Username: "bob"}))
+}
+
+func testFollows(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+
+	for _, id := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, store.CreateUser(ctx, &models.User{ID: id, Username: "user_" + id}))
+	}
+
+	// b, c and d follow a; a follows b. Following twice keeps one edge.
+	require.NoError(t, store.Follow(ctx, "d", "a"))
+	require.NoError(t, store.Follow(ctx, "b", "a"))
+	require.NoError(t, store.Follow(ctx, "c", "a"))
+	require.NoError(t, store.Follow(ctx, "c", "a"))
+	require.NoError(t, store.Follow(ctx, "a", "b"))
+
+	followers, err := store.ListFollowers(ctx, "a", "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "c", "d"}, followers)
+
+	following, err := store.ListFollowing(ctx, "c", "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, following)
+
+	count, err := store.CountFollowers(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+	count, err = store.CountFollowing(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	isFollowing, err := store.IsFollowing(ctx, "b", "a")
+	require.NoError(t, err)
+	assert.True(t, isFollowing)
+	isFollowing, err = store.IsFollowing(ctx, "a", "c")
+	require.NoError(t, err)
+	assert.False(t, isFollowing)
+
+	// Paging continues after the last ID seen
+	page, err := store.ListFollowers(ctx, "a", "", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "c"}, page)
+	page, err = store.ListFollowers(ctx, "a", "c", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"d"}, page)
+	page, err = store.ListFollowers(ctx, "a", "d", 2)
+	require.NoError(t, err)
+	assert.Empty(t, page)
+
+	// Unfollowing removes the edge from both directions
+	require.NoError(t, store.Unfollow(ctx, "c", "a"))
+	assert.ErrorIs(t, store.Unfollow(ctx, "c", "a"), storage.ErrNotFound)
+	followers, err = store.ListFollowers(ctx, "a", "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "d"}, followers)
+	following, err = store.ListFollowing(ctx, "c", "", 0)
+	require.NoError(t, err)
+	assert.Empty(t, following)
+
+	// Deleting a user removes all of its edges
+	require.NoError(t, store.DeleteUser(ctx, "b"))
+	followers, err = store.ListFollowers(ctx, "a", "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"d"}, followers)
+	following, err = store.ListFollowing(ctx, "a", "", 0)
+	require.NoError(t, err)
+	assert.Empty(t, following)
+}
+
+func testPosts(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+
+	_, err := store.GetPost(ctx, "p1")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	posts, err := store.ListPostsByUser(ctx, "u1", 0)
+	require.NoError(t, err)
+	assert.Empty(t, posts)
+
+	base := time.Now().Truncate(time.Second)
+	// Saved out of order on purpose, the author index is ordered by time
+	require.NoError(t, store.SavePost(ctx, &models.Post{ID: "p2", UserID: "u1", Content: "second", CreatedAt: base.Add(2 * time.Second)}))
+	require.NoError(t, store.SavePost(ctx, &models.Post{ID: "p1", UserID: "u1", Content: "first", CreatedAt: base.Add(time.Second)}))
+	require.NoError(t, store.SavePost(ctx, &models.Post{ID: "p3", UserID: "u1", Content: "third", CreatedAt: base.Add(3 * time.Second)}))
+	require.NoError(t, store.SavePost(ctx, &models.Post{ID: "p4", UserID: "u2", Content: "other", CreatedAt: base}))
+
+	post, err := store.GetPost(ctx, "p1")
+	require.NoError(t, err)
+	assert.Equal(t, "u1", post.UserID)
+	assert.Equal(t, "first", post.Content)
+	assert.True(t, base.Add(time.Second).Equal(post.CreatedAt))
+
+	posts, err = store.ListPostsByUser(ctx, "u1", 0)
+	require.NoError(t, err)
+	require.Len(t, posts, 3)
+	assert.Equal(t, "p3", posts[0].ID)
+	assert.Equal(t, "p2", posts[1].ID)
+	assert.Equal(t, "p1", posts[2].ID)
+
+	posts, err = store.ListPostsByUser(ctx, "u1", 2)
+	require.NoError(t, err)
+	require.Len(t, posts, 2)
+	assert.Equal(t, "p3", posts[0].ID)
+	assert.Equal(t, "p2", posts[1].ID)
+
+	// Saving an existing post updates it without duplicating it
+	require.NoError(t, store.SavePost(ctx, &models.Post{ID: "p1", UserID: "u1", Content: "edited", CreatedAt: base.Add(time.Second)}))
+	posts, err = store.ListPostsByUser(ctx, "u1", 0)
+	require.NoError(t, err)
+	require.Len(t, posts, 3)
+	assert.Equal(t, "edited", posts[2].Content)
+}
+
+func testNotifications(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+
+	notifications, err := store.ListNotifications(ctx, "u1", 0)
+	require.NoError(t, err)
+	assert.Empty(t, notifications)
+
+	base := time.Now().Truncate(time.Second)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, store.AddNotification(ctx, &models.Notification{
+			ID:        fmt.Sprintf("n%d", i),
+			UserID:    "u1",
+			PostID:    "p1",
+			Content:   fmt.Sprintf("notification %d", i),
+			CreatedAt: base.Add(time.Duration(i) * time.Second),
+			Status:    models.NotificationStatusDelivered,
+		}))
+	}
+	require.NoError(t, store.AddNotification(ctx, &models.Notification{ID: "other", UserID: "u2", CreatedAt: base}))
+
+	// Storing a notification twice, e.g. when a job is replayed, is rejected
+	err = store.AddNotification(ctx, &models.Notification{ID: "n0", UserID: "u1", CreatedAt: base})
+	assert.ErrorIs(t, err, storage.ErrAlreadyExists)
+
+	notifications, err = store.ListNotifications(ctx, "u1", 0)
+	require.NoError(t, err)
+	require.Len(t, notifications, 5)
+	for i, n := range notifications {
+		assert.Equal(t, fmt.Sprintf("n%d", i), n.ID)
+		assert.Equal(t, "u1", n.UserID)
+		assert.True(t, base.Add(time.Duration(i)*time.Second).Equal(n.CreatedAt))
+		assert.Equal(t, models.NotificationStatusDelivered, n.Status)
+	}
+
+	// A limit keeps only the most recent notifications
+	notifications, err = store.ListNotifications(ctx, "u1", 2)
+	require.NoError(t, err)
+	require.Len(t, notifications, 2)
+	assert.Equal(t, "n3", notifications[0].ID)
+	assert.Equal(t, "n4", notifications[1].ID)
+
+	// Aggregate notifications keep the posts they merge
+	require.NoError(t, store.AddNotification(ctx, &models.Notification{
+		ID:        "aggregate",
+		UserID:    "u3",
+		PostID:    "p3",
+		AuthorID:  "alice",
+		EventType: models.EventTypeNewPost,
+		PostIDs:   []string{"p1", "p2", "p3"},
+		CreatedAt: base,
+	}))
+	notifications, err = store.ListNotifications(ctx, "u3", 0)
+	require.NoError(t, err)
+	require.Len(t, notifications, 1)
+	assert.Equal(t, "alice", notifications[0].AuthorID)
+	assert.Equal(t, models.EventTypeNewPost, notifications[0].EventType)
+	assert.Equal(t, []string{"p1", "p2", "p3"}, notifications[0].PostIDs)
+	assert.Empty(t, notifications[0].Channels)
+
+	// The delivery state of a stored notification can be updated
+	deliveredAt := base.Add(time.Minute)
+	update := &models.Notification{
+		ID:          "n1",
+		UserID:      "u1",
+		Status:      models.NotificationStatusFailed,
+		RetryCount:  2,
+		LastRetry:   &deliveredAt,
+		DeliveredAt: &deliveredAt,
+		Channels:    []models.Channel{models.ChannelInApp, models.ChannelEmail},
+		Deliveries: []models.ChannelDelivery{
+			{Channel: models.ChannelInApp, Status: models.NotificationStatusDelivered, Attempts: 1, DeliveredAt: &deliveredAt},
+			{Channel: models.ChannelEmail, Status: models.NotificationStatusFailed, Attempts: 3, LastError: "mailbox full"},
+		},
+	}
+	require.NoError(t, store.UpdateNotificationDelivery(ctx, update))
+	assert.ErrorIs(t, store.UpdateNotificationDelivery(ctx, &models.Notification{ID: "missing", UserID: "u1"}), storage.ErrNotFound)
+
+	notifications, err = store.ListNotifications(ctx, "u1", 0)
+	require.NoError(t, err)
+	updated := notifications[1]
+	assert.Equal(t, "notification 1", updated.Content)
+	assert.Equal(t, models.NotificationStatusFailed, updated.Status)
+	assert.Equal(t, 2, updated.RetryCount)
+	require.NotNil(t, updated.LastRetry)
+	assert.True(t, deliveredAt.Equal(*updated.LastRetry))
+	assert.Equal(t, update.Channels, updated.Channels)
+	require.Len(t, updated.Deliveries, 2)
+	require.NotNil(t, updated.Deliveries[0].DeliveredAt)
+	assert.True(t, deliveredAt.Equal(*updated.Deliveries[0].DeliveredAt))
+	assert.Equal(t, models.ChannelEmail, updated.Deliveries[1].Channel)
+	assert.Equal(t, models.NotificationStatusFailed, updated.Deliveries[1].Status)
+	assert.Equal(t, 3, updated.Deliveries[1].Attempts)
+	assert.Equal(t, "mailbox full", updated.Deliveries[1].LastError)
+	assert.Nil(t, updated.Deliveries[1].DeliveredAt)
+	assert.Empty(t, notifications[0].Deliveries)
+}
+
+func testNotificationQuery(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+
+	base := time.Now().Truncate(time.Second)
+	var seqs []int64
+	for i := 0; i < 6; i++ {
+		notification := &models.Notification{
+			ID:        fmt.Sprintf("n%d", i),
+			UserID:    "u1",
+			CreatedAt: base.Add(time.Duration(i) * time.Second),
+			Read:      i%2 == 1,
+		}
+		require.NoError(t, store.AddNotification(ctx, notification))
+		if len(seqs) > 0 {
+			assert.Greater(t, notification.Seq, seqs[len(seqs)-1])
+		}
+		seqs = append(seqs, notification.Seq)
+	}
+	require.NoError(t, store.AddNotification(ctx, &models.Notification{ID: "other", UserID: "u2", CreatedAt: base}))
+
+	ids := func(notifications []*models.Notification) []string {
+		result := make([]string, 0, len(notifications))
+		for _, n := range notifications {
+			result = append(result, n.ID)
+		}
+		return result
+	}
+
+	// Newest first, and the stored Seq matches the assigned one
+	notifications, err := store.QueryNotifications(ctx, "u1", storage.NotificationQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"n5", "n4", "n3", "n2", "n1", "n0"}, ids(notifications))
+	assert.Equal(t, seqs[5], notifications[0].Seq)
+
+	// Paging continues below the Seq of the last result
+	notifications, err = store.QueryNotifications(ctx, "u1", storage.NotificationQuery{Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"n5", "n4"}, ids(notifications))
+
+	notifications, err = store.QueryNotifications(ctx, "u1", storage.NotificationQuery{
+		BeforeSeq: notifications[1].Seq,
+		Limit:     2,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"n3", "n2"}, ids(notifications))
+
+	// Notifications newer than a position, as used to resume a feed
+	notifications, err = store.QueryNotifications(ctx, "u1", storage.NotificationQuery{AfterSeq: seqs[3]})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"n5", "n4"}, ids(notifications))
+
+	// and paging forward from one, oldest first
+	notifications, err = store.QueryNotifications(ctx, "u1", storage.NotificationQuery{AfterSeq: seqs[1], Limit: 2, OldestFirst: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"n2", "n3"}, ids(notifications))
+
+	// Filters
+	notifications, err = store.QueryNotifications(ctx, "u1", storage.NotificationQuery{UnreadOnly: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"n4", "n2", "n0"}, ids(notifications))
+
+	notifications, err = store.QueryNotifications(ctx, "u1", storage.NotificationQuery{
+		Since: base.Add(1 * time.Second),
+		Until: base.Add(4 * time.Second),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"n3", "n2", "n1"}, ids(notifications))
+
+	notifications, err = store.QueryNotifications(ctx, "u1", storage.NotificationQuery{
+		UnreadOnly: true,
+		Since:      base.Add(1 * time.Second),
+		Limit:      1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"n4"}, ids(notifications))
+}
+
+func testReadState(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+
+	base := time.Now().Truncate(time.Second)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, store.AddNotification(ctx, &models.Notification{
+			ID:        fmt.Sprintf("n%d", i),
+			UserID:    "u1",
+			CreatedAt: base.Add(time.Duration(i) * time.Second),
+		}))
+	}
+	require.NoError(t, store.AddNotification(ctx, &models.Notification{ID: "other", UserID: "u2", CreatedAt: base}))
+
+	unread, err := store.CountUnreadNotifications(ctx, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, 5, unread)
+
+	// Marking a single notification is idempotent
+	require.NoError(t, store.MarkNotificationRead(ctx, "u1", "n0"))
+	require.NoError(t, store.MarkNotificationRead(ctx, "u1", "n0"))
+	assert.ErrorIs(t, store.MarkNotificationRead(ctx, "u1", "missing"), storage.ErrNotFound)
+	assert.ErrorIs(t, store.MarkNotificationRead(ctx, "u1", "other"), storage.ErrNotFound)
+
+	// Only unread notifications owned by the user are counted
+	marked, err := store.MarkNotificationsRead(ctx, "u1", []string{"n0", "n1", "n2", "other", "missing"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, marked)
+
+	marked, err = store.MarkNotificationsRead(ctx, "u1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, marked)
+
+	// Marking everything up to a time leaves newer notifications unread
+	marked, err = store.MarkAllNotificationsRead(ctx, "u1", base.Add(3*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 1, marked)
+
+	unread, err = store.CountUnreadNotifications(ctx, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, unread)
+
+	notifications, err := store.ListNotifications(ctx, "u1", 0)
+	require.NoError(t, err)
+	for _, n := range notifications {
+		assert.Equal(t, n.ID != "n4", n.Read, n.ID)
+	}
+
+	// Other users are not affected
+	unread, err = store.CountUnreadNotifications(ctx, "u2")
+	require.NoError(t, err)
+	assert.Equal(t, 1, unread)
+}
+
+func testPreferences(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+
+	_, err := store.GetPreferences(ctx, "u1")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	updatedAt := time.Now().Truncate(time.Second)
+	preferences := &models.NotificationPreferences{
+		UserID:          "u1",
+		Channels:        []models.Channel{models.ChannelInApp, models.ChannelEmail},
+		MutedEventTypes: []models.EventType{models.EventTypeNewPost},
+		MutedAuthors:    []string{"u3", "u4"},
+		QuietHours:      &models.QuietHours{Start: "22:00", End: "07:00", TimeZone: "Europe/Berlin"},
+		UpdatedAt:       updatedAt,
+	}
+	require.NoError(t, store.SavePreferences(ctx, preferences))
+	require.NoError(t, store.SavePreferences(ctx, &models.NotificationPreferences{UserID: "u2", UpdatedAt: updatedAt}))
+
+	// Changing the saved value must not leak into the store
+	preferences.MutedAuthors[0] = "changed"
+	preferences.QuietHours.Start = "changed"
+
+	stored, err := store.GetPreferences(ctx, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, []models.Channel{models.ChannelInApp, models.ChannelEmail}, stored.Channels)
+	assert.Equal(t, []models.EventType{models.EventTypeNewPost}, stored.MutedEventTypes)
+	assert.Equal(t, []string{"u3", "u4"}, stored.MutedAuthors)
+	assert.Equal(t, &models.QuietHours{Start: "22:00", End: "07:00", TimeZone: "Europe/Berlin"}, stored.QuietHours)
+	assert.True(t, updatedAt.Equal(stored.UpdatedAt))
+
+	stored, err = store.GetPreferences(ctx, "u2")
+	require.NoError(t, err)
+	assert.Empty(t, stored.Channels)
+	assert.Empty(t, stored.MutedAuthors)
+	assert.Nil(t, stored.QuietHours)
+
+	// Users without preferences are left out of listings
+	listed, err := store.ListPreferences(ctx, []string{"u1", "u2", "missing"})
+	require.NoError(t, err)
+	assert.Len(t, listed, 2)
+	assert.Equal(t, []string{"u3", "u4"}, listed["u1"].MutedAuthors)
+	listed, err = store.ListPreferences(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, listed)
+
+	// Saving replaces everything
+	require.NoError(t, store.SavePreferences(ctx, &models.NotificationPreferences{UserID: "u1", UpdatedAt: updatedAt}))
+	stored, err = store.GetPreferences(ctx, "u1")
+	require.NoError(t, err)
+	assert.Empty(t, stored.Channels)
+	assert.Empty(t, stored.MutedEventTypes)
+	assert.Empty(t, stored.MutedAuthors)
+	assert.Nil(t, stored.QuietHours)
+
+	// Deleting a user deletes their preferences
+	require.NoError(t, store.CreateUser(ctx, &models.User{ID: "u2", Username: "bob"}))
+	require.NoError(t, store.DeleteUser(ctx, "u2"))
+	_, err = store.GetPreferences(ctx, "u2")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testFanouts(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+
+	_, err := store.GetFanout(ctx, "missing")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	base := time.Now().Truncate(time.Second)
+	for i, status := range []models.FanoutStatus{
+		models.FanoutStatusRunning,
+		models.FanoutStatusCompleted,
+		models.FanoutStatusPending,
+		models.FanoutStatusFailed,
+	} {
+		require.NoError(t, store.SaveFanout(ctx, &models.FanoutJob{
+			ID:             fmt.Sprintf("f%d", i),
+			PostID:         "p1",
+			AuthorID:       "u1",
+			Status:         status,
+			TotalFollowers: 10,
+			CreatedAt:      base.Add(time.Duration(i) * time.Second),
+			UpdatedAt:      base,
+		}))
+	}
+
+	// Only pending and running jobs are unfinished, oldest first
+	unfinished, err := store.ListUnfinishedFanouts(ctx)
+	require.NoError(t, err)
+	require.Len(t, unfinished, 2)
+	assert.Equal(t, "f0", unfinished[0].ID)
+	assert.Equal(t, "f2", unfinished[1].ID)
+
+	// Saving a checkpoint replaces the job
+	completedAt := base.Add(time.Minute)
+	job := &models.FanoutJob{
+		ID:             "f0",
+		PostID:         "p1",
+		AuthorID:       "u1",
+		Status:         models.FanoutStatusCompleted,
+		Cursor:         "u9",
+		TotalFollowers: 10,
+		Processed:      10,
+		Queued:         8,
+		Skipped:        2,
+		TurnedAway:     3,
+		CreatedAt:      base,
+		UpdatedAt:      completedAt,
+		CompletedAt:    &completedAt,
+	}
+	require.NoError(t, store.SaveFanout(ctx, job))
+	stored, err := store.GetFanout(ctx, "f0")
+	require.NoError(t, err)
+	assert.Equal(t, models.FanoutStatusCompleted, stored.Status)
+	assert.Equal(t, "u9", stored.Cursor)
+	assert.Equal(t, []int{10, 10, 8, 2, 3}, []int{stored.TotalFollowers, stored.Processed, stored.Queued, stored.Skipped, stored.TurnedAway})
+	assert.True(t, base.Equal(stored.CreatedAt))
+	assert.True(t, completedAt.Equal(stored.UpdatedAt))
+	require.NotNil(t, stored.CompletedAt)
+	assert.True(t, completedAt.Equal(*stored.CompletedAt))
+
+	unfinished, err = store.ListUnfinishedFanouts(ctx)
+	require.NoError(t, err)
+	require.Len(t, unfinished, 1)
+	assert.Equal(t, "f2", unfinished[0].ID)
+
+	// A failed job keeps its error
+	require.NoError(t, store.SaveFanout(ctx, &models.FanoutJob{ID: "f3", Status: models.FanoutStatusFailed, Error: "boom", CreatedAt: base, UpdatedAt: base}))
+	stored, err = store.GetFanout(ctx, "f3")
+	require.NoError(t, err)
+	assert.Equal(t, "boom", stored.Error)
+	assert.Nil(t, stored.CompletedAt)
+
+	// A post can be stored along with its job
+	post := &models.Post{ID: "p2", UserID: "u1", Content: "hello", CreatedAt: base}
+	require.NoError(t, store.CreatePostWithFanout(ctx, post, &models.FanoutJob{
+		ID: "f4", PostID: "p2", AuthorID: "u1", Status: models.FanoutStatusPending, CreatedAt: base, UpdatedAt: base,
+	}))
+	storedPost, err := store.GetPost(ctx, "p2")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", storedPost.Content)
+	stored, err = store.GetFanout(ctx, "f4")
+	require.NoError(t, err)
+	assert.Equal(t, "p2", stored.PostID)
+
+	// Unfinished jobs without a lease go to the first dispatcher to claim them
+	now := time.Now()
+	claimed, err := store.ClaimFanouts(ctx, "d1", now, now.Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, claimed, 2)
+	assert.Equal(t, "f4", claimed[0].ID)
+	assert.Equal(t, "f2", claimed[1].ID)
+	assert.Equal(t, "d1", claimed[0].Owner)
+	assert.True(t, now.Add(time.Minute).Equal(claimed[0].LeaseUntil))
+	claimed, err = store.ClaimFanouts(ctx, "d2", now, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, claimed)
+
+	// Checkpoints keep the lease
+	claimed, err = store.ClaimFanouts(ctx, "d2", now.Add(time.Minute), now.Add(2*time.Minute))
+	require.NoError(t, err)
+	require.Len(t, claimed, 2)
+	require.NoError(t, store.SaveFanout(ctx, claimed[0]))
+	stored, err = store.GetFanout(ctx, "f4")
+	require.NoError(t, err)
+	assert.Equal(t, "d2", stored.Owner)
+	assert.True(t, now.Add(2*time.Minute).Equal(stored.LeaseUntil))
+
+	// Released leases can be claimed right away
+	require.NoError(t, store.RenewFanoutLeases(ctx, "d2", now))
+	claimed, err = store.ClaimFanouts(ctx, "d3", now, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Len(t, claimed, 2)
+}
+
+func testIdempotencyKeys(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+
+	now := time.Now().Truncate(time.Second)
+	key := func(userID, key, hash string, createdAt time.Time) *models.IdempotencyKey {
+		return &models.IdempotencyKey{
+			UserID:      userID,
+			Key:         key,
+			RequestHash: hash,
+			CreatedAt:   createdAt,
+			ExpiresAt:   createdAt.Add(time.Hour),
+		}
+	}
+
+	_, err := store.ClaimIdempotencyKey(ctx, key("u1", "k1", "h1", now))
+	require.NoError(t, err)
+	// Keys are scoped by user
+	_, err = store.ClaimIdempotencyKey(ctx, key("u2", "k1", "h2", now))
+	require.NoError(t, err)
+
+	// A claimed key is returned as it is, without a response while in progress
+	stored, err := store.ClaimIdempotencyKey(ctx, key("u1", "k1", "other", now.Add(time.Minute)))
+	assert.ErrorIs(t, err, storage.ErrAlreadyExists)
+	require.NotNil(t, stored)
+	assert.Equal(t, "h1", stored.RequestHash)
+	assert.Empty(t, stored.Response)
+	assert.True(t, now.Equal(stored.CreatedAt))
+	assert.True(t, now.Add(time.Hour).Equal(stored.ExpiresAt))
+
+	// Saving adds the response
+	completed := key("u1", "k1", "h1", now)
+	completed.Response = []byte("response")
+	require.NoError(t, store.SaveIdempotencyKey(ctx, completed))
+	completed.Response[0] = 'X'
+	stored, err = store.ClaimIdempotencyKey(ctx, key("u1", "k1", "h1", now.Add(time.Minute)))
+	assert.ErrorIs(t, err, storage.ErrAlreadyExists)
+	assert.Equal(t, []byte("response"), stored.Response)
+
+	// A released key can be claimed again
+	require.NoError(t, store.ReleaseIdempotencyKey(ctx, "u2", "k1"))
+	require.NoError(t, store.ReleaseIdempotencyKey(ctx, "u2", "missing"))
+	_, err = store.ClaimIdempotencyKey(ctx, key("u2", "k1", "h3", now.Add(time.Minute)))
+	require.NoError(t, err)
+
+	// So can an expired one
+	stored, err = store.ClaimIdempotencyKey(ctx, key("u1", "k1", "h4", now.Add(time.Hour)))
+	require.NoError(t, err)
+	assert.Equal(t, "h4", stored.RequestHash)
+	assert.Empty(t, stored.Response)
+	_, err = store.ClaimIdempotencyKey(ctx, key("u1", "k1", "h5", now.Add(time.Hour)))
+	assert.ErrorIs(t, err, storage.ErrAlreadyExists)
+}
+
+func testJobs(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+
+	jobs, err := store.ListJobs(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, jobs)
+
+	createdAt := time.Now().Truncate(time.Second)
+	for _, id := range []string{"n1", "n2", "n3"} {
+		require.NoError(t, store.SaveJob(ctx, &models.NotificationJob{
+			Notification: &models.Notification{ID: id, UserID: "u1", PostID: "p1", Content: "hello " + id, CreatedAt: createdAt},
+			Attempt:      1,
+		}))
+	}
+
+	// Saving a job again updates it in place, deleting it acks it
+	dueAt := createdAt.Add(time.Minute)
+	failure := models.DeliveryAttempt{Attempt: 1, At: createdAt, Error: "connection refused"}
+	require.NoError(t, store.SaveJob(ctx, &models.NotificationJob{
+		Notification: &models.Notification{
+			ID:         "n1",
+			UserID:     "u1",
+			PostID:     "p1",
+			Content:    "hello n1",
+			CreatedAt:  createdAt,
+			Status:     models.NotificationStatusFailed,
+			RetryCount: 1,
+			LastRetry:  &createdAt,
+			AuthorID:   "alice",
+			EventType:  models.EventTypeNewPost,
+			PostIDs:    []string{"p0", "p1"},
+			Channels:   []models.Channel{models.ChannelWebhook},
+			Deliveries: []models.ChannelDelivery{
+				{Channel: models.ChannelWebhook, Status: models.NotificationStatusFailed, Attempts: 1, LastError: "connection refused"},
+			},
+		},
+		Attempt:    2,
+		DueAt:      dueAt,
+		Attempts:   []models.DeliveryAttempt{failure},
+		Priority:   models.PriorityHigh,
+		MaxBackoff: time.Hour,
+	}))
+	require.NoError(t, store.DeleteJob(ctx, "n2"))
+	require.NoError(t, store.DeleteJob(ctx, "missing"))
+
+	jobs, err = store.ListJobs(ctx)
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, "n1", jobs[0].Notification.ID)
+	assert.Equal(t, 2, jobs[0].Attempt)
+	assert.True(t, dueAt.Equal(jobs[0].DueAt))
+	assert.Equal(t, models.PriorityHigh, jobs[0].Priority)
+	assert.Equal(t, time.Hour, jobs[0].MaxBackoff)
+	assert.Zero(t, jobs[1].MaxBackoff)
+
+	job, err := store.GetJob(ctx, "n1")
+	require.NoError(t, err)
+	assert.Equal(t, jobs[0], job)
+	_, err = store.GetJob(ctx, "n2")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	require.Len(t, jobs[0].Attempts, 1)
+	assert.Equal(t, failure.Error, jobs[0].Attempts[0].Error)
+	assert.True(t, failure.At.Equal(jobs[0].Attempts[0].At))
+	assert.Equal(t, "u1", jobs[0].Notification.UserID)
+	assert.Equal(t, "p1", jobs[0].Notification.PostID)
+	assert.Equal(t, "hello n1", jobs[0].Notification.Content)
+	assert.True(t, createdAt.Equal(jobs[0].Notification.CreatedAt))
+	assert.Equal(t, models.NotificationStatusFailed, jobs[0].Notification.Status)
+	assert.Equal(t, 1, jobs[0].Notification.RetryCount)
+	require.NotNil(t, jobs[0].Notification.LastRetry)
+	assert.True(t, createdAt.Equal(*jobs[0].Notification.LastRetry))
+	assert.Equal(t, []models.Channel{models.ChannelWebhook}, jobs[0].Notification.Channels)
+	assert.Equal(t, "alice", jobs[0].Notification.AuthorID)
+	assert.Equal(t, models.EventTypeNewPost, jobs[0].Notification.EventType)
+	assert.Equal(t, []string{"p0", "p1"}, jobs[0].Notification.PostIDs)
+	assert.Empty(t, jobs[1].Notification.PostIDs)
+	require.Len(t, jobs[0].Notification.Deliveries, 1)
+	assert.Equal(t, "connection refused", jobs[0].Notification.Deliveries[0].LastError)
+	assert.Empty(t, jobs[1].Notification.Deliveries)
+	assert.Equal(t, "n3", jobs[1].Notification.ID)
+	assert.Equal(t, 1, jobs[1].Attempt)
+	assert.True(t, jobs[1].DueAt.IsZero())
+	assert.Empty(t, jobs[1].Attempts)
+
+	// Jobs without a lease go to the first queue to claim them, a page at
+	// a time
+	now := time.Now()
+	claimed, err := store.ClaimJobs(ctx, "q1", now, now.Add(time.Minute), 1)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, "n1", claimed[0].Notification.ID)
+	assert.Equal(t, "q1", claimed[0].Owner)
+	assert.True(t, now.Add(time.Minute).Equal(claimed[0].LeaseUntil))
+	claimed, err = store.ClaimJobs(ctx, "q1", now, now.Add(time.Minute), 1)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, "n3", claimed[0].Notification.ID)
+	claimed, err = store.ClaimJobs(ctx, "q2", now, now.Add(time.Minute), 0)
+	require.NoError(t, err)
+	assert.Empty(t, claimed)
+
+	// A job saved with a lease is held from the start
+	require.NoError(t, store.SaveJob(ctx, &models.NotificationJob{
+		Notification: &models.Notification{ID: "n4", UserID: "u1", CreatedAt: createdAt},
+		Attempt:      1,
+		Owner:        "q2",
+		LeaseUntil:   now.Add(time.Minute),
+	}))
+	job, err = store.GetJob(ctx, "n4")
+	require.NoError(t, err)
+	assert.Equal(t, "q2", job.Owner)
+
+	// Renewed leases hold, expired and released ones can be claimed
+	require.NoError(t, store.RenewJobLeases(ctx, "q1", now.Add(2*time.Minute)))
+	require.NoError(t, store.RenewJobLeases(ctx, "q2", now))
+	claimed, err = store.ClaimJobs(ctx, "q3", now.Add(90*time.Second), now.Add(3*time.Minute), 0)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, "n4", claimed[0].Notification.ID)
+	claimed, err = store.ClaimJobs(ctx, "q3", now.Add(2*time.Minute), now.Add(3*time.Minute), 0)
+	require.NoError(t, err)
+	assert.Len(t, claimed, 2)
+	jobs, err = store.ListJobs(ctx)
+	require.NoError(t, err)
+	for _, job := range jobs {
+		assert.Equal(t, "q3", job.Owner, job.Notification.ID)
+	}
+}
+
+func testDeadLetters(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+
+	_, err := store.GetDeadLetter(ctx, "missing")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	assert.ErrorIs(t, store.DeleteDeadLetter(ctx, "missing"), storage.ErrNotFound)
+
+	base := time.Now().Truncate(time.Second)
+	lastRetry := base.Add(time.Second)
+	for i := 0; i < 4; i++ {
+		deadLetter := &models.DeadLetter{
+			Notification: &models.Notification{
+				ID:         fmt.Sprintf("n%d", i),
+				UserID:     "u1",
+				PostID:     "p1",
+				Content:    fmt.Sprintf("notification %d", i),
+				CreatedAt:  base,
+				Status:     models.NotificationStatusDeadLettered,
+				RetryCount: 1,
+				LastRetry:  &lastRetry,
+				AuthorID:   "alice",
+				EventType:  models.EventTypeNewPost,
+				PostIDs:    []string{"p0", "p1"},
+				Deliveries: []models.ChannelDelivery{
+					{Channel: models.ChannelEmail, Status: models.NotificationStatusFailed, Attempts: 2, LastError: "connection refused"},
+				},
+			},
+			Reason: "gave up",
+			Attempts: []models.DeliveryAttempt{
+				{Attempt: 1, At: base, Error: "timeout"},
+				{Attempt: 2, Channel: models.ChannelEmail, At: base.Add(time.Second), Error: "connection refused"},
+			},
+			FailedAt: base.Add(time.Duration(i) * time.Minute),
+		}
+		require.NoError(t, store.AddDeadLetter(ctx, deadLetter))
+		assert.NotZero(t, deadLetter.Seq)
+	}
+
+	deadLetter, err := store.GetDeadLetter(ctx, "n1")
+	require.NoError(t, err)
+	assert.Equal(t, "u1", deadLetter.Notification.UserID)
+	assert.Equal(t, "notification 1", deadLetter.Notification.Content)
+	assert.Equal(t, models.NotificationStatusDeadLettered, deadLetter.Notification.Status)
+	assert.Equal(t, 1, deadLetter.Notification.RetryCount)
+	assert.Equal(t, "alice", deadLetter.Notification.AuthorID)
+	assert.Equal(t, models.EventTypeNewPost, deadLetter.Notification.EventType)
+	assert.Equal(t, []string{"p0", "p1"}, deadLetter.Notification.PostIDs)
+	require.NotNil(t, deadLetter.Notification.LastRetry)
+	assert.True(t, lastRetry.Equal(*deadLetter.Notification.LastRetry))
+	require.Len(t, deadLetter.Notification.Deliveries, 1)
+	assert.Equal(t, models.ChannelEmail, deadLetter.Notification.Deliveries[0].Channel)
+	assert.Equal(t, 2, deadLetter.Notification.Deliveries[0].Attempts)
+	assert.True(t, base.Equal(deadLetter.Notification.CreatedAt))
+	assert.Equal(t, "gave up", deadLetter.Reason)
+	assert.True(t, base.Add(time.Minute).Equal(deadLetter.FailedAt))
+	require.Len(t, deadLetter.Attempts, 2)
+	assert.Equal(t, 2, deadLetter.Attempts[1].Attempt)
+	assert.Equal(t, "connection refused", deadLetter.Attempts[1].Error)
+	assert.Equal(t, models.ChannelEmail, deadLetter.Attempts[1].Channel)
+	assert.True(t, base.Add(time.Second).Equal(deadLetter.Attempts[1].At))
+
+	// Pages follow the order they were added in
+	page, err := store.ListDeadLetters(ctx, 0, 2)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, "n0", page[0].Notification.ID)
+	assert.Equal(t, "n1", page[1].Notification.ID)
+	page, err = store.ListDeadLetters(ctx, page[1].Seq, 0)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, "n2", page[0].Notification.ID)
+	assert.Equal(t, "n3", page[1].Notification.ID)
+
+	// Dead-lettering a notification again moves it to the end
+	require.NoError(t, store.AddDeadLetter(ctx, &models.DeadLetter{
+		Notification: &models.Notification{ID: "n0", UserID: "u1", CreatedAt: base},
+		Reason:       "gave up again",
+		FailedAt:     base.Add(time.Hour),
+	}))
+	page, err = store.ListDeadLetters(ctx, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, page, 4)
+	assert.Equal(t, "n0", page[3].Notification.ID)
+	assert.Equal(t, "gave up again", page[3].Reason)
+	assert.Empty(t, page[3].Attempts)
+	last, err := store.LastDeadLetterSeq(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, page[3].Seq, last)
+
+	require.NoError(t, store.DeleteDeadLetter(ctx, "n2"))
+	count, err := store.CountDeadLetters(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	// Purging removes the dead letters that failed up to the given time
+	purged, err := store.PurgeDeadLetters(ctx, base.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	page, err = store.ListDeadLetters(ctx, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, "n3", page[0].Notification.ID)
+	assert.Equal(t, "n0", page[1].Notification.ID)
+}
+
+func testMetrics(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+
+	metrics, err := store.GetMetrics(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, models.NotificationMetrics{}, *metrics)
+
+	require.NoError(t, store.RecordDelivery(ctx, 100))
+	require.NoError(t, store.RecordDelivery(ctx, 200))
+	require.NoError(t, store.RecordFailure(ctx))
+
+	metrics, err = store.GetMetrics(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, metrics.TotalNotificationsSent)
+	assert.Equal(t, 1, metrics.FailedAttempts)
+	assert.Equal(t, 150.0, metrics.AverageDeliveryTime)
+}
+
+func testSeeds(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+
+	seeded, err := store.Seeded(ctx, "sample")
+	require.NoError(t, err)
+	assert.False(t, seeded)
+
+	require.NoError(t, store.MarkSeeded(ctx, "sample"))
+	require.NoError(t, store.MarkSeeded(ctx, "sample"))
+	seeded, err = store.Seeded(ctx, "sample")
+	require.NoError(t, err)
+	assert.True(t, seeded)
+
+	seeded, err = store.Seeded(ctx, "other")
+	require.NoError(t, err)
+	assert.False(t, seeded)
+}
@@ -22,6 +22,69 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// Where a notification is in its delivery lifecycle
+type NotificationStatus int32
+
+const (
+	// Notifications stored before statuses were tracked
+	NotificationStatus_NOTIFICATION_STATUS_UNSPECIFIED NotificationStatus = 0
+	// Waiting in the queue for its first attempt
+	NotificationStatus_NOTIFICATION_STATUS_PENDING    NotificationStatus = 1
+	NotificationStatus_NOTIFICATION_STATUS_DELIVERING NotificationStatus = 2
+	NotificationStatus_NOTIFICATION_STATUS_DELIVERED  NotificationStatus = 3
+	// The latest attempt failed and a retry is scheduled
+	NotificationStatus_NOTIFICATION_STATUS_FAILED NotificationStatus = 4
+	// Gave up after the last retry, see ListDeadLetters
+	NotificationStatus_NOTIFICATION_STATUS_DEAD_LETTERED NotificationStatus = 5
+)
+
+// Enum value maps for NotificationStatus.
+var (
+	NotificationStatus_name = map[int32]string{
+		0: "NOTIFICATION_STATUS_UNSPECIFIED",
+		1: "NOTIFICATION_STATUS_PENDING",
+		2: "NOTIFICATION_STATUS_DELIVERING",
+		3: "NOTIFICATION_STATUS_DELIVERED",
+		4: "NOTIFICATION_STATUS_FAILED",
+		5: "NOTIFICATION_STATUS_DEAD_LETTERED",
+	}
+	NotificationStatus_value = map[string]int32{
+		"NOTIFICATION_STATUS_UNSPECIFIED":   0,
+		"NOTIFICATION_STATUS_PENDING":       1,
+		"NOTIFICATION_STATUS_DELIVERING":    2,
+		"NOTIFICATION_STATUS_DELIVERED":     3,
+		"NOTIFICATION_STATUS_FAILED":        4,
+		"NOTIFICATION_STATUS_DEAD_LETTERED": 5,
+	}
+)
+
+func (x NotificationStatus) Enum() *NotificationStatus {
+	p := new(NotificationStatus)
+	*p = x
+	return p
+}
+
+func (x NotificationStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (NotificationStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_notification_proto_enumTypes[0].Descriptor()
+}
+
+func (NotificationStatus) Type() protoreflect.EnumType {
+	return &file_proto_notification_proto_enumTypes[0]
+}
+
+func (x NotificationStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use NotificationStatus.Descriptor instead.
+func (NotificationStatus) EnumDescriptor() ([]byte, []int) {
+	return file_proto_notification_proto_rawDescGZIP(), []int{0}
+}
+
 type UserId struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
@@ -66,21 +129,181 @@ func (x *UserId) GetUserId() string {
 	return ""
 }
 
+// Field 1 matches UserId, so older clients that send a UserId still work
+type GetNotificationsRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	UserId string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	// Defaults to 20, at most 500
+	PageSize int32 `protobuf:"varint,2,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// Cursor of the last notification of the previous page
+	Cursor     string `protobuf:"bytes,3,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	UnreadOnly bool   `protobuf:"varint,4,opt,name=unread_only,json=unreadOnly,proto3" json:"unread_only,omitempty"`
+	// Unix time in seconds; since is inclusive and until exclusive
+	Since         int64 `protobuf:"varint,5,opt,name=since,proto3" json:"since,omitempty"`
+	Until         int64 `protobuf:"varint,6,opt,name=until,proto3" json:"until,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetNotificationsRequest) Reset() {
+	*x = GetNotificationsRequest{}
+	mi := &file_proto_notification_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetNotificationsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetNotificationsRequest) ProtoMessage() {}
+
+func (x *GetNotificationsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_notification_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetNotificationsRequest.ProtoReflect.Descriptor instead.
+func (*GetNotificationsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_notification_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetNotificationsRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *GetNotificationsRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *GetNotificationsRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+func (x *GetNotificationsRequest) GetUnreadOnly() bool {
+	if x != nil {
+		return x.UnreadOnly
+	}
+	return false
+}
+
+func (x *GetNotificationsRequest) GetSince() int64 {
+	if x != nil {
+		return x.Since
+	}
+	return 0
+}
+
+func (x *GetNotificationsRequest) GetUntil() int64 {
+	if x != nil {
+		return x.Until
+	}
+	return 0
+}
+
+type SubscribeNotificationsRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	UserId string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	// Cursor of the last notification received. Notifications delivered since
+	// then are replayed before live ones.
+	Cursor        string `protobuf:"bytes,2,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeNotificationsRequest) Reset() {
+	*x = SubscribeNotificationsRequest{}
+	mi := &file_proto_notification_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeNotificationsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeNotificationsRequest) ProtoMessage() {}
+
+func (x *SubscribeNotificationsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_notification_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeNotificationsRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeNotificationsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_notification_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SubscribeNotificationsRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *SubscribeNotificationsRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
 type Notification struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	UserId        string                 `protobuf:"bytes,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
-	PostId        string                 `protobuf:"bytes,3,opt,name=post_id,json=postId,proto3" json:"post_id,omitempty"`
-	Content       string                 `protobuf:"bytes,4,opt,name=content,proto3" json:"content,omitempty"`
-	Read          bool                   `protobuf:"varint,5,opt,name=read,proto3" json:"read,omitempty"`
-	CreatedAt     int64                  `protobuf:"varint,6,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Id        string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	UserId    string                 `protobuf:"bytes,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	PostId    string                 `protobuf:"bytes,3,opt,name=post_id,json=postId,proto3" json:"post_id,omitempty"`
+	Content   string                 `protobuf:"bytes,4,opt,name=content,proto3" json:"content,omitempty"`
+	Read      bool                   `protobuf:"varint,5,opt,name=read,proto3" json:"read,omitempty"`
+	CreatedAt int64                  `protobuf:"varint,6,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	// Pass as GetNotificationsRequest.cursor to continue after this notification
+	Cursor string             `protobuf:"bytes,7,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	Status NotificationStatus `protobuf:"varint,8,opt,name=status,proto3,enum=notification.NotificationStatus" json:"status,omitempty"`
+	// Number of retries the delivery took so far
+	RetryCount int32 `protobuf:"varint,9,opt,name=retry_count,json=retryCount,proto3" json:"retry_count,omitempty"`
+	// Unix time in seconds of the latest retry, 0 before the first one
+	LastRetry int64 `protobuf:"varint,10,opt,name=last_retry,json=lastRetry,proto3" json:"last_retry,omitempty"`
+	// Unix time in seconds, 0 until the notification is delivered
+	DeliveredAt int64 `protobuf:"varint,11,opt,name=delivered_at,json=deliveredAt,proto3" json:"delivered_at,omitempty"`
+	// Outcome of every channel the notification went through so far
+	Deliveries []*ChannelDelivery `protobuf:"bytes,12,rep,name=deliveries,proto3" json:"deliveries,omitempty"`
+	// User whose activity the notification is about
+	AuthorId string `protobuf:"bytes,13,opt,name=author_id,json=authorId,proto3" json:"author_id,omitempty"`
+	// What happened, e.g. new_post
+	EventType string `protobuf:"bytes,14,opt,name=event_type,json=eventType,proto3" json:"event_type,omitempty"`
+	// Posts an aggregate notification merges, oldest first, with post_id the
+	// latest. Empty for a notification about a single post.
+	PostIds       []string `protobuf:"bytes,15,rep,name=post_ids,json=postIds,proto3" json:"post_ids,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Notification) Reset() {
 	*x = Notification{}
-	mi := &file_proto_notification_proto_msgTypes[1]
+	mi := &file_proto_notification_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -92,7 +315,7 @@ func (x *Notification) String() string {
 func (*Notification) ProtoMessage() {}
 
 func (x *Notification) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_notification_proto_msgTypes[1]
+	mi := &file_proto_notification_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -105,7 +328,7 @@ func (x *Notification) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Notification.ProtoReflect.Descriptor instead.
 func (*Notification) Descriptor() ([]byte, []int) {
-	return file_proto_notification_proto_rawDescGZIP(), []int{1}
+	return file_proto_notification_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *Notification) GetId() string {
@@ -150,30 +373,99 @@ func (x *Notification) GetCreatedAt() int64 {
 	return 0
 }
 
-type NotificationMetrics struct {
-	state                  protoimpl.MessageState `protogen:"open.v1"`
-	TotalNotificationsSent int64                  `protobuf:"varint,1,opt,name=total_notifications_sent,json=totalNotificationsSent,proto3" json:"total_notifications_sent,omitempty"`
-	FailedAttempts         int64                  `protobuf:"varint,2,opt,name=failed_attempts,json=failedAttempts,proto3" json:"failed_attempts,omitempty"`
-	AverageDeliveryTime    float64                `protobuf:"fixed64,3,opt,name=average_delivery_time,json=averageDeliveryTime,proto3" json:"average_delivery_time,omitempty"`
-	unknownFields          protoimpl.UnknownFields
-	sizeCache              protoimpl.SizeCache
+func (x *Notification) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
 }
 
-func (x *NotificationMetrics) Reset() {
-	*x = NotificationMetrics{}
-	mi := &file_proto_notification_proto_msgTypes[2]
+func (x *Notification) GetStatus() NotificationStatus {
+	if x != nil {
+		return x.Status
+	}
+	return NotificationStatus_NOTIFICATION_STATUS_UNSPECIFIED
+}
+
+func (x *Notification) GetRetryCount() int32 {
+	if x != nil {
+		return x.RetryCount
+	}
+	return 0
+}
+
+func (x *Notification) GetLastRetry() int64 {
+	if x != nil {
+		return x.LastRetry
+	}
+	return 0
+}
+
+func (x *Notification) GetDeliveredAt() int64 {
+	if x != nil {
+		return x.DeliveredAt
+	}
+	return 0
+}
+
+func (x *Notification) GetDeliveries() []*ChannelDelivery {
+	if x != nil {
+		return x.Deliveries
+	}
+	return nil
+}
+
+func (x *Notification) GetAuthorId() string {
+	if x != nil {
+		return x.AuthorId
+	}
+	return ""
+}
+
+func (x *Notification) GetEventType() string {
+	if x != nil {
+		return x.EventType
+	}
+	return ""
+}
+
+func (x *Notification) GetPostIds() []string {
+	if x != nil {
+		return x.PostIds
+	}
+	return nil
+}
+
+// Delivery of a notification through one channel: in_app, webhook, email or
+// mock
+type ChannelDelivery struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Channel  string                 `protobuf:"bytes,1,opt,name=channel,proto3" json:"channel,omitempty"`
+	Status   NotificationStatus     `protobuf:"varint,2,opt,name=status,proto3,enum=notification.NotificationStatus" json:"status,omitempty"`
+	Attempts int32                  `protobuf:"varint,3,opt,name=attempts,proto3" json:"attempts,omitempty"`
+	// Error of the latest failed attempt, cleared once the channel delivered
+	LastError string `protobuf:"bytes,4,opt,name=last_error,json=lastError,proto3" json:"last_error,omitempty"`
+	// Unix time in seconds, 0 until the channel delivered
+	DeliveredAt   int64 `protobuf:"varint,5,opt,name=delivered_at,json=deliveredAt,proto3" json:"delivered_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChannelDelivery) Reset() {
+	*x = ChannelDelivery{}
+	mi := &file_proto_notification_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *NotificationMetrics) String() string {
+func (x *ChannelDelivery) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*NotificationMetrics) ProtoMessage() {}
+func (*ChannelDelivery) ProtoMessage() {}
 
-func (x *NotificationMetrics) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_notification_proto_msgTypes[2]
+func (x *ChannelDelivery) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_notification_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -184,54 +476,1381 @@ func (x *NotificationMetrics) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use NotificationMetrics.ProtoReflect.Descriptor instead.
-func (*NotificationMetrics) Descriptor() ([]byte, []int) {
-	return file_proto_notification_proto_rawDescGZIP(), []int{2}
+// Deprecated: Use ChannelDelivery.ProtoReflect.Descriptor instead.
+func (*ChannelDelivery) Descriptor() ([]byte, []int) {
+	return file_proto_notification_proto_rawDescGZIP(), []int{4}
 }
 
-func (x *NotificationMetrics) GetTotalNotificationsSent() int64 {
+func (x *ChannelDelivery) GetChannel() string {
 	if x != nil {
-		return x.TotalNotificationsSent
+		return x.Channel
+	}
+	return ""
+}
+
+func (x *ChannelDelivery) GetStatus() NotificationStatus {
+	if x != nil {
+		return x.Status
+	}
+	return NotificationStatus_NOTIFICATION_STATUS_UNSPECIFIED
+}
+
+func (x *ChannelDelivery) GetAttempts() int32 {
+	if x != nil {
+		return x.Attempts
 	}
 	return 0
 }
 
-func (x *NotificationMetrics) GetFailedAttempts() int64 {
+func (x *ChannelDelivery) GetLastError() string {
 	if x != nil {
-		return x.FailedAttempts
+		return x.LastError
+	}
+	return ""
+}
+
+func (x *ChannelDelivery) GetDeliveredAt() int64 {
+	if x != nil {
+		return x.DeliveredAt
 	}
 	return 0
 }
 
-func (x *NotificationMetrics) GetAverageDeliveryTime() float64 {
+// What a user wants to be notified about and how
+type NotificationPreferences struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	UserId string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	// Channels to deliver through: in_app, webhook, email or mock. Empty means
+	// the server's default channels.
+	Channels []string `protobuf:"bytes,2,rep,name=channels,proto3" json:"channels,omitempty"`
+	// Event types the user is not notified about, e.g. new_post
+	MutedEventTypes []string `protobuf:"bytes,3,rep,name=muted_event_types,json=mutedEventTypes,proto3" json:"muted_event_types,omitempty"`
+	// IDs of users whose posts the user is not notified about
+	MutedAuthors []string `protobuf:"bytes,4,rep,name=muted_authors,json=mutedAuthors,proto3" json:"muted_authors,omitempty"`
+	// Unset when the user has no quiet hours
+	QuietHours *QuietHours `protobuf:"bytes,5,opt,name=quiet_hours,json=quietHours,proto3" json:"quiet_hours,omitempty"`
+	// Unix time in seconds, 0 while the user has the defaults. Ignored by
+	// UpdatePreferences.
+	UpdatedAt     int64 `protobuf:"varint,6,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NotificationPreferences) Reset() {
+	*x = NotificationPreferences{}
+	mi := &file_proto_notification_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NotificationPreferences) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NotificationPreferences) ProtoMessage() {}
+
+func (x *NotificationPreferences) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_notification_proto_msgTypes[5]
 	if x != nil {
-		return x.AverageDeliveryTime
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NotificationPreferences.ProtoReflect.Descriptor instead.
+func (*NotificationPreferences) Descriptor() ([]byte, []int) {
+	return file_proto_notification_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *NotificationPreferences) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *NotificationPreferences) GetChannels() []string {
+	if x != nil {
+		return x.Channels
+	}
+	return nil
+}
+
+func (x *NotificationPreferences) GetMutedEventTypes() []string {
+	if x != nil {
+		return x.MutedEventTypes
+	}
+	return nil
+}
+
+func (x *NotificationPreferences) GetMutedAuthors() []string {
+	if x != nil {
+		return x.MutedAuthors
+	}
+	return nil
+}
+
+func (x *NotificationPreferences) GetQuietHours() *QuietHours {
+	if x != nil {
+		return x.QuietHours
+	}
+	return nil
+}
+
+func (x *NotificationPreferences) GetUpdatedAt() int64 {
+	if x != nil {
+		return x.UpdatedAt
 	}
 	return 0
 }
 
-var File_proto_notification_proto protoreflect.FileDescriptor
+// A daily period during which notifications only go to the inbox
+type QuietHours struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Times of day as HH:MM. A period that ends before it starts runs past
+	// midnight.
+	Start string `protobuf:"bytes,1,opt,name=start,proto3" json:"start,omitempty"`
+	End   string `protobuf:"bytes,2,opt,name=end,proto3" json:"end,omitempty"`
+	// IANA time zone name, e.g. Europe/Berlin. Defaults to UTC.
+	TimeZone      string `protobuf:"bytes,3,opt,name=time_zone,json=timeZone,proto3" json:"time_zone,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
 
-const file_proto_notification_proto_rawDesc = "" +
-	"\n" +
-	"\x18proto/notification.proto\x12\fnotification\x1a\x1bgoogle/protobuf/empty.proto\"!\n" +
-	"\x06UserId\x12\x17\n" +
-	"\auser_id\x18\x01 \x01(\tR\x06userId\"\x9d\x01\n" +
-	"\fNotification\x12\x0e\n" +
+func (x *QuietHours) Reset() {
+	*x = QuietHours{}
+	mi := &file_proto_notification_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QuietHours) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QuietHours) ProtoMessage() {}
+
+func (x *QuietHours) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_notification_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QuietHours.ProtoReflect.Descriptor instead.
+func (*QuietHours) Descriptor() ([]byte, []int) {
+	return file_proto_notification_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *QuietHours) GetStart() string {
+	if x != nil {
+		return x.Start
+	}
+	return ""
+}
+
+func (x *QuietHours) GetEnd() string {
+	if x != nil {
+		return x.End
+	}
+	return ""
+}
+
+func (x *QuietHours) GetTimeZone() string {
+	if x != nil {
+		return x.TimeZone
+	}
+	return ""
+}
+
+type MarkNotificationReadRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	UserId         string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	NotificationId string                 `protobuf:"bytes,2,opt,name=notification_id,json=notificationId,proto3" json:"notification_id,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *MarkNotificationReadRequest) Reset() {
+	*x = MarkNotificationReadRequest{}
+	mi := &file_proto_notification_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MarkNotificationReadRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MarkNotificationReadRequest) ProtoMessage() {}
+
+func (x *MarkNotificationReadRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_notification_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MarkNotificationReadRequest.ProtoReflect.Descriptor instead.
+func (*MarkNotificationReadRequest) Descriptor() ([]byte, []int) {
+	return file_proto_notification_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *MarkNotificationReadRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *MarkNotificationReadRequest) GetNotificationId() string {
+	if x != nil {
+		return x.NotificationId
+	}
+	return ""
+}
+
+type MarkNotificationsReadRequest struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	UserId          string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	NotificationIds []string               `protobuf:"bytes,2,rep,name=notification_ids,json=notificationIds,proto3" json:"notification_ids,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *MarkNotificationsReadRequest) Reset() {
+	*x = MarkNotificationsReadRequest{}
+	mi := &file_proto_notification_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MarkNotificationsReadRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MarkNotificationsReadRequest) ProtoMessage() {}
+
+func (x *MarkNotificationsReadRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_notification_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MarkNotificationsReadRequest.ProtoReflect.Descriptor instead.
+func (*MarkNotificationsReadRequest) Descriptor() ([]byte, []int) {
+	return file_proto_notification_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *MarkNotificationsReadRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *MarkNotificationsReadRequest) GetNotificationIds() []string {
+	if x != nil {
+		return x.NotificationIds
+	}
+	return nil
+}
+
+type MarkAllReadRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	UserId string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	// Unix time in seconds; notifications created up to and including this
+	// second are marked. Zero means now.
+	Before        int64 `protobuf:"varint,2,opt,name=before,proto3" json:"before,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MarkAllReadRequest) Reset() {
+	*x = MarkAllReadRequest{}
+	mi := &file_proto_notification_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MarkAllReadRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MarkAllReadRequest) ProtoMessage() {}
+
+func (x *MarkAllReadRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_notification_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MarkAllReadRequest.ProtoReflect.Descriptor instead.
+func (*MarkAllReadRequest) Descriptor() ([]byte, []int) {
+	return file_proto_notification_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *MarkAllReadRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *MarkAllReadRequest) GetBefore() int64 {
+	if x != nil {
+		return x.Before
+	}
+	return 0
+}
+
+type MarkReadResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Number of notifications that changed from unread to read
+	Marked int32 `protobuf:"varint,1,opt,name=marked,proto3" json:"marked,omitempty"`
+	// Unread notifications left for the user afterwards
+	UnreadCount   int32 `protobuf:"varint,2,opt,name=unread_count,json=unreadCount,proto3" json:"unread_count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MarkReadResponse) Reset() {
+	*x = MarkReadResponse{}
+	mi := &file_proto_notification_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MarkReadResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MarkReadResponse) ProtoMessage() {}
+
+func (x *MarkReadResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_notification_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MarkReadResponse.ProtoReflect.Descriptor instead.
+func (*MarkReadResponse) Descriptor() ([]byte, []int) {
+	return file_proto_notification_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *MarkReadResponse) GetMarked() int32 {
+	if x != nil {
+		return x.Marked
+	}
+	return 0
+}
+
+func (x *MarkReadResponse) GetUnreadCount() int32 {
+	if x != nil {
+		return x.UnreadCount
+	}
+	return 0
+}
+
+type UnreadCount struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Count         int32                  `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnreadCount) Reset() {
+	*x = UnreadCount{}
+	mi := &file_proto_notification_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnreadCount) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnreadCount) ProtoMessage() {}
+
+func (x *UnreadCount) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_notification_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnreadCount.ProtoReflect.Descriptor instead.
+func (*UnreadCount) Descriptor() ([]byte, []int) {
+	return file_proto_notification_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *UnreadCount) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type NotificationMetrics struct {
+	state                  protoimpl.MessageState `protogen:"open.v1"`
+	TotalNotificationsSent int64                  `protobuf:"varint,1,opt,name=total_notifications_sent,json=totalNotificationsSent,proto3" json:"total_notifications_sent,omitempty"`
+	FailedAttempts         int64                  `protobuf:"varint,2,opt,name=failed_attempts,json=failedAttempts,proto3" json:"failed_attempts,omitempty"`
+	AverageDeliveryTime    float64                `protobuf:"fixed64,3,opt,name=average_delivery_time,json=averageDeliveryTime,proto3" json:"average_delivery_time,omitempty"`
+	// The priority lanes of the queue, most urgent first
+	Lanes         []*QueueLane `protobuf:"bytes,4,rep,name=lanes,proto3" json:"lanes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NotificationMetrics) Reset() {
+	*x = NotificationMetrics{}
+	mi := &file_proto_notification_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NotificationMetrics) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NotificationMetrics) ProtoMessage() {}
+
+func (x *NotificationMetrics) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_notification_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NotificationMetrics.ProtoReflect.Descriptor instead.
+func (*NotificationMetrics) Descriptor() ([]byte, []int) {
+	return file_proto_notification_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *NotificationMetrics) GetTotalNotificationsSent() int64 {
+	if x != nil {
+		return x.TotalNotificationsSent
+	}
+	return 0
+}
+
+func (x *NotificationMetrics) GetFailedAttempts() int64 {
+	if x != nil {
+		return x.FailedAttempts
+	}
+	return 0
+}
+
+func (x *NotificationMetrics) GetAverageDeliveryTime() float64 {
+	if x != nil {
+		return x.AverageDeliveryTime
+	}
+	return 0
+}
+
+func (x *NotificationMetrics) GetLanes() []*QueueLane {
+	if x != nil {
+		return x.Lanes
+	}
+	return nil
+}
+
+// The jobs waiting for a worker in one priority lane of the queue
+type QueueLane struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Priority string                 `protobuf:"bytes,1,opt,name=priority,proto3" json:"priority,omitempty"`
+	Depth    int64                  `protobuf:"varint,2,opt,name=depth,proto3" json:"depth,omitempty"`
+	// How long the job at the head of the lane has waited
+	OldestWaitMs int64 `protobuf:"varint,3,opt,name=oldest_wait_ms,json=oldestWaitMs,proto3" json:"oldest_wait_ms,omitempty"`
+	// Jobs waiting in the store for room in the lane
+	Spilled       int64 `protobuf:"varint,4,opt,name=spilled,proto3" json:"spilled,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueueLane) Reset() {
+	*x = QueueLane{}
+	mi := &file_proto_notification_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueueLane) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueueLane) ProtoMessage() {}
+
+func (x *QueueLane) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_notification_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueueLane.ProtoReflect.Descriptor instead.
+func (*QueueLane) Descriptor() ([]byte, []int) {
+	return file_proto_notification_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *QueueLane) GetPriority() string {
+	if x != nil {
+		return x.Priority
+	}
+	return ""
+}
+
+func (x *QueueLane) GetDepth() int64 {
+	if x != nil {
+		return x.Depth
+	}
+	return 0
+}
+
+func (x *QueueLane) GetOldestWaitMs() int64 {
+	if x != nil {
+		return x.OldestWaitMs
+	}
+	return 0
+}
+
+func (x *QueueLane) GetSpilled() int64 {
+	if x != nil {
+		return x.Spilled
+	}
+	return 0
+}
+
+type NotificationId struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	NotificationId string                 `protobuf:"bytes,1,opt,name=notification_id,json=notificationId,proto3" json:"notification_id,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *NotificationId) Reset() {
+	*x = NotificationId{}
+	mi := &file_proto_notification_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NotificationId) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NotificationId) ProtoMessage() {}
+
+func (x *NotificationId) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_notification_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NotificationId.ProtoReflect.Descriptor instead.
+func (*NotificationId) Descriptor() ([]byte, []int) {
+	return file_proto_notification_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *NotificationId) GetNotificationId() string {
+	if x != nil {
+		return x.NotificationId
+	}
+	return ""
+}
+
+type ListDeadLettersRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Defaults to 20, at most 100
+	PageSize      int32  `protobuf:"varint,1,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	Cursor        string `protobuf:"bytes,2,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListDeadLettersRequest) Reset() {
+	*x = ListDeadLettersRequest{}
+	mi := &file_proto_notification_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListDeadLettersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDeadLettersRequest) ProtoMessage() {}
+
+func (x *ListDeadLettersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_notification_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDeadLettersRequest.ProtoReflect.Descriptor instead.
+func (*ListDeadLettersRequest) Descriptor() ([]byte, []int) {
+	return file_proto_notification_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *ListDeadLettersRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListDeadLettersRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+type DeliveryAttempt struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Attempt int32                  `protobuf:"varint,1,opt,name=attempt,proto3" json:"attempt,omitempty"`
+	// Unix time in seconds
+	AttemptedAt int64 `protobuf:"varint,2,opt,name=attempted_at,json=attemptedAt,proto3" json:"attempted_at,omitempty"`
+	// Empty if the channel delivered the notification
+	Error         string `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	Channel       string `protobuf:"bytes,4,opt,name=channel,proto3" json:"channel,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeliveryAttempt) Reset() {
+	*x = DeliveryAttempt{}
+	mi := &file_proto_notification_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeliveryAttempt) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeliveryAttempt) ProtoMessage() {}
+
+func (x *DeliveryAttempt) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_notification_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeliveryAttempt.ProtoReflect.Descriptor instead.
+func (*DeliveryAttempt) Descriptor() ([]byte, []int) {
+	return file_proto_notification_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *DeliveryAttempt) GetAttempt() int32 {
+	if x != nil {
+		return x.Attempt
+	}
+	return 0
+}
+
+func (x *DeliveryAttempt) GetAttemptedAt() int64 {
+	if x != nil {
+		return x.AttemptedAt
+	}
+	return 0
+}
+
+func (x *DeliveryAttempt) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *DeliveryAttempt) GetChannel() string {
+	if x != nil {
+		return x.Channel
+	}
+	return ""
+}
+
+// A notification waiting in the queue, or being delivered
+type NotificationJob struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Notification *Notification          `protobuf:"bytes,1,opt,name=notification,proto3" json:"notification,omitempty"`
+	// The attempt the job is due for, starting at 1
+	Attempt int32 `protobuf:"varint,2,opt,name=attempt,proto3" json:"attempt,omitempty"`
+	// Unix time in seconds a retry is due at, 0 for right away
+	DueAt int64 `protobuf:"varint,3,opt,name=due_at,json=dueAt,proto3" json:"due_at,omitempty"`
+	// Every channel tried so far, oldest first
+	Attempts      []*DeliveryAttempt `protobuf:"bytes,4,rep,name=attempts,proto3" json:"attempts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NotificationJob) Reset() {
+	*x = NotificationJob{}
+	mi := &file_proto_notification_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NotificationJob) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NotificationJob) ProtoMessage() {}
+
+func (x *NotificationJob) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_notification_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NotificationJob.ProtoReflect.Descriptor instead.
+func (*NotificationJob) Descriptor() ([]byte, []int) {
+	return file_proto_notification_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *NotificationJob) GetNotification() *Notification {
+	if x != nil {
+		return x.Notification
+	}
+	return nil
+}
+
+func (x *NotificationJob) GetAttempt() int32 {
+	if x != nil {
+		return x.Attempt
+	}
+	return 0
+}
+
+func (x *NotificationJob) GetDueAt() int64 {
+	if x != nil {
+		return x.DueAt
+	}
+	return 0
+}
+
+func (x *NotificationJob) GetAttempts() []*DeliveryAttempt {
+	if x != nil {
+		return x.Attempts
+	}
+	return nil
+}
+
+type DeadLetter struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Notification *Notification          `protobuf:"bytes,1,opt,name=notification,proto3" json:"notification,omitempty"`
+	// Why the notification was given up on
+	Reason string `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	// The delivery attempts, oldest first
+	Attempts []*DeliveryAttempt `protobuf:"bytes,3,rep,name=attempts,proto3" json:"attempts,omitempty"`
+	// Unix time in seconds
+	FailedAt      int64 `protobuf:"varint,4,opt,name=failed_at,json=failedAt,proto3" json:"failed_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeadLetter) Reset() {
+	*x = DeadLetter{}
+	mi := &file_proto_notification_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeadLetter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeadLetter) ProtoMessage() {}
+
+func (x *DeadLetter) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_notification_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeadLetter.ProtoReflect.Descriptor instead.
+func (*DeadLetter) Descriptor() ([]byte, []int) {
+	return file_proto_notification_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *DeadLetter) GetNotification() *Notification {
+	if x != nil {
+		return x.Notification
+	}
+	return nil
+}
+
+func (x *DeadLetter) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *DeadLetter) GetAttempts() []*DeliveryAttempt {
+	if x != nil {
+		return x.Attempts
+	}
+	return nil
+}
+
+func (x *DeadLetter) GetFailedAt() int64 {
+	if x != nil {
+		return x.FailedAt
+	}
+	return 0
+}
+
+type DeadLetterPage struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	DeadLetters []*DeadLetter          `protobuf:"bytes,1,rep,name=dead_letters,json=deadLetters,proto3" json:"dead_letters,omitempty"`
+	// Empty on the last page
+	NextCursor    string `protobuf:"bytes,2,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"`
+	TotalCount    int32  `protobuf:"varint,3,opt,name=total_count,json=totalCount,proto3" json:"total_count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeadLetterPage) Reset() {
+	*x = DeadLetterPage{}
+	mi := &file_proto_notification_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeadLetterPage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeadLetterPage) ProtoMessage() {}
+
+func (x *DeadLetterPage) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_notification_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeadLetterPage.ProtoReflect.Descriptor instead.
+func (*DeadLetterPage) Descriptor() ([]byte, []int) {
+	return file_proto_notification_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *DeadLetterPage) GetDeadLetters() []*DeadLetter {
+	if x != nil {
+		return x.DeadLetters
+	}
+	return nil
+}
+
+func (x *DeadLetterPage) GetNextCursor() string {
+	if x != nil {
+		return x.NextCursor
+	}
+	return ""
+}
+
+func (x *DeadLetterPage) GetTotalCount() int32 {
+	if x != nil {
+		return x.TotalCount
+	}
+	return 0
+}
+
+type ReplayDeadLettersResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Replayed      int32                  `protobuf:"varint,1,opt,name=replayed,proto3" json:"replayed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReplayDeadLettersResponse) Reset() {
+	*x = ReplayDeadLettersResponse{}
+	mi := &file_proto_notification_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReplayDeadLettersResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReplayDeadLettersResponse) ProtoMessage() {}
+
+func (x *ReplayDeadLettersResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_notification_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReplayDeadLettersResponse.ProtoReflect.Descriptor instead.
+func (*ReplayDeadLettersResponse) Descriptor() ([]byte, []int) {
+	return file_proto_notification_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *ReplayDeadLettersResponse) GetReplayed() int32 {
+	if x != nil {
+		return x.Replayed
+	}
+	return 0
+}
+
+type PurgeDeadLettersRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Unix time in seconds; dead letters that failed up to and including this
+	// second are purged. Zero purges all of them.
+	Before        int64 `protobuf:"varint,1,opt,name=before,proto3" json:"before,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PurgeDeadLettersRequest) Reset() {
+	*x = PurgeDeadLettersRequest{}
+	mi := &file_proto_notification_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PurgeDeadLettersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PurgeDeadLettersRequest) ProtoMessage() {}
+
+func (x *PurgeDeadLettersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_notification_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PurgeDeadLettersRequest.ProtoReflect.Descriptor instead.
+func (*PurgeDeadLettersRequest) Descriptor() ([]byte, []int) {
+	return file_proto_notification_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *PurgeDeadLettersRequest) GetBefore() int64 {
+	if x != nil {
+		return x.Before
+	}
+	return 0
+}
+
+type PurgeDeadLettersResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Purged        int32                  `protobuf:"varint,1,opt,name=purged,proto3" json:"purged,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PurgeDeadLettersResponse) Reset() {
+	*x = PurgeDeadLettersResponse{}
+	mi := &file_proto_notification_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PurgeDeadLettersResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PurgeDeadLettersResponse) ProtoMessage() {}
+
+func (x *PurgeDeadLettersResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_notification_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PurgeDeadLettersResponse.ProtoReflect.Descriptor instead.
+func (*PurgeDeadLettersResponse) Descriptor() ([]byte, []int) {
+	return file_proto_notification_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *PurgeDeadLettersResponse) GetPurged() int32 {
+	if x != nil {
+		return x.Purged
+	}
+	return 0
+}
+
+type WorkerPool struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Workers int32                  `protobuf:"varint,1,opt,name=workers,proto3" json:"workers,omitempty"`
+	// Workers delivering a notification right now
+	Busy       int32 `protobuf:"varint,2,opt,name=busy,proto3" json:"busy,omitempty"`
+	MinWorkers int32 `protobuf:"varint,3,opt,name=min_workers,json=minWorkers,proto3" json:"min_workers,omitempty"`
+	MaxWorkers int32 `protobuf:"varint,4,opt,name=max_workers,json=maxWorkers,proto3" json:"max_workers,omitempty"`
+	// False while the size is pinned through SetWorkerPool
+	Autoscaling   bool `protobuf:"varint,5,opt,name=autoscaling,proto3" json:"autoscaling,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WorkerPool) Reset() {
+	*x = WorkerPool{}
+	mi := &file_proto_notification_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WorkerPool) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WorkerPool) ProtoMessage() {}
+
+func (x *WorkerPool) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_notification_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WorkerPool.ProtoReflect.Descriptor instead.
+func (*WorkerPool) Descriptor() ([]byte, []int) {
+	return file_proto_notification_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *WorkerPool) GetWorkers() int32 {
+	if x != nil {
+		return x.Workers
+	}
+	return 0
+}
+
+func (x *WorkerPool) GetBusy() int32 {
+	if x != nil {
+		return x.Busy
+	}
+	return 0
+}
+
+func (x *WorkerPool) GetMinWorkers() int32 {
+	if x != nil {
+		return x.MinWorkers
+	}
+	return 0
+}
+
+func (x *WorkerPool) GetMaxWorkers() int32 {
+	if x != nil {
+		return x.MaxWorkers
+	}
+	return 0
+}
+
+func (x *WorkerPool) GetAutoscaling() bool {
+	if x != nil {
+		return x.Autoscaling
+	}
+	return false
+}
+
+type SetWorkerPoolRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Between 1 and max_workers, or 0 to autoscale again
+	Workers       int32 `protobuf:"varint,1,opt,name=workers,proto3" json:"workers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetWorkerPoolRequest) Reset() {
+	*x = SetWorkerPoolRequest{}
+	mi := &file_proto_notification_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetWorkerPoolRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetWorkerPoolRequest) ProtoMessage() {}
+
+func (x *SetWorkerPoolRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_notification_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetWorkerPoolRequest.ProtoReflect.Descriptor instead.
+func (*SetWorkerPoolRequest) Descriptor() ([]byte, []int) {
+	return file_proto_notification_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *SetWorkerPoolRequest) GetWorkers() int32 {
+	if x != nil {
+		return x.Workers
+	}
+	return 0
+}
+
+var File_proto_notification_proto protoreflect.FileDescriptor
+
+const file_proto_notification_proto_rawDesc = "" +
+	"\n" +
+	"\x18proto/notification.proto\x12\fnotification\x1a\x1bgoogle/protobuf/empty.proto\"!\n" +
+	"\x06UserId\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\"\xb4\x01\n" +
+	"\x17GetNotificationsRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1b\n" +
+	"\tpage_size\x18\x02 \x01(\x05R\bpageSize\x12\x16\n" +
+	"\x06cursor\x18\x03 \x01(\tR\x06cursor\x12\x1f\n" +
+	"\vunread_only\x18\x04 \x01(\bR\n" +
+	"unreadOnly\x12\x14\n" +
+	"\x05since\x18\x05 \x01(\x03R\x05since\x12\x14\n" +
+	"\x05until\x18\x06 \x01(\x03R\x05until\"P\n" +
+	"\x1dSubscribeNotificationsRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x16\n" +
+	"\x06cursor\x18\x02 \x01(\tR\x06cursor\"\xe8\x03\n" +
+	"\fNotification\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x17\n" +
 	"\auser_id\x18\x02 \x01(\tR\x06userId\x12\x17\n" +
 	"\apost_id\x18\x03 \x01(\tR\x06postId\x12\x18\n" +
 	"\acontent\x18\x04 \x01(\tR\acontent\x12\x12\n" +
 	"\x04read\x18\x05 \x01(\bR\x04read\x12\x1d\n" +
 	"\n" +
-	"created_at\x18\x06 \x01(\x03R\tcreatedAt\"\xac\x01\n" +
+	"created_at\x18\x06 \x01(\x03R\tcreatedAt\x12\x16\n" +
+	"\x06cursor\x18\a \x01(\tR\x06cursor\x128\n" +
+	"\x06status\x18\b \x01(\x0e2 .notification.NotificationStatusR\x06status\x12\x1f\n" +
+	"\vretry_count\x18\t \x01(\x05R\n" +
+	"retryCount\x12\x1d\n" +
+	"\n" +
+	"last_retry\x18\n" +
+	" \x01(\x03R\tlastRetry\x12!\n" +
+	"\fdelivered_at\x18\v \x01(\x03R\vdeliveredAt\x12=\n" +
+	"\n" +
+	"deliveries\x18\f \x03(\v2\x1d.notification.ChannelDeliveryR\n" +
+	"deliveries\x12\x1b\n" +
+	"\tauthor_id\x18\r \x01(\tR\bauthorId\x12\x1d\n" +
+	"\n" +
+	"event_type\x18\x0e \x01(\tR\teventType\x12\x19\n" +
+	"\bpost_ids\x18\x0f \x03(\tR\apostIds\"\xc3\x01\n" +
+	"\x0fChannelDelivery\x12\x18\n" +
+	"\achannel\x18\x01 \x01(\tR\achannel\x128\n" +
+	"\x06status\x18\x02 \x01(\x0e2 .notification.NotificationStatusR\x06status\x12\x1a\n" +
+	"\battempts\x18\x03 \x01(\x05R\battempts\x12\x1d\n" +
+	"\n" +
+	"last_error\x18\x04 \x01(\tR\tlastError\x12!\n" +
+	"\fdelivered_at\x18\x05 \x01(\x03R\vdeliveredAt\"\xf9\x01\n" +
+	"\x17NotificationPreferences\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1a\n" +
+	"\bchannels\x18\x02 \x03(\tR\bchannels\x12*\n" +
+	"\x11muted_event_types\x18\x03 \x03(\tR\x0fmutedEventTypes\x12#\n" +
+	"\rmuted_authors\x18\x04 \x03(\tR\fmutedAuthors\x129\n" +
+	"\vquiet_hours\x18\x05 \x01(\v2\x18.notification.QuietHoursR\n" +
+	"quietHours\x12\x1d\n" +
+	"\n" +
+	"updated_at\x18\x06 \x01(\x03R\tupdatedAt\"Q\n" +
+	"\n" +
+	"QuietHours\x12\x14\n" +
+	"\x05start\x18\x01 \x01(\tR\x05start\x12\x10\n" +
+	"\x03end\x18\x02 \x01(\tR\x03end\x12\x1b\n" +
+	"\ttime_zone\x18\x03 \x01(\tR\btimeZone\"_\n" +
+	"\x1bMarkNotificationReadRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12'\n" +
+	"\x0fnotification_id\x18\x02 \x01(\tR\x0enotificationId\"b\n" +
+	"\x1cMarkNotificationsReadRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12)\n" +
+	"\x10notification_ids\x18\x02 \x03(\tR\x0fnotificationIds\"E\n" +
+	"\x12MarkAllReadRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x16\n" +
+	"\x06before\x18\x02 \x01(\x03R\x06before\"M\n" +
+	"\x10MarkReadResponse\x12\x16\n" +
+	"\x06marked\x18\x01 \x01(\x05R\x06marked\x12!\n" +
+	"\funread_count\x18\x02 \x01(\x05R\vunreadCount\"#\n" +
+	"\vUnreadCount\x12\x14\n" +
+	"\x05count\x18\x01 \x01(\x05R\x05count\"\xdb\x01\n" +
 	"\x13NotificationMetrics\x128\n" +
 	"\x18total_notifications_sent\x18\x01 \x01(\x03R\x16totalNotificationsSent\x12'\n" +
 	"\x0ffailed_attempts\x18\x02 \x01(\x03R\x0efailedAttempts\x122\n" +
-	"\x15average_delivery_time\x18\x03 \x01(\x01R\x13averageDeliveryTime2\xb2\x01\n" +
-	"\x13NotificationService\x12F\n" +
-	"\x10GetNotifications\x12\x14.notification.UserId\x1a\x1a.notification.Notification0\x01\x12S\n" +
-	"\x16GetNotificationMetrics\x12\x16.google.protobuf.Empty\x1a!.notification.NotificationMetricsBSZQgithub.com/iwhitebird/social-app-microservices/proto/generated/notification/protob\x06proto3"
+	"\x15average_delivery_time\x18\x03 \x01(\x01R\x13averageDeliveryTime\x12-\n" +
+	"\x05lanes\x18\x04 \x03(\v2\x17.notification.QueueLaneR\x05lanes\"}\n" +
+	"\tQueueLane\x12\x1a\n" +
+	"\bpriority\x18\x01 \x01(\tR\bpriority\x12\x14\n" +
+	"\x05depth\x18\x02 \x01(\x03R\x05depth\x12$\n" +
+	"\x0eoldest_wait_ms\x18\x03 \x01(\x03R\foldestWaitMs\x12\x18\n" +
+	"\aspilled\x18\x04 \x01(\x03R\aspilled\"9\n" +
+	"\x0eNotificationId\x12'\n" +
+	"\x0fnotification_id\x18\x01 \x01(\tR\x0enotificationId\"M\n" +
+	"\x16ListDeadLettersRequest\x12\x1b\n" +
+	"\tpage_size\x18\x01 \x01(\x05R\bpageSize\x12\x16\n" +
+	"\x06cursor\x18\x02 \x01(\tR\x06cursor\"~\n" +
+	"\x0fDeliveryAttempt\x12\x18\n" +
+	"\aattempt\x18\x01 \x01(\x05R\aattempt\x12!\n" +
+	"\fattempted_at\x18\x02 \x01(\x03R\vattemptedAt\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\x12\x18\n" +
+	"\achannel\x18\x04 \x01(\tR\achannel\"\xbd\x01\n" +
+	"\x0fNotificationJob\x12>\n" +
+	"\fnotification\x18\x01 \x01(\v2\x1a.notification.NotificationR\fnotification\x12\x18\n" +
+	"\aattempt\x18\x02 \x01(\x05R\aattempt\x12\x15\n" +
+	"\x06due_at\x18\x03 \x01(\x03R\x05dueAt\x129\n" +
+	"\battempts\x18\x04 \x03(\v2\x1d.notification.DeliveryAttemptR\battempts\"\xbc\x01\n" +
+	"\n" +
+	"DeadLetter\x12>\n" +
+	"\fnotification\x18\x01 \x01(\v2\x1a.notification.NotificationR\fnotification\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\x129\n" +
+	"\battempts\x18\x03 \x03(\v2\x1d.notification.DeliveryAttemptR\battempts\x12\x1b\n" +
+	"\tfailed_at\x18\x04 \x01(\x03R\bfailedAt\"\x8f\x01\n" +
+	"\x0eDeadLetterPage\x12;\n" +
+	"\fdead_letters\x18\x01 \x03(\v2\x18.notification.DeadLetterR\vdeadLetters\x12\x1f\n" +
+	"\vnext_cursor\x18\x02 \x01(\tR\n" +
+	"nextCursor\x12\x1f\n" +
+	"\vtotal_count\x18\x03 \x01(\x05R\n" +
+	"totalCount\"7\n" +
+	"\x19ReplayDeadLettersResponse\x12\x1a\n" +
+	"\breplayed\x18\x01 \x01(\x05R\breplayed\"1\n" +
+	"\x17PurgeDeadLettersRequest\x12\x16\n" +
+	"\x06before\x18\x01 \x01(\x03R\x06before\"2\n" +
+	"\x18PurgeDeadLettersResponse\x12\x16\n" +
+	"\x06purged\x18\x01 \x01(\x05R\x06purged\"\x9e\x01\n" +
+	"\n" +
+	"WorkerPool\x12\x18\n" +
+	"\aworkers\x18\x01 \x01(\x05R\aworkers\x12\x12\n" +
+	"\x04busy\x18\x02 \x01(\x05R\x04busy\x12\x1f\n" +
+	"\vmin_workers\x18\x03 \x01(\x05R\n" +
+	"minWorkers\x12\x1f\n" +
+	"\vmax_workers\x18\x04 \x01(\x05R\n" +
+	"maxWorkers\x12 \n" +
+	"\vautoscaling\x18\x05 \x01(\bR\vautoscaling\"0\n" +
+	"\x14SetWorkerPoolRequest\x12\x18\n" +
+	"\aworkers\x18\x01 \x01(\x05R\aworkers*\xe8\x01\n" +
+	"\x12NotificationStatus\x12#\n" +
+	"\x1fNOTIFICATION_STATUS_UNSPECIFIED\x10\x00\x12\x1f\n" +
+	"\x1bNOTIFICATION_STATUS_PENDING\x10\x01\x12\"\n" +
+	"\x1eNOTIFICATION_STATUS_DELIVERING\x10\x02\x12!\n" +
+	"\x1dNOTIFICATION_STATUS_DELIVERED\x10\x03\x12\x1e\n" +
+	"\x1aNOTIFICATION_STATUS_FAILED\x10\x04\x12%\n" +
+	"!NOTIFICATION_STATUS_DEAD_LETTERED\x10\x052\xcf\v\n" +
+	"\x13NotificationService\x12W\n" +
+	"\x10GetNotifications\x12%.notification.GetNotificationsRequest\x1a\x1a.notification.Notification0\x01\x12c\n" +
+	"\x16SubscribeNotifications\x12+.notification.SubscribeNotificationsRequest\x1a\x1a.notification.Notification0\x01\x12S\n" +
+	"\x16GetNotificationMetrics\x12\x16.google.protobuf.Empty\x1a!.notification.NotificationMetrics\x12a\n" +
+	"\x14MarkNotificationRead\x12).notification.MarkNotificationReadRequest\x1a\x1e.notification.MarkReadResponse\x12c\n" +
+	"\x15MarkNotificationsRead\x12*.notification.MarkNotificationsReadRequest\x1a\x1e.notification.MarkReadResponse\x12O\n" +
+	"\vMarkAllRead\x12 .notification.MarkAllReadRequest\x1a\x1e.notification.MarkReadResponse\x12A\n" +
+	"\x0eGetUnreadCount\x12\x14.notification.UserId\x1a\x19.notification.UnreadCount\x12M\n" +
+	"\x0eGetPreferences\x12\x14.notification.UserId\x1a%.notification.NotificationPreferences\x12a\n" +
+	"\x11UpdatePreferences\x12%.notification.NotificationPreferences\x1a%.notification.NotificationPreferences\x12Q\n" +
+	"\x12GetNotificationJob\x12\x1c.notification.NotificationId\x1a\x1d.notification.NotificationJob\x12U\n" +
+	"\x0fListDeadLetters\x12$.notification.ListDeadLettersRequest\x1a\x1c.notification.DeadLetterPage\x12G\n" +
+	"\rGetDeadLetter\x12\x1c.notification.NotificationId\x1a\x18.notification.DeadLetter\x12Y\n" +
+	"\x10ReplayDeadLetter\x12\x1c.notification.NotificationId\x1a'.notification.ReplayDeadLettersResponse\x12T\n" +
+	"\x11ReplayDeadLetters\x12\x16.google.protobuf.Empty\x1a'.notification.ReplayDeadLettersResponse\x12a\n" +
+	"\x10PurgeDeadLetters\x12%.notification.PurgeDeadLettersRequest\x1a&.notification.PurgeDeadLettersResponse\x12A\n" +
+	"\rGetWorkerPool\x12\x16.google.protobuf.Empty\x1a\x18.notification.WorkerPool\x12M\n" +
+	"\rSetWorkerPool\x12\".notification.SetWorkerPoolRequest\x1a\x18.notification.WorkerPoolBSZQgithub.com/iwhitebird/social-app-microservices/proto/generated/notification/protob\x06proto3"
 
 var (
 	file_proto_notification_proto_rawDescOnce sync.Once
@@ -245,23 +1864,87 @@ func file_proto_notification_proto_rawDescGZIP() []byte {
 	return file_proto_notification_proto_rawDescData
 }
 
-var file_proto_notification_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_proto_notification_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_proto_notification_proto_msgTypes = make([]protoimpl.MessageInfo, 25)
 var file_proto_notification_proto_goTypes = []any{
-	(*UserId)(nil),              // 0: notification.UserId
-	(*Notification)(nil),        // 1: notification.Notification
-	(*NotificationMetrics)(nil), // 2: notification.NotificationMetrics
-	(*emptypb.Empty)(nil),       // 3: google.protobuf.Empty
+	(NotificationStatus)(0),               // 0: notification.NotificationStatus
+	(*UserId)(nil),                        // 1: notification.UserId
+	(*GetNotificationsRequest)(nil),       // 2: notification.GetNotificationsRequest
+	(*SubscribeNotificationsRequest)(nil), // 3: notification.SubscribeNotificationsRequest
+	(*Notification)(nil),                  // 4: notification.Notification
+	(*ChannelDelivery)(nil),               // 5: notification.ChannelDelivery
+	(*NotificationPreferences)(nil),       // 6: notification.NotificationPreferences
+	(*QuietHours)(nil),                    // 7: notification.QuietHours
+	(*MarkNotificationReadRequest)(nil),   // 8: notification.MarkNotificationReadRequest
+	(*MarkNotificationsReadRequest)(nil),  // 9: notification.MarkNotificationsReadRequest
+	(*MarkAllReadRequest)(nil),            // 10: notification.MarkAllReadRequest
+	(*MarkReadResponse)(nil),              // 11: notification.MarkReadResponse
+	(*UnreadCount)(nil),                   // 12: notification.UnreadCount
+	(*NotificationMetrics)(nil),           // 13: notification.NotificationMetrics
+	(*QueueLane)(nil),                     // 14: notification.QueueLane
+	(*NotificationId)(nil),                // 15: notification.NotificationId
+	(*ListDeadLettersRequest)(nil),        // 16: notification.ListDeadLettersRequest
+	(*DeliveryAttempt)(nil),               // 17: notification.DeliveryAttempt
+	(*NotificationJob)(nil),               // 18: notification.NotificationJob
+	(*DeadLetter)(nil),                    // 19: notification.DeadLetter
+	(*DeadLetterPage)(nil),                // 20: notification.DeadLetterPage
+	(*ReplayDeadLettersResponse)(nil),     // 21: notification.ReplayDeadLettersResponse
+	(*PurgeDeadLettersRequest)(nil),       // 22: notification.PurgeDeadLettersRequest
+	(*PurgeDeadLettersResponse)(nil),      // 23: notification.PurgeDeadLettersResponse
+	(*WorkerPool)(nil),                    // 24: notification.WorkerPool
+	(*SetWorkerPoolRequest)(nil),          // 25: notification.SetWorkerPoolRequest
+	(*emptypb.Empty)(nil),                 // 26: google.protobuf.Empty
 }
 var file_proto_notification_proto_depIdxs = []int32{
-	0, // 0: notification.NotificationService.GetNotifications:input_type -> notification.UserId
-	3, // 1: notification.NotificationService.GetNotificationMetrics:input_type -> google.protobuf.Empty
-	1, // 2: notification.NotificationService.GetNotifications:output_type -> notification.Notification
-	2, // 3: notification.NotificationService.GetNotificationMetrics:output_type -> notification.NotificationMetrics
-	2, // [2:4] is the sub-list for method output_type
-	0, // [0:2] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	0,  // 0: notification.Notification.status:type_name -> notification.NotificationStatus
+	5,  // 1: notification.Notification.deliveries:type_name -> notification.ChannelDelivery
+	0,  // 2: notification.ChannelDelivery.status:type_name -> notification.NotificationStatus
+	7,  // 3: notification.NotificationPreferences.quiet_hours:type_name -> notification.QuietHours
+	14, // 4: notification.NotificationMetrics.lanes:type_name -> notification.QueueLane
+	4,  // 5: notification.NotificationJob.notification:type_name -> notification.Notification
+	17, // 6: notification.NotificationJob.attempts:type_name -> notification.DeliveryAttempt
+	4,  // 7: notification.DeadLetter.notification:type_name -> notification.Notification
+	17, // 8: notification.DeadLetter.attempts:type_name -> notification.DeliveryAttempt
+	19, // 9: notification.DeadLetterPage.dead_letters:type_name -> notification.DeadLetter
+	2,  // 10: notification.NotificationService.GetNotifications:input_type -> notification.GetNotificationsRequest
+	3,  // 11: notification.NotificationService.SubscribeNotifications:input_type -> notification.SubscribeNotificationsRequest
+	26, // 12: notification.NotificationService.GetNotificationMetrics:input_type -> google.protobuf.Empty
+	8,  // 13: notification.NotificationService.MarkNotificationRead:input_type -> notification.MarkNotificationReadRequest
+	9,  // 14: notification.NotificationService.MarkNotificationsRead:input_type -> notification.MarkNotificationsReadRequest
+	10, // 15: notification.NotificationService.MarkAllRead:input_type -> notification.MarkAllReadRequest
+	1,  // 16: notification.NotificationService.GetUnreadCount:input_type -> notification.UserId
+	1,  // 17: notification.NotificationService.GetPreferences:input_type -> notification.UserId
+	6,  // 18: notification.NotificationService.UpdatePreferences:input_type -> notification.NotificationPreferences
+	15, // 19: notification.NotificationService.GetNotificationJob:input_type -> notification.NotificationId
+	16, // 20: notification.NotificationService.ListDeadLetters:input_type -> notification.ListDeadLettersRequest
+	15, // 21: notification.NotificationService.GetDeadLetter:input_type -> notification.NotificationId
+	15, // 22: notification.NotificationService.ReplayDeadLetter:input_type -> notification.NotificationId
+	26, // 23: notification.NotificationService.ReplayDeadLetters:input_type -> google.protobuf.Empty
+	22, // 24: notification.NotificationService.PurgeDeadLetters:input_type -> notification.PurgeDeadLettersRequest
+	26, // 25: notification.NotificationService.GetWorkerPool:input_type -> google.protobuf.Empty
+	25, // 26: notification.NotificationService.SetWorkerPool:input_type -> notification.SetWorkerPoolRequest
+	4,  // 27: notification.NotificationService.GetNotifications:output_type -> notification.Notification
+	4,  // 28: notification.NotificationService.SubscribeNotifications:output_type -> notification.Notification
+	13, // 29: notification.NotificationService.GetNotificationMetrics:output_type -> notification.NotificationMetrics
+	11, // 30: notification.NotificationService.MarkNotificationRead:output_type -> notification.MarkReadResponse
+	11, // 31: notification.NotificationService.MarkNotificationsRead:output_type -> notification.MarkReadResponse
+	11, // 32: notification.NotificationService.MarkAllRead:output_type -> notification.MarkReadResponse
+	12, // 33: notification.NotificationService.GetUnreadCount:output_type -> notification.UnreadCount
+	6,  // 34: notification.NotificationService.GetPreferences:output_type -> notification.NotificationPreferences
+	6,  // 35: notification.NotificationService.UpdatePreferences:output_type -> notification.NotificationPreferences
+	18, // 36: notification.NotificationService.GetNotificationJob:output_type -> notification.NotificationJob
+	20, // 37: notification.NotificationService.ListDeadLetters:output_type -> notification.DeadLetterPage
+	19, // 38: notification.NotificationService.GetDeadLetter:output_type -> notification.DeadLetter
+	21, // 39: notification.NotificationService.ReplayDeadLetter:output_type -> notification.ReplayDeadLettersResponse
+	21, // 40: notification.NotificationService.ReplayDeadLetters:output_type -> notification.ReplayDeadLettersResponse
+	23, // 41: notification.NotificationService.PurgeDeadLetters:output_type -> notification.PurgeDeadLettersResponse
+	24, // 42: notification.NotificationService.GetWorkerPool:output_type -> notification.WorkerPool
+	24, // 43: notification.NotificationService.SetWorkerPool:output_type -> notification.WorkerPool
+	27, // [27:44] is the sub-list for method output_type
+	10, // [10:27] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
 }
 
 func init() { file_proto_notification_proto_init() }
@@ -274,13 +1957,14 @@ func file_proto_notification_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_notification_proto_rawDesc), len(file_proto_notification_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   3,
+			NumEnums:      1,
+			NumMessages:   25,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_proto_notification_proto_goTypes,
 		DependencyIndexes: file_proto_notification_proto_depIdxs,
+		EnumInfos:         file_proto_notification_proto_enumTypes,
 		MessageInfos:      file_proto_notification_proto_msgTypes,
 	}.Build()
 	File_proto_notification_proto = out.File
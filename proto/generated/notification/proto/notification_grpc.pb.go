@@ -21,15 +21,59 @@ const _ = grpc.SupportPackageIsVersion9
 
 const (
 	NotificationService_GetNotifications_FullMethodName       = "/notification.NotificationService/GetNotifications"
+	NotificationService_SubscribeNotifications_FullMethodName = "/notification.NotificationService/SubscribeNotifications"
 	NotificationService_GetNotificationMetrics_FullMethodName = "/notification.NotificationService/GetNotificationMetrics"
+	NotificationService_MarkNotificationRead_FullMethodName   = "/notification.NotificationService/MarkNotificationRead"
+	NotificationService_MarkNotificationsRead_FullMethodName  = "/notification.NotificationService/MarkNotificationsRead"
+	NotificationService_MarkAllRead_FullMethodName            = "/notification.NotificationService/MarkAllRead"
+	NotificationService_GetUnreadCount_FullMethodName         = "/notification.NotificationService/GetUnreadCount"
+	NotificationService_GetPreferences_FullMethodName         = "/notification.NotificationService/GetPreferences"
+	NotificationService_UpdatePreferences_FullMethodName      = "/notification.NotificationService/UpdatePreferences"
+	NotificationService_GetNotificationJob_FullMethodName     = "/notification.NotificationService/GetNotificationJob"
+	NotificationService_ListDeadLetters_FullMethodName        = "/notification.NotificationService/ListDeadLetters"
+	NotificationService_GetDeadLetter_FullMethodName          = "/notification.NotificationService/GetDeadLetter"
+	NotificationService_ReplayDeadLetter_FullMethodName       = "/notification.NotificationService/ReplayDeadLetter"
+	NotificationService_ReplayDeadLetters_FullMethodName      = "/notification.NotificationService/ReplayDeadLetters"
+	NotificationService_PurgeDeadLetters_FullMethodName       = "/notification.NotificationService/PurgeDeadLetters"
+	NotificationService_GetWorkerPool_FullMethodName          = "/notification.NotificationService/GetWorkerPool"
+	NotificationService_SetWorkerPool_FullMethodName          = "/notification.NotificationService/SetWorkerPool"
 )
 
 // NotificationServiceClient is the client API for NotificationService service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type NotificationServiceClient interface {
-	GetNotifications(ctx context.Context, in *UserId, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Notification], error)
+	// Streams one page of a user's notifications, newest first
+	GetNotifications(ctx context.Context, in *GetNotificationsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Notification], error)
+	// Stays open and pushes notifications as they are delivered, oldest first.
+	// Ends with RESOURCE_EXHAUSTED if the client cannot keep up; reconnect with
+	// the cursor of the last notification received to continue.
+	SubscribeNotifications(ctx context.Context, in *SubscribeNotificationsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Notification], error)
 	GetNotificationMetrics(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*NotificationMetrics, error)
+	MarkNotificationRead(ctx context.Context, in *MarkNotificationReadRequest, opts ...grpc.CallOption) (*MarkReadResponse, error)
+	MarkNotificationsRead(ctx context.Context, in *MarkNotificationsReadRequest, opts ...grpc.CallOption) (*MarkReadResponse, error)
+	MarkAllRead(ctx context.Context, in *MarkAllReadRequest, opts ...grpc.CallOption) (*MarkReadResponse, error)
+	GetUnreadCount(ctx context.Context, in *UserId, opts ...grpc.CallOption) (*UnreadCount, error)
+	// Returns the defaults for users that never saved any preferences
+	GetPreferences(ctx context.Context, in *UserId, opts ...grpc.CallOption) (*NotificationPreferences, error)
+	// Replaces the preferences of a user
+	UpdatePreferences(ctx context.Context, in *NotificationPreferences, opts ...grpc.CallOption) (*NotificationPreferences, error)
+	// Returns a notification that is still in the queue with the attempts to
+	// deliver it so far. Ends with NOT_FOUND once the queue is done with it.
+	GetNotificationJob(ctx context.Context, in *NotificationId, opts ...grpc.CallOption) (*NotificationJob, error)
+	// Admin RPCs for the notifications the queue gave up on after running out
+	// of retries, oldest first
+	ListDeadLetters(ctx context.Context, in *ListDeadLettersRequest, opts ...grpc.CallOption) (*DeadLetterPage, error)
+	GetDeadLetter(ctx context.Context, in *NotificationId, opts ...grpc.CallOption) (*DeadLetter, error)
+	// Puts a dead-lettered notification back into the queue for a fresh round
+	// of attempts
+	ReplayDeadLetter(ctx context.Context, in *NotificationId, opts ...grpc.CallOption) (*ReplayDeadLettersResponse, error)
+	ReplayDeadLetters(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ReplayDeadLettersResponse, error)
+	PurgeDeadLetters(ctx context.Context, in *PurgeDeadLettersRequest, opts ...grpc.CallOption) (*PurgeDeadLettersResponse, error)
+	// Admin RPCs for the queue's worker pool. SetWorkerPool pins the pool to a
+	// number of workers, or hands it back to the autoscaler with 0.
+	GetWorkerPool(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*WorkerPool, error)
+	SetWorkerPool(ctx context.Context, in *SetWorkerPoolRequest, opts ...grpc.CallOption) (*WorkerPool, error)
 }
 
 type notificationServiceClient struct {
@@ -40,13 +84,13 @@ func NewNotificationServiceClient(cc grpc.ClientConnInterface) NotificationServi
 	return &notificationServiceClient{cc}
 }
 
-func (c *notificationServiceClient) GetNotifications(ctx context.Context, in *UserId, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Notification], error) {
+func (c *notificationServiceClient) GetNotifications(ctx context.Context, in *GetNotificationsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Notification], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	stream, err := c.cc.NewStream(ctx, &NotificationService_ServiceDesc.Streams[0], NotificationService_GetNotifications_FullMethodName, cOpts...)
 	if err != nil {
 		return nil, err
 	}
-	x := &grpc.GenericClientStream[UserId, Notification]{ClientStream: stream}
+	x := &grpc.GenericClientStream[GetNotificationsRequest, Notification]{ClientStream: stream}
 	if err := x.ClientStream.SendMsg(in); err != nil {
 		return nil, err
 	}
@@ -59,6 +103,25 @@ func (c *notificationServiceClient) GetNotifications(ctx context.Context, in *Us
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type NotificationService_GetNotificationsClient = grpc.ServerStreamingClient[Notification]
 
+func (c *notificationServiceClient) SubscribeNotifications(ctx context.Context, in *SubscribeNotificationsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Notification], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &NotificationService_ServiceDesc.Streams[1], NotificationService_SubscribeNotifications_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SubscribeNotificationsRequest, Notification]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NotificationService_SubscribeNotificationsClient = grpc.ServerStreamingClient[Notification]
+
 func (c *notificationServiceClient) GetNotificationMetrics(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*NotificationMetrics, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(NotificationMetrics)
@@ -69,12 +132,181 @@ func (c *notificationServiceClient) GetNotificationMetrics(ctx context.Context,
 	return out, nil
 }
 
+func (c *notificationServiceClient) MarkNotificationRead(ctx context.Context, in *MarkNotificationReadRequest, opts ...grpc.CallOption) (*MarkReadResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MarkReadResponse)
+	err := c.cc.Invoke(ctx, NotificationService_MarkNotificationRead_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) MarkNotificationsRead(ctx context.Context, in *MarkNotificationsReadRequest, opts ...grpc.CallOption) (*MarkReadResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MarkReadResponse)
+	err := c.cc.Invoke(ctx, NotificationService_MarkNotificationsRead_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) MarkAllRead(ctx context.Context, in *MarkAllReadRequest, opts ...grpc.CallOption) (*MarkReadResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MarkReadResponse)
+	err := c.cc.Invoke(ctx, NotificationService_MarkAllRead_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) GetUnreadCount(ctx context.Context, in *UserId, opts ...grpc.CallOption) (*UnreadCount, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UnreadCount)
+	err := c.cc.Invoke(ctx, NotificationService_GetUnreadCount_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) GetPreferences(ctx context.Context, in *UserId, opts ...grpc.CallOption) (*NotificationPreferences, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NotificationPreferences)
+	err := c.cc.Invoke(ctx, NotificationService_GetPreferences_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) UpdatePreferences(ctx context.Context, in *NotificationPreferences, opts ...grpc.CallOption) (*NotificationPreferences, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NotificationPreferences)
+	err := c.cc.Invoke(ctx, NotificationService_UpdatePreferences_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) GetNotificationJob(ctx context.Context, in *NotificationId, opts ...grpc.CallOption) (*NotificationJob, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NotificationJob)
+	err := c.cc.Invoke(ctx, NotificationService_GetNotificationJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) ListDeadLetters(ctx context.Context, in *ListDeadLettersRequest, opts ...grpc.CallOption) (*DeadLetterPage, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeadLetterPage)
+	err := c.cc.Invoke(ctx, NotificationService_ListDeadLetters_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) GetDeadLetter(ctx context.Context, in *NotificationId, opts ...grpc.CallOption) (*DeadLetter, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeadLetter)
+	err := c.cc.Invoke(ctx, NotificationService_GetDeadLetter_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) ReplayDeadLetter(ctx context.Context, in *NotificationId, opts ...grpc.CallOption) (*ReplayDeadLettersResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReplayDeadLettersResponse)
+	err := c.cc.Invoke(ctx, NotificationService_ReplayDeadLetter_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) ReplayDeadLetters(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ReplayDeadLettersResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReplayDeadLettersResponse)
+	err := c.cc.Invoke(ctx, NotificationService_ReplayDeadLetters_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) PurgeDeadLetters(ctx context.Context, in *PurgeDeadLettersRequest, opts ...grpc.CallOption) (*PurgeDeadLettersResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PurgeDeadLettersResponse)
+	err := c.cc.Invoke(ctx, NotificationService_PurgeDeadLetters_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) GetWorkerPool(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*WorkerPool, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(WorkerPool)
+	err := c.cc.Invoke(ctx, NotificationService_GetWorkerPool_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) SetWorkerPool(ctx context.Context, in *SetWorkerPoolRequest, opts ...grpc.CallOption) (*WorkerPool, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(WorkerPool)
+	err := c.cc.Invoke(ctx, NotificationService_SetWorkerPool_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // NotificationServiceServer is the server API for NotificationService service.
 // All implementations must embed UnimplementedNotificationServiceServer
 // for forward compatibility.
 type NotificationServiceServer interface {
-	GetNotifications(*UserId, grpc.ServerStreamingServer[Notification]) error
+	// Streams one page of a user's notifications, newest first
+	GetNotifications(*GetNotificationsRequest, grpc.ServerStreamingServer[Notification]) error
+	// Stays open and pushes notifications as they are delivered, oldest first.
+	// Ends with RESOURCE_EXHAUSTED if the client cannot keep up; reconnect with
+	// the cursor of the last notification received to continue.
+	SubscribeNotifications(*SubscribeNotificationsRequest, grpc.ServerStreamingServer[Notification]) error
 	GetNotificationMetrics(context.Context, *emptypb.Empty) (*NotificationMetrics, error)
+	MarkNotificationRead(context.Context, *MarkNotificationReadRequest) (*MarkReadResponse, error)
+	MarkNotificationsRead(context.Context, *MarkNotificationsReadRequest) (*MarkReadResponse, error)
+	MarkAllRead(context.Context, *MarkAllReadRequest) (*MarkReadResponse, error)
+	GetUnreadCount(context.Context, *UserId) (*UnreadCount, error)
+	// Returns the defaults for users that never saved any preferences
+	GetPreferences(context.Context, *UserId) (*NotificationPreferences, error)
+	// Replaces the preferences of a user
+	UpdatePreferences(context.Context, *NotificationPreferences) (*NotificationPreferences, error)
+	// Returns a notification that is still in the queue with the attempts to
+	// deliver it so far. Ends with NOT_FOUND once the queue is done with it.
+	GetNotificationJob(context.Context, *NotificationId) (*NotificationJob, error)
+	// Admin RPCs for the notifications the queue gave up on after running out
+	// of retries, oldest first
+	ListDeadLetters(context.Context, *ListDeadLettersRequest) (*DeadLetterPage, error)
+	GetDeadLetter(context.Context, *NotificationId) (*DeadLetter, error)
+	// Puts a dead-lettered notification back into the queue for a fresh round
+	// of attempts
+	ReplayDeadLetter(context.Context, *NotificationId) (*ReplayDeadLettersResponse, error)
+	ReplayDeadLetters(context.Context, *emptypb.Empty) (*ReplayDeadLettersResponse, error)
+	PurgeDeadLetters(context.Context, *PurgeDeadLettersRequest) (*PurgeDeadLettersResponse, error)
+	// Admin RPCs for the queue's worker pool. SetWorkerPool pins the pool to a
+	// number of workers, or hands it back to the autoscaler with 0.
+	GetWorkerPool(context.Context, *emptypb.Empty) (*WorkerPool, error)
+	SetWorkerPool(context.Context, *SetWorkerPoolRequest) (*WorkerPool, error)
 	mustEmbedUnimplementedNotificationServiceServer()
 }
 
@@ -85,12 +317,57 @@ type NotificationServiceServer interface {
 // pointer dereference when methods are called.
 type UnimplementedNotificationServiceServer struct{}
 
-func (UnimplementedNotificationServiceServer) GetNotifications(*UserId, grpc.ServerStreamingServer[Notification]) error {
+func (UnimplementedNotificationServiceServer) GetNotifications(*GetNotificationsRequest, grpc.ServerStreamingServer[Notification]) error {
 	return status.Errorf(codes.Unimplemented, "method GetNotifications not implemented")
 }
+func (UnimplementedNotificationServiceServer) SubscribeNotifications(*SubscribeNotificationsRequest, grpc.ServerStreamingServer[Notification]) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeNotifications not implemented")
+}
 func (UnimplementedNotificationServiceServer) GetNotificationMetrics(context.Context, *emptypb.Empty) (*NotificationMetrics, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetNotificationMetrics not implemented")
 }
+func (UnimplementedNotificationServiceServer) MarkNotificationRead(context.Context, *MarkNotificationReadRequest) (*MarkReadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MarkNotificationRead not implemented")
+}
+func (UnimplementedNotificationServiceServer) MarkNotificationsRead(context.Context, *MarkNotificationsReadRequest) (*MarkReadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MarkNotificationsRead not implemented")
+}
+func (UnimplementedNotificationServiceServer) MarkAllRead(context.Context, *MarkAllReadRequest) (*MarkReadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MarkAllRead not implemented")
+}
+func (UnimplementedNotificationServiceServer) GetUnreadCount(context.Context, *UserId) (*UnreadCount, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUnreadCount not implemented")
+}
+func (UnimplementedNotificationServiceServer) GetPreferences(context.Context, *UserId) (*NotificationPreferences, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPreferences not implemented")
+}
+func (UnimplementedNotificationServiceServer) UpdatePreferences(context.Context, *NotificationPreferences) (*NotificationPreferences, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdatePreferences not implemented")
+}
+func (UnimplementedNotificationServiceServer) GetNotificationJob(context.Context, *NotificationId) (*NotificationJob, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetNotificationJob not implemented")
+}
+func (UnimplementedNotificationServiceServer) ListDeadLetters(context.Context, *ListDeadLettersRequest) (*DeadLetterPage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDeadLetters not implemented")
+}
+func (UnimplementedNotificationServiceServer) GetDeadLetter(context.Context, *NotificationId) (*DeadLetter, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDeadLetter not implemented")
+}
+func (UnimplementedNotificationServiceServer) ReplayDeadLetter(context.Context, *NotificationId) (*ReplayDeadLettersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReplayDeadLetter not implemented")
+}
+func (UnimplementedNotificationServiceServer) ReplayDeadLetters(context.Context, *emptypb.Empty) (*ReplayDeadLettersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReplayDeadLetters not implemented")
+}
+func (UnimplementedNotificationServiceServer) PurgeDeadLetters(context.Context, *PurgeDeadLettersRequest) (*PurgeDeadLettersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PurgeDeadLetters not implemented")
+}
+func (UnimplementedNotificationServiceServer) GetWorkerPool(context.Context, *emptypb.Empty) (*WorkerPool, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetWorkerPool not implemented")
+}
+func (UnimplementedNotificationServiceServer) SetWorkerPool(context.Context, *SetWorkerPoolRequest) (*WorkerPool, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetWorkerPool not implemented")
+}
 func (UnimplementedNotificationServiceServer) mustEmbedUnimplementedNotificationServiceServer() {}
 func (UnimplementedNotificationServiceServer) testEmbeddedByValue()                             {}
 
@@ -113,16 +390,27 @@ func RegisterNotificationServiceServer(s grpc.ServiceRegistrar, srv Notification
 }
 
 func _NotificationService_GetNotifications_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(UserId)
+	m := new(GetNotificationsRequest)
 	if err := stream.RecvMsg(m); err != nil {
 		return err
 	}
-	return srv.(NotificationServiceServer).GetNotifications(m, &grpc.GenericServerStream[UserId, Notification]{ServerStream: stream})
+	return srv.(NotificationServiceServer).GetNotifications(m, &grpc.GenericServerStream[GetNotificationsRequest, Notification]{ServerStream: stream})
 }
 
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type NotificationService_GetNotificationsServer = grpc.ServerStreamingServer[Notification]
 
+func _NotificationService_SubscribeNotifications_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeNotificationsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NotificationServiceServer).SubscribeNotifications(m, &grpc.GenericServerStream[SubscribeNotificationsRequest, Notification]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NotificationService_SubscribeNotificationsServer = grpc.ServerStreamingServer[Notification]
+
 func _NotificationService_GetNotificationMetrics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(emptypb.Empty)
 	if err := dec(in); err != nil {
@@ -141,6 +429,258 @@ func _NotificationService_GetNotificationMetrics_Handler(srv interface{}, ctx co
 	return interceptor(ctx, in, info, handler)
 }
 
+func _NotificationService_MarkNotificationRead_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MarkNotificationReadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).MarkNotificationRead(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationService_MarkNotificationRead_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).MarkNotificationRead(ctx, req.(*MarkNotificationReadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_MarkNotificationsRead_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MarkNotificationsReadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).MarkNotificationsRead(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationService_MarkNotificationsRead_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).MarkNotificationsRead(ctx, req.(*MarkNotificationsReadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_MarkAllRead_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MarkAllReadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).MarkAllRead(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationService_MarkAllRead_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).MarkAllRead(ctx, req.(*MarkAllReadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_GetUnreadCount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserId)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).GetUnreadCount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationService_GetUnreadCount_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).GetUnreadCount(ctx, req.(*UserId))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_GetPreferences_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserId)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).GetPreferences(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationService_GetPreferences_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).GetPreferences(ctx, req.(*UserId))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_UpdatePreferences_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NotificationPreferences)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).UpdatePreferences(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationService_UpdatePreferences_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).UpdatePreferences(ctx, req.(*NotificationPreferences))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_GetNotificationJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NotificationId)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).GetNotificationJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationService_GetNotificationJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).GetNotificationJob(ctx, req.(*NotificationId))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_ListDeadLetters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDeadLettersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).ListDeadLetters(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationService_ListDeadLetters_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).ListDeadLetters(ctx, req.(*ListDeadLettersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_GetDeadLetter_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NotificationId)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).GetDeadLetter(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationService_GetDeadLetter_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).GetDeadLetter(ctx, req.(*NotificationId))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_ReplayDeadLetter_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NotificationId)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).ReplayDeadLetter(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationService_ReplayDeadLetter_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).ReplayDeadLetter(ctx, req.(*NotificationId))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_ReplayDeadLetters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).ReplayDeadLetters(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationService_ReplayDeadLetters_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).ReplayDeadLetters(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_PurgeDeadLetters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PurgeDeadLettersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).PurgeDeadLetters(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationService_PurgeDeadLetters_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).PurgeDeadLetters(ctx, req.(*PurgeDeadLettersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_GetWorkerPool_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).GetWorkerPool(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationService_GetWorkerPool_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).GetWorkerPool(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_SetWorkerPool_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetWorkerPoolRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).SetWorkerPool(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationService_SetWorkerPool_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).SetWorkerPool(ctx, req.(*SetWorkerPoolRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // NotificationService_ServiceDesc is the grpc.ServiceDesc for NotificationService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -152,6 +692,62 @@ var NotificationService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetNotificationMetrics",
 			Handler:    _NotificationService_GetNotificationMetrics_Handler,
 		},
+		{
+			MethodName: "MarkNotificationRead",
+			Handler:    _NotificationService_MarkNotificationRead_Handler,
+		},
+		{
+			MethodName: "MarkNotificationsRead",
+			Handler:    _NotificationService_MarkNotificationsRead_Handler,
+		},
+		{
+			MethodName: "MarkAllRead",
+			Handler:    _NotificationService_MarkAllRead_Handler,
+		},
+		{
+			MethodName: "GetUnreadCount",
+			Handler:    _NotificationService_GetUnreadCount_Handler,
+		},
+		{
+			MethodName: "GetPreferences",
+			Handler:    _NotificationService_GetPreferences_Handler,
+		},
+		{
+			MethodName: "UpdatePreferences",
+			Handler:    _NotificationService_UpdatePreferences_Handler,
+		},
+		{
+			MethodName: "GetNotificationJob",
+			Handler:    _NotificationService_GetNotificationJob_Handler,
+		},
+		{
+			MethodName: "ListDeadLetters",
+			Handler:    _NotificationService_ListDeadLetters_Handler,
+		},
+		{
+			MethodName: "GetDeadLetter",
+			Handler:    _NotificationService_GetDeadLetter_Handler,
+		},
+		{
+			MethodName: "ReplayDeadLetter",
+			Handler:    _NotificationService_ReplayDeadLetter_Handler,
+		},
+		{
+			MethodName: "ReplayDeadLetters",
+			Handler:    _NotificationService_ReplayDeadLetters_Handler,
+		},
+		{
+			MethodName: "PurgeDeadLetters",
+			Handler:    _NotificationService_PurgeDeadLetters_Handler,
+		},
+		{
+			MethodName: "GetWorkerPool",
+			Handler:    _NotificationService_GetWorkerPool_Handler,
+		},
+		{
+			MethodName: "SetWorkerPool",
+			Handler:    _NotificationService_SetWorkerPool_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -159,6 +755,11 @@ var NotificationService_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _NotificationService_GetNotifications_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "SubscribeNotifications",
+			Handler:       _NotificationService_SubscribeNotifications_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "proto/notification.proto",
 }
@@ -21,12 +21,72 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type FanoutStatus int32
+
+const (
+	FanoutStatus_FANOUT_STATUS_UNSPECIFIED FanoutStatus = 0
+	FanoutStatus_FANOUT_STATUS_PENDING     FanoutStatus = 1
+	FanoutStatus_FANOUT_STATUS_RUNNING     FanoutStatus = 2
+	FanoutStatus_FANOUT_STATUS_COMPLETED   FanoutStatus = 3
+	FanoutStatus_FANOUT_STATUS_FAILED      FanoutStatus = 4
+)
+
+// Enum value maps for FanoutStatus.
+var (
+	FanoutStatus_name = map[int32]string{
+		0: "FANOUT_STATUS_UNSPECIFIED",
+		1: "FANOUT_STATUS_PENDING",
+		2: "FANOUT_STATUS_RUNNING",
+		3: "FANOUT_STATUS_COMPLETED",
+		4: "FANOUT_STATUS_FAILED",
+	}
+	FanoutStatus_value = map[string]int32{
+		"FANOUT_STATUS_UNSPECIFIED": 0,
+		"FANOUT_STATUS_PENDING":     1,
+		"FANOUT_STATUS_RUNNING":     2,
+		"FANOUT_STATUS_COMPLETED":   3,
+		"FANOUT_STATUS_FAILED":      4,
+	}
+)
+
+func (x FanoutStatus) Enum() *FanoutStatus {
+	p := new(FanoutStatus)
+	*p = x
+	return p
+}
+
+func (x FanoutStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (FanoutStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_post_proto_enumTypes[0].Descriptor()
+}
+
+func (FanoutStatus) Type() protoreflect.EnumType {
+	return &file_proto_post_proto_enumTypes[0]
+}
+
+func (x FanoutStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use FanoutStatus.Descriptor instead.
+func (FanoutStatus) EnumDescriptor() ([]byte, []int) {
+	return file_proto_post_proto_rawDescGZIP(), []int{0}
+}
+
 type Post struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	UserId        string                 `protobuf:"bytes,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
-	Content       string                 `protobuf:"bytes,3,opt,name=content,proto3" json:"content,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Id        string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	UserId    string                 `protobuf:"bytes,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Content   string                 `protobuf:"bytes,3,opt,name=content,proto3" json:"content,omitempty"`
+	CreatedAt int64                  `protobuf:"varint,4,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	// Optional. PublishPost answers a retry sent with the same key with the
+	// original response instead of publishing the post again.
+	IdempotencyKey string `protobuf:"bytes,5,opt,name=idempotency_key,json=idempotencyKey,proto3" json:"idempotency_key,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
 }
 
 func (x *Post) Reset() {
@@ -59,6 +119,13 @@ func (*Post) Descriptor() ([]byte, []int) {
 	return file_proto_post_proto_rawDescGZIP(), []int{0}
 }
 
+func (x *Post) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
 func (x *Post) GetUserId() string {
 	if x != nil {
 		return x.UserId
@@ -73,18 +140,178 @@ func (x *Post) GetContent() string {
 	return ""
 }
 
+func (x *Post) GetCreatedAt() int64 {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return 0
+}
+
+func (x *Post) GetIdempotencyKey() string {
+	if x != nil {
+		return x.IdempotencyKey
+	}
+	return ""
+}
+
+type PostId struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PostId) Reset() {
+	*x = PostId{}
+	mi := &file_proto_post_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PostId) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PostId) ProtoMessage() {}
+
+func (x *PostId) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_post_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PostId.ProtoReflect.Descriptor instead.
+func (*PostId) Descriptor() ([]byte, []int) {
+	return file_proto_post_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *PostId) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type ListPostsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Limit         int32                  `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPostsRequest) Reset() {
+	*x = ListPostsRequest{}
+	mi := &file_proto_post_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPostsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPostsRequest) ProtoMessage() {}
+
+func (x *ListPostsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_post_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPostsRequest.ProtoReflect.Descriptor instead.
+func (*ListPostsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_post_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ListPostsRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *ListPostsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type PostList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Posts         []*Post                `protobuf:"bytes,1,rep,name=posts,proto3" json:"posts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PostList) Reset() {
+	*x = PostList{}
+	mi := &file_proto_post_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PostList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PostList) ProtoMessage() {}
+
+func (x *PostList) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_post_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PostList.ProtoReflect.Descriptor instead.
+func (*PostList) Descriptor() ([]byte, []int) {
+	return file_proto_post_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *PostList) GetPosts() []*Post {
+	if x != nil {
+		return x.Posts
+	}
+	return nil
+}
+
 type NotificationResponse struct {
-	state               protoimpl.MessageState `protogen:"open.v1"`
-	Success             bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
-	Message             string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
-	NotificationsQueued int32                  `protobuf:"varint,3,opt,name=notifications_queued,json=notificationsQueued,proto3" json:"notifications_queued,omitempty"`
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Success bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	// Always 0, followers are notified in the background by the fan-out job
+	//
+	// Deprecated: Marked as deprecated in proto/post.proto.
+	NotificationsQueued int32  `protobuf:"varint,3,opt,name=notifications_queued,json=notificationsQueued,proto3" json:"notifications_queued,omitempty"`
+	PostId              string `protobuf:"bytes,4,opt,name=post_id,json=postId,proto3" json:"post_id,omitempty"`
+	FanoutId            string `protobuf:"bytes,5,opt,name=fanout_id,json=fanoutId,proto3" json:"fanout_id,omitempty"`
+	Followers           int32  `protobuf:"varint,6,opt,name=followers,proto3" json:"followers,omitempty"`
 	unknownFields       protoimpl.UnknownFields
 	sizeCache           protoimpl.SizeCache
 }
 
 func (x *NotificationResponse) Reset() {
 	*x = NotificationResponse{}
-	mi := &file_proto_post_proto_msgTypes[1]
+	mi := &file_proto_post_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -96,7 +323,7 @@ func (x *NotificationResponse) String() string {
 func (*NotificationResponse) ProtoMessage() {}
 
 func (x *NotificationResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_post_proto_msgTypes[1]
+	mi := &file_proto_post_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -109,7 +336,7 @@ func (x *NotificationResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use NotificationResponse.ProtoReflect.Descriptor instead.
 func (*NotificationResponse) Descriptor() ([]byte, []int) {
-	return file_proto_post_proto_rawDescGZIP(), []int{1}
+	return file_proto_post_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *NotificationResponse) GetSuccess() bool {
@@ -126,6 +353,7 @@ func (x *NotificationResponse) GetMessage() string {
 	return ""
 }
 
+// Deprecated: Marked as deprecated in proto/post.proto.
 func (x *NotificationResponse) GetNotificationsQueued() int32 {
 	if x != nil {
 		return x.NotificationsQueued
@@ -133,21 +361,272 @@ func (x *NotificationResponse) GetNotificationsQueued() int32 {
 	return 0
 }
 
+func (x *NotificationResponse) GetPostId() string {
+	if x != nil {
+		return x.PostId
+	}
+	return ""
+}
+
+func (x *NotificationResponse) GetFanoutId() string {
+	if x != nil {
+		return x.FanoutId
+	}
+	return ""
+}
+
+func (x *NotificationResponse) GetFollowers() int32 {
+	if x != nil {
+		return x.Followers
+	}
+	return 0
+}
+
+type FanoutId struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FanoutId) Reset() {
+	*x = FanoutId{}
+	mi := &file_proto_post_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FanoutId) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FanoutId) ProtoMessage() {}
+
+func (x *FanoutId) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_post_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FanoutId.ProtoReflect.Descriptor instead.
+func (*FanoutId) Descriptor() ([]byte, []int) {
+	return file_proto_post_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *FanoutId) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type FanoutJob struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Id             string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	PostId         string                 `protobuf:"bytes,2,opt,name=post_id,json=postId,proto3" json:"post_id,omitempty"`
+	AuthorId       string                 `protobuf:"bytes,3,opt,name=author_id,json=authorId,proto3" json:"author_id,omitempty"`
+	Status         FanoutStatus           `protobuf:"varint,4,opt,name=status,proto3,enum=post.FanoutStatus" json:"status,omitempty"`
+	TotalFollowers int32                  `protobuf:"varint,5,opt,name=total_followers,json=totalFollowers,proto3" json:"total_followers,omitempty"`
+	Processed      int32                  `protobuf:"varint,6,opt,name=processed,proto3" json:"processed,omitempty"`
+	Queued         int32                  `protobuf:"varint,7,opt,name=queued,proto3" json:"queued,omitempty"`
+	Skipped        int32                  `protobuf:"varint,8,opt,name=skipped,proto3" json:"skipped,omitempty"`
+	Error          string                 `protobuf:"bytes,9,opt,name=error,proto3" json:"error,omitempty"`
+	CreatedAt      int64                  `protobuf:"varint,10,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt      int64                  `protobuf:"varint,11,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	CompletedAt    int64                  `protobuf:"varint,12,opt,name=completed_at,json=completedAt,proto3" json:"completed_at,omitempty"`
+	// Notifications the full queue turned away, each was tried again later
+	TurnedAway    int32 `protobuf:"varint,13,opt,name=turned_away,json=turnedAway,proto3" json:"turned_away,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FanoutJob) Reset() {
+	*x = FanoutJob{}
+	mi := &file_proto_post_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FanoutJob) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FanoutJob) ProtoMessage() {}
+
+func (x *FanoutJob) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_post_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FanoutJob.ProtoReflect.Descriptor instead.
+func (*FanoutJob) Descriptor() ([]byte, []int) {
+	return file_proto_post_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *FanoutJob) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *FanoutJob) GetPostId() string {
+	if x != nil {
+		return x.PostId
+	}
+	return ""
+}
+
+func (x *FanoutJob) GetAuthorId() string {
+	if x != nil {
+		return x.AuthorId
+	}
+	return ""
+}
+
+func (x *FanoutJob) GetStatus() FanoutStatus {
+	if x != nil {
+		return x.Status
+	}
+	return FanoutStatus_FANOUT_STATUS_UNSPECIFIED
+}
+
+func (x *FanoutJob) GetTotalFollowers() int32 {
+	if x != nil {
+		return x.TotalFollowers
+	}
+	return 0
+}
+
+func (x *FanoutJob) GetProcessed() int32 {
+	if x != nil {
+		return x.Processed
+	}
+	return 0
+}
+
+func (x *FanoutJob) GetQueued() int32 {
+	if x != nil {
+		return x.Queued
+	}
+	return 0
+}
+
+func (x *FanoutJob) GetSkipped() int32 {
+	if x != nil {
+		return x.Skipped
+	}
+	return 0
+}
+
+func (x *FanoutJob) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *FanoutJob) GetCreatedAt() int64 {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return 0
+}
+
+func (x *FanoutJob) GetUpdatedAt() int64 {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return 0
+}
+
+func (x *FanoutJob) GetCompletedAt() int64 {
+	if x != nil {
+		return x.CompletedAt
+	}
+	return 0
+}
+
+func (x *FanoutJob) GetTurnedAway() int32 {
+	if x != nil {
+		return x.TurnedAway
+	}
+	return 0
+}
+
 var File_proto_post_proto protoreflect.FileDescriptor
 
 const file_proto_post_proto_rawDesc = "" +
 	"\n" +
-	"\x10proto/post.proto\x12\x04post\"9\n" +
-	"\x04Post\x12\x17\n" +
+	"\x10proto/post.proto\x12\x04post\"\x91\x01\n" +
+	"\x04Post\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x17\n" +
 	"\auser_id\x18\x02 \x01(\tR\x06userId\x12\x18\n" +
-	"\acontent\x18\x03 \x01(\tR\acontent\"}\n" +
+	"\acontent\x18\x03 \x01(\tR\acontent\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\x04 \x01(\x03R\tcreatedAt\x12'\n" +
+	"\x0fidempotency_key\x18\x05 \x01(\tR\x0eidempotencyKey\"\x18\n" +
+	"\x06PostId\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"A\n" +
+	"\x10ListPostsRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x14\n" +
+	"\x05limit\x18\x02 \x01(\x05R\x05limit\",\n" +
+	"\bPostList\x12 \n" +
+	"\x05posts\x18\x01 \x03(\v2\n" +
+	".post.PostR\x05posts\"\xd5\x01\n" +
 	"\x14NotificationResponse\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\tR\amessage\x121\n" +
-	"\x14notifications_queued\x18\x03 \x01(\x05R\x13notificationsQueued2D\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x125\n" +
+	"\x14notifications_queued\x18\x03 \x01(\x05B\x02\x18\x01R\x13notificationsQueued\x12\x17\n" +
+	"\apost_id\x18\x04 \x01(\tR\x06postId\x12\x1b\n" +
+	"\tfanout_id\x18\x05 \x01(\tR\bfanoutId\x12\x1c\n" +
+	"\tfollowers\x18\x06 \x01(\x05R\tfollowers\"\x1a\n" +
+	"\bFanoutId\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x8e\x03\n" +
+	"\tFanoutJob\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x17\n" +
+	"\apost_id\x18\x02 \x01(\tR\x06postId\x12\x1b\n" +
+	"\tauthor_id\x18\x03 \x01(\tR\bauthorId\x12*\n" +
+	"\x06status\x18\x04 \x01(\x0e2\x12.post.FanoutStatusR\x06status\x12'\n" +
+	"\x0ftotal_followers\x18\x05 \x01(\x05R\x0etotalFollowers\x12\x1c\n" +
+	"\tprocessed\x18\x06 \x01(\x05R\tprocessed\x12\x16\n" +
+	"\x06queued\x18\a \x01(\x05R\x06queued\x12\x18\n" +
+	"\askipped\x18\b \x01(\x05R\askipped\x12\x14\n" +
+	"\x05error\x18\t \x01(\tR\x05error\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\n" +
+	" \x01(\x03R\tcreatedAt\x12\x1d\n" +
+	"\n" +
+	"updated_at\x18\v \x01(\x03R\tupdatedAt\x12!\n" +
+	"\fcompleted_at\x18\f \x01(\x03R\vcompletedAt\x12\x1f\n" +
+	"\vturned_away\x18\r \x01(\x05R\n" +
+	"turnedAway*\x9a\x01\n" +
+	"\fFanoutStatus\x12\x1d\n" +
+	"\x19FANOUT_STATUS_UNSPECIFIED\x10\x00\x12\x19\n" +
+	"\x15FANOUT_STATUS_PENDING\x10\x01\x12\x19\n" +
+	"\x15FANOUT_STATUS_RUNNING\x10\x02\x12\x1b\n" +
+	"\x17FANOUT_STATUS_COMPLETED\x10\x03\x12\x18\n" +
+	"\x14FANOUT_STATUS_FAILED\x10\x042\xd2\x01\n" +
 	"\vPostService\x125\n" +
 	"\vPublishPost\x12\n" +
-	".post.Post\x1a\x1a.post.NotificationResponseBKZIgithub.com/iwhitebird/social-app-microservices/proto/generated/post/protob\x06proto3"
+	".post.Post\x1a\x1a.post.NotificationResponse\x12#\n" +
+	"\aGetPost\x12\f.post.PostId\x1a\n" +
+	".post.Post\x129\n" +
+	"\x0fListPostsByUser\x12\x16.post.ListPostsRequest\x1a\x0e.post.PostList\x12,\n" +
+	"\tGetFanout\x12\x0e.post.FanoutId\x1a\x0f.post.FanoutJobBKZIgithub.com/iwhitebird/social-app-microservices/proto/generated/post/protob\x06proto3"
 
 var (
 	file_proto_post_proto_rawDescOnce sync.Once
@@ -161,19 +640,34 @@ func file_proto_post_proto_rawDescGZIP() []byte {
 	return file_proto_post_proto_rawDescData
 }
 
-var file_proto_post_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_proto_post_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_proto_post_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
 var file_proto_post_proto_goTypes = []any{
-	(*Post)(nil),                 // 0: post.Post
-	(*NotificationResponse)(nil), // 1: post.NotificationResponse
+	(FanoutStatus)(0),            // 0: post.FanoutStatus
+	(*Post)(nil),                 // 1: post.Post
+	(*PostId)(nil),               // 2: post.PostId
+	(*ListPostsRequest)(nil),     // 3: post.ListPostsRequest
+	(*PostList)(nil),             // 4: post.PostList
+	(*NotificationResponse)(nil), // 5: post.NotificationResponse
+	(*FanoutId)(nil),             // 6: post.FanoutId
+	(*FanoutJob)(nil),            // 7: post.FanoutJob
 }
 var file_proto_post_proto_depIdxs = []int32{
-	0, // 0: post.PostService.PublishPost:input_type -> post.Post
-	1, // 1: post.PostService.PublishPost:output_type -> post.NotificationResponse
-	1, // [1:2] is the sub-list for method output_type
-	0, // [0:1] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	1, // 0: post.PostList.posts:type_name -> post.Post
+	0, // 1: post.FanoutJob.status:type_name -> post.FanoutStatus
+	1, // 2: post.PostService.PublishPost:input_type -> post.Post
+	2, // 3: post.PostService.GetPost:input_type -> post.PostId
+	3, // 4: post.PostService.ListPostsByUser:input_type -> post.ListPostsRequest
+	6, // 5: post.PostService.GetFanout:input_type -> post.FanoutId
+	5, // 6: post.PostService.PublishPost:output_type -> post.NotificationResponse
+	1, // 7: post.PostService.GetPost:output_type -> post.Post
+	4, // 8: post.PostService.ListPostsByUser:output_type -> post.PostList
+	7, // 9: post.PostService.GetFanout:output_type -> post.FanoutJob
+	6, // [6:10] is the sub-list for method output_type
+	2, // [2:6] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
 }
 
 func init() { file_proto_post_proto_init() }
@@ -186,13 +680,14 @@ func file_proto_post_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_post_proto_rawDesc), len(file_proto_post_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   2,
+			NumEnums:      1,
+			NumMessages:   7,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_proto_post_proto_goTypes,
 		DependencyIndexes: file_proto_post_proto_depIdxs,
+		EnumInfos:         file_proto_post_proto_enumTypes,
 		MessageInfos:      file_proto_post_proto_msgTypes,
 	}.Build()
 	File_proto_post_proto = out.File
@@ -19,7 +19,10 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	PostService_PublishPost_FullMethodName = "/post.PostService/PublishPost"
+	PostService_PublishPost_FullMethodName     = "/post.PostService/PublishPost"
+	PostService_GetPost_FullMethodName         = "/post.PostService/GetPost"
+	PostService_ListPostsByUser_FullMethodName = "/post.PostService/ListPostsByUser"
+	PostService_GetFanout_FullMethodName       = "/post.PostService/GetFanout"
 )
 
 // PostServiceClient is the client API for PostService service.
@@ -27,6 +30,9 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type PostServiceClient interface {
 	PublishPost(ctx context.Context, in *Post, opts ...grpc.CallOption) (*NotificationResponse, error)
+	GetPost(ctx context.Context, in *PostId, opts ...grpc.CallOption) (*Post, error)
+	ListPostsByUser(ctx context.Context, in *ListPostsRequest, opts ...grpc.CallOption) (*PostList, error)
+	GetFanout(ctx context.Context, in *FanoutId, opts ...grpc.CallOption) (*FanoutJob, error)
 }
 
 type postServiceClient struct {
@@ -47,11 +53,44 @@ func (c *postServiceClient) PublishPost(ctx context.Context, in *Post, opts ...g
 	return out, nil
 }
 
+func (c *postServiceClient) GetPost(ctx context.Context, in *PostId, opts ...grpc.CallOption) (*Post, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Post)
+	err := c.cc.Invoke(ctx, PostService_GetPost_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *postServiceClient) ListPostsByUser(ctx context.Context, in *ListPostsRequest, opts ...grpc.CallOption) (*PostList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PostList)
+	err := c.cc.Invoke(ctx, PostService_ListPostsByUser_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *postServiceClient) GetFanout(ctx context.Context, in *FanoutId, opts ...grpc.CallOption) (*FanoutJob, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FanoutJob)
+	err := c.cc.Invoke(ctx, PostService_GetFanout_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // PostServiceServer is the server API for PostService service.
 // All implementations must embed UnimplementedPostServiceServer
 // for forward compatibility.
 type PostServiceServer interface {
 	PublishPost(context.Context, *Post) (*NotificationResponse, error)
+	GetPost(context.Context, *PostId) (*Post, error)
+	ListPostsByUser(context.Context, *ListPostsRequest) (*PostList, error)
+	GetFanout(context.Context, *FanoutId) (*FanoutJob, error)
 	mustEmbedUnimplementedPostServiceServer()
 }
 
@@ -65,6 +104,15 @@ type UnimplementedPostServiceServer struct{}
 func (UnimplementedPostServiceServer) PublishPost(context.Context, *Post) (*NotificationResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method PublishPost not implemented")
 }
+func (UnimplementedPostServiceServer) GetPost(context.Context, *PostId) (*Post, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPost not implemented")
+}
+func (UnimplementedPostServiceServer) ListPostsByUser(context.Context, *ListPostsRequest) (*PostList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListPostsByUser not implemented")
+}
+func (UnimplementedPostServiceServer) GetFanout(context.Context, *FanoutId) (*FanoutJob, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFanout not implemented")
+}
 func (UnimplementedPostServiceServer) mustEmbedUnimplementedPostServiceServer() {}
 func (UnimplementedPostServiceServer) testEmbeddedByValue()                     {}
 
@@ -104,6 +152,60 @@ func _PostService_PublishPost_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _PostService_GetPost_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PostId)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PostServiceServer).GetPost(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PostService_GetPost_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PostServiceServer).GetPost(ctx, req.(*PostId))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PostService_ListPostsByUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPostsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PostServiceServer).ListPostsByUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PostService_ListPostsByUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PostServiceServer).ListPostsByUser(ctx, req.(*ListPostsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PostService_GetFanout_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FanoutId)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PostServiceServer).GetFanout(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PostService_GetFanout_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PostServiceServer).GetFanout(ctx, req.(*FanoutId))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // PostService_ServiceDesc is the grpc.ServiceDesc for PostService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -115,6 +217,18 @@ var PostService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "PublishPost",
 			Handler:    _PostService_PublishPost_Handler,
 		},
+		{
+			MethodName: "GetPost",
+			Handler:    _PostService_GetPost_Handler,
+		},
+		{
+			MethodName: "ListPostsByUser",
+			Handler:    _PostService_ListPostsByUser_Handler,
+		},
+		{
+			MethodName: "GetFanout",
+			Handler:    _PostService_GetFanout_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/post.proto",